@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+func testProfileRunner(t *testing.T) *Runner {
+	t.Helper()
+	base := t.TempDir()
+	cfg := config.Default()
+	cfg.Agent.Workspace = filepath.Join(base, "main")
+	cfg.Memory.Backend = "markdown"
+	cfg.Agent.Profiles = map[string]config.AgentProfile{
+		"coder": {
+			Name:      "Coder",
+			Workspace: filepath.Join(base, "coder"),
+			Model:     "openai/gpt-4o",
+			Tools:     []string{"shell", "memory_*"},
+			Autonomy:  "full",
+		},
+		"ops": {
+			Name:            "Ops",
+			Workspace:       cfg.Agent.Workspace,
+			Provider:        "groq",
+			Autonomy:        "readonly",
+			MemoryNamespace: "ops",
+		},
+	}
+	return NewRunner(cfg, slog.Default())
+}
+
+func TestRunnerForAgentAppliesProfile(t *testing.T) {
+	r := testProfileRunner(t)
+
+	if got := r.forAgent(""); got != r {
+		t.Fatalf("empty agent id should use the main runner")
+	}
+	if got := r.forAgent("unknown"); got != r {
+		t.Fatalf("unknown agent id should use the main runner")
+	}
+
+	coder := r.forAgent("Coder")
+	if coder == r {
+		t.Fatalf("expected dedicated runner for coder")
+	}
+	if coder != r.forAgent("coder") {
+		t.Fatalf("expected cached runner for coder")
+	}
+	if coder.cfg.Agent.Model != "openai/gpt-4o" || coder.cfg.Autonomy.Level != "full" {
+		t.Fatalf("unexpected coder config: model=%s autonomy=%s", coder.cfg.Agent.Model, coder.cfg.Autonomy.Level)
+	}
+	if !strings.HasSuffix(coder.cfg.Agent.Workspace, "coder") {
+		t.Fatalf("unexpected coder workspace: %s", coder.cfg.Agent.Workspace)
+	}
+	if r.cfg.Agent.Model == coder.cfg.Agent.Model {
+		t.Fatalf("profile must not mutate the shared config")
+	}
+
+	var names []string
+	for _, spec := range coder.tools.Specs() {
+		names = append(names, spec.Name)
+	}
+	if got := strings.Join(names, ","); got != "memory_forget,memory_recall,memory_store,shell" {
+		t.Fatalf("unexpected coder tools: %s", got)
+	}
+	if !strings.Contains(coder.buildSystemPrompt(&RunRequest{}), "You are Coder") {
+		t.Fatalf("expected profile name in system prompt")
+	}
+
+	ops := r.forAgent("ops")
+	if ops.provider != "groq" || ops.tools.policy.autonomy != "readonly" {
+		t.Fatalf("unexpected ops runner: provider=%s autonomy=%s", ops.provider, ops.tools.policy.autonomy)
+	}
+	if want := filepath.Join(r.cfg.Agent.Workspace, "agents", "ops"); memoryWorkspace(ops.cfg) != want {
+		t.Fatalf("unexpected ops memory workspace: %s", memoryWorkspace(ops.cfg))
+	}
+	if !ops.tools.Has("skill_read") {
+		t.Fatalf("profile without tools list should keep all tools")
+	}
+}
+
+func TestAgentIDFromSessionKey(t *testing.T) {
+	tests := map[string]string{
+		"agent:ops:feishu:direct:u1": "ops",
+		"agent:main:main":            "main",
+		"cli-123":                    "",
+		"agent:only":                 "",
+	}
+	for key, want := range tests {
+		if got := agentIDFromSessionKey(key); got != want {
+			t.Fatalf("agentIDFromSessionKey(%q) = %q; want %q", key, got, want)
+		}
+	}
+}
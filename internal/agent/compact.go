@@ -0,0 +1,42 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/highclaw/highclaw/internal/system/tracing"
+)
+
+// manualCompactKeep 手动压缩时保留的最近消息数（最后一轮问答）
+const manualCompactKeep = 2
+
+// Compact 立即压缩 req.History（TUI /compact）：与自动压缩使用相同的摘要方式，
+// 较早的消息合并为一条摘要，只保留最近一轮问答。没有可压缩的内容时原样返回。
+func (r *Runner) Compact(ctx context.Context, req *RunRequest) ([]ChatMessage, error) {
+	agentID := strings.TrimSpace(req.AgentID)
+	if agentID == "" {
+		agentID = agentIDFromSessionKey(req.SessionKey)
+	}
+	if target := r.forAgent(agentID); target != r {
+		return target.Compact(ctx, req)
+	}
+	r = r.current()
+
+	provider := strings.TrimSpace(req.Provider)
+	if provider == "" {
+		provider = r.provider
+	}
+	tokenModel := r.modelRef(req)
+	budget := newContextBudget(tokenModel).historyBudget(0)
+	ctx, span := tracing.Start(ctx, "history.compact", "session", req.SessionKey, "messages", len(req.History), "manual", true)
+	defer span.End()
+	compacted, err := compactHistory(ctx, req.History, r.models, provider, strings.TrimSpace(req.Model), tokenModel,
+		int(float64(budget)*compactionKeepRatio), manualCompactKeep)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("summarize history: %w", err)
+	}
+	span.SetAttr("kept", len(compacted))
+	return compacted, nil
+}
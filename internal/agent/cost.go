@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"strings"
+	"time"
+
+	"github.com/highclaw/highclaw/internal/config"
+	"github.com/highclaw/highclaw/internal/domain/model"
+	"github.com/highclaw/highclaw/internal/system/metrics"
+)
+
+// SpendFunc 返回 since 以来的累计费用（美元），由调用方基于 tasklog 提供
+type SpendFunc func(since time.Time) (float64, error)
+
+// modelPricing 返回 provider/model 的单价：cost.prices 覆盖优先，其次是内置价格表
+func modelPricing(cfg *config.Config, provider, modelName string) (model.Pricing, bool) {
+	if p, ok := cfg.PriceOverride(provider, modelName); ok {
+		return model.Pricing(p), true
+	}
+	if p, ok := model.LookupPricing(provider + "/" + modelName); ok {
+		return p, true
+	}
+	return model.LookupPricing(modelName)
+}
+
+// usageCost 计算一次模型调用的费用；价格未知时返回 0
+func usageCost(cfg *config.Config, provider, modelName string, usage TokenUsage) float64 {
+	p, ok := modelPricing(cfg, provider, modelName)
+	if !ok {
+		return 0
+	}
+	return p.Cost(usage.InputTokens, usage.OutputTokens, usage.CacheRead, usage.CacheWrite)
+}
+
+// SetSpendSource 设置月度预算使用的费用来源，对已加载的 agent profile 同样生效
+func (r *Runner) SetSpendSource(f SpendFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spend = f
+	for _, child := range r.agents {
+		child.spend = f
+	}
+}
+
+// budgetModel 本月费用超出 cost.monthlyBudget 时返回 "hint:<budgetHint>"，否则返回空。
+// 只替换默认模型：调用方显式指定的模型保持不变。
+func (r *Runner) budgetModel(req *RunRequest) string {
+	budget := r.cfg.Cost.MonthlyBudget
+	hint := strings.TrimSpace(r.cfg.Cost.BudgetHint)
+	if budget <= 0 || hint == "" || r.spend == nil || strings.TrimSpace(req.Model) != "" {
+		return ""
+	}
+	now := time.Now().UTC()
+	spent, err := r.spend(time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		r.logger.Warn("cost: read monthly spend failed", "error", err)
+		return ""
+	}
+	if spent < budget {
+		r.overBudget.Store(false)
+		return ""
+	}
+	if !r.overBudget.Swap(true) {
+		r.logger.Warn("cost: monthly budget exceeded, routing to cheaper model", "budget", budget, "spent", spent, "hint", hint)
+	}
+	return "hint:" + hint
+}
+
+// recordUsageMetrics 把一次模型调用的 token 和费用计入 /metrics
+func recordUsageMetrics(modelRef string, u TokenUsage) {
+	metrics.Tokens.Add(float64(u.InputTokens), modelRef, "input")
+	metrics.Tokens.Add(float64(u.OutputTokens), modelRef, "output")
+	metrics.Tokens.Add(float64(u.CacheRead), modelRef, "cache_read")
+	metrics.Tokens.Add(float64(u.CacheWrite), modelRef, "cache_write")
+	metrics.CostUSD.Add(u.CostUSD, modelRef)
+}
@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+// pricedProvider 记录收到的模型名，并返回固定用量
+type pricedProvider struct {
+	mu     sync.Mutex
+	models []string
+}
+
+func (p *pricedProvider) Chat(ctx context.Context, req *ChatRequest, model string) (*ChatResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.models = append(p.models, model)
+	return &ChatResponse{Content: "ok", Usage: TokenUsage{InputTokens: 1000, OutputTokens: 500, CacheRead: 2000}}, nil
+}
+
+func TestRunCostAndBudgetRouting(t *testing.T) {
+	cfg := config.Default()
+	cfg.Agent.Workspace = t.TempDir()
+	cfg.Memory.Backend = "markdown"
+	cfg.Memory.AutoSave = false
+	cfg.ModelRoutes = []config.ModelRouteConfig{{Hint: "cheap", Provider: "fake", Model: "fake/cheap"}}
+	cfg.Cost = config.CostConfig{
+		Prices: map[string]config.ModelPrice{
+			"fake/premium": {Input: 10, Output: 20, CacheRead: 1},
+			"cheap":        {Input: 1, Output: 2},
+		},
+		MonthlyBudget: 5,
+		BudgetHint:    "cheap",
+	}
+
+	r := NewRunner(cfg, slog.Default())
+	t.Cleanup(r.Close)
+	fake := &pricedProvider{}
+	r.models.factory.Register("fake", func(cfg *config.Config) (Provider, error) { return fake, nil })
+	cfg.Agent.Model = "fake/premium"
+
+	spent := 1.0
+	r.SetSpendSource(func(since time.Time) (float64, error) {
+		if since.Day() != 1 {
+			t.Fatalf("budget should be checked from the start of the month, got %v", since)
+		}
+		return spent, nil
+	})
+
+	res, err := r.Run(context.Background(), &RunRequest{SessionKey: "agent:main:cost", Message: "hi", Provider: "fake"})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	// 1000*10 + 500*20 + 2000*1 = 22000 / 1M
+	if math.Abs(res.TokensUsed.CostUSD-0.022) > 1e-9 || res.Model != "fake/premium" {
+		t.Fatalf("under budget: got model %q cost %v", res.Model, res.TokensUsed.CostUSD)
+	}
+
+	spent = 5
+	res, err = r.Run(context.Background(), &RunRequest{SessionKey: "agent:main:cost", Message: "hi", Provider: "fake"})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Model != "fake/cheap" || math.Abs(res.TokensUsed.CostUSD-0.002) > 1e-9 {
+		t.Fatalf("over budget should route to hint:cheap, got model %q cost %v", res.Model, res.TokensUsed.CostUSD)
+	}
+
+	res, err = r.Run(context.Background(), &RunRequest{SessionKey: "agent:main:cost", Message: "hi", Provider: "fake", Model: "fake/premium"})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Model != "fake/premium" {
+		t.Fatalf("explicit model must not be rerouted, got %q", res.Model)
+	}
+}
@@ -0,0 +1,188 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/highclaw/highclaw/internal/security"
+)
+
+const (
+	delegateToolName = "delegate"
+	// 子 agent 默认 token 预算（输入+输出累计）
+	delegateDefaultTokenBudget = 60000
+	// 单个 runner 同时运行的子 agent 上限
+	maxConcurrentDelegations = 4
+)
+
+// DelegateResult 子 agent 的运行结果，回填到父 RunResult.Delegations
+type DelegateResult struct {
+	Task       string     `json:"task"`
+	Reply      string     `json:"reply"`
+	ToolCalls  []ToolCall `json:"toolCalls,omitempty"`
+	TokensUsed TokenUsage `json:"tokensUsed"`
+	Error      string     `json:"error,omitempty"`
+	// DLPHits 子 agent 工具输出中的 DLP 命中
+	DLPHits []security.DLPHit `json:"dlpHits,omitempty"`
+}
+
+type delegateSinkKey struct{}
+
+// delegateSink 挂在父 run 的 ctx 上，收集并发子 agent 的结果
+type delegateSink struct {
+	parent   *RunRequest
+	provider string
+
+	mu      sync.Mutex
+	results []DelegateResult
+}
+
+func (s *delegateSink) add(res DelegateResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, res)
+}
+
+// finish 把子 agent 的结果和 token 用量并入父结果
+func (s *delegateSink) finish(result *RunResult) *RunResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.results {
+		result.TokensUsed.merge(d.TokensUsed)
+		result.DLPHits = append(result.DLPHits, d.DLPHits...)
+	}
+	result.Delegations = append(result.Delegations, s.results...)
+	return result
+}
+
+// registerDelegateTool 注册 delegate 工具；子 agent 自身不再注册，避免无限递归
+func (r *Runner) registerDelegateTool() {
+	sem := make(chan struct{}, maxConcurrentDelegations)
+	var seq atomic.Int64
+	r.tools.Register(delegateToolName,
+		"Delegate a self-contained sub-task to a sub-agent with a fresh context. Use for research or multi-file work; several delegate calls in one response run in parallel. Returns the sub-agent's final answer.",
+		`{"type":"object","properties":{"task":{"type":"string","description":"Complete, self-contained instructions for the sub-agent"},"system_prompt":{"type":"string","description":"Optional role/instructions prepended to the sub-agent system prompt"},"tools":{"type":"array","items":{"type":"string"},"description":"Tool names the sub-agent may use (default: all except delegate)"},"token_budget":{"type":"integer","description":"Max input+output tokens for the sub-agent"},"model":{"type":"string"}},"required":["task"]}`,
+		func(ctx context.Context, input string) (string, error) {
+			var payload struct {
+				Task         string   `json:"task"`
+				SystemPrompt string   `json:"system_prompt"`
+				Tools        []string `json:"tools"`
+				TokenBudget  int      `json:"token_budget"`
+				Model        string   `json:"model"`
+			}
+			if err := json.Unmarshal([]byte(input), &payload); err != nil {
+				return "", fmt.Errorf("invalid delegate input: %w", err)
+			}
+			task := strings.TrimSpace(payload.Task)
+			if task == "" {
+				return "", fmt.Errorf("Missing 'task' parameter")
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+			defer func() { <-sem }()
+
+			sink, _ := ctx.Value(delegateSinkKey{}).(*delegateSink)
+			child := r.newDelegateRunner(payload.Tools)
+			childReq := &RunRequest{
+				Message:     task,
+				Model:       strings.TrimSpace(payload.Model),
+				TokenBudget: payload.TokenBudget,
+			}
+			if childReq.TokenBudget <= 0 {
+				childReq.TokenBudget = delegateDefaultTokenBudget
+			}
+			n := seq.Add(1)
+			if sink != nil && sink.parent != nil {
+				childReq.SessionKey = fmt.Sprintf("%s:delegate:%d", sink.parent.SessionKey, n)
+				childReq.Channel = sink.parent.Channel
+				childReq.Sender = sink.parent.Sender
+				childReq.PeerKind = sink.parent.PeerKind
+				childReq.GroupID = sink.parent.GroupID
+				childReq.Provider = sink.provider
+				childReq.Temperature = sink.parent.Temperature
+				if childReq.Model == "" {
+					childReq.Model = sink.parent.Model
+				}
+			}
+			childReq.SystemPrompt = child.buildSystemPrompt(childReq)
+			if custom := strings.TrimSpace(payload.SystemPrompt); custom != "" {
+				childReq.SystemPrompt = custom + "\n\n" + childReq.SystemPrompt
+			}
+
+			r.logger.Info("delegate started", "session", childReq.SessionKey, "tools", len(child.tools.tools), "budget", childReq.TokenBudget)
+			res, err := child.Run(ctx, childReq)
+			out := DelegateResult{Task: task}
+			if err != nil {
+				out.Error = err.Error()
+				if sink != nil {
+					sink.add(out)
+				}
+				r.logger.Warn("delegate failed", "session", childReq.SessionKey, "error", err)
+				return "", fmt.Errorf("sub-agent failed: %w", err)
+			}
+			out.Reply = res.Reply
+			out.ToolCalls = res.ToolCalls
+			out.TokensUsed = res.TokensUsed
+			out.DLPHits = res.DLPHits
+			if sink != nil {
+				sink.add(out)
+			}
+			r.logger.Info("delegate finished", "session", childReq.SessionKey,
+				"tool_calls", len(res.ToolCalls),
+				"input_tokens", res.TokensUsed.InputTokens,
+				"output_tokens", res.TokensUsed.OutputTokens,
+			)
+			return fmt.Sprintf("%s\n\n(sub-agent: %d tool calls, %d tokens)",
+				res.Reply, len(res.ToolCalls), res.TokensUsed.InputTokens+res.TokensUsed.OutputTokens), nil
+		})
+}
+
+// newDelegateRunner 基于当前 runner 构建子 agent：共享模型管理和工具实现，
+// 工具集限制为 allowed 与父工具集的交集（不含 delegate），且不自动写入记忆。
+func (r *Runner) newDelegateRunner(allowed []string) *Runner {
+	r = r.current()
+	sub := &ToolRegistry{
+		tools:     make(map[string]ToolSpec),
+		policy:    r.tools.policy,
+		logger:    r.tools.logger,
+		memory:    r.tools.memory,
+		journal:   r.tools.journal,
+		processes: r.tools.processes,
+	}
+	for name, spec := range r.tools.tools {
+		if name == delegateToolName {
+			continue
+		}
+		sub.tools[name] = spec
+	}
+	if len(allowed) > 0 {
+		sub.Retain(allowed)
+	}
+
+	cfg := *r.cfg
+	cfg.Memory.AutoSave = false
+	name := r.name
+	if name == "" {
+		name = "HighClaw"
+	}
+	return &Runner{
+		cfg:        &cfg,
+		logger:     r.logger.With("delegate", true),
+		models:     r.models,
+		tools:      sub,
+		dlp:        r.dlp,
+		approver:   r.approver,
+		overBudget: new(atomic.Bool),
+		agentID:    r.agentID,
+		name:       name + " sub-agent",
+		provider:   r.provider,
+	}
+}
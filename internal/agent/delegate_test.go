@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+// scriptedProvider 按系统提示词区分父/子 agent，返回预设响应
+type scriptedProvider struct {
+	mu        sync.Mutex
+	mainCalls int
+	childMsgs []string
+}
+
+func (p *scriptedProvider) Chat(ctx context.Context, req *ChatRequest, model string) (*ChatResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	usage := TokenUsage{InputTokens: 10, OutputTokens: 5}
+	if strings.HasPrefix(req.SystemPrompt, "You are HighClaw sub-agent") {
+		last := req.Messages[len(req.Messages)-1].Content
+		p.childMsgs = append(p.childMsgs, last)
+		return &ChatResponse{Content: "child done: " + last, Usage: usage}, nil
+	}
+	p.mainCalls++
+	if p.mainCalls == 1 {
+		return &ChatResponse{Content: `Splitting work.
+<invoke>
+{"name":"delegate","arguments":{"task":"research A","tools":["memory_recall"]}}
+</invoke>
+<invoke>
+{"name":"delegate","arguments":{"task":"research B"}}
+</invoke>`, Usage: usage}, nil
+	}
+	return &ChatResponse{Content: "all done", Usage: usage}, nil
+}
+
+func TestDelegateToolRunsChildrenAndReportsResults(t *testing.T) {
+	cfg := config.Default()
+	cfg.Agent.Workspace = t.TempDir()
+	cfg.Memory.Backend = "markdown"
+	cfg.Memory.AutoSave = false
+	r := NewRunner(cfg, slog.Default())
+	fake := &scriptedProvider{}
+	r.models.factory.Register("fake", func(cfg *config.Config) (Provider, error) { return fake, nil })
+
+	res, err := r.Run(context.Background(), &RunRequest{
+		SessionKey: "agent:main:test",
+		Message:    "do two things",
+		Provider:   "fake",
+		Model:      "fake/test",
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if res.Reply != "all done" {
+		t.Fatalf("unexpected reply: %q", res.Reply)
+	}
+	if len(res.Delegations) != 2 {
+		t.Fatalf("expected 2 delegations, got %d", len(res.Delegations))
+	}
+	if len(res.ToolCalls) != 2 || !strings.Contains(res.ToolCalls[0].Output, "child done: research A") {
+		t.Fatalf("unexpected parent tool calls: %+v", res.ToolCalls)
+	}
+	// 2 次父调用 + 2 次子调用
+	if res.TokensUsed.InputTokens != 40 || res.TokensUsed.OutputTokens != 20 {
+		t.Fatalf("unexpected usage: %+v", res.TokensUsed)
+	}
+	if len(fake.childMsgs) != 2 {
+		t.Fatalf("expected 2 child calls, got %d", len(fake.childMsgs))
+	}
+}
+
+func TestDelegateRunnerRestrictsTools(t *testing.T) {
+	cfg := config.Default()
+	cfg.Agent.Workspace = t.TempDir()
+	cfg.Memory.Backend = "markdown"
+	r := NewRunner(cfg, slog.Default())
+
+	child := r.newDelegateRunner([]string{"memory_recall", "delegate", "not_a_tool"})
+	if len(child.tools.tools) != 1 || !child.tools.Has("memory_recall") {
+		t.Fatalf("unexpected child tools: %v", child.tools.Specs())
+	}
+	if child.cfg.Memory.AutoSave {
+		t.Fatalf("sub-agent must not auto-save memory")
+	}
+	if !r.tools.Has(delegateToolName) {
+		t.Fatalf("parent registry lost delegate tool")
+	}
+
+	all := r.newDelegateRunner(nil)
+	if all.tools.Has(delegateToolName) || !all.tools.Has("shell") {
+		t.Fatalf("default child toolset should be parent tools minus delegate")
+	}
+}
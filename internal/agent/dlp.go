@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/highclaw/highclaw/internal/config"
+	"github.com/highclaw/highclaw/internal/security"
+)
+
+// dlpFilter 出站数据防泄漏：工具输出回传模型前、最终回复返回渠道前各扫描一次
+type dlpFilter struct {
+	cfg     *config.Config
+	scanner *security.DLPScanner
+}
+
+// newDLPFilter 按配置构建扫描器；已配置的 provider key、渠道 token 等敏感字段做精确匹配。
+// 自定义规则编译失败时只记录警告，内置规则照常生效。
+func newDLPFilter(cfg *config.Config, logger *slog.Logger) *dlpFilter {
+	f := &dlpFilter{cfg: cfg}
+	if !cfg.DLPEnabled() {
+		return f
+	}
+	patterns := map[string]string{}
+	for i, p := range cfg.DLP.Patterns {
+		name := strings.TrimSpace(p.Name)
+		if name == "" {
+			name = fmt.Sprintf("custom_%d", i+1)
+		}
+		if strings.TrimSpace(p.Regex) != "" {
+			patterns[name] = p.Regex
+		}
+	}
+	scanner, err := security.NewDLPScanner(patterns, cfg.SecretValues())
+	if err != nil {
+		logger.Warn("dlp: custom patterns ignored", "error", err)
+		scanner, _ = security.NewDLPScanner(nil, cfg.SecretValues())
+	}
+	f.scanner = scanner
+	return f
+}
+
+// toolOutput 过滤回传给模型的工具输出
+func (f *dlpFilter) toolOutput(tool, output string) (string, []security.DLPHit) {
+	if f == nil || f.scanner == nil {
+		return output, nil
+	}
+	action := f.cfg.DLPToolOutputAction()
+	if action == config.DLPActionOff {
+		return output, nil
+	}
+	masked, hits := f.scanner.Redact(output, "tool:"+tool)
+	if len(hits) == 0 {
+		return output, nil
+	}
+	setDLPAction(hits, action)
+	if action == config.DLPActionBlock {
+		return fmt.Sprintf("Error: output of %s withheld by DLP policy (matched: %s)", tool, strings.Join(security.DLPRuleNames(hits), ", ")), hits
+	}
+	return masked, hits
+}
+
+// reply 按渠道策略处理最终回复。返回发给渠道的文本、生效的处理方式和命中；
+// approve 时原文由调用方连同审批单一起保存，审批通过后再投递。
+func (f *dlpFilter) reply(channel, reply string) (string, string, []security.DLPHit) {
+	if f == nil || f.scanner == nil {
+		return reply, "", nil
+	}
+	action := f.cfg.DLPActionFor(channel)
+	if action == config.DLPActionOff {
+		return reply, "", nil
+	}
+	masked, hits := f.scanner.Redact(reply, "reply")
+	if len(hits) == 0 {
+		return reply, "", nil
+	}
+	setDLPAction(hits, action)
+	rules := strings.Join(security.DLPRuleNames(hits), ", ")
+	switch action {
+	case config.DLPActionBlock:
+		return fmt.Sprintf("⚠️ This reply was blocked because it contained sensitive data (%s).", rules), action, hits
+	case config.DLPActionApprove:
+		return fmt.Sprintf("⚠️ This reply contains sensitive data (%s) and is waiting for an administrator's approval.", rules), action, hits
+	}
+	return masked, action, hits
+}
+
+// mask 仅做替换，用于不经过渠道策略的中间输出（如工具调用前的终端提示）
+func (f *dlpFilter) mask(text string) string {
+	if f == nil || f.scanner == nil {
+		return text
+	}
+	masked, _ := f.scanner.Redact(text, "")
+	return masked
+}
+
+func setDLPAction(hits []security.DLPHit, action string) {
+	for i := range hits {
+		hits[i].Action = action
+	}
+}
@@ -0,0 +1,793 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// read_file 单次返回的上限
+	fileReadDefaultLines = 2000
+	fileReadMaxBytes     = 256 * 1024
+	// 超过该大小的文件不读取/编辑/搜索
+	fileMaxSize = 10 * 1024 * 1024
+	// glob/grep/list_dir 返回条目上限
+	fileSearchMaxResults = 200
+	fileListMaxEntries   = 500
+	// 每个会话保留的撤销记录条数
+	fileJournalMaxEntries = 50
+)
+
+// 遍历目录时跳过的目录
+var fileWalkSkipDirs = map[string]struct{}{
+	".git": {}, "node_modules": {}, ".venv": {}, "__pycache__": {},
+}
+
+// fileToolError 文件工具的结构化错误，以 JSON 返回给模型，便于按 code 决定下一步
+type fileToolError struct {
+	Code    string `json:"error"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *fileToolError) Error() string {
+	raw, _ := json.Marshal(e)
+	return string(raw)
+}
+
+func fileErr(code, path, format string, args ...any) error {
+	return &fileToolError{Code: code, Path: path, Message: fmt.Sprintf(format, args...)}
+}
+
+// fileJournalEntry 一次写操作之前的文件快照
+type fileJournalEntry struct {
+	Path    string
+	Tool    string
+	Existed bool
+	Content []byte
+	Mode    fs.FileMode
+	At      time.Time
+}
+
+// fileJournal 按会话记录 write_file/edit_file 的撤销快照（仅保存在内存中）
+type fileJournal struct {
+	mu       sync.Mutex
+	sessions map[string][]fileJournalEntry
+}
+
+func newFileJournal() *fileJournal {
+	return &fileJournal{sessions: make(map[string][]fileJournalEntry)}
+}
+
+// snapshot 在写入 path 之前记录当前内容
+func (j *fileJournal) snapshot(session, path, tool string) error {
+	entry := fileJournalEntry{Path: path, Tool: tool, At: time.Now()}
+	st, err := os.Stat(path)
+	switch {
+	case err == nil:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		entry.Existed, entry.Content, entry.Mode = true, data, st.Mode().Perm()
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entries := append(j.sessions[session], entry)
+	if len(entries) > fileJournalMaxEntries {
+		entries = entries[len(entries)-fileJournalMaxEntries:]
+	}
+	j.sessions[session] = entries
+	return nil
+}
+
+func (j *fileJournal) pop(session string) (fileJournalEntry, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entries := j.sessions[session]
+	if len(entries) == 0 {
+		return fileJournalEntry{}, false
+	}
+	last := entries[len(entries)-1]
+	j.sessions[session] = entries[:len(entries)-1]
+	return last, true
+}
+
+// restore 把文件恢复到快照状态；快照时文件不存在则删除
+func (e fileJournalEntry) restore() error {
+	if !e.Existed {
+		if err := os.Remove(e.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(e.Path, e.Content, e.Mode)
+}
+
+// toolSessionKey 返回当前 run 的会话 key，用于区分撤销记录
+func toolSessionKey(ctx context.Context) string {
+	if sink, ok := ctx.Value(delegateSinkKey{}).(*delegateSink); ok && sink.parent != nil && sink.parent.SessionKey != "" {
+		return sink.parent.SessionKey
+	}
+	return "default"
+}
+
+// registerFileTools 注册工作区文件工具，路径统一经过 SecurityPolicy.ResolvePath 校验
+func (r *ToolRegistry) registerFileTools() {
+	r.Register("read_file", "Read a text file. Relative paths resolve against the workspace. Use offset/limit (1-based lines) for large files.",
+		`{"type":"object","properties":{"path":{"type":"string"},"offset":{"type":"integer","description":"First line to return (1-based)"},"limit":{"type":"integer","description":"Max lines to return (default 2000)"}},"required":["path"]}`,
+		r.readFileTool())
+	r.Register("write_file", "Create or overwrite a file with the given content. Parent directories are created. Can be undone with undo_file_change.",
+		`{"type":"object","properties":{"path":{"type":"string"},"content":{"type":"string"}},"required":["path","content"]}`,
+		r.writeFileTool())
+	r.Register("edit_file", "Edit a file by replacing an exact old_string with new_string (must match once unless replace_all), or by applying a unified diff. Prefer this over shell sed/heredoc edits.",
+		`{"type":"object","properties":{"path":{"type":"string"},"old_string":{"type":"string","description":"Exact text to replace, including whitespace"},"new_string":{"type":"string"},"replace_all":{"type":"boolean"},"diff":{"type":"string","description":"Unified diff (@@ hunks) to apply instead of old_string/new_string"}},"required":["path"]}`,
+		r.editFileTool())
+	r.Register("list_dir", "List a directory. Directories end with '/'.",
+		`{"type":"object","properties":{"path":{"type":"string","description":"Directory (default: workspace root)"}}}`,
+		r.listDirTool())
+	r.Register("glob", "Find files by glob pattern relative to path, e.g. '**/*.go' or 'src/*.ts'.",
+		`{"type":"object","properties":{"pattern":{"type":"string"},"path":{"type":"string","description":"Base directory (default: workspace root)"}},"required":["pattern"]}`,
+		r.globTool())
+	r.Register("grep", "Search file contents with a regular expression. Returns path:line: text.",
+		`{"type":"object","properties":{"pattern":{"type":"string"},"path":{"type":"string","description":"File or directory (default: workspace root)"},"include":{"type":"string","description":"Glob filter for file names, e.g. '*.go'"},"ignore_case":{"type":"boolean"},"max_results":{"type":"integer"}},"required":["pattern"]}`,
+		r.grepTool())
+	r.Register("undo_file_change", "Undo the most recent write_file/edit_file changes made in this session.",
+		`{"type":"object","properties":{"steps":{"type":"integer","description":"Number of changes to undo (default 1)"}}}`,
+		r.undoFileChangeTool())
+}
+
+// displayPath 工作区内的路径显示为相对路径
+func (r *ToolRegistry) displayPath(abs string) string {
+	if rel, err := filepath.Rel(r.policy.Workspace(), abs); err == nil && pathWithin(abs, r.policy.Workspace()) {
+		return filepath.ToSlash(rel)
+	}
+	return abs
+}
+
+func decodeFileToolInput(tool, input string, v any) error {
+	if err := json.Unmarshal([]byte(input), v); err != nil {
+		return fileErr("invalid_input", "", "invalid %s input: %v", tool, err)
+	}
+	return nil
+}
+
+// readTextFile 读取文本文件，拒绝目录、超大文件和二进制文件
+func readTextFile(abs, display string) ([]byte, fs.FileMode, error) {
+	st, err := os.Stat(abs)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, fileErr("not_found", display, "file does not exist")
+		}
+		return nil, 0, fileErr("io_error", display, "%v", err)
+	}
+	if st.IsDir() {
+		return nil, 0, fileErr("is_directory", display, "path is a directory; use list_dir")
+	}
+	if st.Size() > fileMaxSize {
+		return nil, 0, fileErr("file_too_large", display, "file is %d bytes (limit %d)", st.Size(), fileMaxSize)
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, 0, fileErr("io_error", display, "%v", err)
+	}
+	if isBinary(data) {
+		return nil, 0, fileErr("binary_file", display, "file appears to be binary")
+	}
+	return data, st.Mode().Perm(), nil
+}
+
+func isBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+func (r *ToolRegistry) readFileTool() ToolHandler {
+	return func(ctx context.Context, input string) (string, error) {
+		var in struct {
+			Path   string `json:"path"`
+			Offset int    `json:"offset"`
+			Limit  int    `json:"limit"`
+		}
+		if err := decodeFileToolInput("read_file", input, &in); err != nil {
+			return "", err
+		}
+		abs, err := r.policy.ResolvePath(in.Path, false)
+		if err != nil {
+			return "", err
+		}
+		data, _, err := readTextFile(abs, in.Path)
+		if err != nil {
+			return "", err
+		}
+		if len(data) == 0 {
+			return "(empty file)", nil
+		}
+
+		lines := strings.SplitAfter(string(data), "\n")
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		start := in.Offset
+		if start < 1 {
+			start = 1
+		}
+		if start > len(lines) {
+			return "", fileErr("invalid_range", in.Path, "offset %d is past end of file (%d lines)", start, len(lines))
+		}
+		limit := in.Limit
+		if limit <= 0 {
+			limit = fileReadDefaultLines
+		}
+		end := start - 1 + limit
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		var b strings.Builder
+		for i := start - 1; i < end; i++ {
+			if b.Len()+len(lines[i]) > fileReadMaxBytes {
+				end = i
+				break
+			}
+			b.WriteString(lines[i])
+		}
+		if end < len(lines) {
+			fmt.Fprintf(&b, "\n… (showing lines %d-%d of %d; use offset/limit to read more)", start, end, len(lines))
+		}
+		return b.String(), nil
+	}
+}
+
+// writeWithJournal 记录快照后写入文件
+func (r *ToolRegistry) writeWithJournal(ctx context.Context, tool, abs string, content []byte, mode fs.FileMode) error {
+	if err := r.journal.snapshot(toolSessionKey(ctx), abs, tool); err != nil {
+		return fileErr("io_error", r.displayPath(abs), "snapshot for undo failed: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return fileErr("io_error", r.displayPath(abs), "%v", err)
+	}
+	if err := os.WriteFile(abs, content, mode); err != nil {
+		return fileErr("io_error", r.displayPath(abs), "%v", err)
+	}
+	r.logger.Info("file written", "tool", tool, "path", abs, "bytes", len(content))
+	return nil
+}
+
+func (r *ToolRegistry) writeFileTool() ToolHandler {
+	return func(ctx context.Context, input string) (string, error) {
+		var in struct {
+			Path    string  `json:"path"`
+			Content *string `json:"content"`
+		}
+		if err := decodeFileToolInput("write_file", input, &in); err != nil {
+			return "", err
+		}
+		if in.Content == nil {
+			return "", fileErr("invalid_input", in.Path, "content is required")
+		}
+		abs, err := r.policy.ResolvePath(in.Path, true)
+		if err != nil {
+			return "", err
+		}
+		mode := fs.FileMode(0o644)
+		if st, err := os.Stat(abs); err == nil {
+			if st.IsDir() {
+				return "", fileErr("is_directory", in.Path, "path is a directory")
+			}
+			mode = st.Mode().Perm()
+		}
+		if err := r.writeWithJournal(ctx, "write_file", abs, []byte(*in.Content), mode); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Wrote %d bytes to %s", len(*in.Content), r.displayPath(abs)), nil
+	}
+}
+
+func (r *ToolRegistry) editFileTool() ToolHandler {
+	return func(ctx context.Context, input string) (string, error) {
+		var in struct {
+			Path       string `json:"path"`
+			OldString  string `json:"old_string"`
+			NewString  string `json:"new_string"`
+			ReplaceAll bool   `json:"replace_all"`
+			Diff       string `json:"diff"`
+		}
+		if err := decodeFileToolInput("edit_file", input, &in); err != nil {
+			return "", err
+		}
+		abs, err := r.policy.ResolvePath(in.Path, true)
+		if err != nil {
+			return "", err
+		}
+		data, mode, err := readTextFile(abs, in.Path)
+		if err != nil {
+			return "", err
+		}
+		content := string(data)
+
+		var updated, summary string
+		if strings.TrimSpace(in.Diff) != "" {
+			out, hunks, err := applyUnifiedDiff(content, in.Diff)
+			if err != nil {
+				return "", fileErr("patch_failed", in.Path, "%v", err)
+			}
+			updated, summary = out, fmt.Sprintf("applied %d hunk(s)", hunks)
+		} else {
+			if in.OldString == "" {
+				return "", fileErr("invalid_input", in.Path, "old_string or diff is required")
+			}
+			n := strings.Count(content, in.OldString)
+			switch {
+			case n == 0:
+				return "", fileErr("no_match", in.Path, "old_string was not found; re-read the file and copy the exact text")
+			case n > 1 && !in.ReplaceAll:
+				return "", fileErr("ambiguous_match", in.Path, "old_string matches %d times; add surrounding context or set replace_all", n)
+			}
+			if in.ReplaceAll {
+				updated = strings.ReplaceAll(content, in.OldString, in.NewString)
+			} else {
+				updated = strings.Replace(content, in.OldString, in.NewString, 1)
+				n = 1
+			}
+			summary = fmt.Sprintf("%d replacement(s)", n)
+		}
+		if updated == content {
+			return fmt.Sprintf("No changes to %s", r.displayPath(abs)), nil
+		}
+		if err := r.writeWithJournal(ctx, "edit_file", abs, []byte(updated), mode); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Edited %s: %s", r.displayPath(abs), summary), nil
+	}
+}
+
+func (r *ToolRegistry) listDirTool() ToolHandler {
+	return func(ctx context.Context, input string) (string, error) {
+		var in struct {
+			Path string `json:"path"`
+		}
+		if err := decodeFileToolInput("list_dir", input, &in); err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(in.Path) == "" {
+			in.Path = "."
+		}
+		abs, err := r.policy.ResolvePath(in.Path, false)
+		if err != nil {
+			return "", err
+		}
+		entries, err := os.ReadDir(abs)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return "", fileErr("not_found", in.Path, "directory does not exist")
+			}
+			return "", fileErr("io_error", in.Path, "%v", err)
+		}
+		if len(entries) == 0 {
+			return "(empty directory)", nil
+		}
+		var b strings.Builder
+		for i, e := range entries {
+			if i == fileListMaxEntries {
+				fmt.Fprintf(&b, "… (%d more entries)\n", len(entries)-i)
+				break
+			}
+			if e.IsDir() {
+				fmt.Fprintf(&b, "%s/\n", e.Name())
+				continue
+			}
+			size := int64(0)
+			if info, err := e.Info(); err == nil {
+				size = info.Size()
+			}
+			fmt.Fprintf(&b, "%s (%d bytes)\n", e.Name(), size)
+		}
+		return strings.TrimRight(b.String(), "\n"), nil
+	}
+}
+
+// walkFiles 遍历 root 下的普通文件，跳过常见的依赖/VCS 目录和 forbiddenPaths（root 本身已由 ResolvePath 校验，
+// 但其下的子目录仍可能被禁止，如在 $HOME 下搜索时的 ~/.ssh）；fn 返回 false 时停止
+func (p *SecurityPolicy) walkFiles(root string, fn func(path string, d fs.DirEntry) bool) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if p.pathForbidden(path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if _, skip := fileWalkSkipDirs[d.Name()]; skip && path != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if !fn(path, d) {
+			return fs.SkipAll
+		}
+		return nil
+	})
+}
+
+// globToRegexp 把 glob 转成正则：** 匹配任意层目录，* 和 ? 不跨越 /
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	p := filepath.ToSlash(strings.TrimPrefix(pattern, "./"))
+	braces := 0
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '*':
+			if i+1 < len(p) && p[i+1] == '*' {
+				i++
+				if i+1 < len(p) && p[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '{':
+			braces++
+			b.WriteString("(?:")
+		case '}':
+			if braces == 0 {
+				b.WriteString(`\}`)
+				continue
+			}
+			braces--
+			b.WriteString(")")
+		case ',':
+			if braces == 0 {
+				b.WriteString(",")
+				continue
+			}
+			b.WriteString("|")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+func (r *ToolRegistry) globTool() ToolHandler {
+	return func(ctx context.Context, input string) (string, error) {
+		var in struct {
+			Pattern string `json:"pattern"`
+			Path    string `json:"path"`
+		}
+		if err := decodeFileToolInput("glob", input, &in); err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(in.Pattern) == "" {
+			return "", fileErr("invalid_input", "", "pattern is required")
+		}
+		if strings.TrimSpace(in.Path) == "" {
+			in.Path = "."
+		}
+		base, err := r.policy.ResolvePath(in.Path, false)
+		if err != nil {
+			return "", err
+		}
+		re, err := globToRegexp(in.Pattern)
+		if err != nil {
+			return "", fileErr("invalid_pattern", "", "%v", err)
+		}
+
+		var matches []string
+		truncated := false
+		err = r.policy.walkFiles(base, func(path string, d fs.DirEntry) bool {
+			if ctx.Err() != nil {
+				return false
+			}
+			rel, _ := filepath.Rel(base, path)
+			if !re.MatchString(filepath.ToSlash(rel)) {
+				return true
+			}
+			if len(matches) == fileSearchMaxResults {
+				truncated = true
+				return false
+			}
+			matches = append(matches, r.displayPath(path))
+			return true
+		})
+		if err != nil {
+			return "", fileErr("io_error", in.Path, "%v", err)
+		}
+		if len(matches) == 0 {
+			return "No files matched.", nil
+		}
+		sort.Strings(matches)
+		out := strings.Join(matches, "\n")
+		if truncated {
+			out += fmt.Sprintf("\n… (results truncated at %d)", fileSearchMaxResults)
+		}
+		return out, nil
+	}
+}
+
+func (r *ToolRegistry) grepTool() ToolHandler {
+	return func(ctx context.Context, input string) (string, error) {
+		var in struct {
+			Pattern    string `json:"pattern"`
+			Path       string `json:"path"`
+			Include    string `json:"include"`
+			IgnoreCase bool   `json:"ignore_case"`
+			MaxResults int    `json:"max_results"`
+		}
+		if err := decodeFileToolInput("grep", input, &in); err != nil {
+			return "", err
+		}
+		if in.Pattern == "" {
+			return "", fileErr("invalid_input", "", "pattern is required")
+		}
+		expr := in.Pattern
+		if in.IgnoreCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return "", fileErr("invalid_pattern", "", "%v", err)
+		}
+		var include *regexp.Regexp
+		if strings.TrimSpace(in.Include) != "" {
+			if include, err = globToRegexp(in.Include); err != nil {
+				return "", fileErr("invalid_pattern", "", "include: %v", err)
+			}
+		}
+		limit := in.MaxResults
+		if limit <= 0 || limit > fileSearchMaxResults {
+			limit = fileSearchMaxResults
+		}
+		if strings.TrimSpace(in.Path) == "" {
+			in.Path = "."
+		}
+		base, err := r.policy.ResolvePath(in.Path, false)
+		if err != nil {
+			return "", err
+		}
+
+		var b strings.Builder
+		count := 0
+		err = r.policy.walkFiles(base, func(path string, d fs.DirEntry) bool {
+			if ctx.Err() != nil {
+				return false
+			}
+			if include != nil && !include.MatchString(d.Name()) {
+				return true
+			}
+			info, err := d.Info()
+			if err != nil || info.Size() > fileMaxSize {
+				return true
+			}
+			data, err := os.ReadFile(path)
+			if err != nil || isBinary(data) {
+				return true
+			}
+			for i, line := range strings.Split(string(data), "\n") {
+				if !re.MatchString(line) {
+					continue
+				}
+				if count == limit {
+					return false
+				}
+				count++
+				fmt.Fprintf(&b, "%s:%d: %s\n", r.displayPath(path), i+1, truncateWithEllipsis(strings.TrimRight(line, "\r"), 300))
+			}
+			return true
+		})
+		if err != nil {
+			return "", fileErr("io_error", in.Path, "%v", err)
+		}
+		if count == 0 {
+			return "No matches.", nil
+		}
+		out := strings.TrimRight(b.String(), "\n")
+		if count == limit {
+			out += fmt.Sprintf("\n… (results limited to %d)", limit)
+		}
+		return out, nil
+	}
+}
+
+func (r *ToolRegistry) undoFileChangeTool() ToolHandler {
+	return func(ctx context.Context, input string) (string, error) {
+		var in struct {
+			Steps int `json:"steps"`
+		}
+		if err := decodeFileToolInput("undo_file_change", input, &in); err != nil {
+			return "", err
+		}
+		if in.Steps <= 0 {
+			in.Steps = 1
+		}
+		if r.policy.autonomy == "readonly" {
+			return "", fileErr("read_only", "", "file writes are disabled in read-only mode")
+		}
+		session := toolSessionKey(ctx)
+		var restored []string
+		for i := 0; i < in.Steps; i++ {
+			entry, ok := r.journal.pop(session)
+			if !ok {
+				break
+			}
+			if err := entry.restore(); err != nil {
+				return "", fileErr("io_error", r.displayPath(entry.Path), "undo failed: %v", err)
+			}
+			action := "restored"
+			if !entry.Existed {
+				action = "removed"
+			}
+			restored = append(restored, fmt.Sprintf("%s %s (undid %s)", action, r.displayPath(entry.Path), entry.Tool))
+		}
+		if len(restored) == 0 {
+			return "", fileErr("nothing_to_undo", "", "no file changes recorded in this session")
+		}
+		r.logger.Info("file changes undone", "session", session, "count", len(restored))
+		return strings.Join(restored, "\n"), nil
+	}
+}
+
+var diffHunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+type diffHunk struct {
+	oldStart int
+	oldLines []string
+	newLines []string
+}
+
+// parseUnifiedDiff 解析单文件 unified diff 的 hunk，忽略 ---/+++ 等头部
+func parseUnifiedDiff(patch string) ([]diffHunk, error) {
+	var hunks []diffHunk
+	var cur *diffHunk
+	for _, line := range strings.Split(strings.TrimRight(strings.ReplaceAll(patch, "\r\n", "\n"), "\n"), "\n") {
+		if m := diffHunkHeader.FindStringSubmatch(line); m != nil {
+			var start int
+			fmt.Sscanf(m[1], "%d", &start)
+			hunks = append(hunks, diffHunk{oldStart: start})
+			cur = &hunks[len(hunks)-1]
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "\\"):
+			// "\ No newline at end of file"
+		case strings.HasPrefix(line, "+"):
+			cur.newLines = append(cur.newLines, line[1:])
+		case strings.HasPrefix(line, "-"):
+			cur.oldLines = append(cur.oldLines, line[1:])
+		case strings.HasPrefix(line, " "):
+			cur.oldLines = append(cur.oldLines, line[1:])
+			cur.newLines = append(cur.newLines, line[1:])
+		case line == "":
+			// 模型常把空白上下文行的前导空格丢掉
+			cur.oldLines = append(cur.oldLines, "")
+			cur.newLines = append(cur.newLines, "")
+		default:
+			return nil, fmt.Errorf("unexpected diff line: %q", line)
+		}
+	}
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("no @@ hunks found in diff")
+	}
+	return hunks, nil
+}
+
+// applyUnifiedDiff 依次应用 hunk。行号只作为起点，实际按上下文就近查找，
+// 先精确匹配，再忽略行尾空白匹配。
+func applyUnifiedDiff(content, patch string) (string, int, error) {
+	hunks, err := parseUnifiedDiff(patch)
+	if err != nil {
+		return "", 0, err
+	}
+	trailingNewline := strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+
+	minPos, delta := 0, 0
+	for i, h := range hunks {
+		expected := h.oldStart - 1 + delta
+		if h.oldStart == 0 {
+			expected = 0
+		}
+		pos := findHunk(lines, h.oldLines, expected, minPos)
+		if pos < 0 {
+			return "", 0, fmt.Errorf("hunk %d (@@ -%d) does not match the file; re-read it and regenerate the diff", i+1, h.oldStart)
+		}
+		next := make([]string, 0, len(lines)-len(h.oldLines)+len(h.newLines))
+		next = append(next, lines[:pos]...)
+		next = append(next, h.newLines...)
+		next = append(next, lines[pos+len(h.oldLines):]...)
+		lines = next
+		minPos = pos + len(h.newLines)
+		delta += len(h.newLines) - len(h.oldLines)
+	}
+
+	out := strings.Join(lines, "\n")
+	if trailingNewline || (content == "" && len(lines) > 0) {
+		out += "\n"
+	}
+	return out, len(hunks), nil
+}
+
+// findHunk 返回 old 在 lines 中离 expected 最近的匹配位置，找不到返回 -1
+func findHunk(lines, old []string, expected, minPos int) int {
+	if len(old) == 0 {
+		if expected < minPos {
+			expected = minPos
+		}
+		if expected > len(lines) {
+			expected = len(lines)
+		}
+		return expected
+	}
+	for _, loose := range []bool{false, true} {
+		best := -1
+		for pos := minPos; pos+len(old) <= len(lines); pos++ {
+			if !linesEqual(lines[pos:pos+len(old)], old, loose) {
+				continue
+			}
+			if best < 0 || absInt(pos-expected) < absInt(best-expected) {
+				best = pos
+			}
+		}
+		if best >= 0 {
+			return best
+		}
+	}
+	return -1
+}
+
+func linesEqual(a, b []string, loose bool) bool {
+	for i := range a {
+		x, y := a[i], b[i]
+		if loose {
+			x, y = strings.TrimRight(x, " \t\r"), strings.TrimRight(y, " \t\r")
+		}
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,158 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+func newFileToolsRegistry(t *testing.T) (*ToolRegistry, string) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Agent.Workspace = t.TempDir()
+	cfg.Memory.Backend = "markdown"
+	cfg.Autonomy.ForbiddenPaths = []string{filepath.Join(cfg.Agent.Workspace, "secret")}
+	return NewToolRegistry(cfg, slog.Default()), cfg.Agent.Workspace
+}
+
+func fileToolErrCode(err error) string {
+	var fe *fileToolError
+	if errors.As(err, &fe) {
+		return fe.Code
+	}
+	return ""
+}
+
+func TestResolvePathEnforcesWorkspaceAndForbiddenPaths(t *testing.T) {
+	reg, ws := newFileToolsRegistry(t)
+	if got, err := reg.policy.ResolvePath("notes/a.txt", true); err != nil || got != filepath.Join(ws, "notes", "a.txt") {
+		t.Fatalf("unexpected resolve: %q %v", got, err)
+	}
+	if _, err := reg.policy.ResolvePath("../escape.txt", false); fileToolErrCode(err) != "outside_workspace" {
+		t.Fatalf("expected outside_workspace, got %v", err)
+	}
+	if _, err := reg.policy.ResolvePath("secret/key.pem", false); fileToolErrCode(err) != "forbidden_path" {
+		t.Fatalf("expected forbidden_path, got %v", err)
+	}
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(ws, "link")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	if _, err := reg.policy.ResolvePath("link/x.txt", true); fileToolErrCode(err) != "outside_workspace" {
+		t.Fatalf("symlink escape should be blocked, got %v", err)
+	}
+}
+
+func TestEditFileAndUndo(t *testing.T) {
+	reg, ws := newFileToolsRegistry(t)
+	ctx := context.Background()
+	run := func(name string, args map[string]any) (string, error) {
+		raw, _ := json.Marshal(args)
+		return reg.Execute(ctx, name, string(raw))
+	}
+
+	if _, err := run("write_file", map[string]any{"path": "main.go", "content": "package main\n\nfunc a() {}\nfunc a() {}\n"}); err != nil {
+		t.Fatalf("write_file: %v", err)
+	}
+	if _, err := run("edit_file", map[string]any{"path": "main.go", "old_string": "func a() {}", "new_string": "func b() {}"}); fileToolErrCode(err) != "ambiguous_match" {
+		t.Fatalf("expected ambiguous_match, got %v", err)
+	}
+	if _, err := run("edit_file", map[string]any{"path": "main.go", "old_string": "missing", "new_string": "x"}); fileToolErrCode(err) != "no_match" {
+		t.Fatalf("expected no_match, got %v", err)
+	}
+	if _, err := run("edit_file", map[string]any{"path": "main.go", "old_string": "func a() {}\nfunc a", "new_string": "func a() {}\nfunc b"}); err != nil {
+		t.Fatalf("edit_file: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(ws, "main.go"))
+	if string(data) != "package main\n\nfunc a() {}\nfunc b() {}\n" {
+		t.Fatalf("unexpected content after edit: %q", data)
+	}
+
+	if _, err := run("undo_file_change", map[string]any{}); err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	data, _ = os.ReadFile(filepath.Join(ws, "main.go"))
+	if !strings.Contains(string(data), "func a() {}\nfunc a() {}") {
+		t.Fatalf("undo did not restore content: %q", data)
+	}
+	if _, err := run("undo_file_change", map[string]any{}); err != nil {
+		t.Fatalf("undo create: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(ws, "main.go")); !os.IsNotExist(err) {
+		t.Fatalf("undoing write_file of a new file should remove it")
+	}
+	if _, err := run("undo_file_change", map[string]any{}); fileToolErrCode(err) != "nothing_to_undo" {
+		t.Fatalf("expected nothing_to_undo, got %v", err)
+	}
+}
+
+func TestApplyUnifiedDiff(t *testing.T) {
+	original := "one\ntwo\nthree\nfour\nfive\n"
+	// 行号偏了两行，仍按上下文找到位置
+	patch := `--- a/f.txt
++++ b/f.txt
+@@ -5,3 +5,3 @@
+ three
+-four
++FOUR
+ five
+`
+	got, hunks, err := applyUnifiedDiff(original, patch)
+	if err != nil || hunks != 1 || got != "one\ntwo\nthree\nFOUR\nfive\n" {
+		t.Fatalf("unexpected patch result %q %d %v", got, hunks, err)
+	}
+	if _, _, err := applyUnifiedDiff(original, "@@ -1,1 +1,1 @@\n-nope\n+yes\n"); err == nil {
+		t.Fatalf("expected non-matching hunk to fail")
+	}
+}
+
+func TestGlobAndGrep(t *testing.T) {
+	reg, ws := newFileToolsRegistry(t)
+	for path, content := range map[string]string{
+		"a.go":                "package a\n// TODO: one\n",
+		"pkg/b.go":            "package b\n",
+		"pkg/c.txt":           "todo: two\n",
+		"node_modules/x/y.go": "// TODO: hidden\n",
+	} {
+		full := filepath.Join(ws, path)
+		_ = os.MkdirAll(filepath.Dir(full), 0o755)
+		_ = os.WriteFile(full, []byte(content), 0o644)
+	}
+	out, err := reg.Execute(context.Background(), "glob", `{"pattern":"**/*.go"}`)
+	if err != nil || out != "a.go\npkg/b.go" {
+		t.Fatalf("unexpected glob output %q %v", out, err)
+	}
+	out, err = reg.Execute(context.Background(), "grep", `{"pattern":"todo","ignore_case":true,"include":"*.{go,txt}"}`)
+	if err != nil || out != "a.go:2: // TODO: one\npkg/c.txt:1: todo: two" {
+		t.Fatalf("unexpected grep output %q %v", out, err)
+	}
+}
+
+func TestGlobAndGrepSkipForbiddenSubtrees(t *testing.T) {
+	reg, ws := newFileToolsRegistry(t)
+	for path, content := range map[string]string{
+		"notes.txt":      "key: public\n",
+		"secret/id_rsa":  "key: PRIVATE\n",
+		"secret/sub/pem": "key: PRIVATE\n",
+	} {
+		full := filepath.Join(ws, path)
+		_ = os.MkdirAll(filepath.Dir(full), 0o755)
+		_ = os.WriteFile(full, []byte(content), 0o644)
+	}
+	// 搜索根目录本身允许，其下被禁止的子目录不能被读取
+	out, err := reg.Execute(context.Background(), "grep", `{"pattern":"key"}`)
+	if err != nil || out != "notes.txt:1: key: public" {
+		t.Fatalf("grep leaked forbidden files: %q %v", out, err)
+	}
+	out, err = reg.Execute(context.Background(), "glob", `{"pattern":"**/*{id_rsa,pem,.txt}"}`)
+	if err != nil || out != "notes.txt" {
+		t.Fatalf("glob listed forbidden files: %q %v", out, err)
+	}
+}
@@ -0,0 +1,249 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/highclaw/highclaw/internal/agent/providers"
+	"github.com/highclaw/highclaw/internal/config"
+	"github.com/highclaw/highclaw/internal/domain/model"
+)
+
+// localProviderKinds 本地 provider 名 → 服务类型
+var localProviderKinds = map[string]string{
+	"ollama":    providers.LocalOllama,
+	"llamacpp":  providers.LocalLlamaCpp,
+	"llama.cpp": providers.LocalLlamaCpp,
+}
+
+const (
+	// localCapsTTL 能力探测结果的缓存时间；探测失败只缓存 localCapsErrTTL，服务恢复后尽快重试
+	localCapsTTL    = 10 * time.Minute
+	localCapsErrTTL = 30 * time.Second
+	// localProbeTimeout 单次能力探测的超时，避免本地服务不可达时拖慢每次运行
+	localProbeTimeout = 3 * time.Second
+)
+
+type localCapsEntry struct {
+	info providers.LocalModel
+	err  error
+	at   time.Time
+}
+
+// localCapsCache 按 "baseURL|model" 缓存探测结果，所有 runner 共享
+var localCapsCache = struct {
+	sync.Mutex
+	m map[string]localCapsEntry
+}{m: map[string]localCapsEntry{}}
+
+func isLocalProvider(name string) bool {
+	_, ok := localProviderKinds[strings.ToLower(strings.TrimSpace(name))]
+	return ok
+}
+
+// localBaseURL 本地服务地址：agent.providers.<name>.baseUrl > OLLAMA_HOST > 默认端口
+func localBaseURL(cfg *config.Config, provider string) string {
+	if pcfg, ok := cfg.Agent.Providers[provider]; ok && strings.TrimSpace(pcfg.BaseURL) != "" {
+		return strings.TrimSpace(pcfg.BaseURL)
+	}
+	if localProviderKinds[provider] == providers.LocalOllama {
+		if host := strings.TrimSpace(os.Getenv("OLLAMA_HOST")); host != "" {
+			if !strings.Contains(host, "://") {
+				host = "http://" + host
+			}
+			return host
+		}
+		return "http://localhost:11434"
+	}
+	return "http://localhost:8080"
+}
+
+func newLocalClient(cfg *config.Config, provider string) *providers.LocalClient {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	return providers.NewLocalClient(localProviderKinds[provider], localBaseURL(cfg, provider), cfg.Agent.Providers[provider].KeepAlive)
+}
+
+// registerLocalProviders 注册 Ollama / llama.cpp；本地服务不需要 API key
+func registerLocalProviders(f *ProviderFactory) {
+	for name := range localProviderKinds {
+		providerName := name
+		f.Register(providerName, func(cfg *config.Config) (Provider, error) {
+			return &localProvider{client: newLocalClient(cfg, providerName)}, nil
+		})
+	}
+}
+
+// probeLocalModel 探测（或从缓存读取）本地模型能力
+func probeLocalModel(ctx context.Context, client *providers.LocalClient, modelName string) (providers.LocalModel, error) {
+	key := client.BaseURL + "|" + modelName
+	localCapsCache.Lock()
+	e, ok := localCapsCache.m[key]
+	localCapsCache.Unlock()
+	ttl := localCapsTTL
+	if e.err != nil {
+		ttl = localCapsErrTTL
+	}
+	if ok && time.Since(e.at) < ttl {
+		return e.info, e.err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, localProbeTimeout)
+	defer cancel()
+	info, err := client.Probe(ctx, modelName)
+	localCapsCache.Lock()
+	localCapsCache.m[key] = localCapsEntry{info: info, err: err, at: time.Now()}
+	localCapsCache.Unlock()
+	return info, err
+}
+
+// localModelCaps 返回本次调用将使用的本地模型能力；非本地 provider 或探测失败时 ok 为 false（不降级）
+func (m *ModelManager) localModelCaps(providerOverride, modelRef string) (providers.LocalModel, bool) {
+	modelRef = strings.TrimSpace(modelRef)
+	if modelRef == "" {
+		modelRef = m.cfg.Agent.Model
+	}
+	routeProvider, routeModel, routed := m.resolveHintRoute(modelRef)
+	if routed {
+		modelRef = routeModel
+	}
+	provider := m.resolvePrimaryProvider(providerOverride, modelRef)
+	if routeProvider != "" {
+		provider = routeProvider
+	}
+	if !isLocalProvider(provider) {
+		return providers.LocalModel{}, false
+	}
+	modelName := normalizeModelForProvider(modelRef, provider)
+	info, err := probeLocalModel(context.Background(), newLocalClient(m.cfg, provider), modelName)
+	if err != nil {
+		m.logger.Debug("local model probe failed", "provider", provider, "model", modelName, "error", err)
+		return providers.LocalModel{}, false
+	}
+	return info, true
+}
+
+// localCaps 返回本次运行所用本地模型的能力
+func (r *Runner) localCaps(req *RunRequest) (providers.LocalModel, bool) {
+	provider := strings.TrimSpace(req.Provider)
+	if provider == "" {
+		provider = r.provider
+	}
+	return r.models.localModelCaps(provider, req.Model)
+}
+
+type localProvider struct {
+	client *providers.LocalClient
+}
+
+func (p *localProvider) Chat(ctx context.Context, req *ChatRequest, modelName string) (*ChatResponse, error) {
+	messages := make([]providers.LocalMessage, 0, len(req.Messages)+1)
+	if req.SystemPrompt != "" {
+		messages = append(messages, providers.LocalMessage{Role: "system", Content: req.SystemPrompt})
+	}
+	for _, msg := range req.Messages {
+		lm := providers.LocalMessage{Role: msg.Role, Content: msg.Content}
+		for _, img := range msg.Images {
+			lm.Images = append(lm.Images, img.Data)
+		}
+		messages = append(messages, lm)
+	}
+	resp, err := p.client.Chat(ctx, &providers.LocalChatRequest{
+		Model:       modelName,
+		Messages:    messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ChatResponse{
+		Content: resp.Content,
+		Usage:   TokenUsage{InputTokens: resp.InputTokens, OutputTokens: resp.OutputTokens},
+	}, nil
+}
+
+// LocalDiscovery 一个本地服务的模型发现结果
+type LocalDiscovery struct {
+	Provider string
+	BaseURL  string
+	Models   []providers.LocalModel
+	Err      error
+}
+
+// configuredLocalProviders 配置中出现的本地 provider：agent.providers 条目或默认模型的前缀
+func configuredLocalProviders(cfg *config.Config) []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(name string) {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if isLocalProvider(name) && !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	for name := range cfg.Agent.Providers {
+		add(name)
+	}
+	if prefix, _, ok := splitModelPrefix(cfg.Agent.Model); ok {
+		add(prefix)
+	}
+	for _, route := range cfg.ModelRoutes {
+		add(route.Provider)
+	}
+	return out
+}
+
+// DiscoverLocalModels 列出已配置本地服务上的模型、逐个探测能力，并登记到模型目录（models list）
+func DiscoverLocalModels(ctx context.Context, cfg *config.Config) []LocalDiscovery {
+	var out []LocalDiscovery
+	for _, provider := range configuredLocalProviders(cfg) {
+		client := newLocalClient(cfg, provider)
+		d := LocalDiscovery{Provider: provider, BaseURL: client.BaseURL}
+		listCtx, cancel := context.WithTimeout(ctx, localProbeTimeout)
+		listed, err := client.ListModels(listCtx)
+		cancel()
+		if err != nil {
+			d.Err = err
+			out = append(out, d)
+			continue
+		}
+		catalog := make([]model.Model, 0, len(listed))
+		for _, lm := range listed {
+			info, err := probeLocalModel(ctx, client, lm.ID)
+			if err != nil {
+				info = lm
+			}
+			if info.Family == "" {
+				info.Family = lm.Family
+			}
+			if info.ParameterSize == "" {
+				info.ParameterSize = lm.ParameterSize
+			}
+			d.Models = append(d.Models, info)
+
+			var capabilities []string
+			if info.Tools {
+				capabilities = append(capabilities, "tools")
+			}
+			if info.Vision {
+				capabilities = append(capabilities, "vision")
+			}
+			desc := strings.TrimSpace(fmt.Sprintf("Local %s %s", info.Family, info.ParameterSize))
+			catalog = append(catalog, model.Model{
+				ID:           info.ID,
+				Name:         info.ID,
+				Provider:     provider,
+				Description:  strings.Join(strings.Fields(desc), " "),
+				MaxTokens:    info.ContextLength,
+				Capabilities: capabilities,
+			})
+		}
+		model.RegisterLocalModels(provider, catalog)
+		out = append(out, d)
+	}
+	return out
+}
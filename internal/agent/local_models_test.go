@@ -0,0 +1,161 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/highclaw/highclaw/internal/config"
+	"github.com/highclaw/highclaw/internal/domain/model"
+)
+
+// fakeOllama 模拟 Ollama 的 /api/tags、/api/show 和 /api/chat
+type fakeOllama struct {
+	mu    sync.Mutex
+	chats []map[string]any
+}
+
+func (f *fakeOllama) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/tags":
+		_, _ = w.Write([]byte(`{"models":[
+			{"name":"qwen2.5:7b","details":{"family":"qwen2","parameter_size":"7.6B"}},
+			{"name":"tinyllama:latest","details":{"family":"llama","parameter_size":"1B"}},
+			{"name":"llava:7b","details":{"family":"llama","parameter_size":"7B"}}]}`))
+	case "/api/show":
+		var in struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		switch in.Model {
+		case "qwen2.5:7b":
+			_, _ = w.Write([]byte(`{"capabilities":["completion","tools"],"model_info":{"qwen2.context_length":32768}}`))
+		case "tinyllama:latest":
+			_, _ = w.Write([]byte(`{"capabilities":["completion"],"model_info":{"llama.context_length":2048}}`))
+		case "llava:7b":
+			// 旧版 Ollama：没有 capabilities，按模板和投影层推断
+			_, _ = w.Write([]byte(`{"template":"{{ .Prompt }}","details":{"families":["llama","clip"]},"model_info":{"llama.context_length":4096}}`))
+		default:
+			http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+		}
+	case "/api/chat":
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.mu.Lock()
+		f.chats = append(f.chats, body)
+		f.mu.Unlock()
+		_, _ = w.Write([]byte(`{"message":{"role":"assistant","content":"hello from local"},"prompt_eval_count":42,"eval_count":7,"done":true}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeOllama) lastChat(t *testing.T) map[string]any {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.chats) == 0 {
+		t.Fatal("no chat request received")
+	}
+	return f.chats[len(f.chats)-1]
+}
+
+func chatMessages(body map[string]any) (system, last string) {
+	msgs, _ := body["messages"].([]any)
+	for i, m := range msgs {
+		mm, _ := m.(map[string]any)
+		content, _ := mm["content"].(string)
+		if i == 0 && mm["role"] == "system" {
+			system = content
+		}
+		last = content
+	}
+	return system, last
+}
+
+func TestLocalProviderDiscoveryAndDegradation(t *testing.T) {
+	fake := &fakeOllama{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	cfg := config.Default()
+	cfg.Agent.Workspace = t.TempDir()
+	cfg.Memory.Backend = "markdown"
+	cfg.Memory.AutoSave = false
+	cfg.Agent.Providers = map[string]config.ProviderConfig{"ollama": {BaseURL: srv.URL, KeepAlive: "30m"}}
+
+	found := DiscoverLocalModels(context.Background(), cfg)
+	if len(found) != 1 || found[0].Err != nil || len(found[0].Models) != 3 {
+		t.Fatalf("unexpected discovery result: %+v", found)
+	}
+	caps := map[string]model.Model{}
+	for _, m := range model.GetModelsByProvider("ollama") {
+		caps[m.ID] = m
+	}
+	if m := caps["qwen2.5:7b"]; m.MaxTokens != 32768 || strings.Join(m.Capabilities, ",") != "tools" {
+		t.Fatalf("qwen2.5 should be registered with tools and 32k context, got %+v", m)
+	}
+	if m := caps["llava:7b"]; strings.Join(m.Capabilities, ",") != "vision" {
+		t.Fatalf("llava vision should be inferred from the projector family, got %+v", m)
+	}
+	if model.ContextWindow("ollama/tinyllama:latest") != 2048 {
+		t.Fatalf("discovered context length should be used for token budgeting")
+	}
+
+	r := NewRunner(cfg, slog.Default())
+	t.Cleanup(r.Close)
+
+	res, err := r.Run(context.Background(), &RunRequest{SessionKey: "agent:main:local", Message: "hi", Model: "ollama/qwen2.5:7b"})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	body := fake.lastChat(t)
+	system, _ := chatMessages(body)
+	if res.Reply != "hello from local" || body["keep_alive"] != "30m" || body["model"] != "qwen2.5:7b" {
+		t.Fatalf("unexpected local chat: reply=%q body=%v", res.Reply, body)
+	}
+	if !strings.Contains(system, "## Tools") || res.TokensUsed.InputTokens != 42 || res.TokensUsed.OutputTokens != 7 || res.TokensUsed.CostUSD != 0 {
+		t.Fatalf("tool-capable model should get tools; usage=%+v", res.TokensUsed)
+	}
+
+	if _, err := r.Run(context.Background(), &RunRequest{SessionKey: "agent:main:local2", Message: "describe this", Model: "ollama/tinyllama:latest", Images: [][]byte{[]byte("\x89PNG")}}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	system, last := chatMessages(fake.lastChat(t))
+	if strings.Contains(system, "## Tools") || strings.Contains(system, "<invoke>") {
+		t.Fatalf("model without tool calling must not be offered tools:\n%s", system)
+	}
+	if !strings.Contains(last, "Image attachments omitted") {
+		t.Fatalf("images should be dropped for a model without vision, got %q", last)
+	}
+}
+
+func TestLlamaCppDiscovery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			_, _ = w.Write([]byte(`{"object":"list","data":[{"id":"gemma-3-4b-it-Q4_K_M.gguf"}]}`))
+		case "/props":
+			_, _ = w.Write([]byte(`{"default_generation_settings":{"n_ctx":8192},"modalities":{"vision":true},"chat_template":"{% for message in messages %}{{ message.content }}{% endfor %}"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := config.Default()
+	cfg.Agent.Providers = map[string]config.ProviderConfig{"llamacpp": {BaseURL: srv.URL}}
+	found := DiscoverLocalModels(context.Background(), cfg)
+	if len(found) != 1 || found[0].Err != nil || len(found[0].Models) != 1 {
+		t.Fatalf("unexpected discovery result: %+v", found)
+	}
+	m := found[0].Models[0]
+	if m.ContextLength != 8192 || !m.Vision || m.Tools {
+		t.Fatalf("unexpected llama.cpp capabilities: %+v", m)
+	}
+}
@@ -1,6 +1,8 @@
 package agent
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/highclaw/highclaw/internal/config"
@@ -35,18 +37,31 @@ func toDTOs(entries []memoryEntry) []MemoryEntryDTO {
 	return out
 }
 
+// memoryWorkspace 返回记忆存储根目录；配置了 namespace 时隔离到 <workspace>/agents/<namespace>
+func memoryWorkspace(cfg *config.Config) string {
+	if cfg == nil {
+		return ""
+	}
+	base := strings.TrimSpace(cfg.Agent.Workspace)
+	ns := strings.TrimSpace(cfg.Memory.Namespace)
+	if ns == "" {
+		return base
+	}
+	if base == "" {
+		base = filepath.Join(os.TempDir(), "highclaw-workspace")
+	}
+	ns = strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(ns)
+	return filepath.Join(base, "agents", ns)
+}
+
 // 根据 config 创建对应的 memoryStore 实例
 func resolveMemoryStore(cfg *config.Config) memoryStore {
 	backend := strings.ToLower(strings.TrimSpace(cfg.Memory.Backend))
 	switch backend {
 	case "sqlite":
 		return newSQLiteMemoryStore(cfg)
-	case "markdown", "none", "":
-		ws := strings.TrimSpace(cfg.Agent.Workspace)
-		return newMarkdownMemoryStore(ws)
 	default:
-		ws := strings.TrimSpace(cfg.Agent.Workspace)
-		return newMarkdownMemoryStore(ws)
+		return newMarkdownMemoryStore(memoryWorkspace(cfg))
 	}
 }
 
@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math"
 	"os"
@@ -38,14 +39,12 @@ type sqliteMemoryStore struct {
 	embeddingCacheSize int
 	embedder           embeddingProvider
 	mu                 sync.Mutex
+	closed             bool
 }
 
 // newSQLiteMemoryStore 根据配置创建 SQLite 内存存储实例
 func newSQLiteMemoryStore(cfg *config.Config) *sqliteMemoryStore {
-	base := ""
-	if cfg != nil {
-		base = strings.TrimSpace(cfg.Agent.Workspace)
-	}
+	base := memoryWorkspace(cfg)
 	if base == "" {
 		base = filepath.Join(os.TempDir(), "highclaw-workspace")
 	}
@@ -81,6 +80,9 @@ func (s *sqliteMemoryStore) openDB() (*sql.DB, error) {
 	if s.db != nil {
 		return s.db, nil
 	}
+	if s.closed {
+		return nil, errMemoryStoreClosed
+	}
 	db, err := sql.Open("sqlite", s.dbPath+"?_pragma=busy_timeout%3d5000&_pragma=journal_mode%3dwal")
 	if err != nil {
 		return nil, fmt.Errorf("open sqlite db: %w", err)
@@ -90,6 +92,22 @@ func (s *sqliteMemoryStore) openDB() (*sql.DB, error) {
 	return db, nil
 }
 
+// errMemoryStoreClosed 存储已关闭（配置热加载换了新存储后，仍在进行的运行可能遇到）
+var errMemoryStoreClosed = errors.New("memory store closed")
+
+// close 关闭数据库连接，之后不再重新打开
+func (s *sqliteMemoryStore) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
+
 // init 初始化数据库表结构、索引和 FTS5 trigger
 func (s *sqliteMemoryStore) init() error {
 	s.mu.Lock()
@@ -654,7 +672,11 @@ func (s *sqliteMemoryStore) getOrComputeEmbedding(text string) ([]byte, error) {
 	now := time.Now().UTC().Format(time.RFC3339Nano)
 
 	s.mu.Lock()
-	db, _ = s.openDB()
+	db, err = s.openDB()
+	if err != nil {
+		s.mu.Unlock()
+		return b, nil
+	}
 	db.Exec("INSERT OR REPLACE INTO embedding_cache(content_hash, embedding, created_at, accessed_at) VALUES(?,?,?,?)", hash, b, now, now)
 	if s.embeddingCacheSize > 0 {
 		db.Exec("DELETE FROM embedding_cache WHERE content_hash IN (SELECT content_hash FROM embedding_cache ORDER BY accessed_at ASC LIMIT MAX(0, (SELECT COUNT(*) FROM embedding_cache)-?))", s.embeddingCacheSize)
@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,6 +11,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/highclaw/highclaw/internal/config"
 )
 
 type memoryStore interface {
@@ -22,6 +25,8 @@ type memoryStore interface {
 	count() (int, error)
 	healthCheck() bool
 	location() string
+	// close 释放存储占用的资源（数据库连接等）；之后的操作返回错误
+	close() error
 }
 
 type memoryMeta struct {
@@ -69,6 +74,23 @@ func (s *disabledMemoryStore) healthCheck() bool {
 
 func (s *disabledMemoryStore) location() string { return "disabled" }
 
+func (s *disabledMemoryStore) close() error { return nil }
+
+// memoryStoreKey 汇总决定记忆存储实例的配置（memory 段、存储目录、embedding 端点），
+// 热加载前后相同时沿用原存储，不重新打开数据库
+func memoryStoreKey(cfg *config.Config) string {
+	embedding := "none"
+	if e, ok := createEmbeddingProvider(cfg).(*openAIEmbedding); ok {
+		embedding = strings.Join([]string{e.baseURL, e.apiKey, e.model, strconv.Itoa(e.dims)}, "|")
+	}
+	data, _ := json.Marshal(struct {
+		Memory    config.MemoryConfig
+		Workspace string
+		Embedding string
+	}{cfg.Memory, memoryWorkspace(cfg), embedding})
+	return string(data)
+}
+
 type markdownMemoryStore struct {
 	workspaceDir string
 	mu           sync.Mutex
@@ -192,6 +214,10 @@ func (s *markdownMemoryStore) location() string {
 	return s.workspaceDir
 }
 
+func (s *markdownMemoryStore) close() error {
+	return nil
+}
+
 func (s *markdownMemoryStore) get(key string) (*memoryEntry, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
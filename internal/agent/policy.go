@@ -2,7 +2,9 @@ package agent
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
@@ -15,6 +17,15 @@ type SecurityPolicy struct {
 	workspaceOnly            bool
 	requireApprovalForMedium bool
 	blockHighRisk            bool
+	workspace                string
+	forbiddenPaths           []string
+	shellRules               []config.ShellRule
+}
+
+// defaultForbiddenPaths 文件工具始终禁止访问的敏感路径（追加 autonomy.forbiddenPaths）
+var defaultForbiddenPaths = []string{
+	"/etc", "/proc", "/sys", "/dev", "/boot",
+	"~/.ssh", "~/.gnupg", "~/.aws", "~/.kube", "~/.docker",
 }
 
 func NewSecurityPolicy(cfg *config.Config) *SecurityPolicy {
@@ -37,15 +48,10 @@ func NewSecurityPolicy(cfg *config.Config) *SecurityPolicy {
 		"cd", "basename", "dirname", "realpath", "du", "df",
 	}
 
-	// 合并命令白名单：默认 + sandbox.allow + autonomy.allowedCommands
+	// 合并命令白名单：默认 + autonomy.allowedCommands（sandbox.allow 是工具名，不参与）
 	for _, cmd := range defaultCommands {
 		allowed[cmd] = struct{}{}
 	}
-	for _, cmd := range cfg.Agent.Sandbox.Allow {
-		if cmd = strings.TrimSpace(cmd); cmd != "" {
-			allowed[cmd] = struct{}{}
-		}
-	}
 	for _, cmd := range cfg.Autonomy.AllowedCommands {
 		if cmd = strings.TrimSpace(cmd); cmd != "" {
 			allowed[cmd] = struct{}{}
@@ -64,157 +70,207 @@ func NewSecurityPolicy(cfg *config.Config) *SecurityPolicy {
 		workspaceOnly = *cfg.Autonomy.WorkspaceOnly
 	}
 
+	workspace := strings.TrimSpace(cfg.Agent.Workspace)
+	if workspace == "" {
+		workspace = filepath.Join(config.ConfigDir(), "workspace")
+	}
+	workspace = filepath.Clean(expandHome(workspace))
+
+	// 配置文件（含系统配置、include 片段和 profile 文件）里有 API key 和权限设置，
+	// secrets 密钥文件（含轮换时的 .old / .new）能解密所有 enc: 值，同样禁止直接读写
+	keyFile := filepath.Clean(expandHome(cfg.SecretsKeyFile()))
+	forbidden := []string{config.ConfigPath(), keyFile, keyFile + ".old", keyFile + ".new"}
+	for _, p := range cfg.SourceFiles() {
+		if abs, err := filepath.Abs(p); err == nil {
+			forbidden = append(forbidden, abs)
+		}
+	}
+	for _, p := range append(append([]string{}, defaultForbiddenPaths...), cfg.Autonomy.ForbiddenPaths...) {
+		if p = strings.TrimSpace(p); p != "" {
+			forbidden = append(forbidden, filepath.Clean(expandHome(p)))
+		}
+	}
+
 	return &SecurityPolicy{
 		autonomy:                 autonomy,
 		allowed:                  allowed,
 		workspaceOnly:            workspaceOnly,
 		requireApprovalForMedium: true,
 		blockHighRisk:            true,
+		workspace:                workspace,
+		forbiddenPaths:           forbidden,
+		shellRules:               compileShellRules(cfg.Autonomy.ShellRules),
 	}
 }
 
-func (p *SecurityPolicy) ValidateBashInput(inputJSON string) error {
-	var in struct {
-		Command  string `json:"command"`
-		Approved bool   `json:"approved"`
+// Workspace 返回文件工具解析相对路径时使用的工作区根目录
+func (p *SecurityPolicy) Workspace() string {
+	return p.workspace
+}
+
+// ResolvePath 把文件工具的 path 参数解析为绝对路径，并校验 workspaceOnly、forbiddenPaths 和只读模式。
+// 相对路径相对于工作区；符号链接按真实路径校验，防止通过链接逃出工作区。
+func (p *SecurityPolicy) ResolvePath(path string, write bool) (string, error) {
+	raw := strings.TrimSpace(path)
+	if raw == "" {
+		return "", &fileToolError{Code: "invalid_path", Message: "path is required"}
 	}
-	if err := json.Unmarshal([]byte(inputJSON), &in); err != nil {
-		return fmt.Errorf("invalid bash input json: %w", err)
+	if write && p.autonomy == "readonly" {
+		return "", &fileToolError{Code: "read_only", Path: raw, Message: "file writes are disabled in read-only mode"}
 	}
-	cmd := strings.TrimSpace(in.Command)
-	if cmd == "" {
-		return fmt.Errorf("command is required")
+	abs := expandHome(raw)
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(p.workspace, abs)
 	}
+	abs = filepath.Clean(abs)
+	real := evalExistingSymlinks(abs)
 
-	normalized := strings.ToLower(cmd)
-	if strings.Contains(normalized, "`") || strings.Contains(normalized, "$(") || strings.Contains(normalized, "${") {
-		return fmt.Errorf("command blocked by policy")
+	if p.workspaceOnly && !pathWithin(real, evalExistingSymlinks(p.workspace)) {
+		msg := "path is outside the workspace (autonomy.workspaceOnly is enabled)"
+		if pathWithin(abs, p.workspace) {
+			msg = "path resolves outside the workspace through a symlink"
+		}
+		return "", &fileToolError{Code: "outside_workspace", Path: raw, Message: msg}
 	}
+	if p.pathForbidden(abs) || p.pathForbidden(real) {
+		return "", &fileToolError{Code: "forbidden_path", Path: raw, Message: "access to this path is forbidden by policy"}
+	}
+	return abs, nil
+}
 
-	hasCommand := false
-	highestRisk := "low"
-	for _, seg := range splitCommandSegments(cmd) {
-		risk := commandRiskLevel(seg)
-		if risk == "high" {
-			highestRisk = "high"
-		} else if risk == "medium" && highestRisk != "high" {
-			highestRisk = "medium"
+// pathForbidden 判断绝对路径是否位于 forbiddenPaths 之内
+func (p *SecurityPolicy) pathForbidden(path string) bool {
+	for _, f := range p.forbiddenPaths {
+		if pathWithin(path, f) {
+			return true
 		}
+	}
+	return false
+}
 
-		base := baseCommand(seg)
-		if base == "" {
-			continue
-		}
-		hasCommand = true
-		if _, ok := p.allowed[base]; !ok {
-			return fmt.Errorf("command not allowed by policy: %s", base)
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
 		}
 	}
-	if !hasCommand {
-		return fmt.Errorf("command is required")
-	}
+	return path
+}
 
-	if p.workspaceOnly && hasAbsolutePath(cmd) {
-		return fmt.Errorf("command blocked: absolute paths are disallowed in workspace-only mode")
+// pathWithin 判断 path 是否等于 root 或位于 root 之下
+func pathWithin(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
 	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
 
-	if p.blockHighRisk && highestRisk == "high" {
-		return fmt.Errorf("Command blocked: high-risk command is disallowed by policy")
-	}
-	if p.autonomy == "supervised" && p.requireApprovalForMedium && highestRisk == "medium" && !in.Approved {
-		return fmt.Errorf("Command requires explicit approval (approved=true): medium-risk operation")
-	}
-	if p.autonomy == "readonly" {
-		return fmt.Errorf("command execution is disabled in read-only mode")
+// evalExistingSymlinks 解析路径中已存在部分的符号链接（目标文件可能还不存在）
+func evalExistingSymlinks(path string) string {
+	rest := ""
+	for cur := path; ; {
+		if real, err := filepath.EvalSymlinks(cur); err == nil {
+			return filepath.Join(real, rest)
+		}
+		parent := filepath.Dir(cur)
+		if parent == cur {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(cur), rest)
+		cur = parent
 	}
+}
 
-	return nil
+// policyDenial 安全策略拒绝执行的错误；审计记录据此区分策略拒绝和工具自身的失败
+type policyDenial struct {
+	msg      string
+	approval string // 需要审批时为 required
 }
 
-func hasAbsolutePath(command string) bool {
-	for _, token := range strings.Fields(command) {
-		token = strings.Trim(token, "\"'")
-		if token == "" {
-			continue
-		}
-		if strings.HasPrefix(token, "~/") {
-			return true
-		}
-		if filepath.IsAbs(token) {
-			return true
+func (e *policyDenial) Error() string { return e.msg }
+
+func deny(approval, format string, args ...any) error {
+	return &policyDenial{msg: fmt.Sprintf(format, args...), approval: approval}
+}
+
+// CommandRisk 返回 shell 命令命中规则的最高风险级别（low / medium / high / block），无法解析时为空
+func (p *SecurityPolicy) CommandRisk(command string) string {
+	analysis, err := analyseShell(strings.TrimSpace(command))
+	if err != nil {
+		return ""
+	}
+	highest := "low"
+	for _, inv := range analysis.invocations {
+		if risk, _ := p.invocationRisk(inv); riskRank[risk] > riskRank[highest] {
+			highest = risk
 		}
 	}
-	return false
+	return highest
 }
 
-func commandRiskLevel(segment string) string {
-	base := strings.ToLower(baseCommand(segment))
-	lowered := strings.ToLower(segment)
-
-	highRisk := map[string]struct{}{
-		"rm": {}, "mkfs": {}, "dd": {}, "shutdown": {}, "reboot": {}, "halt": {}, "poweroff": {},
-		"sudo": {}, "su": {}, "chown": {}, "chmod": {}, "useradd": {}, "userdel": {}, "usermod": {},
-		"passwd": {}, "mount": {}, "umount": {}, "iptables": {}, "ufw": {}, "firewall-cmd": {},
-		"curl": {}, "wget": {}, "nc": {}, "ncat": {}, "netcat": {}, "scp": {}, "ssh": {}, "ftp": {}, "telnet": {},
+func (p *SecurityPolicy) ValidateBashInput(inputJSON string) error {
+	var in struct {
+		Command  string `json:"command"`
+		Approved bool   `json:"approved"`
 	}
-	if _, ok := highRisk[base]; ok {
-		return "high"
+	if err := json.Unmarshal([]byte(inputJSON), &in); err != nil {
+		return fmt.Errorf("invalid bash input json: %w", err)
 	}
-	if strings.Contains(lowered, "rm -rf /") || strings.Contains(lowered, "rm -fr /") || strings.Contains(lowered, ":(){:|:&};:") {
-		return "high"
+	cmd := strings.TrimSpace(in.Command)
+	if cmd == "" {
+		return fmt.Errorf("command is required")
 	}
 
-	parts := strings.Fields(lowered)
-	if len(parts) > 1 {
-		switch base {
-		case "git":
-			medium := map[string]struct{}{
-				"commit": {}, "push": {}, "reset": {}, "clean": {}, "rebase": {}, "merge": {},
-				"cherry-pick": {}, "revert": {}, "branch": {}, "checkout": {},
-			}
-			if _, ok := medium[parts[1]]; ok {
-				return "medium"
+	analysis, err := analyseShell(cmd)
+	if err != nil {
+		return err
+	}
+
+	hasCommand := false
+	highestRisk, reason := "low", ""
+	for _, inv := range analysis.invocations {
+		if !inv.pseudo {
+			hasCommand = true
+			if _, ok := p.allowed[inv.name]; !ok {
+				return deny("", "command not allowed by policy: %s", inv.name)
 			}
-		case "npm":
-			if parts[1] == "publish" {
-				return "medium"
+		}
+		if err := p.checkShellPaths(inv); err != nil {
+			var denial *policyDenial
+			if !errors.As(err, &denial) {
+				return &policyDenial{msg: err.Error()}
 			}
-		case "cargo":
-			if parts[1] == "publish" {
-				return "medium"
+			if denial.approval != "required" || !in.Approved {
+				return denial
 			}
 		}
+		if risk, why := p.invocationRisk(inv); riskRank[risk] > riskRank[highestRisk] {
+			highestRisk, reason = risk, why
+		}
 	}
-	return "low"
-}
-
-func splitCommandSegments(command string) []string {
-	normalized := command
-	for _, sep := range []string{"&&", "||", "\n", ";", "|"} {
-		normalized = strings.ReplaceAll(normalized, sep, "\x00")
+	if !hasCommand {
+		return fmt.Errorf("command is required")
 	}
-	parts := strings.Split(normalized, "\x00")
-	out := make([]string, 0, len(parts))
-	for _, p := range parts {
-		if s := strings.TrimSpace(p); s != "" {
-			out = append(out, s)
+
+	if highestRisk == "block" {
+		if reason == "" {
+			reason = "matched a blocking shell rule"
 		}
+		return deny("", "command blocked by policy: %s", reason)
 	}
-	return out
-}
-
-func baseCommand(segment string) string {
-	fields := strings.Fields(segment)
-	if len(fields) == 0 {
-		return ""
+	if p.blockHighRisk && highestRisk == "high" {
+		if reason != "" {
+			return deny("", "Command blocked: high-risk command is disallowed by policy (%s)", reason)
+		}
+		return deny("", "Command blocked: high-risk command is disallowed by policy")
 	}
-	first := fields[0]
-	// Skip env assignment: FOO=bar cmd
-	if strings.Contains(first, "=") && len(fields) > 1 {
-		first = fields[1]
+	if p.autonomy == "supervised" && p.requireApprovalForMedium && highestRisk == "medium" && !in.Approved {
+		return deny("required", "Command requires explicit approval (approved=true): medium-risk operation")
 	}
-	if i := strings.LastIndex(first, "/"); i >= 0 {
-		first = first[i+1:]
+	if p.autonomy == "readonly" {
+		return deny("", "command execution is disabled in read-only mode")
 	}
-	return strings.TrimSpace(first)
+
+	return nil
 }
@@ -1,6 +1,10 @@
 package agent
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/highclaw/highclaw/internal/config"
@@ -40,3 +44,39 @@ func TestPolicyRequiresApprovalForMediumRisk(t *testing.T) {
 		t.Fatalf("expected approved medium-risk command to pass, got error: %v", err)
 	}
 }
+
+func TestPolicyForbidsConfigSourceFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HIGHCLAW_CONFIG", filepath.Join(dir, "config.yaml"))
+	t.Setenv(config.SystemConfigEnv, filepath.Join(dir, "system.yaml"))
+	t.Setenv(config.ProfileEnv, "")
+	t.Chdir(t.TempDir())
+	files := map[string]string{
+		"system.yaml":        "log:\n  level: warn\n",
+		"config.yaml":        "include: shared/*.yaml\n",
+		"shared/models.yaml": "agent:\n  model: openai/gpt-4o\n",
+	}
+	for name, data := range files {
+		_ = os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	off := false
+	cfg.Autonomy.WorkspaceOnly = &off
+	p := NewSecurityPolicy(cfg)
+	for name := range files {
+		path := filepath.Join(dir, name)
+		if _, err := p.ResolvePath(path, false); fileToolErrCode(err) != "forbidden_path" {
+			t.Errorf("%s: expected forbidden_path, got %v", name, err)
+		}
+		input, _ := json.Marshal(map[string]string{"command": "cat " + path})
+		if err := p.ValidateBashInput(string(input)); err == nil || !strings.Contains(err.Error(), "forbidden") {
+			t.Errorf("%s: expected shell read to be forbidden, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/highclaw/highclaw/internal/agent/tools"
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+const (
+	processDefaultWait = 30 * time.Second
+	processMaxWait     = 5 * time.Minute
+	// 单次 read/wait 返回给模型的输出上限（每路）
+	processMaxOutputChars = 16000
+)
+
+// newProcessSupervisor 按 autonomy 配置创建后台进程管理器
+func newProcessSupervisor(cfg config.AutonomyConfig) *tools.ProcessSupervisor {
+	opts := tools.ProcessOptions{MaxPerOwner: cfg.MaxProcesses}
+	if cfg.ProcessIdleMinutes > 0 {
+		opts.IdleTimeout = time.Duration(cfg.ProcessIdleMinutes) * time.Minute
+	}
+	return tools.NewProcessSupervisor(opts)
+}
+
+// registerProcessTools 注册 process 工具：start 与 shell 走同一套命令策略，进程归属当前会话
+func (r *ToolRegistry) registerProcessTools() {
+	r.Register("process", "Manage long-running background commands (dev servers, watchers, builds). "+
+		"Actions: start {command} -> id; read {id} returns new stdout/stderr since the last read; "+
+		"wait {id, timeout} waits for exit (seconds, default 30); send {id, input} writes to stdin; "+
+		"signal {id, signal} sends TERM/INT/HUP/KILL; kill {id}; list. Processes are killed when the session resets.",
+		`{"type":"object","properties":{"action":{"type":"string","enum":["start","read","wait","send","signal","kill","list"]},"command":{"type":"string"},"id":{"type":"string"},"input":{"type":"string"},"signal":{"type":"string"},"timeout":{"type":"integer"}},"required":["action"]}`,
+		r.processTool())
+}
+
+func (r *ToolRegistry) processTool() ToolHandler {
+	return func(ctx context.Context, input string) (string, error) {
+		var in struct {
+			Action  string `json:"action"`
+			Command string `json:"command"`
+			ID      string `json:"id"`
+			Input   string `json:"input"`
+			Signal  string `json:"signal"`
+			Timeout int    `json:"timeout"`
+		}
+		if err := json.Unmarshal([]byte(input), &in); err != nil {
+			return "", fmt.Errorf("invalid process input: %w", err)
+		}
+		owner := toolSessionKey(ctx)
+		action := strings.ToLower(strings.TrimSpace(in.Action))
+		if action != "start" && action != "list" && strings.TrimSpace(in.ID) == "" {
+			return "", fmt.Errorf("Missing 'id' parameter")
+		}
+
+		switch action {
+		case "start":
+			// 与 shell 工具相同的校验（白名单、风险规则、路径限制、approved）
+			if err := r.policy.ValidateBashInput(input); err != nil {
+				return "", err
+			}
+			dir := r.policy.Workspace()
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				dir = ""
+			}
+			st, err := r.processes.Start(owner, strings.TrimSpace(in.Command), dir)
+			if err != nil {
+				return "", err
+			}
+			r.logger.Info("process started", "session", owner, "id", st.ID, "pid", st.PID, "command", st.Command)
+			return processJSON(st)
+		case "read":
+			out, err := r.processes.Read(owner, in.ID)
+			if err != nil {
+				return "", err
+			}
+			return processJSON(trimProcessOutput(out))
+		case "wait":
+			timeout := processDefaultWait
+			if in.Timeout > 0 {
+				timeout = time.Duration(in.Timeout) * time.Second
+			}
+			if timeout > processMaxWait {
+				timeout = processMaxWait
+			}
+			out, err := r.processes.Wait(ctx, owner, in.ID, timeout)
+			if err != nil {
+				return "", err
+			}
+			return processJSON(trimProcessOutput(out))
+		case "send":
+			if err := r.processes.Send(owner, in.ID, in.Input); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Sent %d bytes to %s", len(in.Input), in.ID), nil
+		case "signal":
+			if err := r.processes.Signal(owner, in.ID, in.Signal); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Signal sent to %s", in.ID), nil
+		case "kill":
+			if err := r.processes.Kill(owner, in.ID); err != nil {
+				return "", err
+			}
+			r.logger.Info("process killed", "session", owner, "id", in.ID)
+			return fmt.Sprintf("Killed %s", in.ID), nil
+		case "list":
+			list := r.processes.List(owner)
+			if len(list) == 0 {
+				return "No background processes.", nil
+			}
+			return processJSON(list)
+		default:
+			return "", fmt.Errorf("unknown process action %q", in.Action)
+		}
+	}
+}
+
+// trimProcessOutput 只保留每路输出的末尾，避免一次塞满上下文
+func trimProcessOutput(out tools.ProcessOutput) tools.ProcessOutput {
+	trim := func(s string) string {
+		if len(s) <= processMaxOutputChars {
+			return s
+		}
+		cut := len(s) - processMaxOutputChars
+		for cut < len(s) && !utf8.RuneStart(s[cut]) {
+			cut++
+		}
+		out.Dropped += int64(cut)
+		return "…" + s[cut:]
+	}
+	out.Stdout = trim(out.Stdout)
+	out.Stderr = trim(out.Stderr)
+	return out
+}
+
+func processJSON(v any) (string, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// ResetSession 结束会话（及其 delegate 子会话）启动的后台进程，在会话重置或删除时调用。
+func (r *Runner) ResetSession(sessionKey string) {
+	for _, runner := range r.allRunners() {
+		if n := runner.tools.processes.CloseSession(sessionKey); n > 0 {
+			runner.logger.Info("background processes stopped", "session", sessionKey, "count", n)
+		}
+	}
+}
+
+// Close 结束所有后台进程并关闭记忆存储，进程退出前调用。
+func (r *Runner) Close() {
+	for _, runner := range r.allRunners() {
+		runner.tools.processes.Shutdown()
+		_ = runner.tools.memory.close()
+	}
+}
+
+func (r *Runner) allRunners() []*Runner {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	runners := []*Runner{r.snapshotLocked()}
+	for _, child := range r.agents {
+		runners = append(runners, child.current())
+	}
+	return runners
+}
@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/highclaw/highclaw/internal/agent/tools"
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+func newProcessToolsRegistry(t *testing.T) *ToolRegistry {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Agent.Workspace = t.TempDir()
+	cfg.Memory.Backend = "markdown"
+	cfg.Autonomy.Level = "full"
+	reg := NewToolRegistry(cfg, slog.Default())
+	t.Cleanup(reg.processes.Shutdown)
+	return reg
+}
+
+func sessionCtx(key string) context.Context {
+	return context.WithValue(context.Background(), delegateSinkKey{}, &delegateSink{parent: &RunRequest{SessionKey: key}})
+}
+
+func runProcessTool(t *testing.T, reg *ToolRegistry, ctx context.Context, args map[string]any) (map[string]any, error) {
+	t.Helper()
+	raw, _ := json.Marshal(args)
+	out, err := reg.Execute(ctx, "process", string(raw))
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	_ = json.Unmarshal([]byte(out), &m)
+	if m == nil {
+		m = map[string]any{"text": out}
+	}
+	return m, nil
+}
+
+func TestProcessToolCapturesOutputAndExitCode(t *testing.T) {
+	reg := newProcessToolsRegistry(t)
+	ctx := sessionCtx("s1")
+
+	started, err := runProcessTool(t, reg, ctx, map[string]any{"action": "start", "command": "echo hello; echo oops >&2; cat missing.txt 2>/dev/null"})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	id, _ := started["id"].(string)
+	if id == "" {
+		t.Fatalf("start returned no id: %v", started)
+	}
+	out, err := runProcessTool(t, reg, ctx, map[string]any{"action": "wait", "id": id, "timeout": 10})
+	if err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if out["stdout"] != "hello\n" || out["stderr"] != "oops\n" {
+		t.Fatalf("unexpected output: %v", out)
+	}
+	if out["running"] != false || out["exitCode"] != float64(1) {
+		t.Fatalf("expected exit code 1, got %v", out)
+	}
+	// 输出只返回一次
+	out, _ = runProcessTool(t, reg, ctx, map[string]any{"action": "read", "id": id})
+	if out["stdout"] != "" {
+		t.Fatalf("read after wait should be empty, got %q", out["stdout"])
+	}
+}
+
+func TestProcessToolSessionOwnershipAndReset(t *testing.T) {
+	reg := newProcessToolsRegistry(t)
+	a, b := sessionCtx("a"), sessionCtx("b")
+
+	started, err := runProcessTool(t, reg, a, map[string]any{"action": "start", "command": "cat"})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	id := started["id"].(string)
+	if _, err := runProcessTool(t, reg, a, map[string]any{"action": "send", "id": id, "input": "ping\n"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	var got string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline) && !strings.Contains(got, "ping"); {
+		out, err := runProcessTool(t, reg, a, map[string]any{"action": "read", "id": id})
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		got += out["stdout"].(string)
+		time.Sleep(20 * time.Millisecond)
+	}
+	if got != "ping\n" {
+		t.Fatalf("expected echoed stdin, got %q", got)
+	}
+
+	if _, err := runProcessTool(t, reg, b, map[string]any{"action": "read", "id": id}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("other session must not see the process, got %v", err)
+	}
+	if out, _ := runProcessTool(t, reg, b, map[string]any{"action": "list"}); out["text"] != "No background processes." {
+		t.Fatalf("unexpected list for other session: %v", out)
+	}
+
+	if n := reg.processes.CloseSession("a"); n != 1 {
+		t.Fatalf("expected 1 process stopped, got %d", n)
+	}
+	if list := reg.processes.List("a"); len(list) != 0 {
+		t.Fatalf("processes left after reset: %v", list)
+	}
+}
+
+func TestProcessToolAppliesShellPolicy(t *testing.T) {
+	reg := newProcessToolsRegistry(t)
+	ctx := sessionCtx("s1")
+	if _, err := runProcessTool(t, reg, ctx, map[string]any{"action": "start", "command": "curl https://example.com"}); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Fatalf("expected allowlist rejection, got %v", err)
+	}
+	if _, err := runProcessTool(t, reg, ctx, map[string]any{"action": "start", "command": "cat /etc/passwd"}); err == nil {
+		t.Fatalf("expected forbidden path rejection")
+	}
+	if list := reg.processes.List("s1"); len(list) != 0 {
+		t.Fatalf("rejected commands must not start: %v", list)
+	}
+}
+
+func TestChildCommandsCannotReadSecrets(t *testing.T) {
+	t.Setenv(config.SecretsPassphraseEnv, "vault-passphrase")
+	t.Setenv(config.SecretsNewPassphraseEnv, "next-passphrase")
+	reg := newProcessToolsRegistry(t)
+	ctx := sessionCtx("s1")
+
+	out, err := reg.Execute(ctx, "shell", `{"command":"env"}`)
+	if err != nil || strings.Contains(out, "passphrase") || !strings.Contains(out, "PATH=") {
+		t.Fatalf("shell env leaked the secrets passphrase: %q %v", out, err)
+	}
+	started, err := runProcessTool(t, reg, ctx, map[string]any{"action": "start", "command": "env"})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	res, _ := runProcessTool(t, reg, ctx, map[string]any{"action": "wait", "id": started["id"], "timeout": 10})
+	if stdout, _ := res["stdout"].(string); strings.Contains(stdout, "passphrase") || !strings.Contains(stdout, "PATH=") {
+		t.Fatalf("process env leaked the secrets passphrase: %q", stdout)
+	}
+
+	keyFile := config.Default().SecretsKeyFile()
+	for _, path := range []string{keyFile, keyFile + ".old", keyFile + ".new"} {
+		raw, _ := json.Marshal(map[string]string{"command": "cat " + path})
+		if _, err := reg.Execute(ctx, "shell", string(raw)); err == nil || !strings.Contains(err.Error(), "forbidden") {
+			t.Fatalf("reading %s should be forbidden, got %v", path, err)
+		}
+	}
+}
+
+func TestRingBufferKeepsTail(t *testing.T) {
+	buf := tools.NewRingBuffer(8)
+	_, _ = buf.Write([]byte("0123456789"))
+	data, next, dropped := buf.ReadSince(0)
+	if string(data) != "23456789" || next != 10 || dropped != 2 {
+		t.Fatalf("unexpected read: %q next=%d dropped=%d", data, next, dropped)
+	}
+	_, _ = buf.Write([]byte("abcdefghij"))
+	data, next, dropped = buf.ReadSince(next)
+	if string(data) != "cdefghij" || next != 20 || dropped != 2 {
+		t.Fatalf("unexpected read: %q next=%d dropped=%d", data, next, dropped)
+	}
+}
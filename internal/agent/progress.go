@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+// 运行进度事件：模型循环中的中间文本和工具调用的开始、输出、结束，
+// 通过 RunRequest.OnProgress 推送给调用方（TUI 工具卡片、CLI 输出），runner 自身不再写 stdout。
+
+// 进度事件类型
+const (
+	ProgressText       = "text"        // 模型随工具调用一起输出的中间文本
+	ProgressToolStart  = "tool_start"  // 工具开始执行
+	ProgressToolOutput = "tool_output" // 工具执行中的输出（按行推送，目前仅 shell）
+	ProgressToolEnd    = "tool_end"    // 工具执行结束
+)
+
+// ProgressEvent 运行中的一条进度事件，文本和工具参数、输出均已做 DLP 脱敏
+type ProgressEvent struct {
+	Kind      string
+	RunID     string
+	Iteration int // 模型循环轮次（从 1 开始）
+	// CallID 工具调用 ID，在一次运行内唯一，关联同一调用的 start / output / end
+	CallID string
+	Tool   string
+	Input  string // tool_start：参数 JSON
+	Text   string // text：中间文本；tool_output：一行输出；tool_end：完整输出
+	// Status tool_end：success | error | denied
+	Status     string
+	Error      string
+	DurationMs int64
+}
+
+// progressReporter 向 OnProgress 推送事件；delegate 并发执行时可能被多个 goroutine 调用
+type progressReporter struct {
+	fn    func(ProgressEvent)
+	runID string
+	dlp   *dlpFilter
+
+	mu        sync.Mutex
+	iteration int
+	calls     int
+}
+
+func newProgressReporter(fn func(ProgressEvent), runID string, dlp *dlpFilter) *progressReporter {
+	if fn == nil {
+		return nil
+	}
+	return &progressReporter{fn: fn, runID: runID, dlp: dlp}
+}
+
+func (p *progressReporter) emit(ev ProgressEvent) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	ev.RunID = p.runID
+	ev.Iteration = p.iteration
+	p.mu.Unlock()
+	p.fn(ev)
+}
+
+// nextIteration 进入下一轮模型调用
+func (p *progressReporter) nextIteration() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.iteration++
+	p.mu.Unlock()
+}
+
+func (p *progressReporter) text(text string) {
+	if p == nil {
+		return
+	}
+	p.emit(ProgressEvent{Kind: ProgressText, Text: p.dlp.mask(text)})
+}
+
+// toolStart 推送工具开始事件，返回该调用的 ID
+func (p *progressReporter) toolStart(call ParsedToolCall) string {
+	if p == nil {
+		return ""
+	}
+	p.mu.Lock()
+	p.calls++
+	id := fmt.Sprintf("call_%d", p.calls)
+	p.mu.Unlock()
+	p.emit(ProgressEvent{Kind: ProgressToolStart, CallID: id, Tool: call.Name, Input: p.dlp.mask(string(call.Arguments))})
+	return id
+}
+
+func (p *progressReporter) toolEnd(id string, tc ToolCall) {
+	if p == nil {
+		return
+	}
+	status := "success"
+	switch {
+	case tc.Policy == PolicyDeny:
+		status = "denied"
+	case tc.Error != "":
+		status = "error"
+	}
+	p.emit(ProgressEvent{
+		Kind:       ProgressToolEnd,
+		CallID:     id,
+		Tool:       tc.Name,
+		Text:       p.dlp.mask(tc.Output),
+		Status:     status,
+		Error:      tc.Error,
+		DurationMs: tc.DurationMs,
+	})
+}
+
+// outputWriter 返回把工具输出按行推送为 tool_output 事件的 writer；
+// 调用方在工具结束后 Flush 推送最后一行不完整的输出
+func (p *progressReporter) outputWriter(id, tool string) *progressWriter {
+	if p == nil {
+		return nil
+	}
+	return &progressWriter{p: p, id: id, tool: tool}
+}
+
+// progressWriter 工具 stdout/stderr 的行缓冲 writer，逐行脱敏后推送，避免密钥被拆在两个片段中漏过 DLP
+type progressWriter struct {
+	p    *progressReporter
+	id   string
+	tool string
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(b)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		line := string(w.buf.Next(i + 1))
+		w.send(line[:len(line)-1])
+	}
+	return len(b), nil
+}
+
+// Flush 推送缓冲中剩余的不完整行
+func (w *progressWriter) Flush() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf.Len() > 0 {
+		w.send(w.buf.String())
+		w.buf.Reset()
+	}
+}
+
+func (w *progressWriter) send(line string) {
+	w.p.emit(ProgressEvent{Kind: ProgressToolOutput, CallID: w.id, Tool: w.tool, Text: w.p.dlp.mask(line)})
+}
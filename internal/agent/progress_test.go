@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+// progressProvider 第一次请求带说明文字的 shell 调用，之后直接回复
+type progressProvider struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *progressProvider) Chat(ctx context.Context, req *ChatRequest, model string) (*ChatResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.calls == 1 {
+		return &ChatResponse{Content: `Listing first.
+<invoke>
+{"name":"shell","arguments":{"command":"echo one; echo two"}}
+</invoke>`}, nil
+	}
+	return &ChatResponse{Content: "done"}, nil
+}
+
+func TestRunEmitsProgressEvents(t *testing.T) {
+	cfg := config.Default()
+	cfg.Agent.Workspace = t.TempDir()
+	cfg.Memory.Backend = "markdown"
+	cfg.Memory.AutoSave = false
+
+	r := NewRunner(cfg, slog.Default())
+	t.Cleanup(r.Close)
+	fake := &progressProvider{}
+	r.models.factory.Register("fake", func(cfg *config.Config) (Provider, error) { return fake, nil })
+
+	var events []ProgressEvent
+	req := &RunRequest{
+		SessionKey: "agent:main:progress", Channel: "cli", Message: "hi", Provider: "fake", Model: "fake/m",
+		OnProgress: func(ev ProgressEvent) { events = append(events, ev) },
+	}
+	res, err := r.Run(context.Background(), req)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	var kinds []string
+	for _, ev := range events {
+		kinds = append(kinds, ev.Kind)
+		if ev.RunID != res.RunID || ev.Iteration != 1 {
+			t.Fatalf("event not tied to run %s iteration 1: %+v", res.RunID, ev)
+		}
+	}
+	want := []string{ProgressText, ProgressToolStart, ProgressToolOutput, ProgressToolOutput, ProgressToolEnd}
+	if len(kinds) != len(want) {
+		t.Fatalf("event kinds = %v; want %v", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("event kinds = %v; want %v", kinds, want)
+		}
+	}
+	start, end := events[1], events[4]
+	if start.Tool != "shell" || start.CallID == "" || start.CallID != end.CallID {
+		t.Fatalf("start/end not linked: %+v %+v", start, end)
+	}
+	if events[2].Text != "one" || events[3].Text != "two" {
+		t.Fatalf("live output = %q %q", events[2].Text, events[3].Text)
+	}
+	if end.Status != "success" || end.Text != "one\ntwo\n" {
+		t.Fatalf("unexpected end event: %+v", end)
+	}
+}
+
+func TestRunStopsWhenCancelled(t *testing.T) {
+	cfg := config.Default()
+	cfg.Agent.Workspace = t.TempDir()
+	cfg.Memory.Backend = "markdown"
+	cfg.Memory.AutoSave = false
+
+	r := NewRunner(cfg, slog.Default())
+	t.Cleanup(r.Close)
+	fake := &progressProvider{}
+	r.models.factory.Register("fake", func(cfg *config.Config) (Provider, error) { return fake, nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := &RunRequest{
+		SessionKey: "agent:main:cancel", Channel: "cli", Message: "hi", Provider: "fake", Model: "fake/m",
+		// 第一个工具开始时取消，本轮工具结束后不应再请求模型
+		OnProgress: func(ev ProgressEvent) {
+			if ev.Kind == ProgressToolStart {
+				cancel()
+			}
+		},
+	}
+	_, err := r.Run(ctx, req)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if fake.calls != 1 {
+		t.Fatalf("model called %d times after cancel; want 1", fake.calls)
+	}
+}
@@ -50,6 +50,32 @@ type ChatRequest struct {
 	Tools         []Tool    `json:"tools,omitempty"`
 	ThinkingLevel string    `json:"-"` // Not sent to API, used for extended thinking
 	Stream        bool      `json:"stream,omitempty"`
+
+	// SystemBlocks, when set, replaces System with content blocks so that
+	// cache_control breakpoints can be attached to the system prompt.
+	SystemBlocks []ContentBlock `json:"-"`
+}
+
+// MarshalJSON sends SystemBlocks as the "system" field when present.
+func (r ChatRequest) MarshalJSON() ([]byte, error) {
+	type alias ChatRequest
+	if len(r.SystemBlocks) == 0 {
+		return json.Marshal(alias(r))
+	}
+	return json.Marshal(struct {
+		alias
+		System []ContentBlock `json:"system"`
+	}{alias(r), r.SystemBlocks})
+}
+
+// CacheControl marks a prompt cache breakpoint.
+type CacheControl struct {
+	Type string `json:"type"` // "ephemeral"
+}
+
+// EphemeralCache returns the default (5 minute) cache breakpoint marker.
+func EphemeralCache() *CacheControl {
+	return &CacheControl{Type: "ephemeral"}
 }
 
 // Message represents a chat message.
@@ -77,6 +103,9 @@ type ContentBlock struct {
 	ToolUseID string `json:"tool_use_id,omitempty"`
 	Content   string `json:"content,omitempty"`
 	IsError   bool   `json:"is_error,omitempty"`
+
+	// Prompt caching breakpoint (text, image, tool_use, tool_result blocks)
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
 }
 
 // ImageSource represents an image in base64 format.
@@ -91,6 +120,8 @@ type Tool struct {
 	Name        string          `json:"name"`
 	Description string          `json:"description"`
 	InputSchema json.RawMessage `json:"input_schema"`
+
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
 }
 
 // ChatResponse represents the response from Anthropic API.
@@ -0,0 +1,40 @@
+package providers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestChatRequestMarshalsCachedSystemBlocks(t *testing.T) {
+	req := ChatRequest{
+		Model:     "claude-sonnet-4",
+		MaxTokens: 1024,
+		System:    "ignored when blocks are set",
+		SystemBlocks: []ContentBlock{
+			{Type: "text", Text: "tools and skills", CacheControl: EphemeralCache()},
+			{Type: "text", Text: "runtime"},
+		},
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var out struct {
+		System []struct {
+			Text         string        `json:"text"`
+			CacheControl *CacheControl `json:"cache_control"`
+		} `json:"system"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("system should be an array of blocks: %v (%s)", err, data)
+	}
+	if len(out.System) != 2 || out.System[0].CacheControl == nil || out.System[0].CacheControl.Type != "ephemeral" || out.System[1].CacheControl != nil {
+		t.Fatalf("unexpected system blocks: %s", data)
+	}
+
+	plain, _ := json.Marshal(ChatRequest{Model: "m", System: "plain"})
+	if !strings.Contains(string(plain), `"system":"plain"`) {
+		t.Fatalf("plain system prompt should stay a string: %s", plain)
+	}
+}
@@ -0,0 +1,297 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 本地模型服务类型
+const (
+	LocalOllama   = "ollama"
+	LocalLlamaCpp = "llamacpp"
+)
+
+// LocalClient 本地模型服务（Ollama / llama.cpp server）客户端：模型发现、能力探测和对话。
+// Ollama 走原生 /api/chat 以支持 keep_alive；llama.cpp 走 OpenAI 兼容接口。
+type LocalClient struct {
+	Kind      string
+	BaseURL   string
+	KeepAlive string
+	client    *http.Client
+}
+
+// NewLocalClient creates a client for a local model server.
+func NewLocalClient(kind, baseURL, keepAlive string) *LocalClient {
+	return &LocalClient{
+		Kind:      kind,
+		BaseURL:   strings.TrimRight(strings.TrimSpace(baseURL), "/"),
+		KeepAlive: strings.TrimSpace(keepAlive),
+		client: &http.Client{
+			// 本地模型首次加载可能较慢
+			Timeout: 300 * time.Second,
+		},
+	}
+}
+
+// LocalModel 本地服务上的一个模型及其探测到的能力
+type LocalModel struct {
+	ID            string
+	Family        string
+	ParameterSize string
+	ContextLength int
+	Tools         bool
+	Vision        bool
+}
+
+// LocalMessage 本地对话消息；Images 为原始图片字节
+type LocalMessage struct {
+	Role    string
+	Content string
+	Images  [][]byte
+}
+
+// LocalChatRequest 本地对话请求
+type LocalChatRequest struct {
+	Model       string
+	Messages    []LocalMessage
+	MaxTokens   int
+	Temperature float64
+}
+
+// LocalChatResponse 本地对话响应
+type LocalChatResponse struct {
+	Content      string
+	InputTokens  int
+	OutputTokens int
+}
+
+// ListModels 列出服务上已安装的模型（Ollama: /api/tags，llama.cpp: /v1/models）
+func (c *LocalClient) ListModels(ctx context.Context) ([]LocalModel, error) {
+	if c.Kind == LocalLlamaCpp {
+		var resp struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/v1/models", nil, &resp); err != nil {
+			return nil, err
+		}
+		out := make([]LocalModel, 0, len(resp.Data))
+		for _, m := range resp.Data {
+			out = append(out, LocalModel{ID: m.ID})
+		}
+		return out, nil
+	}
+
+	var resp struct {
+		Models []struct {
+			Name    string `json:"name"`
+			Details struct {
+				Family        string `json:"family"`
+				ParameterSize string `json:"parameter_size"`
+			} `json:"details"`
+		} `json:"models"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/tags", nil, &resp); err != nil {
+		return nil, err
+	}
+	out := make([]LocalModel, 0, len(resp.Models))
+	for _, m := range resp.Models {
+		out = append(out, LocalModel{ID: m.Name, Family: m.Details.Family, ParameterSize: m.Details.ParameterSize})
+	}
+	return out, nil
+}
+
+// Probe 探测模型的上下文长度、工具调用和图片输入能力
+func (c *LocalClient) Probe(ctx context.Context, model string) (LocalModel, error) {
+	info := LocalModel{ID: model}
+	if c.Kind == LocalLlamaCpp {
+		// llama.cpp server 一次只加载一个模型，/props 描述的就是它
+		var props struct {
+			DefaultGenerationSettings struct {
+				NCtx int `json:"n_ctx"`
+			} `json:"default_generation_settings"`
+			Modalities struct {
+				Vision bool `json:"vision"`
+			} `json:"modalities"`
+			ChatTemplate string `json:"chat_template"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/props", nil, &props); err != nil {
+			return info, err
+		}
+		info.ContextLength = props.DefaultGenerationSettings.NCtx
+		info.Vision = props.Modalities.Vision
+		info.Tools = strings.Contains(props.ChatTemplate, "tools")
+		return info, nil
+	}
+
+	var show struct {
+		Capabilities []string       `json:"capabilities"`
+		Template     string         `json:"template"`
+		ModelInfo    map[string]any `json:"model_info"`
+		Details      struct {
+			Family        string   `json:"family"`
+			Families      []string `json:"families"`
+			ParameterSize string   `json:"parameter_size"`
+		} `json:"details"`
+		ProjectorInfo map[string]any `json:"projector_info"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/show", map[string]string{"model": model}, &show); err != nil {
+		return info, err
+	}
+	info.Family = show.Details.Family
+	info.ParameterSize = show.Details.ParameterSize
+	for k, v := range show.ModelInfo {
+		if strings.HasSuffix(k, ".context_length") {
+			if n, ok := v.(float64); ok {
+				info.ContextLength = int(n)
+			}
+		}
+	}
+	if len(show.Capabilities) > 0 {
+		for _, capability := range show.Capabilities {
+			switch capability {
+			case "tools":
+				info.Tools = true
+			case "vision":
+				info.Vision = true
+			}
+		}
+		return info, nil
+	}
+	// 旧版 Ollama 不返回 capabilities：按模板和多模态投影层推断
+	info.Tools = strings.Contains(show.Template, ".Tools")
+	info.Vision = len(show.ProjectorInfo) > 0
+	for _, f := range show.Details.Families {
+		if f == "clip" || f == "mllama" {
+			info.Vision = true
+		}
+	}
+	return info, nil
+}
+
+// Chat sends a non-streaming chat request to the local server.
+func (c *LocalClient) Chat(ctx context.Context, req *LocalChatRequest) (*LocalChatResponse, error) {
+	if c.Kind == LocalLlamaCpp {
+		return c.chatOpenAI(ctx, req)
+	}
+
+	type ollamaMessage struct {
+		Role    string   `json:"role"`
+		Content string   `json:"content"`
+		Images  []string `json:"images,omitempty"`
+	}
+	body := map[string]any{
+		"model":  req.Model,
+		"stream": false,
+	}
+	messages := make([]ollamaMessage, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		msg := ollamaMessage{Role: m.Role, Content: m.Content}
+		for _, img := range m.Images {
+			msg.Images = append(msg.Images, base64.StdEncoding.EncodeToString(img))
+		}
+		messages = append(messages, msg)
+	}
+	body["messages"] = messages
+	options := map[string]any{}
+	if req.Temperature > 0 {
+		options["temperature"] = req.Temperature
+	}
+	if req.MaxTokens > 0 {
+		options["num_predict"] = req.MaxTokens
+	}
+	if len(options) > 0 {
+		body["options"] = options
+	}
+	if c.KeepAlive != "" {
+		body["keep_alive"] = c.KeepAlive
+	}
+
+	var resp struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		PromptEvalCount int `json:"prompt_eval_count"`
+		EvalCount       int `json:"eval_count"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/chat", body, &resp); err != nil {
+		return nil, err
+	}
+	return &LocalChatResponse{Content: resp.Message.Content, InputTokens: resp.PromptEvalCount, OutputTokens: resp.EvalCount}, nil
+}
+
+// chatOpenAI llama.cpp 的 /v1/chat/completions，图片按 OpenAI 多模态格式传入
+func (c *LocalClient) chatOpenAI(ctx context.Context, req *LocalChatRequest) (*LocalChatResponse, error) {
+	messages := make([]OpenAIMessage, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		if len(m.Images) == 0 {
+			messages = append(messages, OpenAIMessage{Role: m.Role, Content: m.Content})
+			continue
+		}
+		parts := []map[string]any{{"type": "text", "text": m.Content}}
+		for _, img := range m.Images {
+			url := "data:" + http.DetectContentType(img) + ";base64," + base64.StdEncoding.EncodeToString(img)
+			parts = append(parts, map[string]any{"type": "image_url", "image_url": map[string]string{"url": url}})
+		}
+		messages = append(messages, OpenAIMessage{Role: m.Role, Content: parts})
+	}
+	var resp OpenAIChatResponse
+	err := c.do(ctx, http.MethodPost, "/v1/chat/completions", &OpenAIChatRequest{
+		Model:       req.Model,
+		Messages:    messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	out := &LocalChatResponse{InputTokens: resp.Usage.PromptTokens, OutputTokens: resp.Usage.CompletionTokens}
+	if len(resp.Choices) > 0 {
+		if s, ok := resp.Choices[0].Message.Content.(string); ok {
+			out.Content = s
+		}
+	}
+	return out, nil
+}
+
+func (c *LocalClient) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("marshal request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	if in != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("%s server not reachable at %s: %w", c.Kind, c.BaseURL, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp.StatusCode, string(data))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("unmarshal response: %w", err)
+	}
+	return nil
+}
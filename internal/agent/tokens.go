@@ -0,0 +1,155 @@
+package agent
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktokenloader "github.com/pkoukk/tiktoken-go-loader"
+
+	"github.com/highclaw/highclaw/internal/domain/model"
+)
+
+const (
+	// 每条消息的固定开销（role、分隔符），与 OpenAI cookbook 的估算一致
+	tokensPerMessage = 4
+	// 输出预留：默认 max_tokens 4096 + 余量
+	outputReserveTokens = 4096 + 1024
+
+	// 各段预算占输入预算的比例（系统提示词核心部分不设上限）
+	budgetShareBootstrap = 0.15
+	budgetShareSkills    = 0.10
+	budgetShareMemory    = 0.05
+
+	// history 超过预算的该比例时触发压缩，压缩后保留不超过预算一半的最近消息
+	compactionTriggerRatio = 0.75
+	compactionKeepRatio    = 0.5
+)
+
+var (
+	encoderOnce sync.Once
+	encoders    map[string]*tiktoken.Tiktoken
+)
+
+// tokenEncoder 返回模型对应的 BPE 编码器。OpenAI 新模型用 o200k_base，
+// 其余（Claude、GLM、DeepSeek 等没有公开 tokenizer 的模型）用 cl100k_base 近似。
+func tokenEncoder(modelRef string) *tiktoken.Tiktoken {
+	encoderOnce.Do(func() {
+		tiktoken.SetBpeLoader(tiktokenloader.NewOfflineLoader())
+		encoders = make(map[string]*tiktoken.Tiktoken, 2)
+		for _, name := range []string{tiktoken.MODEL_CL100K_BASE, tiktoken.MODEL_O200K_BASE} {
+			if enc, err := tiktoken.GetEncoding(name); err == nil {
+				encoders[name] = enc
+			}
+		}
+	})
+	id := strings.ToLower(modelRef)
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		id = id[i+1:]
+	}
+	for _, prefix := range []string{"gpt-4o", "gpt-4.1", "gpt-5", "o1", "o3", "o4"} {
+		if strings.HasPrefix(id, prefix) {
+			if enc := encoders[tiktoken.MODEL_O200K_BASE]; enc != nil {
+				return enc
+			}
+		}
+	}
+	return encoders[tiktoken.MODEL_CL100K_BASE]
+}
+
+// countTokens 用 tokenizer 计算文本 token 数；编码器不可用时退回 chars/4 估算
+func countTokens(modelRef, text string) int {
+	if text == "" {
+		return 0
+	}
+	if enc := tokenEncoder(modelRef); enc != nil {
+		return len(enc.EncodeOrdinary(text))
+	}
+	return (len([]rune(text)) + 3) / 4
+}
+
+// countMessagesTokens 计算消息列表的 token 数（含每条消息的固定开销）
+func countMessagesTokens(modelRef string, messages []ChatMessage) int {
+	total := 0
+	for _, m := range messages {
+		total += tokensPerMessage + countTokens(modelRef, m.Content)
+	}
+	return total
+}
+
+// truncateToTokens 把文本截断到 maxTokens 以内（按 token 边界解码，不会切坏多字节字符）
+func truncateToTokens(modelRef, text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	enc := tokenEncoder(modelRef)
+	if enc == nil {
+		return truncateWithEllipsis(text, maxTokens*4)
+	}
+	ids := enc.EncodeOrdinary(text)
+	if len(ids) <= maxTokens {
+		return text
+	}
+	out := enc.Decode(ids[:maxTokens])
+	return strings.ToValidUTF8(out, "") + "…"
+}
+
+// contextBudget 按模型上下文窗口在系统提示词各段、记忆上下文和历史之间分配 token
+type contextBudget struct {
+	Model     string
+	Window    int
+	Input     int // 可用于输入的 token（窗口 - 输出预留）
+	Bootstrap int // Project Context（bootstrap 文件）上限
+	Skills    int // skills 段上限
+	Memory    int // 记忆上下文上限
+}
+
+func newContextBudget(modelRef string) contextBudget {
+	window := model.ContextWindow(modelRef)
+	reserve := outputReserveTokens
+	if reserve > window/4 {
+		reserve = window / 4
+	}
+	input := window - reserve
+	return contextBudget{
+		Model:     modelRef,
+		Window:    window,
+		Input:     input,
+		Bootstrap: int(float64(input) * budgetShareBootstrap),
+		Skills:    int(float64(input) * budgetShareSkills),
+		Memory:    int(float64(input) * budgetShareMemory),
+	}
+}
+
+// historyBudget 扣除系统提示词后留给对话历史的 token
+func (b contextBudget) historyBudget(systemTokens int) int {
+	h := b.Input - systemTokens
+	if h < 1024 {
+		h = 1024
+	}
+	return h
+}
+
+// fitHistory 从最早的非 system 消息开始丢弃，直到历史不超过 budget；始终保留最后一条消息
+func fitHistory(modelRef string, history []ChatMessage, budget int) []ChatMessage {
+	if len(history) == 0 {
+		return history
+	}
+	start := 0
+	if strings.EqualFold(strings.TrimSpace(history[0].Role), "system") {
+		start = 1
+	}
+	total := countMessagesTokens(modelRef, history)
+	drop := 0
+	for total > budget && start+drop < len(history)-1 {
+		total -= tokensPerMessage + countTokens(modelRef, history[start+drop].Content)
+		drop++
+	}
+	if drop == 0 {
+		return history
+	}
+	trimmed := make([]ChatMessage, 0, len(history)-drop)
+	trimmed = append(trimmed, history[:start]...)
+	trimmed = append(trimmed, history[start+drop:]...)
+	return trimmed
+}
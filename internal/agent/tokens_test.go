@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/highclaw/highclaw/internal/domain/model"
+)
+
+func TestContextWindowLookup(t *testing.T) {
+	cases := map[string]int{
+		"anthropic/claude-sonnet-4-20250514": 200000,
+		"claude-3-5-haiku-latest":            200000,
+		"openai/gpt-4o-mini":                 128000,
+		"unknown/some-model":                 model.DefaultContextWindow,
+	}
+	for ref, want := range cases {
+		if got := model.ContextWindow(ref); got != want {
+			t.Fatalf("ContextWindow(%q)=%d, want %d", ref, got, want)
+		}
+	}
+}
+
+func TestCountAndTruncateTokens(t *testing.T) {
+	text := strings.Repeat("hello world ", 200)
+	n := countTokens("openai/gpt-4o", text)
+	if n < 300 || n > 500 {
+		t.Fatalf("unexpected token count %d", n)
+	}
+	cut := truncateToTokens("openai/gpt-4o", text, 50)
+	if got := countTokens("openai/gpt-4o", cut); got > 52 {
+		t.Fatalf("truncated text still has %d tokens", got)
+	}
+	if truncateToTokens("openai/gpt-4o", "short", 50) != "short" {
+		t.Fatalf("short text should be unchanged")
+	}
+	// 多字节字符截断后仍是合法 UTF-8
+	cn := truncateToTokens("anthropic/claude-sonnet-4", strings.Repeat("中文上下文预算", 100), 10)
+	if !strings.HasSuffix(cn, "…") || strings.ContainsRune(cn, '�') {
+		t.Fatalf("unexpected truncation: %q", cn)
+	}
+}
+
+func TestFitHistoryDropsOldestFirst(t *testing.T) {
+	long := strings.Repeat("token ", 400)
+	history := []ChatMessage{
+		{Role: "system", Content: "summary"},
+		{Role: "user", Content: long},
+		{Role: "assistant", Content: long},
+		{Role: "user", Content: "latest question"},
+	}
+	got := fitHistory("gpt-4o", history, 500)
+	if len(got) != 3 || got[0].Role != "system" || got[2].Content != "latest question" {
+		t.Fatalf("unexpected fitted history: %+v", got)
+	}
+	if got := fitHistory("gpt-4o", history, 100000); len(got) != len(history) {
+		t.Fatalf("history within budget should be unchanged")
+	}
+	if got := fitHistory("gpt-4o", history[3:], 1); len(got) != 1 {
+		t.Fatalf("last message must always be kept")
+	}
+}
+
+func TestContextBudgetShares(t *testing.T) {
+	b := newContextBudget("anthropic/claude-sonnet-4")
+	if b.Window != 200000 || b.Input != 200000-outputReserveTokens {
+		t.Fatalf("unexpected budget: %+v", b)
+	}
+	if b.Bootstrap <= b.Skills || b.Skills <= b.Memory || b.Memory == 0 {
+		t.Fatalf("unexpected section shares: %+v", b)
+	}
+	if h := b.historyBudget(b.Input); h != 1024 {
+		t.Fatalf("history budget should have a floor, got %d", h)
+	}
+}
+
+func TestSystemPromptCacheSplit(t *testing.T) {
+	prompt := "You are HighClaw.\n\n## Tools\n\n...\n\n" + promptSafetyHeading + "be safe"
+	split := systemPromptCacheSplit(prompt)
+	if split <= 0 || !strings.HasPrefix(prompt[split:], promptSafetyHeading) {
+		t.Fatalf("unexpected split %d", split)
+	}
+	if systemPromptCacheSplit("no headings") != 0 {
+		t.Fatalf("expected no split")
+	}
+}
@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 工具调用审计：每次执行记录策略决定、风险级别、审批状态、耗时和输出摘要，
+// 随 RunResult.ToolCalls 返回，由调用方写入 tasklog 并关联到父聊天记录。
+
+// 策略决定
+const (
+	PolicyAllow = "allow"
+	PolicyDeny  = "deny"
+)
+
+// mediumRiskTools 会修改工作区文件或删除记忆的工具
+var mediumRiskTools = map[string]bool{
+	"write_file":       true,
+	"edit_file":        true,
+	"undo_file_change": true,
+	"memory_forget":    true,
+}
+
+// policyFileErrors 文件工具中由安全策略（而非文件本身）产生的错误码
+var policyFileErrors = map[string]bool{
+	"forbidden_path":    true,
+	"outside_workspace": true,
+	"read_only":         true,
+}
+
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("run_%d", time.Now().UnixNano())
+	}
+	return "run_" + hex.EncodeToString(b)
+}
+
+// toolRisk 工具调用的风险级别：shell 命令按 shellRules 评估，其余按工具类型
+func (r *Runner) toolRisk(call ParsedToolCall) string {
+	var in struct {
+		Action  string `json:"action"`
+		Command string `json:"command"`
+	}
+	_ = json.Unmarshal(call.Arguments, &in)
+	switch {
+	case call.Name == "shell" || call.Name == "bash":
+		return r.tools.policy.CommandRisk(in.Command)
+	case call.Name == "process" && strings.EqualFold(strings.TrimSpace(in.Action), "start"):
+		return r.tools.policy.CommandRisk(in.Command)
+	case mediumRiskTools[call.Name]:
+		return "medium"
+	}
+	return "low"
+}
+
+// auditDecision 从工具错误中识别策略拒绝，返回策略决定和审批状态
+func auditDecision(err error) (policy, approval string) {
+	var denial *policyDenial
+	if errors.As(err, &denial) {
+		return PolicyDeny, denial.approval
+	}
+	var fe *fileToolError
+	if errors.As(err, &fe) && policyFileErrors[fe.Code] {
+		return PolicyDeny, ""
+	}
+	return PolicyAllow, ""
+}
+
+// OutputHash 工具输出的 sha256 摘要（截取前 16 位十六进制），审计时用于比对输出而不保存全文
+func OutputHash(output string) string {
+	sum := sha256.Sum256([]byte(output))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+// auditProvider 第一次请求两个工具（一个被策略拒绝），之后按 fail 决定回复或失败
+type auditProvider struct {
+	mu    sync.Mutex
+	calls int
+	fail  bool
+}
+
+func (p *auditProvider) Chat(ctx context.Context, req *ChatRequest, model string) (*ChatResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.calls%2 == 1 {
+		return &ChatResponse{Content: `<invoke>
+{"name":"shell","arguments":{"command":"git push --force origin main"}}
+</invoke>
+<invoke>
+{"name":"memory_store","arguments":{"key":"k","content":"v"}}
+</invoke>`}, nil
+	}
+	if p.fail {
+		return nil, errors.New("upstream down")
+	}
+	return &ChatResponse{Content: "done"}, nil
+}
+
+func TestRunReturnsToolCallAudit(t *testing.T) {
+	cfg := config.Default()
+	cfg.Agent.Workspace = t.TempDir()
+	cfg.Memory.Backend = "markdown"
+	cfg.Memory.AutoSave = false
+	cfg.Reliability.ProviderBackoffMs = 1
+	cfg.Reliability.ProviderRetries = 0
+
+	r := NewRunner(cfg, slog.Default())
+	t.Cleanup(r.Close)
+	fake := &auditProvider{}
+	r.models.factory.Register("fake", func(cfg *config.Config) (Provider, error) { return fake, nil })
+	req := &RunRequest{SessionKey: "agent:main:audit", Channel: "cli", Message: "hi", Provider: "fake", Model: "fake/m"}
+
+	res, err := r.Run(context.Background(), req)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.RunID == "" || len(res.ToolCalls) != 2 {
+		t.Fatalf("expected run id and 2 tool calls, got %q %d", res.RunID, len(res.ToolCalls))
+	}
+	shell, mem := res.ToolCalls[0], res.ToolCalls[1]
+	if shell.Policy != PolicyDeny || shell.Risk != "high" || shell.Error == "" {
+		t.Fatalf("force push should be denied as high risk: %+v", shell)
+	}
+	if mem.Policy != PolicyAllow || mem.Risk != "low" || mem.Error != "" || mem.OutputHash != OutputHash(mem.Output) || len(mem.OutputHash) != 16 {
+		t.Fatalf("unexpected memory_store audit: %+v", mem)
+	}
+
+	fake.fail = true
+	_, err = r.Run(context.Background(), req)
+	var runErr *RunError
+	if !errors.As(err, &runErr) || runErr.RunID == "" || runErr.RunID == res.RunID || len(runErr.ToolCalls) != 2 {
+		t.Fatalf("failed run should carry its tool calls: %#v", err)
+	}
+}
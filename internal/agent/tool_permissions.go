@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+// toolGroups 权限配置里可用的工具分组名
+var toolGroups = map[string][]string{
+	"shell":  {"shell", "bash", "process"},
+	"file":   {"read_file", "write_file", "edit_file", "list_dir", "glob", "grep", "undo_file_change"},
+	"web":    {"web_search", "web_fetch"},
+	"memory": {"memory_store", "memory_recall", "memory_forget"},
+	"skill":  {"skill_read"},
+}
+
+// toolPatternMatches 判断工具名是否命中一项权限配置：分组名、精确名或 glob
+func toolPatternMatches(pattern, name string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if pattern == "" {
+		return false
+	}
+	if members, ok := toolGroups[pattern]; ok {
+		for _, m := range members {
+			if m == name {
+				return true
+			}
+		}
+		return false
+	}
+	if pattern == name {
+		return true
+	}
+	ok, _ := filepath.Match(pattern, name)
+	return ok
+}
+
+func toolListMatches(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if toolPatternMatches(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// toolPermitted allow 为空表示全部允许；deny 优先
+func toolPermitted(allow, deny []string, name string) bool {
+	if len(allow) > 0 && !toolListMatches(allow, name) {
+		return false
+	}
+	return !toolListMatches(deny, name)
+}
+
+// view 返回只包含 keep 命中工具的注册表副本，其余字段（策略、记忆、进程）共享
+func (r *ToolRegistry) view(keep func(name string) bool) *ToolRegistry {
+	sub := *r
+	sub.tools = make(map[string]ToolSpec, len(r.tools))
+	for name, spec := range r.tools {
+		if keep(name) {
+			sub.tools[name] = spec
+		}
+	}
+	return &sub
+}
+
+// toolProfile 返回本次运行的工具权限档；名称为空表示不额外限制。
+// 规则里引用了不存在的档时拒绝全部工具，避免配置写错导致放开权限。
+func (r *Runner) toolProfile(req *RunRequest) (string, config.ToolPermissionProfile) {
+	name := r.cfg.ToolProfileFor(config.ToolPeer{
+		Channel:  req.Channel,
+		PeerKind: req.PeerKind,
+		GroupID:  req.GroupID,
+		Sender:   req.Sender,
+	})
+	if name == "" {
+		return "", config.ToolPermissionProfile{}
+	}
+	profile, ok := r.cfg.ToolPermissionProfileByName(name)
+	if !ok {
+		r.logger.Warn("unknown tool permission profile, all tools denied", "profile", name, "session", req.SessionKey)
+		return name, config.ToolPermissionProfile{Deny: []string{"*"}}
+	}
+	return name, profile
+}
+
+// toolsFor 返回本次运行对模型可见、可执行的工具集：
+// agent.sandbox.allow/deny 对所有会话生效，再叠加按渠道/会话类型/发送者选出的权限档。
+func (r *Runner) toolsFor(req *RunRequest) *ToolRegistry {
+	// 本地模型不支持工具调用时不提供任何工具
+	if caps, ok := r.localCaps(req); ok && !caps.Tools {
+		r.logger.Debug("local model does not support tool calling, tools disabled", "model", caps.ID)
+		return r.tools.view(func(string) bool { return false })
+	}
+	sandbox := r.cfg.Agent.Sandbox
+	_, profile := r.toolProfile(req)
+	if len(sandbox.Allow) == 0 && len(sandbox.Deny) == 0 && len(profile.Allow) == 0 && len(profile.Deny) == 0 {
+		return r.tools
+	}
+	return r.tools.view(func(name string) bool {
+		return toolPermitted(sandbox.Allow, sandbox.Deny, name) && toolPermitted(profile.Allow, profile.Deny, name)
+	})
+}
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+func newPermissionRunner(t *testing.T) *Runner {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Agent.Workspace = t.TempDir()
+	cfg.Memory.Backend = "markdown"
+	cfg.Agent.Sandbox.Deny = []string{"web"}
+	cfg.Agent.ToolPermissions = config.ToolPermissionsConfig{
+		Profiles: map[string]config.ToolPermissionProfile{
+			"chat": {Allow: []string{"memory_*", "skill_read"}},
+		},
+		Rules: []config.ToolPermissionRule{
+			{Channel: "feishu", PeerKind: "group", Senders: []string{"ou_admin"}, Profile: "full"},
+			{Channel: "telegram", Profile: "chat"},
+		},
+	}
+	r := NewRunner(cfg, slog.Default())
+	t.Cleanup(r.Close)
+	return r
+}
+
+func toolNames(reg *ToolRegistry) string {
+	var names []string
+	for name := range reg.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func TestToolPermissionProfiles(t *testing.T) {
+	r := newPermissionRunner(t)
+
+	direct := r.toolsFor(&RunRequest{Channel: "feishu", Sender: "ou_user", PeerKind: "direct"})
+	if !direct.Has("shell") || !direct.Has("edit_file") || direct.Has("web_fetch") {
+		t.Fatalf("direct chat: sandbox.deny only, got %s", toolNames(direct))
+	}
+
+	group := r.toolsFor(&RunRequest{Channel: "feishu", Sender: "ou_user", PeerKind: "group"})
+	if group.Has("shell") || group.Has("bash") || group.Has("process") || !group.Has("read_file") {
+		t.Fatalf("group chat must not get a shell, got %s", toolNames(group))
+	}
+
+	admin := r.toolsFor(&RunRequest{Channel: "feishu", Sender: "ou_admin", PeerKind: "group"})
+	if !admin.Has("shell") || admin.Has("web_search") {
+		t.Fatalf("allowlisted sender should get full profile minus sandbox.deny, got %s", toolNames(admin))
+	}
+
+	if got := toolNames(r.toolsFor(&RunRequest{Channel: "telegram", PeerKind: "direct"})); got != "memory_forget,memory_recall,memory_store,skill_read" {
+		t.Fatalf("unexpected chat profile tools: %s", got)
+	}
+}
+
+func TestDeniedToolsHiddenAndNotExecutable(t *testing.T) {
+	r := newPermissionRunner(t)
+	req := &RunRequest{Channel: "feishu", Sender: "ou_user", PeerKind: "group"}
+
+	prompt := r.buildSystemPrompt(req)
+	if strings.Contains(prompt, "**shell**") || !strings.Contains(prompt, "**read_file**") {
+		t.Fatalf("system prompt should list only permitted tools")
+	}
+
+	out := r.executeToolCall(context.Background(), r.toolsFor(req), ParsedToolCall{Name: "shell", Arguments: []byte(`{"command":"echo hi"}`)}, nil)
+	if !strings.Contains(out.Output, "not permitted") || out.Policy != PolicyDeny {
+		t.Fatalf("expected permission error, got %+v", out)
+	}
+}
+
+func TestUnknownToolProfileDeniesAll(t *testing.T) {
+	r := newPermissionRunner(t)
+	r.cfg.Agent.ToolPermissions.Default = "missing"
+	if got := toolNames(r.toolsFor(&RunRequest{Channel: "cli"})); got != "" {
+		t.Fatalf("unknown profile must deny all tools, got %s", got)
+	}
+}
@@ -6,10 +6,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"os"
 	"os/exec"
 	"strings"
 	"time"
+
+	"github.com/highclaw/highclaw/internal/config"
 )
 
 // BashInput represents the input to the bash tool.
@@ -50,10 +52,11 @@ func Bash(ctx context.Context, inputJSON string) (string, error) {
 
 	// Execute command via shell
 	cmd := exec.CommandContext(execCtx, "sh", "-c", input.Command)
+	cmd.Env = childEnv()
 
 	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	cmd.Stdout = outputTee(ctx, &stdout)
+	cmd.Stderr = outputTee(ctx, &stderr)
 
 	err := cmd.Run()
 	out := stdout.String()
@@ -75,105 +78,16 @@ func Bash(ctx context.Context, inputJSON string) (string, error) {
 	return errOut, nil
 }
 
-// BashProcess manages a long-running bash process.
-type BashProcess struct {
-	ID      string
-	Command string
-	Cmd     *exec.Cmd
-	Started time.Time
-	Stdin   io.WriteCloser
-}
-
-var processes = make(map[string]*BashProcess)
-
-// BashProcessInput represents input for bash_process tool.
-type BashProcessInput struct {
-	Action  string `json:"action"` // "start", "send", "kill"
-	ID      string `json:"id,omitempty"`
-	Command string `json:"command,omitempty"`
-	Input   string `json:"input,omitempty"`
-}
-
-// BashProcessTool manages background bash processes.
-func BashProcessTool(ctx context.Context, inputJSON string) (string, error) {
-	var input BashProcessInput
-	if err := json.Unmarshal([]byte(inputJSON), &input); err != nil {
-		return "", fmt.Errorf("invalid bash_process input: %w", err)
-	}
-
-	switch input.Action {
-	case "start":
-		return startProcess(input.Command)
-	case "send":
-		return sendToProcess(input.ID, input.Input)
-	case "kill":
-		return killProcess(input.ID)
-	default:
-		return "", fmt.Errorf("unknown action: %s", input.Action)
-	}
-}
-
-func startProcess(command string) (string, error) {
-	if command == "" {
-		return "", fmt.Errorf("command is required")
-	}
-
-	id := fmt.Sprintf("proc-%d", time.Now().UnixNano())
-	cmd := exec.Command("sh", "-c", command)
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return "", fmt.Errorf("create stdin pipe: %w", err)
-	}
-
-	if err := cmd.Start(); err != nil {
-		return "", fmt.Errorf("start process: %w", err)
-	}
-
-	processes[id] = &BashProcess{
-		ID:      id,
-		Command: command,
-		Cmd:     cmd,
-		Started: time.Now(),
-		Stdin:   stdin,
-	}
-
-	return fmt.Sprintf(`{"id": "%s", "status": "started"}`, id), nil
-}
-
-func sendToProcess(id, input string) (string, error) {
-	proc, ok := processes[id]
-	if !ok {
-		return "", fmt.Errorf("process not found: %s", id)
-	}
-
-	if proc.Stdin == nil {
-		return "", fmt.Errorf("process stdin is not available: %s", id)
-	}
-	if _, err := io.WriteString(proc.Stdin, input); err != nil {
-		return "", fmt.Errorf("write stdin: %w", err)
-	}
-	if input == "" || !strings.HasSuffix(input, "\n") {
-		if _, err := io.WriteString(proc.Stdin, "\n"); err != nil {
-			return "", fmt.Errorf("write newline: %w", err)
+// childEnv 子命令使用的环境变量：去掉 secrets 口令，否则 env / printenv 能直接打印出来，静态加密形同虚设
+func childEnv() []string {
+	env := os.Environ()
+	out := make([]string, 0, len(env))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if name == config.SecretsPassphraseEnv || name == config.SecretsNewPassphraseEnv {
+			continue
 		}
+		out = append(out, kv)
 	}
-
-	return fmt.Sprintf(`{"id": "%s", "status": "sent"}`, id), nil
-}
-
-func killProcess(id string) (string, error) {
-	proc, ok := processes[id]
-	if !ok {
-		return "", fmt.Errorf("process not found: %s", id)
-	}
-
-	if err := proc.Cmd.Process.Kill(); err != nil {
-		return "", fmt.Errorf("kill process: %w", err)
-	}
-	if proc.Stdin != nil {
-		_ = proc.Stdin.Close()
-	}
-
-	delete(processes, id)
-	return fmt.Sprintf(`{"id": "%s", "status": "killed"}`, id), nil
+	return out
 }
@@ -0,0 +1,21 @@
+package tools
+
+import (
+	"context"
+	"io"
+)
+
+type outputKey struct{}
+
+// WithOutput 让支持流式输出的工具（shell）在执行过程中把 stdout/stderr 同步写入 w
+func WithOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, outputKey{}, w)
+}
+
+// outputTee 返回 buf 与 ctx 中输出流的组合 writer；ctx 未设置输出流时原样返回 buf
+func outputTee(ctx context.Context, buf io.Writer) io.Writer {
+	if w, ok := ctx.Value(outputKey{}).(io.Writer); ok && w != nil {
+		return io.MultiWriter(buf, w)
+	}
+	return buf
+}
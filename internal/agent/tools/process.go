@@ -0,0 +1,470 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultProcessBufferSize 每路输出（stdout/stderr）保留的字节数
+	DefaultProcessBufferSize = 64 * 1024
+	// DefaultProcessIdleTimeout 无交互多久后结束进程
+	DefaultProcessIdleTimeout = 30 * time.Minute
+	// DefaultMaxProcessesPerOwner 每个会话同时运行的进程上限
+	DefaultMaxProcessesPerOwner = 4
+
+	// 发送 TERM 后等待退出的时间，超时再 KILL
+	processKillGrace = 3 * time.Second
+	// 已退出进程保留多久供 read/wait 读取剩余输出
+	processExitedRetention = 10 * time.Minute
+	processReapInterval    = 30 * time.Second
+)
+
+// ErrProcessNotFound is returned when a process id is unknown or belongs to another session.
+var ErrProcessNotFound = errors.New("process not found")
+
+// RingBuffer keeps the most recent Size bytes written to it and tracks the
+// total number of bytes ever written, so readers can resume from an offset.
+type RingBuffer struct {
+	mu    sync.Mutex
+	size  int
+	data  []byte
+	total int64
+}
+
+// NewRingBuffer creates a ring buffer holding at most size bytes.
+func NewRingBuffer(size int) *RingBuffer {
+	if size <= 0 {
+		size = DefaultProcessBufferSize
+	}
+	return &RingBuffer{size: size}
+}
+
+// Write implements io.Writer.
+func (b *RingBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	b.total += int64(len(p))
+	// 超过两倍容量再整体搬移，摊销拷贝开销
+	if len(b.data) > 2*b.size {
+		b.data = append([]byte(nil), b.data[len(b.data)-b.size:]...)
+	}
+	return len(p), nil
+}
+
+// ReadSince returns the bytes written after offset, the offset to resume from,
+// and how many bytes after offset were already overwritten.
+func (b *RingBuffer) ReadSince(offset int64) (data []byte, next int64, dropped int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	start := b.total - int64(len(b.data))
+	if kept := b.total - int64(b.size); kept > start {
+		start = kept
+	}
+	if offset < start {
+		dropped = start - offset
+		offset = start
+	}
+	if offset > b.total {
+		offset = b.total
+	}
+	from := len(b.data) - int(b.total-offset)
+	return append([]byte(nil), b.data[from:]...), b.total, dropped
+}
+
+// ManagedProcess is a background command started by the supervisor.
+type ManagedProcess struct {
+	ID      string
+	Owner   string
+	Command string
+	Dir     string
+	Started time.Time
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *RingBuffer
+	stderr *RingBuffer
+	done   chan struct{}
+
+	mu         sync.Mutex
+	lastActive time.Time
+	ended      time.Time
+	exitCode   int
+	exitErr    string
+	stdoutRead int64
+	stderrRead int64
+}
+
+// ProcessStatus is a snapshot of a managed process.
+type ProcessStatus struct {
+	ID       string    `json:"id"`
+	PID      int       `json:"pid"`
+	Command  string    `json:"command"`
+	Running  bool      `json:"running"`
+	ExitCode *int      `json:"exitCode,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Started  time.Time `json:"started"`
+	Uptime   string    `json:"uptime"`
+}
+
+// ProcessOutput is new output since the previous read plus the process status.
+type ProcessOutput struct {
+	ProcessStatus
+	Stdout  string `json:"stdout"`
+	Stderr  string `json:"stderr"`
+	Dropped int64  `json:"droppedBytes,omitempty"`
+}
+
+func (p *ManagedProcess) running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (p *ManagedProcess) touch() {
+	p.mu.Lock()
+	p.lastActive = time.Now()
+	p.mu.Unlock()
+}
+
+func (p *ManagedProcess) status() ProcessStatus {
+	st := ProcessStatus{ID: p.ID, Command: p.Command, Started: p.Started, Running: p.running()}
+	if p.cmd.Process != nil {
+		st.PID = p.cmd.Process.Pid
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	end := time.Now()
+	if !st.Running {
+		code := p.exitCode
+		st.ExitCode = &code
+		st.Error = p.exitErr
+		end = p.ended
+	}
+	st.Uptime = end.Sub(p.Started).Round(time.Second).String()
+	return st
+}
+
+// readNew 返回上次读取之后的新输出并推进读游标
+func (p *ManagedProcess) readNew() ProcessOutput {
+	out := ProcessOutput{ProcessStatus: p.status()}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stdout, next, d1 := p.stdout.ReadSince(p.stdoutRead)
+	p.stdoutRead = next
+	stderr, next, d2 := p.stderr.ReadSince(p.stderrRead)
+	p.stderrRead = next
+	out.Stdout, out.Stderr, out.Dropped = string(stdout), string(stderr), d1+d2
+	p.lastActive = time.Now()
+	return out
+}
+
+// ProcessOptions configures a ProcessSupervisor. Zero values use the defaults.
+type ProcessOptions struct {
+	IdleTimeout time.Duration
+	BufferSize  int
+	MaxPerOwner int
+}
+
+// ProcessSupervisor runs background commands for agent sessions. Output is
+// captured into ring buffers, each process belongs to one session, idle
+// processes are reaped, and everything is killed on Shutdown.
+type ProcessSupervisor struct {
+	opts ProcessOptions
+
+	mu    sync.Mutex
+	procs map[string]*ManagedProcess
+	seq   int
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// NewProcessSupervisor creates a supervisor and starts its idle reaper.
+func NewProcessSupervisor(opts ProcessOptions) *ProcessSupervisor {
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultProcessIdleTimeout
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultProcessBufferSize
+	}
+	if opts.MaxPerOwner <= 0 {
+		opts.MaxPerOwner = DefaultMaxProcessesPerOwner
+	}
+	s := &ProcessSupervisor{
+		opts:  opts,
+		procs: make(map[string]*ManagedProcess),
+		stop:  make(chan struct{}),
+	}
+	go s.reapLoop()
+	return s
+}
+
+// Start launches command via sh -c in dir on behalf of owner.
+func (s *ProcessSupervisor) Start(owner, command, dir string) (ProcessStatus, error) {
+	s.mu.Lock()
+	select {
+	case <-s.stop:
+		s.mu.Unlock()
+		return ProcessStatus{}, fmt.Errorf("process supervisor is shut down")
+	default:
+	}
+	running := 0
+	for _, p := range s.procs {
+		if p.Owner == owner && p.running() {
+			running++
+		}
+	}
+	if running >= s.opts.MaxPerOwner {
+		s.mu.Unlock()
+		return ProcessStatus{}, fmt.Errorf("too many background processes (limit %d); kill one first", s.opts.MaxPerOwner)
+	}
+	s.seq++
+	id := fmt.Sprintf("p%d", s.seq)
+	s.mu.Unlock()
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = dir
+	cmd.Env = childEnv()
+	setProcessGroup(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return ProcessStatus{}, fmt.Errorf("stdin pipe: %w", err)
+	}
+	now := time.Now()
+	p := &ManagedProcess{
+		ID:         id,
+		Owner:      owner,
+		Command:    command,
+		Dir:        dir,
+		Started:    now,
+		cmd:        cmd,
+		stdin:      stdin,
+		stdout:     NewRingBuffer(s.opts.BufferSize),
+		stderr:     NewRingBuffer(s.opts.BufferSize),
+		done:       make(chan struct{}),
+		lastActive: now,
+	}
+	cmd.Stdout = p.stdout
+	cmd.Stderr = p.stderr
+	if err := cmd.Start(); err != nil {
+		return ProcessStatus{}, fmt.Errorf("start process: %w", err)
+	}
+
+	go func() {
+		err := cmd.Wait()
+		p.mu.Lock()
+		p.ended = time.Now()
+		p.exitCode = cmd.ProcessState.ExitCode()
+		if err != nil && p.exitCode < 0 {
+			p.exitErr = err.Error()
+		}
+		p.mu.Unlock()
+		close(p.done)
+	}()
+
+	s.mu.Lock()
+	select {
+	case <-s.stop:
+		// 启动期间被 Shutdown，不能留下无人管理的进程
+		s.mu.Unlock()
+		s.terminate(p)
+		return ProcessStatus{}, fmt.Errorf("process supervisor is shut down")
+	default:
+	}
+	s.procs[id] = p
+	s.mu.Unlock()
+	return p.status(), nil
+}
+
+// get 按 id 查找，并校验归属会话；其他会话的进程视为不存在
+func (s *ProcessSupervisor) get(owner, id string) (*ManagedProcess, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.procs[strings.TrimSpace(id)]
+	if !ok || p.Owner != owner {
+		return nil, fmt.Errorf("%w: %s", ErrProcessNotFound, id)
+	}
+	return p, nil
+}
+
+// Read returns output produced since the previous Read or Wait.
+func (s *ProcessSupervisor) Read(owner, id string) (ProcessOutput, error) {
+	p, err := s.get(owner, id)
+	if err != nil {
+		return ProcessOutput{}, err
+	}
+	return p.readNew(), nil
+}
+
+// Send writes input to the process stdin.
+func (s *ProcessSupervisor) Send(owner, id, input string) error {
+	p, err := s.get(owner, id)
+	if err != nil {
+		return err
+	}
+	if !p.running() {
+		return fmt.Errorf("process %s has exited", id)
+	}
+	p.touch()
+	if _, err := io.WriteString(p.stdin, input); err != nil {
+		return fmt.Errorf("write stdin: %w", err)
+	}
+	return nil
+}
+
+// Wait blocks until the process exits, timeout elapses or ctx is done, then
+// returns new output like Read.
+func (s *ProcessSupervisor) Wait(ctx context.Context, owner, id string, timeout time.Duration) (ProcessOutput, error) {
+	p, err := s.get(owner, id)
+	if err != nil {
+		return ProcessOutput{}, err
+	}
+	p.touch()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-p.done:
+	case <-timer.C:
+	case <-ctx.Done():
+		return ProcessOutput{}, ctx.Err()
+	}
+	return p.readNew(), nil
+}
+
+// Signal sends a named signal (TERM, INT, KILL, HUP, QUIT, USR1, USR2) to the process group.
+func (s *ProcessSupervisor) Signal(owner, id, sig string) error {
+	p, err := s.get(owner, id)
+	if err != nil {
+		return err
+	}
+	if !p.running() {
+		return fmt.Errorf("process %s has exited", id)
+	}
+	p.touch()
+	name := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(sig)), "SIG")
+	if name == "" {
+		name = "TERM"
+	}
+	return signalProcess(p.cmd.Process, name)
+}
+
+// Kill terminates the process and forgets it.
+func (s *ProcessSupervisor) Kill(owner, id string) error {
+	p, err := s.get(owner, id)
+	if err != nil {
+		return err
+	}
+	s.terminate(p)
+	s.mu.Lock()
+	delete(s.procs, p.ID)
+	s.mu.Unlock()
+	return nil
+}
+
+// List returns the processes owned by owner, oldest first.
+func (s *ProcessSupervisor) List(owner string) []ProcessStatus {
+	s.mu.Lock()
+	var owned []*ManagedProcess
+	for _, p := range s.procs {
+		if p.Owner == owner {
+			owned = append(owned, p)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(owned, func(i, j int) bool { return owned[i].Started.Before(owned[j].Started) })
+	out := make([]ProcessStatus, 0, len(owned))
+	for _, p := range owned {
+		out = append(out, p.status())
+	}
+	return out
+}
+
+// CloseSession kills every process owned by session or by its delegate sub-sessions.
+func (s *ProcessSupervisor) CloseSession(session string) int {
+	return s.killWhere(func(p *ManagedProcess) bool {
+		return p.Owner == session || strings.HasPrefix(p.Owner, session+":delegate:")
+	})
+}
+
+// Shutdown kills all processes and stops the reaper. The supervisor rejects
+// new processes afterwards.
+func (s *ProcessSupervisor) Shutdown() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.killWhere(func(*ManagedProcess) bool { return true })
+}
+
+func (s *ProcessSupervisor) killWhere(match func(*ManagedProcess) bool) int {
+	s.mu.Lock()
+	var victims []*ManagedProcess
+	for id, p := range s.procs {
+		if match(p) {
+			victims = append(victims, p)
+			delete(s.procs, id)
+		}
+	}
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, p := range victims {
+		wg.Add(1)
+		go func(p *ManagedProcess) {
+			defer wg.Done()
+			s.terminate(p)
+		}(p)
+	}
+	wg.Wait()
+	return len(victims)
+}
+
+// terminate 先发 TERM，宽限期后仍未退出则 KILL
+func (s *ProcessSupervisor) terminate(p *ManagedProcess) {
+	if !p.running() {
+		return
+	}
+	_ = p.stdin.Close()
+	_ = signalProcess(p.cmd.Process, "TERM")
+	select {
+	case <-p.done:
+		return
+	case <-time.After(processKillGrace):
+	}
+	_ = signalProcess(p.cmd.Process, "KILL")
+	<-p.done
+}
+
+func (s *ProcessSupervisor) reapLoop() {
+	ticker := time.NewTicker(processReapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.reap(time.Now())
+		}
+	}
+}
+
+// reap 结束空闲超时的进程，清理退出已久的记录
+func (s *ProcessSupervisor) reap(now time.Time) {
+	s.killWhere(func(p *ManagedProcess) bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.ended.IsZero() {
+			return now.Sub(p.lastActive) > s.opts.IdleTimeout
+		}
+		return now.Sub(p.ended) > processExitedRetention && now.Sub(p.lastActive) > processExitedRetention
+	})
+}
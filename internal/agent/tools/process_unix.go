@@ -0,0 +1,36 @@
+//go:build !windows
+
+package tools
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+var processSignals = map[string]syscall.Signal{
+	"TERM": syscall.SIGTERM,
+	"INT":  syscall.SIGINT,
+	"KILL": syscall.SIGKILL,
+	"HUP":  syscall.SIGHUP,
+	"QUIT": syscall.SIGQUIT,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// setProcessGroup 让子进程自成进程组，信号可以连同它派生的子进程一起送达
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func signalProcess(p *os.Process, name string) error {
+	sig, ok := processSignals[name]
+	if !ok {
+		return fmt.Errorf("unsupported signal %q", name)
+	}
+	if err := syscall.Kill(-p.Pid, sig); err != nil && err != syscall.ESRCH {
+		return p.Signal(sig)
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package tools
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+func setProcessGroup(*exec.Cmd) {}
+
+// signalProcess Windows 没有 POSIX 信号，TERM/INT/KILL 都按强制结束处理
+func signalProcess(p *os.Process, name string) error {
+	switch name {
+	case "TERM", "INT", "KILL":
+		return p.Kill()
+	}
+	return fmt.Errorf("unsupported signal %q on windows", name)
+}
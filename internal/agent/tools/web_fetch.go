@@ -0,0 +1,286 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// DefaultFetchMaxBytes 默认下载上限
+	DefaultFetchMaxBytes = 2 * 1024 * 1024
+	// DefaultFetchTimeout 默认请求超时
+	DefaultFetchTimeout = 20 * time.Second
+)
+
+// ErrPrivateAddress is returned when a fetch target resolves to a private,
+// loopback or link-local address and private access is not allowed.
+var ErrPrivateAddress = errors.New("destination address is private or local")
+
+// WebFetcher downloads web pages with a size cap and an SSRF guard.
+type WebFetcher struct {
+	MaxBytes     int64
+	Timeout      time.Duration
+	AllowPrivate bool
+	UserAgent    string
+}
+
+// FetchResult is the readable content of a fetched page.
+type FetchResult struct {
+	URL         string `json:"url"`
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType"`
+	Title       string `json:"title,omitempty"`
+	Text        string `json:"text"`
+	Truncated   bool   `json:"truncated,omitempty"`
+}
+
+// client 构造 HTTP 客户端：连接建立时按真实解析出的 IP 校验，防止 DNS rebinding 绕过
+func (f *WebFetcher) client() *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !f.AllowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || IsPrivateIP(ip) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+			}
+			return nil
+		}
+	}
+	transport := &http.Transport{
+		// 不走环境变量代理，否则校验的是代理地址而不是目标地址
+		Proxy:               nil,
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	timeout := f.Timeout
+	if timeout <= 0 {
+		timeout = DefaultFetchTimeout
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return fmt.Errorf("stopped after 5 redirects")
+			}
+			return checkFetchURL(req.URL, f.AllowPrivate)
+		},
+	}
+}
+
+// IsPrivateIP reports whether ip is loopback, private, link-local, CGNAT or otherwise not publicly routable.
+func IsPrivateIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	if v4 := ip.To4(); v4 != nil {
+		// 0.0.0.0/8 和运营商级 NAT 100.64.0.0/10
+		return v4[0] == 0 || (v4[0] == 100 && v4[1]&0xc0 == 64)
+	}
+	return false
+}
+
+func checkFetchURL(u *url.URL, allowPrivate bool) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q (only http/https)", u.Scheme)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("URL has no host")
+	}
+	if allowPrivate {
+		return nil
+	}
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	if ip := net.ParseIP(host); ip != nil && IsPrivateIP(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	return nil
+}
+
+// Fetch downloads rawURL and extracts readable text from HTML responses.
+func (f *WebFetcher) Fetch(ctx context.Context, rawURL string) (*FetchResult, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if err := checkFetchURL(u, f.AllowPrivate); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	ua := f.UserAgent
+	if ua == "" {
+		ua = "Mozilla/5.0 (compatible; HighClaw/1.0)"
+	}
+	req.Header.Set("User-Agent", ua)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.9,*/*;q=0.5")
+
+	resp, err := f.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	maxBytes := f.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultFetchMaxBytes
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	result := &FetchResult{
+		URL:         resp.Request.URL.String(),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	if int64(len(body)) > maxBytes {
+		body = body[:maxBytes]
+		result.Truncated = true
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("fetch returned status %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(result.ContentType)
+	switch {
+	case mediaType == "" || mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		result.Title, result.Text = ExtractReadableText(string(body))
+	case strings.HasPrefix(mediaType, "text/"), mediaType == "application/json", strings.HasSuffix(mediaType, "+json"),
+		mediaType == "application/xml", strings.HasSuffix(mediaType, "+xml"):
+		result.Text = strings.TrimSpace(string(body))
+	default:
+		return nil, fmt.Errorf("unsupported content type %q", mediaType)
+	}
+	return result, nil
+}
+
+// 正文提取时整体跳过的元素
+var skipElements = map[string]struct{}{
+	"script": {}, "style": {}, "noscript": {}, "template": {}, "svg": {}, "canvas": {},
+	"nav": {}, "footer": {}, "header": {}, "aside": {}, "form": {}, "iframe": {}, "head": {},
+}
+
+// 块级元素前后换行（相邻块之间保留一个空行）
+var blockElements = map[string]struct{}{
+	"p": {}, "div": {}, "section": {}, "article": {}, "main": {}, "hr": {},
+	"h1": {}, "h2": {}, "h3": {}, "h4": {}, "h5": {}, "h6": {},
+	"ul": {}, "ol": {}, "table": {}, "pre": {}, "blockquote": {},
+	"dl": {}, "figure": {}, "figcaption": {},
+}
+
+// 行级元素只在开始处换行（列表项、表格行）
+var lineElements = map[string]struct{}{
+	"br": {}, "li": {}, "tr": {}, "dt": {}, "dd": {},
+}
+
+// ExtractReadableText returns the page title and visible body text of an HTML document,
+// dropping scripts, styles and navigation chrome and collapsing whitespace.
+func ExtractReadableText(doc string) (title, text string) {
+	z := html.NewTokenizer(strings.NewReader(doc))
+	var b strings.Builder
+	skipDepth := 0
+	inTitle := false
+	inPre := false
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return strings.TrimSpace(title), collapseText(b.String())
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			if tag == "title" {
+				inTitle = tt == html.StartTagToken
+				continue
+			}
+			if _, ok := skipElements[tag]; ok && tt == html.StartTagToken {
+				skipDepth++
+				continue
+			}
+			if _, ok := blockElements[tag]; ok {
+				b.WriteString("\n\n")
+			} else if _, ok := lineElements[tag]; ok {
+				b.WriteString("\n")
+			}
+			switch tag {
+			case "li":
+				b.WriteString("- ")
+			case "pre":
+				inPre = true
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			if tag == "title" {
+				inTitle = false
+				continue
+			}
+			if _, ok := skipElements[tag]; ok && skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			if tag == "pre" {
+				inPre = false
+			}
+			if _, ok := blockElements[tag]; ok {
+				b.WriteString("\n\n")
+			}
+		case html.TextToken:
+			if inTitle {
+				title += string(z.Text())
+				continue
+			}
+			if skipDepth > 0 {
+				continue
+			}
+			text := string(z.Text())
+			if !inPre {
+				// 源码里的换行只是排版空白
+				text = strings.ReplaceAll(text, "\n", " ")
+			}
+			b.WriteString(text)
+		}
+	}
+}
+
+// collapseText 合并行内空白，去掉空行堆叠
+func collapseText(s string) string {
+	lines := strings.Split(s, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" || line == "-" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		out = append(out, line)
+		blank = false
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
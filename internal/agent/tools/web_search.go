@@ -262,3 +262,91 @@ func decodeDuckURL(u string) string {
 	}
 	return u
 }
+
+// SearchBackend is a web search provider used by the web_search tool.
+type SearchBackend interface {
+	Name() string
+	Search(ctx context.Context, query string, maxResults int) ([]WebSearchResult, error)
+}
+
+// DuckDuckGoBackend scrapes DuckDuckGo HTML results (no API key required).
+type DuckDuckGoBackend struct{}
+
+func (DuckDuckGoBackend) Name() string { return "duckduckgo" }
+
+func (DuckDuckGoBackend) Search(ctx context.Context, query string, maxResults int) ([]WebSearchResult, error) {
+	return searchDuckDuckGo(ctx, query, maxResults)
+}
+
+// BraveBackend uses the Brave Search API.
+type BraveBackend struct {
+	APIKey string
+}
+
+func (BraveBackend) Name() string { return "brave" }
+
+func (b BraveBackend) Search(ctx context.Context, query string, maxResults int) ([]WebSearchResult, error) {
+	return BraveSearch(ctx, b.APIKey, query, maxResults)
+}
+
+// GoogleBackend uses the Google Custom Search API.
+type GoogleBackend struct {
+	APIKey         string
+	SearchEngineID string
+}
+
+func (GoogleBackend) Name() string { return "google" }
+
+func (g GoogleBackend) Search(ctx context.Context, query string, maxResults int) ([]WebSearchResult, error) {
+	return GoogleSearch(ctx, g.APIKey, g.SearchEngineID, query, maxResults)
+}
+
+// SearXNGBackend queries a SearXNG instance through its JSON API
+// (the instance must have the "json" format enabled).
+type SearXNGBackend struct {
+	BaseURL string
+}
+
+func (SearXNGBackend) Name() string { return "searxng" }
+
+func (s SearXNGBackend) Search(ctx context.Context, query string, maxResults int) ([]WebSearchResult, error) {
+	base := strings.TrimRight(strings.TrimSpace(s.BaseURL), "/")
+	if base == "" {
+		return nil, fmt.Errorf("searxng url is required")
+	}
+	if maxResults <= 0 {
+		maxResults = 5
+	}
+	u := fmt.Sprintf("%s/search?q=%s&format=json", base, url.QueryEscape(query))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("searxng returned status %d", resp.StatusCode)
+	}
+	var payload struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+	out := make([]WebSearchResult, 0, maxResults)
+	for _, r := range payload.Results {
+		if len(out) == maxResults {
+			break
+		}
+		out = append(out, WebSearchResult{Title: r.Title, URL: r.URL, Snippet: r.Content})
+	}
+	return out, nil
+}
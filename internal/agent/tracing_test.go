@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/highclaw/highclaw/internal/config"
+	"github.com/highclaw/highclaw/internal/system/tracing"
+)
+
+// flakyToolProvider 第一次调用失败（触发重试），第二次请求一个工具，第三次给出回复
+type flakyToolProvider struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *flakyToolProvider) Chat(ctx context.Context, req *ChatRequest, model string) (*ChatResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	switch p.calls {
+	case 1:
+		return nil, errors.New("upstream overloaded")
+	case 2:
+		return &ChatResponse{Content: `<invoke>
+{"name":"no_such_tool","arguments":{}}
+</invoke>`, Usage: TokenUsage{InputTokens: 100, OutputTokens: 10}}, nil
+	}
+	return &ChatResponse{Content: "done", Usage: TokenUsage{InputTokens: 120, OutputTokens: 5}}, nil
+}
+
+func TestRunProducesNestedTrace(t *testing.T) {
+	cfg := config.Default()
+	cfg.Agent.Workspace = t.TempDir()
+	cfg.Memory.Backend = "markdown"
+	cfg.Memory.AutoSave = false
+	cfg.Reliability.ProviderBackoffMs = 1
+
+	r := NewRunner(cfg, slog.Default())
+	t.Cleanup(r.Close)
+	fake := &flakyToolProvider{}
+	r.models.factory.Register("fake", func(cfg *config.Config) (Provider, error) { return fake, nil })
+
+	res, err := r.Run(context.Background(), &RunRequest{SessionKey: "agent:main:trace", Message: "hi", Provider: "fake", Model: "fake/m"})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	trace, ok := tracing.Lookup(res.TraceID)
+	if !ok {
+		t.Fatalf("trace %q not recorded", res.TraceID)
+	}
+
+	byID := map[string]tracing.SpanData{}
+	count := map[string]int{}
+	for _, s := range trace.Spans {
+		byID[s.SpanID] = s
+		count[s.Name]++
+	}
+	root := trace.Root()
+	if root.Name != "agent.run" || root.Attributes["input_tokens"] != 220 {
+		t.Fatalf("unexpected root span: %+v", root)
+	}
+	if count["prompt.build"] != 1 || count["memory.recall"] != 1 || count["model.chat"] != 2 || count["model.attempt"] != 3 || count["tool.exec"] != 1 {
+		t.Fatalf("unexpected span counts: %v", count)
+	}
+	var failed int
+	for _, s := range trace.Spans {
+		switch s.Name {
+		case "model.attempt":
+			if byID[s.ParentID].Name != "model.chat" {
+				t.Fatalf("attempt should be nested under model.chat, parent=%q", byID[s.ParentID].Name)
+			}
+			if s.Error != "" {
+				failed++
+			}
+		case "tool.exec":
+			if s.Attributes["tool"] != "no_such_tool" || s.Error == "" || s.ParentID != root.SpanID {
+				t.Fatalf("unexpected tool span: %+v", s)
+			}
+		}
+	}
+	if failed != 1 {
+		t.Fatalf("the failed attempt should carry its error, got %d failed attempts", failed)
+	}
+	if summary := trace.Summary(); !strings.Contains(summary, "model: 2 call(s)") || !strings.Contains(summary, "tool.exec") {
+		t.Fatalf("unexpected summary:\n%s", summary)
+	}
+}
@@ -0,0 +1,197 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // 注册 GIF 解码器
+	"image/jpeg"
+	_ "image/png" // 注册 PNG 解码器
+	"net/http"
+	"strings"
+
+	"github.com/highclaw/highclaw/internal/domain/model"
+)
+
+const (
+	// maxImagesPerMessage 单条消息最多附带的图片数，多余的丢弃
+	maxImagesPerMessage = 4
+	// maxImageEdge 长边上限；Anthropic 超过 1568px 会在服务端缩放，提前缩放节省带宽和 token
+	maxImageEdge = 1568
+	// maxImageBytes 单张图片上限（base64 后约 5MB，满足 Anthropic / OpenAI 的限制）
+	maxImageBytes = 3_750_000
+	// maxImagePixels 解码前按尺寸拒绝超大图片，防止解压炸弹（解码后的 RGBA 约占 4 字节/像素）
+	maxImagePixels = 16_000_000
+)
+
+// ImagePart 随消息发送给模型的一张图片
+type ImagePart struct {
+	MediaType string `json:"mediaType"`
+	Data      []byte `json:"data"`
+}
+
+// prepareImages 校验、缩放、压缩入站图片；无法识别的图片跳过，并返回给模型看的说明
+func prepareImages(raw [][]byte) ([]ImagePart, string) {
+	var parts []ImagePart
+	var notes []string
+	if len(raw) > maxImagesPerMessage {
+		notes = append(notes, fmt.Sprintf("[Only the first %d of %d attached images are included.]", maxImagesPerMessage, len(raw)))
+		raw = raw[:maxImagesPerMessage]
+	}
+	failed := 0
+	for _, data := range raw {
+		part, err := prepareImage(data)
+		if err != nil {
+			failed++
+			continue
+		}
+		parts = append(parts, part)
+	}
+	if failed > 0 {
+		notes = append(notes, fmt.Sprintf("[%d image attachment(s) could not be read and were omitted.]", failed))
+	}
+	return parts, strings.Join(notes, "\n")
+}
+
+// prepareImage 尺寸和大小都在上限内的图片原样发送，否则缩放并转成 JPEG
+func prepareImage(data []byte) (ImagePart, error) {
+	mediaType := http.DetectContentType(data)
+	switch mediaType {
+	case "image/jpeg", "image/png", "image/gif":
+	case "image/webp":
+		// 标准库无法解码 WebP，只能在大小合规时原样发送
+		if len(data) > maxImageBytes {
+			return ImagePart{}, fmt.Errorf("webp image too large: %d bytes", len(data))
+		}
+		return ImagePart{MediaType: mediaType, Data: data}, nil
+	default:
+		return ImagePart{}, fmt.Errorf("unsupported image type %q", mediaType)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return ImagePart{}, fmt.Errorf("decode image header: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxImagePixels {
+		return ImagePart{}, fmt.Errorf("image dimensions %dx%d out of range", cfg.Width, cfg.Height)
+	}
+	if cfg.Width <= maxImageEdge && cfg.Height <= maxImageEdge && len(data) <= maxImageBytes {
+		return ImagePart{MediaType: mediaType, Data: data}, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return ImagePart{}, fmt.Errorf("decode image: %w", err)
+	}
+	w, h := fitEdge(cfg.Width, cfg.Height, maxImageEdge)
+	for {
+		img := downscale(src, w, h)
+		for _, quality := range []int{85, 70, 55} {
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+				return ImagePart{}, fmt.Errorf("encode image: %w", err)
+			}
+			if buf.Len() <= maxImageBytes {
+				return ImagePart{MediaType: "image/jpeg", Data: buf.Bytes()}, nil
+			}
+		}
+		if w <= 256 || h <= 256 {
+			return ImagePart{}, fmt.Errorf("image still larger than %d bytes at %dx%d", maxImageBytes, w, h)
+		}
+		w, h = w*3/4, h*3/4
+	}
+}
+
+// fitEdge 等比缩放到长边不超过 limit
+func fitEdge(w, h, limit int) (int, int) {
+	if w <= limit && h <= limit {
+		return w, h
+	}
+	if w >= h {
+		return limit, max(1, h*limit/w)
+	}
+	return max(1, w*limit/h), limit
+}
+
+// downscale 区域平均缩放；透明部分铺白底（JPEG 不支持透明）。
+// 每个目标行只把对应的几行源像素铺白底后取平均，不复制整张原图
+func downscale(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	band := image.NewRGBA(image.Rect(0, 0, sw, (sh+h-1)/h+1))
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		rows := image.Rect(0, 0, sw, y1-y0)
+		draw.Draw(band, rows, image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(band, rows, src, image.Pt(b.Min.X, b.Min.Y+y0), draw.Over)
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+			var r, g, bl, n int
+			for sy := 0; sy < y1-y0; sy++ {
+				row := band.Pix[sy*band.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4:]
+					r += int(p[0])
+					g += int(p[1])
+					bl += int(p[2])
+					n++
+				}
+			}
+			o := dst.PixOffset(x, y)
+			dst.Pix[o] = uint8(r / n)
+			dst.Pix[o+1] = uint8(g / n)
+			dst.Pix[o+2] = uint8(bl / n)
+			dst.Pix[o+3] = 0xff
+		}
+	}
+	return dst
+}
+
+// supportsVision 本次运行的模型能否接收图片：本地模型看探测结果，其余查模型目录
+func (r *Runner) supportsVision(req *RunRequest) (string, bool) {
+	if caps, ok := r.localCaps(req); ok {
+		return caps.ID, caps.Vision
+	}
+	ref := strings.TrimSpace(req.Model)
+	if ref == "" {
+		ref = r.cfg.Agent.Model
+	}
+	if _, routeModel, routed := r.models.resolveHintRoute(ref); routed {
+		ref = routeModel
+	}
+	return ref, model.SupportsVision(ref)
+}
+
+// runImages 处理本次运行的图片附件，返回要附到最新用户消息上的图片和说明
+func (r *Runner) runImages(req *RunRequest) ([]ImagePart, string) {
+	if len(req.Images) == 0 {
+		return nil, ""
+	}
+	if ref, ok := r.supportsVision(req); !ok {
+		r.logger.Info("model does not support images, dropping attachments", "model", ref, "images", len(req.Images))
+		return nil, fmt.Sprintf("[Image attachments omitted: the current model (%s) does not support images. "+
+			"Tell the user you cannot see the %d attached image(s) and ask them to describe it or switch to a vision-capable model.]", ref, len(req.Images))
+	}
+	parts, note := prepareImages(req.Images)
+	if note != "" {
+		r.logger.Info("some image attachments were dropped", "attached", len(req.Images), "kept", len(parts))
+	}
+	return parts, note
+}
+
+// attachImages 把图片和说明附到历史中最后一条用户消息上
+func attachImages(history []ChatMessage, images []ImagePart, note string) {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role != "user" {
+			continue
+		}
+		history[i].Images = images
+		if note != "" {
+			history[i].Content += "\n\n" + note
+		}
+		return
+	}
+}
@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+// captureProvider 记录每次调用收到的消息
+type captureProvider struct {
+	mu       sync.Mutex
+	messages [][]ChatMessage
+}
+
+func (p *captureProvider) Chat(ctx context.Context, req *ChatRequest, model string) (*ChatResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = append(p.messages, append([]ChatMessage(nil), req.Messages...))
+	return &ChatResponse{Content: "a red square"}, nil
+}
+
+func (p *captureProvider) last(t *testing.T) ChatMessage {
+	t.Helper()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.messages) == 0 {
+		t.Fatal("provider was not called")
+	}
+	msgs := p.messages[len(p.messages)-1]
+	return msgs[len(msgs)-1]
+}
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 0x80, A: 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestRunSendsImagesToVisionModels(t *testing.T) {
+	cfg := config.Default()
+	cfg.Agent.Workspace = t.TempDir()
+	cfg.Memory.Backend = "markdown"
+	cfg.Memory.AutoSave = false
+
+	r := NewRunner(cfg, slog.Default())
+	t.Cleanup(r.Close)
+	fake := &captureProvider{}
+	r.models.factory.Register("fake", func(cfg *config.Config) (Provider, error) { return fake, nil })
+
+	small := testPNG(t, 64, 32)
+	large := testPNG(t, 3000, 1000)
+	_, err := r.Run(context.Background(), &RunRequest{
+		SessionKey: "agent:main:vision",
+		Message:    "what is this?",
+		Provider:   "fake",
+		Model:      "fake/claude-sonnet-4",
+		Images:     [][]byte{small, large, []byte("not an image")},
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	msg := fake.last(t)
+	if msg.Role != "user" || len(msg.Images) != 2 {
+		t.Fatalf("expected 2 images on the user message, got %+v", msg)
+	}
+	if msg.Images[0].MediaType != "image/png" || !bytes.Equal(msg.Images[0].Data, small) {
+		t.Fatalf("small image should be sent unchanged, got %s", msg.Images[0].MediaType)
+	}
+	cfgOut, format, err := image.DecodeConfig(bytes.NewReader(msg.Images[1].Data))
+	if err != nil || format != "jpeg" || msg.Images[1].MediaType != "image/jpeg" {
+		t.Fatalf("large image should be re-encoded as jpeg, got %q %v", format, err)
+	}
+	if cfgOut.Width != maxImageEdge || cfgOut.Height != 522 {
+		t.Fatalf("large image should be downscaled to fit %dpx, got %dx%d", maxImageEdge, cfgOut.Width, cfgOut.Height)
+	}
+	if !strings.Contains(msg.Content, "1 image attachment(s) could not be read") {
+		t.Fatalf("undecodable image should be reported to the model, got %q", msg.Content)
+	}
+
+	_, err = r.Run(context.Background(), &RunRequest{
+		SessionKey: "agent:main:vision2",
+		Message:    "what is this?",
+		Provider:   "fake",
+		Model:      "fake/o3-mini",
+		Images:     [][]byte{small},
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	msg = fake.last(t)
+	if len(msg.Images) != 0 || !strings.Contains(msg.Content, "Image attachments omitted") || !strings.Contains(msg.Content, "o3-mini") {
+		t.Fatalf("non-vision model should get a text fallback instead of images, got %+v", msg)
+	}
+}
+
+func TestDownscaleAndPixelLimit(t *testing.T) {
+	// 源图 bounds 不从原点开始，左半透明（铺白底）、右半纯黑
+	src := image.NewNRGBA(image.Rect(10, 20, 18, 24))
+	for y := 20; y < 24; y++ {
+		for x := 14; x < 18; x++ {
+			src.Set(x, y, color.NRGBA{A: 0xff})
+		}
+	}
+	dst := downscale(src, 2, 1)
+	if got := dst.RGBAAt(0, 0); got != (color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}) {
+		t.Fatalf("transparent half = %v; want white", got)
+	}
+	if got := dst.RGBAAt(1, 0); got != (color.RGBA{A: 0xff}) {
+		t.Fatalf("opaque half = %v; want black", got)
+	}
+
+	// 只有 PNG 头声明的超大尺寸，解码前就被拒绝
+	var hdr bytes.Buffer
+	hdr.WriteString("\x89PNG\r\n\x1a\n")
+	ihdr := []byte("IHDR\x00\x00\x13\x88\x00\x00\x0f\xa0\x08\x06\x00\x00\x00") // 5000x4000 RGBA
+	binary.Write(&hdr, binary.BigEndian, uint32(len(ihdr)-4))
+	hdr.Write(ihdr)
+	binary.Write(&hdr, binary.BigEndian, crc32.ChecksumIEEE(ihdr))
+	if _, err := prepareImage(hdr.Bytes()); err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Fatalf("20MP image should be rejected before decoding: %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/highclaw/highclaw/internal/agent/tools"
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+const (
+	webDefaultMaxResults = 5
+	webDefaultMaxChars   = 20000
+	webDefaultCacheTTL   = 15 * time.Minute
+	// 每个会话缓存的条目上限
+	webCacheMaxEntries = 64
+)
+
+// webSearchBackend 按配置选择搜索后端
+func webSearchBackend(cfg config.WebToolsConfig) tools.SearchBackend {
+	brave := tools.BraveBackend{APIKey: strings.TrimSpace(cfg.BraveAPIKey)}
+	google := tools.GoogleBackend{APIKey: strings.TrimSpace(cfg.GoogleAPIKey), SearchEngineID: strings.TrimSpace(cfg.GoogleCX)}
+	searx := tools.SearXNGBackend{BaseURL: strings.TrimSpace(cfg.SearXNGURL)}
+
+	switch strings.ToLower(strings.TrimSpace(cfg.SearchProvider)) {
+	case "brave":
+		return brave
+	case "google":
+		return google
+	case "searxng":
+		return searx
+	case "duckduckgo", "ddg":
+		return tools.DuckDuckGoBackend{}
+	}
+	switch {
+	case brave.APIKey != "":
+		return brave
+	case google.APIKey != "" && google.SearchEngineID != "":
+		return google
+	case searx.BaseURL != "":
+		return searx
+	}
+	return tools.DuckDuckGoBackend{}
+}
+
+type webCacheEntry struct {
+	value   string
+	expires time.Time
+}
+
+// webCache 按会话缓存搜索/抓取结果，避免同一轮对话里重复请求
+type webCache struct {
+	ttl      time.Duration
+	mu       sync.Mutex
+	sessions map[string]map[string]webCacheEntry
+}
+
+func newWebCache(ttl time.Duration) *webCache {
+	return &webCache{ttl: ttl, sessions: make(map[string]map[string]webCacheEntry)}
+}
+
+// setTTL 修改缓存有效期（配置热加载）；已缓存条目保持原过期时间
+func (c *webCache) setTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+}
+
+func (c *webCache) get(session, key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl <= 0 {
+		return "", false
+	}
+	entry, ok := c.sessions[session][key]
+	if !ok || time.Now().After(entry.expires) {
+		return "", false
+	}
+	return entry.value, true
+}
+
+func (c *webCache) put(session, key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl <= 0 {
+		return
+	}
+	entries := c.sessions[session]
+	if entries == nil {
+		entries = make(map[string]webCacheEntry)
+		c.sessions[session] = entries
+	}
+	if len(entries) >= webCacheMaxEntries {
+		now := time.Now()
+		for k, e := range entries {
+			if now.After(e.expires) || len(entries) >= webCacheMaxEntries {
+				delete(entries, k)
+			}
+		}
+	}
+	entries[key] = webCacheEntry{value: value, expires: time.Now().Add(c.ttl)}
+}
+
+// registerWebTools 注册 web_search / web_fetch；webTools.enabled=false 时跳过
+func (r *ToolRegistry) registerWebTools(cfg config.WebToolsConfig) {
+	if cfg.Enabled != nil && !*cfg.Enabled {
+		return
+	}
+	ttl := webDefaultCacheTTL
+	if cfg.CacheTTLSeconds > 0 {
+		ttl = time.Duration(cfg.CacheTTLSeconds) * time.Second
+	} else if cfg.CacheTTLSeconds < 0 {
+		ttl = 0
+	}
+	if r.web == nil {
+		r.web = newWebCache(ttl)
+	} else {
+		r.web.setTTL(ttl)
+	}
+	cache := r.web
+	backend := webSearchBackend(cfg)
+	fetcher := &tools.WebFetcher{MaxBytes: cfg.FetchMaxBytes, AllowPrivate: cfg.FetchAllowPrivate}
+
+	r.Register("web_search", fmt.Sprintf("Search the web (%s). Returns titles, URLs and snippets; use web_fetch to read a result.", backend.Name()),
+		`{"type":"object","properties":{"query":{"type":"string"},"max_results":{"type":"integer","description":"Number of results (default 5, max 10)"}},"required":["query"]}`,
+		r.webSearchTool(backend, cfg.MaxResults, cache))
+	r.Register("web_fetch", "Fetch a public http(s) URL and return its readable text. Private/local addresses are blocked.",
+		`{"type":"object","properties":{"url":{"type":"string"},"max_chars":{"type":"integer","description":"Max characters of text to return"}},"required":["url"]}`,
+		r.webFetchTool(fetcher, cfg.FetchMaxChars, cache))
+}
+
+func (r *ToolRegistry) webSearchTool(backend tools.SearchBackend, defaultMax int, cache *webCache) ToolHandler {
+	if defaultMax <= 0 {
+		defaultMax = webDefaultMaxResults
+	}
+	return func(ctx context.Context, input string) (string, error) {
+		var in struct {
+			Query      string `json:"query"`
+			MaxResults int    `json:"max_results"`
+		}
+		if err := json.Unmarshal([]byte(input), &in); err != nil {
+			return "", fmt.Errorf("invalid web_search input: %w", err)
+		}
+		query := strings.TrimSpace(in.Query)
+		if query == "" {
+			return "", fmt.Errorf("Missing 'query' parameter")
+		}
+		limit := in.MaxResults
+		if limit <= 0 {
+			limit = defaultMax
+		}
+		if limit > 10 {
+			limit = 10
+		}
+
+		session := toolSessionKey(ctx)
+		cacheKey := fmt.Sprintf("search:%s:%d:%s", backend.Name(), limit, strings.ToLower(query))
+		if out, ok := cache.get(session, cacheKey); ok {
+			r.logger.Debug("web_search cache hit", "query", query)
+			return out, nil
+		}
+
+		results, err := backend.Search(ctx, query, limit)
+		if err != nil {
+			r.logger.Warn("web_search failed", "backend", backend.Name(), "error", err)
+			return "", fmt.Errorf("%s search failed: %w", backend.Name(), err)
+		}
+		if len(results) == 0 {
+			return fmt.Sprintf("No results for %q.", query), nil
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "Results for %q (%s):\n", query, backend.Name())
+		for i, res := range results {
+			fmt.Fprintf(&b, "\n%d. %s\n   %s\n", i+1, strings.TrimSpace(res.Title), res.URL)
+			if snippet := strings.TrimSpace(res.Snippet); snippet != "" {
+				fmt.Fprintf(&b, "   %s\n", truncateWithEllipsis(snippet, 300))
+			}
+		}
+		out := strings.TrimRight(b.String(), "\n")
+		cache.put(session, cacheKey, out)
+		r.logger.Info("web_search", "backend", backend.Name(), "query", query, "results", len(results))
+		return out, nil
+	}
+}
+
+func (r *ToolRegistry) webFetchTool(fetcher *tools.WebFetcher, defaultMaxChars int, cache *webCache) ToolHandler {
+	if defaultMaxChars <= 0 {
+		defaultMaxChars = webDefaultMaxChars
+	}
+	return func(ctx context.Context, input string) (string, error) {
+		var in struct {
+			URL      string `json:"url"`
+			MaxChars int    `json:"max_chars"`
+		}
+		if err := json.Unmarshal([]byte(input), &in); err != nil {
+			return "", fmt.Errorf("invalid web_fetch input: %w", err)
+		}
+		target := strings.TrimSpace(in.URL)
+		if target == "" {
+			return "", fmt.Errorf("Missing 'url' parameter")
+		}
+		maxChars := in.MaxChars
+		if maxChars <= 0 || maxChars > defaultMaxChars {
+			maxChars = defaultMaxChars
+		}
+
+		session := toolSessionKey(ctx)
+		cacheKey := fmt.Sprintf("fetch:%d:%s", maxChars, target)
+		if out, ok := cache.get(session, cacheKey); ok {
+			r.logger.Debug("web_fetch cache hit", "url", target)
+			return out, nil
+		}
+
+		res, err := fetcher.Fetch(ctx, target)
+		if err != nil {
+			r.logger.Warn("web_fetch failed", "url", target, "error", err)
+			return "", fmt.Errorf("fetch failed: %w", err)
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "URL: %s\n", res.URL)
+		if res.Title != "" {
+			fmt.Fprintf(&b, "Title: %s\n", res.Title)
+		}
+		b.WriteString("\n")
+		text := res.Text
+		if len([]rune(text)) > maxChars {
+			text = string([]rune(text)[:maxChars]) + "\n… (content truncated)"
+		} else if res.Truncated {
+			text += "\n… (download size limit reached)"
+		}
+		b.WriteString(text)
+		out := b.String()
+		cache.put(session, cacheKey, out)
+		r.logger.Info("web_fetch", "url", res.URL, "status", res.Status, "chars", len([]rune(res.Text)))
+		return out, nil
+	}
+}
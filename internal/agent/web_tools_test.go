@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/highclaw/highclaw/internal/agent/tools"
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+const fixturePage = `<!doctype html><html><head><title>Fixture &amp; Page</title>
+<style>body{color:red}</style><script>var secret = 1;</script></head>
+<body><nav>Home | About</nav><article><h1>Hello</h1><p>First   paragraph
+with <b>bold</b> text.</p><ul><li>one</li><li>two</li></ul></article>
+<footer>copyright</footer></body></html>`
+
+func newWebFixture(t *testing.T) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var hits atomic.Int64
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(fixturePage))
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(strings.Repeat("x", 4096)))
+	})
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Query().Get("format") != "json" {
+			http.Error(w, "format", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"results":[{"title":"Go","url":"https://go.dev","content":"The Go language"},{"title":"Tour","url":"https://go.dev/tour","content":"A tour"}]}`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func newWebToolsRegistry(t *testing.T, web config.WebToolsConfig) *ToolRegistry {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Agent.Workspace = t.TempDir()
+	cfg.Memory.Backend = "markdown"
+	cfg.WebTools = web
+	return NewToolRegistry(cfg, slog.Default())
+}
+
+func TestWebFetchExtractsTextAndCaches(t *testing.T) {
+	srv, hits := newWebFixture(t)
+	reg := newWebToolsRegistry(t, config.WebToolsConfig{FetchAllowPrivate: true, FetchMaxBytes: 1024})
+
+	out, err := reg.Execute(context.Background(), "web_fetch", `{"url":"`+srv.URL+`/page"}`)
+	if err != nil {
+		t.Fatalf("web_fetch: %v", err)
+	}
+	if !strings.Contains(out, "Title: Fixture & Page") || !strings.Contains(out, "First paragraph with bold text.") || !strings.Contains(out, "- one") {
+		t.Fatalf("unexpected fetch output:\n%s", out)
+	}
+	for _, hidden := range []string{"secret", "Home | About", "copyright", "color:red"} {
+		if strings.Contains(out, hidden) {
+			t.Fatalf("output should not contain %q:\n%s", hidden, out)
+		}
+	}
+	if _, err := reg.Execute(context.Background(), "web_fetch", `{"url":"`+srv.URL+`/page"}`); err != nil || hits.Load() != 1 {
+		t.Fatalf("expected cached second fetch, hits=%d err=%v", hits.Load(), err)
+	}
+
+	out, err = reg.Execute(context.Background(), "web_fetch", `{"url":"`+srv.URL+`/big"}`)
+	if err != nil || !strings.Contains(out, "download size limit reached") {
+		t.Fatalf("expected size cap note, got %q %v", out, err)
+	}
+}
+
+func TestWebFetchBlocksPrivateAddresses(t *testing.T) {
+	srv, hits := newWebFixture(t)
+	f := &tools.WebFetcher{}
+	for _, u := range []string{srv.URL + "/page", "http://localhost/", "http://169.254.169.254/latest/meta-data", "file:///etc/passwd"} {
+		if _, err := f.Fetch(context.Background(), u); err == nil {
+			t.Fatalf("expected %s to be blocked", u)
+		}
+	}
+	if _, err := f.Fetch(context.Background(), srv.URL+"/page"); !errors.Is(err, tools.ErrPrivateAddress) {
+		t.Fatalf("expected ErrPrivateAddress, got %v", err)
+	}
+	if hits.Load() != 0 {
+		t.Fatalf("blocked fetch must not reach the server")
+	}
+}
+
+func TestWebSearchUsesConfiguredBackend(t *testing.T) {
+	srv, hits := newWebFixture(t)
+	if got := webSearchBackend(config.WebToolsConfig{BraveAPIKey: "k", SearXNGURL: srv.URL}).Name(); got != "brave" {
+		t.Fatalf("auto should prefer brave, got %s", got)
+	}
+	if got := webSearchBackend(config.WebToolsConfig{}).Name(); got != "duckduckgo" {
+		t.Fatalf("default backend should be duckduckgo, got %s", got)
+	}
+
+	reg := newWebToolsRegistry(t, config.WebToolsConfig{SearXNGURL: srv.URL})
+	out, err := reg.Execute(context.Background(), "web_search", `{"query":"golang","max_results":1}`)
+	if err != nil {
+		t.Fatalf("web_search: %v", err)
+	}
+	if !strings.Contains(out, "1. Go\n   https://go.dev\n   The Go language") || strings.Contains(out, "Tour") {
+		t.Fatalf("unexpected search output:\n%s", out)
+	}
+	_, _ = reg.Execute(context.Background(), "web_search", `{"query":"GoLang","max_results":1}`)
+	if hits.Load() != 1 {
+		t.Fatalf("expected cached search, hits=%d", hits.Load())
+	}
+}
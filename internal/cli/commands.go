@@ -14,12 +14,17 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"path"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/highclaw/highclaw/internal/agent"
@@ -27,8 +32,10 @@ import (
 	"github.com/highclaw/highclaw/internal/domain/model"
 	"github.com/highclaw/highclaw/internal/gateway/protocol"
 	"github.com/highclaw/highclaw/internal/gateway/session"
+	"github.com/highclaw/highclaw/internal/security"
 	userSkills "github.com/highclaw/highclaw/internal/skills"
 	"github.com/highclaw/highclaw/internal/system/tasklog"
+	"github.com/highclaw/highclaw/internal/system/tracing"
 	"github.com/highclaw/highclaw/internal/tui"
 	"github.com/spf13/cobra"
 )
@@ -42,8 +49,10 @@ var (
 	agentModel           string
 	agentTemperature     float64
 	agentSession         string
-	agentLast            bool // 接续上次会话
-	agentNoWorkspaceOnly bool // 临时允许访问绝对路径
+	agentLast            bool   // 接续上次会话
+	agentNoWorkspaceOnly bool   // 临时允许访问绝对路径
+	agentProfileID       string // 使用的 agent profile
+	agentTrace           bool   // 回复后输出本次运行的 trace 摘要
 
 	cronTaskID      string
 	cronTaskSpec    string
@@ -85,7 +94,7 @@ var agentCmd = &cobra.Command{
 			return agentChatCmd.RunE(cmd, []string{agentMessage})
 		}
 		return tui.RunWithOptions(tui.Options{
-			Agent:   "main",
+			Agent:   strings.TrimSpace(agentProfileID),
 			Session: strings.TrimSpace(agentSession),
 			Model:   strings.TrimSpace(agentModel),
 		})
@@ -113,6 +122,16 @@ var agentChatCmd = &cobra.Command{
 		}
 
 		runner := agent.NewRunner(cfg, logger)
+		defer runner.Close()
+		runner.SetApprover(execApprover)
+		runner.SetSpendSource(taskSpend)
+
+		initTracing(cfg, logger)
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = tracing.Shutdown(shutdownCtx)
+		}()
 
 		// 初始化任务日志（CLI 模式下如果 gateway 未启动，这里初始化）
 		if taskStore == nil {
@@ -133,11 +152,19 @@ var agentChatCmd = &cobra.Command{
 			}
 		}
 
+		agentID := strings.ToLower(strings.TrimSpace(agentProfileID))
+		if agentID == "" {
+			agentID = config.MainAgentID
+		}
+		if !cfg.HasAgent(agentID) {
+			return fmt.Errorf("unknown agent %q (configured: %s)", agentID, strings.Join(cfg.AgentIDs(), ", "))
+		}
+
 		isNewSession := sessionKey == ""
 		if isNewSession {
-			sessionKey = fmt.Sprintf("agent:%s:%s", "main", fmt.Sprintf("cli-%d", time.Now().UnixNano()))
+			sessionKey = fmt.Sprintf("agent:%s:%s", agentID, fmt.Sprintf("cli-%d", time.Now().UnixNano()))
 		} else if !strings.HasPrefix(sessionKey, "agent:") {
-			sessionKey = fmt.Sprintf("agent:%s:%s", "main", sessionKey)
+			sessionKey = fmt.Sprintf("agent:%s:%s", agentID, sessionKey)
 		}
 
 		// 续发模式：加载已有会话历史作为上下文
@@ -156,39 +183,86 @@ var agentChatCmd = &cobra.Command{
 		history = append(history, agent.ChatMessage{Role: "user", Content: msg})
 
 		chatStart := time.Now()
-		result, err := runner.Run(context.Background(), &agent.RunRequest{
+		// 根 span 放在 CLI 层，运行失败时也能输出 trace 摘要
+		runCtx, span := tracing.Start(context.Background(), "cli.chat", "session", sessionKey)
+		result, err := runner.Run(runCtx, &agent.RunRequest{
 			SessionKey:  sessionKey,
 			Channel:     "cli",
+			AgentID:     agentID,
 			Message:     msg,
 			History:     history,
 			Provider:    strings.TrimSpace(agentProvider),
 			Model:       strings.TrimSpace(agentModel),
 			Temperature: agentTemperature,
+			// 与最终回复一样直接输出模型随工具调用给出的中间文本
+			OnProgress: func(ev agent.ProgressEvent) {
+				if ev.Kind == agent.ProgressText {
+					fmt.Print(ev.Text)
+				}
+			},
 		})
+		span.RecordError(err)
+		span.End()
+		if agentTrace {
+			defer printTraceSummary(span.TraceID())
+		}
 		chatDuration := time.Since(chatStart)
 		modelName := strings.TrimSpace(agentModel)
 		if modelName == "" {
-			modelName = cfg.Agent.Model
+			modelName = cfg.ForAgent(agentID).Agent.Model
+		}
+		rec := &tasklog.TaskRecord{
+			Action:      tasklog.ActionChat,
+			Module:      "agent",
+			SessionKey:  sessionKey,
+			Channel:     "cli",
+			Sender:      "user",
+			AgentID:     agentID,
+			RequestBody: msg,
+			DurationMs:  chatDuration.Milliseconds(),
+			Model:       modelName,
 		}
 		if err != nil {
-			logTask(tasklog.ActionChat, "agent", sessionKey, "cli", "user", msg, err.Error(), "error", chatDuration, 0, 0, modelName)
+			rec.Status = "error"
+			rec.ResponseBody = err.Error()
+			logChatRun(rec, nil, err)
 			return err
 		}
 
+		if result.Model != "" {
+			modelName = result.Model
+		}
+
 		// 保存完整历史（包含新轮次）
 		history = append(history, agent.ChatMessage{Role: "assistant", Content: result.Reply})
 		_ = saveCLISessionFull(sessionKey, "cli", modelName, history)
 
-		// 记录成功的聊天任务
-		logTask(tasklog.ActionChat, "agent", sessionKey, "cli", "user", msg,
-			truncateString(result.Reply, 500), "success", chatDuration,
-			result.TokensUsed.InputTokens, result.TokensUsed.OutputTokens, modelName)
+		// 记录成功的聊天任务及其工具调用
+		rec.Status = "success"
+		rec.ResponseBody = truncateString(result.Reply, 500)
+		rec.TokensInput = result.TokensUsed.InputTokens
+		rec.TokensOutput = result.TokensUsed.OutputTokens
+		rec.CacheRead = result.TokensUsed.CacheRead
+		rec.CacheWrite = result.TokensUsed.CacheWrite
+		rec.CostUSD = result.TokensUsed.CostUSD
+		rec.Model = modelName
+		logChatRun(rec, result, nil)
+		logDLPHits(sessionKey, "cli", "user", result)
+		logInjections(sessionKey, "cli", "user", result)
 
 		fmt.Println(result.Reply)
 		return nil
 	},
 }
 
+// printTraceSummary 把 trace 摘要输出到 stderr，不干扰 stdout 上的回复
+func printTraceSummary(traceID string) {
+	if t, ok := tracing.Lookup(traceID); ok {
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, t.Summary())
+	}
+}
+
 var agentRPCCmd = &cobra.Command{
 	Use:   "rpc",
 	Short: "Start agent in RPC mode (JSON I/O)",
@@ -315,7 +389,7 @@ var configGetCmd = &cobra.Command{
 
 		if len(args) == 0 {
 			// Show all config
-			data, _ := json.MarshalIndent(cfg, "", "  ")
+			data, _ := json.MarshalIndent(cfg.Redacted(), "", "  ")
 			fmt.Println(string(data))
 			return nil
 		}
@@ -324,6 +398,10 @@ var configGetCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		if s, ok := val.(string); ok && s != "" && config.IsSecretPath(args[0]) {
+			// 敏感字段用 secrets get 查看明文
+			val = config.RedactedValue
+		}
 		data, _ := json.MarshalIndent(val, "", "  ")
 		fmt.Println(string(data))
 		return nil
@@ -350,22 +428,41 @@ var configSetCmd = &cobra.Command{
 	},
 }
 
+var configShowOrigin bool
+
 var configShowCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Show full configuration",
+	Long:  "Show the merged configuration. With --origin, list every value with the layer it came from (default, system, user, include, workspace, profile or env).",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := config.Load()
 		if err != nil {
 			return fmt.Errorf("load config: %w", err)
 		}
 
-		data, err := json.MarshalIndent(cfg, "", "  ")
-		if err != nil {
-			return fmt.Errorf("marshal config: %w", err)
+		if configShowOrigin {
+			for _, v := range cfg.Origins() {
+				data, _ := json.Marshal(v.Value)
+				fmt.Printf("%s = %s  # %s\n", v.Path, data, v.Origin)
+			}
+		} else {
+			data, err := json.MarshalIndent(cfg.Redacted(), "", "  ")
+			if err != nil {
+				return fmt.Errorf("marshal config: %w", err)
+			}
+			fmt.Println(string(data))
 		}
 
-		fmt.Println(string(data))
 		fmt.Printf("\nConfig file: %s\n", config.ConfigPath())
+		if files := cfg.SourceFiles(); len(files) > 0 {
+			fmt.Printf("Loaded layers: %s\n", strings.Join(files, ", "))
+		}
+		if p := cfg.Profile(); p != "" {
+			fmt.Printf("Profile: %s\n", p)
+		}
+		for _, w := range cfg.LoadWarnings() {
+			fmt.Printf("warning: %s\n", w)
+		}
 		return nil
 	},
 }
@@ -373,20 +470,79 @@ var configShowCmd = &cobra.Command{
 var configValidateCmd = &cobra.Command{
 	Use:   "validate",
 	Short: "Validate configuration file",
+	Long:  "Strictly check every config layer file against the schema (unknown keys, types, allowed values, deprecated fields, with line:column), then run semantic checks on the merged config.",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg, err := config.Load()
-		if err != nil {
-			return err
+		cfg, loadErr := config.Load()
+		files := cfg.SourceFiles()
+		if loadErr != nil {
+			// 合并失败时仍校验用户配置文件，给出行列信息
+			files = []string{config.ConfigPath()}
 		}
-		issues := validateConfig(cfg)
-		if len(issues) == 0 {
-			fmt.Println("config valid")
-			return nil
+		failed := false
+		for _, path := range files {
+			strict, err := config.ValidateFile(path)
+			if err != nil {
+				return err
+			}
+			for _, i := range strict {
+				fmt.Printf("%s:%s\n", path, i)
+			}
+			failed = failed || config.HasErrors(strict)
+		}
+		if loadErr != nil {
+			return loadErr
+		}
+		for _, w := range cfg.LoadWarnings() {
+			fmt.Println("warning: " + w)
 		}
+
+		issues := validateConfig(cfg)
 		for _, i := range issues {
 			fmt.Println("- " + i)
 		}
-		return fmt.Errorf("validation failed")
+		if len(issues) > 0 || failed {
+			return fmt.Errorf("validation failed")
+		}
+		fmt.Println("config valid")
+		return nil
+	},
+}
+
+var configSchemaOutput string
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the configuration file",
+	Long:  "Print a JSON Schema generated from the config structs, for editor completion and validation (e.g. yaml-language-server).",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := json.MarshalIndent(config.Schema(), "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal schema: %w", err)
+		}
+		data = append(data, '\n')
+		if configSchemaOutput == "" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		if err := os.WriteFile(configSchemaOutput, data, 0o644); err != nil {
+			return fmt.Errorf("write schema: %w", err)
+		}
+		fmt.Printf("schema written to %s\n", configSchemaOutput)
+		return nil
+	},
+}
+
+var configEnvCmd = &cobra.Command{
+	Use:   "env",
+	Short: "List the HIGHCLAW_* environment variable for every config field",
+	Long:  "List the environment variable mapped to every config field. Map keys are written as <KEY> (e.g. HIGHCLAW_AGENT_PROVIDERS__OPENAI__API_KEY); lists take comma-separated values or JSON, objects take JSON.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VARIABLE\tPATH\tTYPE")
+		for _, v := range config.EnvVars() {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", v.Name, v.Path, v.Type)
+		}
+		return w.Flush()
 	},
 }
 
@@ -1042,6 +1198,14 @@ var modelsListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List available models from all providers",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		// 已配置的本地服务（Ollama / llama.cpp）：发现模型并探测能力后一并列出
+		if cfg, err := config.Load(); err == nil {
+			for _, d := range agent.DiscoverLocalModels(context.Background(), cfg) {
+				if d.Err != nil {
+					fmt.Fprintf(os.Stderr, "warning: %s at %s: %v\n", d.Provider, d.BaseURL, d.Err)
+				}
+			}
+		}
 		all := model.GetAllModelsComplete()
 		grouped := make(map[string][]model.Model)
 		for _, m := range all {
@@ -1060,8 +1224,15 @@ var modelsListCmd = &cobra.Command{
 			if !modelsShowAll && limit > 5 {
 				limit = 5
 			}
+			local := model.GetProviderInfo()[p].Category == model.CategoryLocal
 			for i := 0; i < limit; i++ {
-				fmt.Printf("  - %s\n", ms[i].ID)
+				if local {
+					fmt.Printf("  - %-40s ctx %d  capabilities: %s\n", ms[i].ID, ms[i].MaxTokens, strings.Join(append([]string{"chat"}, ms[i].Capabilities...), ", "))
+				} else if price, ok := ms[i].Pricing(); ok {
+					fmt.Printf("  - %-40s $%g / $%g per 1M tokens (in/out)\n", ms[i].ID, price.Input, price.Output)
+				} else {
+					fmt.Printf("  - %s\n", ms[i].ID)
+				}
 			}
 			if !modelsShowAll && len(ms) > limit {
 				fmt.Printf("  ... (%d more, use --all)\n", len(ms)-limit)
@@ -1119,9 +1290,28 @@ var modelsScanCmd = &cobra.Command{
 			if c, ok := cfg.Agent.Providers[p]; ok {
 				hasCfgKey = strings.TrimSpace(c.APIKey) != ""
 			}
+			if p == "ollama" || p == "llamacpp" {
+				continue // 本地服务在下面按发现结果输出
+			}
 			models := model.GetModelsByProvider(p)
 			fmt.Printf("%s  auth=%s  env=%v  cfg=%v  models=%d\n", p, meta.AuthType, hasEnv, hasCfgKey, len(models))
 		}
+		for _, d := range agent.DiscoverLocalModels(context.Background(), cfg) {
+			if d.Err != nil {
+				fmt.Printf("%s  url=%s  status=unreachable  error=%v\n", d.Provider, d.BaseURL, d.Err)
+				continue
+			}
+			tools, vision := 0, 0
+			for _, m := range d.Models {
+				if m.Tools {
+					tools++
+				}
+				if m.Vision {
+					vision++
+				}
+			}
+			fmt.Printf("%s  url=%s  status=ok  models=%d  tools=%d  vision=%d\n", d.Provider, d.BaseURL, len(d.Models), tools, vision)
+		}
 		return nil
 	},
 }
@@ -1151,6 +1341,16 @@ var securityAuditCmd = &cobra.Command{
 				issues = append(issues, fmt.Sprintf("config file is too permissive: %o", st.Mode().Perm()))
 			}
 		}
+		var plaintext []string
+		for _, s := range cfg.SecretFields() {
+			if s.Storage == "plaintext" {
+				plaintext = append(plaintext, s.Path)
+			}
+		}
+		if len(plaintext) > 0 {
+			issues = append(issues, fmt.Sprintf("%d secret(s) stored in plain text (run `highclaw security fix` or `highclaw secrets set`): %s",
+				len(plaintext), strings.Join(plaintext, ", ")))
+		}
 		if len(issues) == 0 {
 			fmt.Println("security audit passed")
 			return nil
@@ -1184,8 +1384,16 @@ var securityFixCmd = &cobra.Command{
 			cfg.Gateway.Auth.Token = token
 			changed = true
 		}
+		for _, s := range cfg.SecretFields() {
+			if s.Storage == "plaintext" {
+				// 保存时加密所有明文密钥
+				cfg.Secrets.Encrypt = true
+				changed = true
+				break
+			}
+		}
 		if len(cfg.Agent.Sandbox.Allow) == 0 {
-			cfg.Agent.Sandbox.Allow = []string{"bash", "read_file", "write_file", "web_search"}
+			cfg.Agent.Sandbox.Allow = []string{"shell", "file", "web_search"}
 			changed = true
 		}
 		if !changed {
@@ -1219,6 +1427,10 @@ var execApprovalsListCmd = &cobra.Command{
 		for _, it := range items {
 			if it.Status == "pending" {
 				pending++
+				if it.Kind == "dlp" {
+					fmt.Printf("%s  requester=%s  dlp=%q  channel=%s  created=%s\n", it.ID, it.Requester, it.Command, it.Channel, it.CreatedAt.Format(time.RFC3339))
+					continue
+				}
 				fmt.Printf("%s  requester=%s  cmd=%q  created=%s\n", it.ID, it.Requester, it.Command, it.CreatedAt.Format(time.RFC3339))
 			}
 		}
@@ -1940,6 +2152,10 @@ func init() {
 	configCmdGroup.AddCommand(configSetCmd)
 	configCmdGroup.AddCommand(configShowCmd)
 	configCmdGroup.AddCommand(configValidateCmd)
+	configCmdGroup.AddCommand(configSchemaCmd)
+	configCmdGroup.AddCommand(configEnvCmd)
+	configShowCmd.Flags().BoolVar(&configShowOrigin, "origin", false, "Show which layer each value comes from")
+	configSchemaCmd.Flags().StringVarP(&configSchemaOutput, "output", "o", "", "Write the schema to a file instead of stdout")
 
 	// Sessions subcommands
 	sessionsCmd.AddCommand(sessionsListCmd)
@@ -2053,6 +2269,8 @@ func init() {
 	agentCmd.Flags().StringVar(&agentModel, "model", "", "Model override (e.g. anthropic/claude-sonnet-4)")
 	agentCmd.Flags().Float64VarP(&agentTemperature, "temperature", "t", 0.7, "Sampling temperature (0.0 - 2.0)")
 	agentCmd.Flags().BoolVar(&agentNoWorkspaceOnly, "no-sandbox", false, "Allow access to paths outside workspace (e.g. ~/Desktop)")
+	agentCmd.Flags().StringVar(&agentProfileID, "agent", "main", "Agent profile to talk to (see agent.profiles in config)")
+	agentCmd.PersistentFlags().BoolVar(&agentTrace, "trace", false, "Print a per-run trace summary (model calls, tools, timings) to stderr")
 
 	modelsListCmd.Flags().BoolVar(&modelsShowAll, "all", false, "Show all models")
 	migrateOpenClawCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Preview migration actions without writing")
@@ -2085,6 +2303,15 @@ type execApproval struct {
 	Reason    string    `json:"reason,omitempty"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
+
+	// Kind 为空是普通命令审批；"exec" 为 agent 发起的 shell 审批（taint 模式）；
+	// "dlp" 为 DLP 扣留的回复，审批通过后由 gateway 投递 Payload 到 Channel/Target。
+	// Delivered 表示 dlp 回复已投递，或 exec 审批已被使用一次。
+	Kind      string `json:"kind,omitempty"`
+	Channel   string `json:"channel,omitempty"`
+	Target    string `json:"target,omitempty"`
+	Payload   string `json:"payload,omitempty"`
+	Delivered bool   `json:"delivered,omitempty"`
 }
 
 type memoryRecord struct {
@@ -2247,6 +2474,23 @@ func setConfigKey(cfg *config.Config, key, value string) error {
 	return nil
 }
 
+// modelPriced 判断模型是否有单价（cost.prices 或内置价格表）；hint 路由在运行时才确定模型，不做检查
+func modelPriced(cfg *config.Config, ref string) bool {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "hint:") {
+		return true
+	}
+	provider, name, ok := strings.Cut(ref, "/")
+	if !ok {
+		provider, name = "", ref
+	}
+	if _, ok := cfg.PriceOverride(provider, name); ok {
+		return true
+	}
+	_, ok = model.LookupPricing(ref)
+	return ok
+}
+
 func validateConfig(cfg *config.Config) []string {
 	var issues []string
 	if strings.TrimSpace(cfg.Agent.Model) == "" {
@@ -2267,6 +2511,123 @@ func validateConfig(cfg *config.Config) []string {
 	if cfg.Gateway.Auth.Mode == "token" && strings.TrimSpace(cfg.Gateway.Auth.Token) == "" && cfg.Gateway.Bind != "loopback" {
 		issues = append(issues, "gateway.auth.token is required when mode is token")
 	}
+	for id, p := range cfg.Agent.Profiles {
+		switch strings.ToLower(strings.TrimSpace(p.Autonomy)) {
+		case "", "readonly", "supervised", "full":
+		default:
+			issues = append(issues, fmt.Sprintf("agent.profiles.%s.autonomy must be one of: readonly, supervised, full", id))
+		}
+	}
+	for i, b := range cfg.Agent.Bindings {
+		if !cfg.HasAgent(b.Agent) {
+			issues = append(issues, fmt.Sprintf("agent.bindings[%d].agent %q is not a configured agent profile", i, b.Agent))
+		}
+	}
+	perms := cfg.Agent.ToolPermissions
+	if perms.Default != "" {
+		if _, ok := cfg.ToolPermissionProfileByName(perms.Default); !ok {
+			issues = append(issues, fmt.Sprintf("agent.toolPermissions.default %q is not a defined profile", perms.Default))
+		}
+	}
+	for i, r := range perms.Rules {
+		if _, ok := cfg.ToolPermissionProfileByName(r.Profile); !ok {
+			issues = append(issues, fmt.Sprintf("agent.toolPermissions.rules[%d].profile %q is not a defined profile", i, r.Profile))
+		}
+		switch strings.ToLower(strings.TrimSpace(r.PeerKind)) {
+		case "", "direct", "group", "channel":
+		default:
+			issues = append(issues, fmt.Sprintf("agent.toolPermissions.rules[%d].peerKind must be one of: direct, group, channel", i))
+		}
+	}
+	for i, r := range cfg.Autonomy.ShellRules {
+		switch strings.ToLower(strings.TrimSpace(r.Risk)) {
+		case "low", "medium", "high", "block":
+		default:
+			issues = append(issues, fmt.Sprintf("autonomy.shellRules[%d].risk must be one of: low, medium, high, block", i))
+		}
+	}
+	for i, q := range cfg.Quotas.Rules {
+		switch security.QuotaScope(q) {
+		case "sender", "group", "channel", "agent":
+		default:
+			issues = append(issues, fmt.Sprintf("quotas.rules[%d].scope must be one of: sender, group, channel, agent", i))
+		}
+		if w := strings.TrimSpace(q.Window); w != "" {
+			if d, err := time.ParseDuration(w); err != nil || d <= 0 {
+				issues = append(issues, fmt.Sprintf("quotas.rules[%d].window must be a positive duration like 1m or 1h", i))
+			}
+		}
+		if q.Messages <= 0 && q.TokensPerDay <= 0 && q.CostPerMonth <= 0 {
+			issues = append(issues, fmt.Sprintf("quotas.rules[%d] sets no limit (messages, tokensPerDay or costPerMonth)", i))
+		}
+		// 费用按模型单价记入 tasklog，单价未知的模型计 0，costPerMonth 永远不会触发
+		if q.CostPerMonth > 0 {
+			ref := cfg.Agent.Model
+			if security.QuotaScope(q) == "agent" && q.Match != "" && q.Match != "*" {
+				ref = cfg.ForAgent(q.Match).Agent.Model
+			}
+			if !modelPriced(cfg, ref) {
+				issues = append(issues, fmt.Sprintf("quotas.rules[%d].costPerMonth needs a price for model %q (add it to cost.prices)", i, ref))
+			}
+		}
+	}
+	for _, target := range cfg.Quotas.Notify {
+		if ch, id, ok := strings.Cut(target, ":"); !ok || ch == "" || id == "" {
+			issues = append(issues, fmt.Sprintf("quotas.notify entry %q must be <channel>:<id>", target))
+		}
+	}
+	if a := cfg.DLP.Action; a != "" && !config.ValidDLPAction(a) {
+		issues = append(issues, "dlp.action must be one of: mask, block, approve, off")
+	}
+	if a := cfg.DLP.ToolOutput; a != "" && !config.ValidDLPAction(a) {
+		issues = append(issues, "dlp.toolOutput must be one of: mask, block, approve, off")
+	}
+	for ch, a := range cfg.DLP.Channels {
+		if !config.ValidDLPAction(a) {
+			issues = append(issues, fmt.Sprintf("dlp.channels.%s must be one of: mask, block, approve, off", ch))
+		} else if strings.EqualFold(strings.TrimSpace(a), config.DLPActionApprove) && !slices.Contains(config.DLPApproveChannels, strings.ToLower(ch)) {
+			issues = append(issues, fmt.Sprintf("dlp.channels.%s: approve is only supported for %s; replies on %s are blocked instead", ch, strings.Join(config.DLPApproveChannels, ", "), ch))
+		}
+	}
+	for i, p := range cfg.DLP.Patterns {
+		if _, err := regexp.Compile(p.Regex); err != nil || strings.TrimSpace(p.Regex) == "" {
+			issues = append(issues, fmt.Sprintf("dlp.patterns[%d].regex is not a valid regular expression", i))
+		}
+	}
+	if cfg.Cost.MonthlyBudget < 0 {
+		issues = append(issues, "cost.monthlyBudget must be >= 0")
+	}
+	if hint := strings.TrimSpace(cfg.Cost.BudgetHint); hint != "" {
+		found := false
+		for _, route := range cfg.ModelRoutes {
+			if strings.TrimSpace(route.Hint) == hint {
+				found = true
+				break
+			}
+		}
+		if !found {
+			issues = append(issues, fmt.Sprintf("cost.budgetHint %q has no matching modelRoutes entry", hint))
+		}
+	}
+	for name, p := range cfg.Cost.Prices {
+		if p.Input < 0 || p.Output < 0 || p.CacheRead < 0 || p.CacheWrite < 0 {
+			issues = append(issues, fmt.Sprintf("cost.prices.%s must not be negative", name))
+		}
+	}
+	for i, p := range cfg.PromptGuard.TrustedTools {
+		if _, err := path.Match(strings.TrimSpace(p), ""); err != nil || strings.TrimSpace(p) == "" {
+			issues = append(issues, fmt.Sprintf("promptGuard.trustedTools[%d] is not a valid tool name pattern", i))
+		}
+	}
+	switch tr := cfg.Observability.Tracing; strings.ToLower(strings.TrimSpace(tr.Exporter)) {
+	case "", tracing.ExporterNone, tracing.ExporterFile:
+	case tracing.ExporterOTLP:
+		if strings.TrimSpace(tr.Endpoint) == "" {
+			issues = append(issues, "observability.tracing.endpoint is required for the otlp exporter")
+		}
+	default:
+		issues = append(issues, fmt.Sprintf("observability.tracing.exporter %q must be none, otlp or file", tr.Exporter))
+	}
 	return issues
 }
 
@@ -2339,23 +2700,52 @@ func loadExecApprovals() ([]execApproval, error) {
 	return items, nil
 }
 
-func updateExecApproval(id, status string) error {
+// execApprovalsMu 串行化 exec_approvals.json 的读-改-写：gateway 里 agent 的 exec 审批、DLP 扣留、
+// 控制台审批和扣留回复投递并发进行，各自整文件写回会覆盖彼此的修改
+var execApprovalsMu sync.Mutex
+
+// updateExecApprovals 在锁内加载审批列表交给 fn 修改并写回；fn 返回 nil 列表时不写，返回错误时原样返回
+func updateExecApprovals(fn func(items []execApproval) ([]execApproval, error)) error {
+	execApprovalsMu.Lock()
+	defer execApprovalsMu.Unlock()
 	items, err := loadExecApprovals()
 	if err != nil {
 		return err
 	}
-	for i := range items {
-		if items[i].ID == id {
+	items, err = fn(items)
+	if err != nil || items == nil {
+		return err
+	}
+	return writeJSONFile(execApprovalsPath(), items)
+}
+
+func updateExecApproval(id, status string) error {
+	if err := setExecApproval(id, status, false); err != nil {
+		return err
+	}
+	fmt.Printf("%s: %s\n", status, id)
+	return nil
+}
+
+// setExecApproval 更新审批状态；拒绝时丢弃扣留的回复原文。pendingOnly 时已处理的审批不能再改
+func setExecApproval(id, status string, pendingOnly bool) error {
+	return updateExecApprovals(func(items []execApproval) ([]execApproval, error) {
+		for i := range items {
+			if items[i].ID != id {
+				continue
+			}
+			if pendingOnly && items[i].Status != "pending" {
+				return nil, fmt.Errorf("approval %s is already %s", id, items[i].Status)
+			}
 			items[i].Status = status
 			items[i].UpdatedAt = time.Now()
-			if err := writeJSONFile(execApprovalsPath(), items); err != nil {
-				return err
+			if status == "denied" {
+				items[i].Payload = ""
 			}
-			fmt.Printf("%s: %s\n", status, id)
-			return nil
+			return items, nil
 		}
-	}
-	return fmt.Errorf("approval not found: %s", id)
+		return nil, fmt.Errorf("approval not found: %s", id)
+	})
 }
 
 func listHookFiles() ([]string, error) {
@@ -2772,12 +3162,173 @@ func memoryHealthBackend(cfg *config.Config) bool {
 	return agent.MemoryHealth(cfg)
 }
 
-// logTask 记录任务日志（安全调用，taskStore 为 nil 时静默跳过）
-func logTask(action, module, sessionKey, channel, sender, request, response, status string, duration time.Duration, tokensIn, tokensOut int, model string) {
-	if taskStore == nil {
+// logDLPHits 把一次运行中的 DLP 命中逐条写入任务日志
+func logDLPHits(sessionKey, channel, sender string, result *agent.RunResult) {
+	if result == nil {
 		return
 	}
-	_ = taskStore.Log(&tasklog.TaskRecord{
+	for _, h := range result.DLPHits {
+		logTask(tasklog.ActionDLP, "dlp", sessionKey, channel, sender,
+			fmt.Sprintf("%s matched %s", h.Source, h.Rule), h.Sample, h.Action, 0, agent.TokenUsage{}, "")
+	}
+}
+
+// toolAuditInputChars / toolAuditOutputChars 工具审计记录中参数和输出保留的长度，完整输出以摘要比对
+const (
+	toolAuditInputChars  = 2000
+	toolAuditOutputChars = 500
+)
+
+// logChatRun 写入聊天记录，再把本次运行的工具调用（含子 agent）作为子记录写入；
+// 运行失败时同样记录失败前已执行的工具调用
+func logChatRun(rec *tasklog.TaskRecord, result *agent.RunResult, err error) {
+	var calls, delegated []agent.ToolCall
+	var runErr *agent.RunError
+	switch {
+	case result != nil:
+		rec.RunID = result.RunID
+		calls = result.ToolCalls
+		for _, d := range result.Delegations {
+			delegated = append(delegated, d.ToolCalls...)
+		}
+	case errors.As(err, &runErr):
+		rec.RunID = runErr.RunID
+		calls = runErr.ToolCalls
+	}
+	logTaskRecord(rec)
+	if rec.ID == 0 {
+		return
+	}
+	logToolCalls(rec, "agent", calls)
+	logToolCalls(rec, "delegate", delegated)
+}
+
+// logToolCalls 把工具调用逐条写入任务日志，关联到父聊天记录
+func logToolCalls(parent *tasklog.TaskRecord, module string, calls []agent.ToolCall) {
+	for _, tc := range calls {
+		status := "success"
+		if tc.Error != "" {
+			status = "error"
+		}
+		logTaskRecord(&tasklog.TaskRecord{
+			Action:       tasklog.ActionTool,
+			Module:       module,
+			SessionKey:   parent.SessionKey,
+			Channel:      parent.Channel,
+			Sender:       parent.Sender,
+			GroupID:      parent.GroupID,
+			AgentID:      parent.AgentID,
+			RequestBody:  truncateString(tc.Input, toolAuditInputChars),
+			ResponseBody: truncateString(tc.Output, toolAuditOutputChars),
+			Status:       status,
+			ErrorMessage: tc.Error,
+			DurationMs:   tc.DurationMs,
+			RunID:        parent.RunID,
+			ParentID:     parent.ID,
+			Tool:         tc.Name,
+			Policy:       tc.Policy,
+			Risk:         tc.Risk,
+			Approval:     tc.Approval,
+			OutputHash:   tc.OutputHash,
+		})
+	}
+}
+
+// queueDLPApproval 为被扣留的回复创建待审批记录，返回审批 ID。target 是审批通过后的投递目标：
+// 飞书为原消息 ID，web 控制台为会话 key
+func queueDLPApproval(channel, target, sessionKey, sender string, result *agent.RunResult) (string, error) {
+	suffix, err := randomHex(4)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	item := execApproval{
+		ID:        "dlp-" + suffix,
+		Command:   fmt.Sprintf("deliver reply to %s (%s)", sessionKey, strings.Join(security.DLPRuleNames(result.DLPHits), ", ")),
+		Requester: sender,
+		Status:    "pending",
+		Reason:    "reply contains sensitive data",
+		CreatedAt: now,
+		UpdatedAt: now,
+		Kind:      "dlp",
+		Channel:   channel,
+		Target:    target,
+		Payload:   result.Withheld,
+	}
+	err = updateExecApprovals(func(items []execApproval) ([]execApproval, error) {
+		return append(items, item), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	// 扣留的原文含敏感数据，收紧文件权限
+	_ = os.Chmod(execApprovalsPath(), 0o600)
+	return item.ID, nil
+}
+
+// logInjections 把提示词注入检测和 taint 拦截逐条写入任务日志
+func logInjections(sessionKey, channel, sender string, result *agent.RunResult) {
+	if result == nil {
+		return
+	}
+	for _, h := range result.Injections {
+		status := "detected"
+		if h.Escalated {
+			status = "escalated"
+		}
+		logTask(tasklog.ActionInjection, "prompt_guard", sessionKey, channel, sender,
+			fmt.Sprintf("%s: %s", h.Tool, strings.Join(h.Signals, ", ")), h.Sample, status, 0, agent.TokenUsage{}, "")
+	}
+}
+
+// execApprover 基于 exec_approvals.json 的人工审批：同一会话、同一命令批准后可执行一次，
+// 未批准时复用或创建待审批记录
+func execApprover(ctx context.Context, req agent.ApprovalRequest) (bool, string, error) {
+	var approved bool
+	var id string
+	err := updateExecApprovals(func(items []execApproval) ([]execApproval, error) {
+		for i := range items {
+			it := &items[i]
+			if it.Kind != "exec" || it.Requester != req.SessionKey || it.Command != req.Command || it.Delivered {
+				continue
+			}
+			switch it.Status {
+			case "approved":
+				it.Delivered = true
+				it.UpdatedAt = time.Now()
+				approved, id = true, it.ID
+				return items, nil
+			case "pending":
+				id = it.ID
+				return nil, nil
+			}
+		}
+		suffix, err := randomHex(4)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		id = "exec-" + suffix
+		return append(items, execApproval{
+			ID:        id,
+			Command:   req.Command,
+			Requester: req.SessionKey,
+			Status:    "pending",
+			Reason:    req.Reason,
+			CreatedAt: now,
+			UpdatedAt: now,
+			Kind:      "exec",
+		}), nil
+	})
+	if err != nil {
+		return false, "", err
+	}
+	return approved, id, nil
+}
+
+// logTask 记录任务日志（安全调用，taskStore 为 nil 时静默跳过）
+func logTask(action, module, sessionKey, channel, sender, request, response, status string, duration time.Duration, usage agent.TokenUsage, model string) {
+	logTaskRecord(&tasklog.TaskRecord{
 		Action:       action,
 		Module:       module,
 		SessionKey:   sessionKey,
@@ -2787,8 +3338,28 @@ func logTask(action, module, sessionKey, channel, sender, request, response, sta
 		ResponseBody: response,
 		Status:       status,
 		DurationMs:   duration.Milliseconds(),
-		TokensInput:  tokensIn,
-		TokensOutput: tokensOut,
+		TokensInput:  usage.InputTokens,
+		TokensOutput: usage.OutputTokens,
+		CacheRead:    usage.CacheRead,
+		CacheWrite:   usage.CacheWrite,
+		CostUSD:      usage.CostUSD,
 		Model:        model,
 	})
 }
+
+// taskSpend 月度预算的费用来源：tasklog 中 since 以来聊天记录的费用合计
+func taskSpend(since time.Time) (float64, error) {
+	if taskStore == nil {
+		return 0, nil
+	}
+	u, err := taskStore.Usage(tasklog.UsageFilter{Since: since})
+	return u.CostUSD, err
+}
+
+// logTaskRecord 写入一条完整的任务记录（taskStore 为 nil 时静默跳过）
+func logTaskRecord(rec *tasklog.TaskRecord) {
+	if taskStore == nil {
+		return
+	}
+	_ = taskStore.Log(rec)
+}
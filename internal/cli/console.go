@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/highclaw/highclaw/internal/agent"
+	"github.com/highclaw/highclaw/internal/config"
+	"github.com/highclaw/highclaw/internal/gateway/session"
+	"github.com/highclaw/highclaw/internal/interfaces/http"
+	"github.com/highclaw/highclaw/internal/system/metrics"
+	"github.com/highclaw/highclaw/internal/system/tasklog"
+	"github.com/highclaw/highclaw/internal/system/tracing"
+)
+
+// consoleChannel web 控制台对话使用的渠道名
+const consoleChannel = "web"
+
+// consoleRunTimeout 控制台单轮对话的超时
+const consoleRunTimeout = 10 * time.Minute
+
+// consoleChat 返回 web 控制台的对话回调：加载会话历史（含 /model 覆盖），运行 agent，保存历史并写任务日志。
+// sessionKey 为空时新建会话。
+func consoleChat(live *config.Live, runner *agent.Runner) http.ConsoleChatFunc {
+	return func(ctx context.Context, sessionKey, message string, onProgress func(agent.ProgressEvent)) (*http.ConsoleChatResult, error) {
+		cfg := live.Get()
+		sessionKey = strings.TrimSpace(sessionKey)
+		if sessionKey == "" {
+			sessionKey = fmt.Sprintf("agent:%s:%s-%d", config.MainAgentID, consoleChannel, time.Now().UnixNano())
+		}
+		agentID := session.ResolveAgentID(cfg, session.PeerContext{Channel: consoleChannel})
+		if parts := strings.Split(sessionKey, ":"); len(parts) >= 3 && parts[0] == "agent" {
+			agentID = parts[1]
+		}
+
+		var history []agent.ChatMessage
+		var modelOverride string
+		if existing, err := session.Load(sessionKey); err == nil {
+			modelOverride = existing.ModelOverride
+			for _, m := range existing.Messages() {
+				role := strings.TrimSpace(m.Role)
+				content := strings.TrimSpace(m.Content)
+				if role != "" && content != "" {
+					history = append(history, agent.ChatMessage{Role: role, Content: content})
+				}
+			}
+		}
+		history = append(history, agent.ChatMessage{Role: "user", Content: message})
+		metrics.MessagesIn.Inc(consoleChannel)
+
+		ctx, cancel := context.WithTimeout(ctx, consoleRunTimeout)
+		defer cancel()
+		ctx, span := tracing.Start(ctx, "console.chat", "session", sessionKey)
+		start := time.Now()
+		result, err := runner.Run(ctx, &agent.RunRequest{
+			SessionKey: sessionKey,
+			Channel:    consoleChannel,
+			Sender:     "console",
+			AgentID:    agentID,
+			Message:    message,
+			History:    history,
+			Model:      modelOverride,
+			OnProgress: onProgress,
+		})
+		span.RecordError(err)
+		span.End()
+
+		modelName := modelOverride
+		if modelName == "" {
+			modelName = cfg.ForAgent(agentID).Agent.Model
+		}
+		rec := &tasklog.TaskRecord{
+			Action:      tasklog.ActionChat,
+			Module:      "agent",
+			SessionKey:  sessionKey,
+			Channel:     consoleChannel,
+			Sender:      "console",
+			AgentID:     agentID,
+			RequestBody: message,
+			DurationMs:  time.Since(start).Milliseconds(),
+			Model:       modelName,
+		}
+		if err != nil {
+			metrics.MessagesOut.Inc(consoleChannel, "error")
+			rec.Status = "error"
+			rec.ResponseBody = err.Error()
+			logChatRun(rec, nil, err)
+			return nil, err
+		}
+		if result.Model != "" {
+			modelName = result.Model
+		}
+
+		// approve：扣留的回复审批通过后由 deliverApprovedReplies 追加到本会话
+		if result.DLPAction == config.DLPActionApprove && result.Withheld != "" {
+			id, err := queueDLPApproval(consoleChannel, sessionKey, sessionKey, "console", result)
+			if err != nil {
+				slog.Error("dlp: queue approval failed", "session", sessionKey, "error", err)
+			} else {
+				result.Reply += "\n(approval id: " + id + ")"
+				slog.Warn("dlp: reply withheld pending approval", "session", sessionKey, "approval", id)
+			}
+		}
+
+		history = append(history, agent.ChatMessage{Role: "assistant", Content: result.Reply})
+		if err := saveCLISessionFull(sessionKey, consoleChannel, modelName, history); err != nil {
+			return nil, fmt.Errorf("save session: %w", err)
+		}
+
+		rec.Status = "success"
+		rec.ResponseBody = truncateString(result.Reply, 500)
+		rec.TokensInput = result.TokensUsed.InputTokens
+		rec.TokensOutput = result.TokensUsed.OutputTokens
+		rec.CacheRead = result.TokensUsed.CacheRead
+		rec.CacheWrite = result.TokensUsed.CacheWrite
+		rec.CostUSD = result.TokensUsed.CostUSD
+		rec.Model = modelName
+		logChatRun(rec, result, nil)
+		logDLPHits(sessionKey, consoleChannel, "console", result)
+		logInjections(sessionKey, consoleChannel, "console", result)
+		metrics.MessagesOut.Inc(consoleChannel, "success")
+
+		return &http.ConsoleChatResult{
+			SessionKey:   sessionKey,
+			Reply:        result.Reply,
+			Model:        modelName,
+			RunID:        result.RunID,
+			InputTokens:  result.TokensUsed.InputTokens,
+			OutputTokens: result.TokensUsed.OutputTokens,
+		}, nil
+	}
+}
+
+// listConsoleApprovals 返回待审批的命令和 DLP 扣留回复，最早的在前
+func listConsoleApprovals() ([]http.Approval, error) {
+	items, err := loadExecApprovals()
+	if err != nil {
+		return nil, err
+	}
+	var out []http.Approval
+	for _, it := range items {
+		if it.Status != "pending" {
+			continue
+		}
+		out = append(out, http.Approval{
+			ID:        it.ID,
+			Kind:      it.Kind,
+			Command:   it.Command,
+			Requester: it.Requester,
+			Reason:    it.Reason,
+			Status:    it.Status,
+			CreatedAt: it.CreatedAt,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+// resolveConsoleApproval 批准或拒绝一条待审批项；已处理的审批不能再改
+func resolveConsoleApproval(id string, approve bool) error {
+	status := "denied"
+	if approve {
+		status = "approved"
+	}
+	return setExecApproval(id, status, true)
+}
@@ -7,7 +7,9 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -19,7 +21,9 @@ import (
 	"github.com/highclaw/highclaw/internal/infrastructure/channels/feishu"
 	"github.com/highclaw/highclaw/internal/interfaces/http"
 	syslogger "github.com/highclaw/highclaw/internal/system/logger"
+	"github.com/highclaw/highclaw/internal/system/metrics"
 	"github.com/highclaw/highclaw/internal/system/tasklog"
+	"github.com/highclaw/highclaw/internal/system/tracing"
 	"github.com/spf13/cobra"
 )
 
@@ -84,6 +88,21 @@ func initFileLogger(cfg *config.Config, verbose bool) (*slog.Logger, *syslogger.
 	return logger, mgr
 }
 
+// initTracing 按 observability.tracing 配置启动 trace 导出；失败只告警，不影响运行
+func initTracing(cfg *config.Config, logger *slog.Logger) {
+	tr := cfg.Observability.Tracing
+	err := tracing.Configure(tracing.Config{
+		Exporter:    tr.Exporter,
+		Endpoint:    tr.Endpoint,
+		Headers:     tr.Headers,
+		File:        tr.File,
+		ServiceName: tr.ServiceName,
+	}, logger)
+	if err != nil {
+		logger.Warn("tracing init failed", "error", err)
+	}
+}
+
 // initTaskLog 根据配置初始化任务日志
 func initTaskLog(cfg *config.Config) *tasklog.Store {
 	enabled := true
@@ -149,9 +168,20 @@ func runGateway(cmd *cobra.Command, args []string) error {
 		})
 	}
 
+	// 初始化运行追踪导出
+	initTracing(cfg, logger)
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = tracing.Shutdown(shutdownCtx)
+	}()
+
 	// Print banner.
 	infra.PrintBanner(version)
 
+	// 配置文件中的监听地址，热加载据此判断是否需要重启（不受命令行覆盖影响）
+	filePort, fileBind := cfg.Gateway.Port, cfg.Gateway.Bind
+
 	// Override config with CLI flags.
 	if cmd.Flags().Changed("port") {
 		if gatewayPort == 0 {
@@ -175,9 +205,16 @@ func runGateway(cmd *cobra.Command, args []string) error {
 		"dev", gatewayDev,
 	)
 
+	// 运行中共享的配置，热加载时整体替换
+	live := config.NewLive(cfg)
+
 	// Create agent runner, session manager, skill manager, and log buffer
 	runner := agent.NewRunner(cfg, logger)
+	defer runner.Close()
+	runner.SetApprover(execApprover)
+	runner.SetSpendSource(taskSpend)
 	sessions := session.NewManager()
+	metrics.ActiveSessions.Set(func() float64 { return float64(sessions.Count()) })
 	logBuffer := http.NewLogBuffer(200)
 
 	// Wrap logger with log buffer handler
@@ -186,28 +223,71 @@ func runGateway(cmd *cobra.Command, args []string) error {
 	slog.SetDefault(logger)
 
 	// Create HTTP server (health + internal reload only)
-	httpServer := http.NewServer(cfg, logger, logBuffer)
+	httpServer := http.NewServer(live, logger, logBuffer)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// 飞书 channel 实例（gateway 级别，供 reload 引用）
-	var feishuCh *feishu.FeishuChannel
+	// 飞书 channel 实例（gateway 级别，供 reload 引用）；热加载会替换它，读取方每次 Load
+	var feishuCh atomic.Pointer[feishu.FeishuChannel]
+
+	// 所有渠道共用的入站处理（配额、DLP 审批、任务日志）
+	pipeline := newInboundPipeline(live, runner, sessions, logger)
+	pipeline.notify = func(notifyCtx context.Context, target, text string) error {
+		channel, id, ok := strings.Cut(target, ":")
+		if !ok || id == "" {
+			return fmt.Errorf("invalid notify target %q, expected <channel>:<id>", target)
+		}
+		switch channel {
+		case "feishu":
+			ch := feishuCh.Load()
+			if ch == nil {
+				return fmt.Errorf("feishu channel not running")
+			}
+			return ch.SendText(notifyCtx, id, text)
+		}
+		return fmt.Errorf("notify not supported for channel %q", channel)
+	}
 
 	// 启动飞书 channel（长连接模式 + bind 验证码）
 	if cfg.Channels.Feishu != nil && cfg.Channels.Feishu.AppID != "" {
-		feishuCh = startFeishuChannel(ctx, cfg, runner, sessions, logger)
+		feishuCh.Store(startFeishuChannel(ctx, cfg, pipeline, logger))
+	}
+
+	// 配置热加载：HTTP reload、SIGHUP 和配置文件变更共用同一流程
+	reloader := &configReloader{
+		cfg:      live,
+		runCtx:   ctx,
+		runner:   runner,
+		pipeline: pipeline,
+		logger:   logger,
+		logMgr:   logMgr,
+		verbose:  gatewayVerbose,
+		feishuCh: &feishuCh,
+		filePort: filePort,
+		fileBind: fileBind,
 	}
-
-	// 注入 channel reload 回调
 	httpServer.SetReloadChannels(func(reloadCtx context.Context) (*http.ChannelReloadResult, error) {
-		return reloadChannels(reloadCtx, ctx, cfg, runner, sessions, logger, &feishuCh)
+		return reloader.reload(reloadCtx, "http", true)
 	})
+	// 监听列表取自当前配置，热加载后 include / profile 文件的增减随之生效
+	go config.Watch(ctx, func() []string { return watchedConfigFiles(live.Get()) }, config.DefaultWatchInterval, func() {
+		_, _ = reloader.reload(ctx, "watch", false)
+	})
+
+	// DLP 扣留的回复审批通过后补发
+	go deliverApprovedReplies(ctx, feishuCh.Load, logger)
 
 	// 注入 channel 运行时状态查询回调
 	httpServer.SetGetChannelStatus(func() *http.ChannelStatusResult {
-		return getChannelStatus(cfg, feishuCh)
+		return getChannelStatus(live.Get(), feishuCh.Load())
 	})
 
+	// 注入 web 控制台的对话、审批和任务检索
+	httpServer.SetChat(consoleChat(live, runner))
+	httpServer.SetApprovals(listConsoleApprovals, resolveConsoleApproval)
+	httpServer.SetSessionReset(runner.ResetSession)
+	httpServer.SetTaskStore(taskStore)
+
 	// 启动 HTTP server，检测端口绑定是否成功
 	serverErr := make(chan error, 1)
 	go func() {
@@ -238,8 +318,8 @@ func runGateway(cmd *cobra.Command, args []string) error {
 	for {
 		lastSig = <-sigCh
 		if lastSig == syscall.SIGHUP {
-			slog.Info("received SIGHUP, reloading channels")
-			if result, err := reloadChannels(ctx, ctx, cfg, runner, sessions, logger, &feishuCh); err != nil {
+			slog.Info("received SIGHUP, reloading config")
+			if result, err := reloader.reload(ctx, "sighup", true); err != nil {
 				slog.Error("SIGHUP reload failed", "error", err)
 			} else {
 				slog.Info("SIGHUP reload complete", "reloaded", result.Reloaded)
@@ -269,8 +349,7 @@ func runGateway(cmd *cobra.Command, args []string) error {
 func startFeishuChannel(
 	ctx context.Context,
 	cfg *config.Config,
-	runner *agent.Runner,
-	sessions *session.Manager,
+	pipeline *inboundPipeline,
 	logger *slog.Logger,
 ) *feishu.FeishuChannel {
 	ch := feishu.NewFeishuChannel(feishu.Config{
@@ -284,7 +363,7 @@ func startFeishuChannel(
 	}, logger)
 
 	ch.SetMessageHandler(func(msgCtx context.Context, msg *feishu.ParsedMessage) (string, error) {
-		return processFeishuMsg(msgCtx, cfg, runner, sessions, logger, msg)
+		return processFeishuMsg(msgCtx, pipeline, msg)
 	})
 
 	if err := ch.Start(ctx); err != nil {
@@ -295,48 +374,38 @@ func startFeishuChannel(
 	return ch
 }
 
-// reloadChannels 重读配置，增量启停 channel（当前仅支持 Feishu）
+// reloadChannels 按已更新的配置增量启停 channel（当前仅支持 Feishu），
+// oldFeishuCfg 为更新前的 feishu 配置，用于判断是否需要重启
 func reloadChannels(
 	reloadCtx context.Context,
 	runCtx context.Context,
 	cfg *config.Config,
-	runner *agent.Runner,
-	sessions *session.Manager,
+	oldFeishuCfg *config.FeishuConfig,
+	pipeline *inboundPipeline,
 	logger *slog.Logger,
-	feishuChPtr **feishu.FeishuChannel,
+	feishuChPtr *atomic.Pointer[feishu.FeishuChannel],
 ) (*http.ChannelReloadResult, error) {
-	newCfg, err := config.Load()
-	if err != nil {
-		return nil, fmt.Errorf("reload config: %w", err)
-	}
-
-	// 覆写前保存旧的 feishu 配置，用于后续比较是否需要重启
-	oldFeishuCfg := cfg.Channels.Feishu
-
-	// 更新内存中的配置
-	*cfg = *newCfg
-
 	result := &http.ChannelReloadResult{
 		Channels: make(map[string]http.ChannelStatus),
 	}
 
-	feishuCh := *feishuChPtr
-	feishuCfg := newCfg.Channels.Feishu
+	feishuCh := feishuChPtr.Load()
+	feishuCfg := cfg.Channels.Feishu
 
 	switch {
 	case feishuCfg == nil || feishuCfg.AppID == "":
 		// 配置已删除：停止现有 channel
 		if feishuCh != nil {
 			_ = feishuCh.Stop(reloadCtx)
-			*feishuChPtr = nil
+			feishuChPtr.Store(nil)
 			result.Reloaded = append(result.Reloaded, "feishu:stopped")
 		}
 		result.Channels["feishu"] = http.ChannelStatus{Status: "disabled"}
 
 	case feishuCh == nil:
 		// 新增 channel：首次启动
-		*feishuChPtr = startFeishuChannel(runCtx, cfg, runner, sessions, logger)
-		ch := *feishuChPtr
+		ch := startFeishuChannel(runCtx, cfg, pipeline, logger)
+		feishuChPtr.Store(ch)
 		status := http.ChannelStatus{Status: "started"}
 		if ch != nil && !ch.IsBound() {
 			status.BindCode = ch.BindCode()
@@ -355,8 +424,8 @@ func reloadChannels(
 
 		if needRestart {
 			_ = feishuCh.Stop(reloadCtx)
-			*feishuChPtr = startFeishuChannel(runCtx, cfg, runner, sessions, logger)
-			ch := *feishuChPtr
+			ch := startFeishuChannel(runCtx, cfg, pipeline, logger)
+			feishuChPtr.Store(ch)
 			status := http.ChannelStatus{Status: "restarted"}
 			if ch != nil && !ch.IsBound() {
 				status.BindCode = ch.BindCode()
@@ -397,89 +466,89 @@ func getChannelStatus(cfg *config.Config, feishuCh *feishu.FeishuChannel) *http.
 	return result
 }
 
-// processFeishuMsg 处理飞书消息：会话路由 → 构建历史 → 调用 Agent → 返回回复
-func processFeishuMsg(
-	ctx context.Context,
-	cfg *config.Config,
-	runner *agent.Runner,
-	sessions *session.Manager,
-	logger *slog.Logger,
-	msg *feishu.ParsedMessage,
-) (string, error) {
-	peerKind := "direct"
-	groupID := ""
-	if msg.ChatType == "group" {
-		peerKind = "group"
-		groupID = msg.ChatID
+// processFeishuMsg 把飞书消息转换为通用入站消息，交给共用的入站处理
+func processFeishuMsg(ctx context.Context, pipeline *inboundPipeline, msg *feishu.ParsedMessage) (string, error) {
+	in := inboundMessage{
+		Channel:   "feishu",
+		SenderID:  msg.SenderID,
+		PeerKind:  "direct",
+		MessageID: msg.MessageID,
+		Text:      msg.Text,
+		Images:    msg.Images,
 	}
-
-	peer := session.PeerContext{
-		Channel:  "feishu",
-		PeerID:   msg.SenderID,
-		PeerKind: peerKind,
-		GroupID:  groupID,
+	if msg.ChatType == "group" {
+		in.PeerKind = "group"
+		in.GroupID = msg.ChatID
 	}
-	sessionKey := session.ResolveSessionFromConfig(cfg, peer)
-
-	var history []agent.ChatMessage
-	if sessions != nil {
-		sess := sessions.GetOrCreate(sessionKey, "feishu")
-		sess.AddMessage(protocol.ChatMessage{
-			Role:    "user",
-			Content: msg.Text,
-			Channel: "feishu",
-		})
+	return pipeline.handle(ctx, in)
+}
 
-		allMsgs := sess.Messages()
-		limit := 16
-		start := 0
-		if len(allMsgs) > limit {
-			start = len(allMsgs) - limit
+// deliverApprovedReplies 定期检查 exec-approvals 中已批准的 DLP 扣留回复，按渠道投递后清除原文：
+// 飞书回复原消息，web 控制台追加到会话历史
+func deliverApprovedReplies(ctx context.Context, feishuCh func() *feishu.FeishuChannel, logger *slog.Logger) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
 		}
-		for _, m := range allMsgs[start:] {
-			role := strings.ToLower(strings.TrimSpace(m.Role))
-			if role != "user" && role != "assistant" && role != "system" {
+		items, err := loadExecApprovals()
+		if err != nil {
+			continue
+		}
+		var delivered []string
+		for _, it := range items {
+			if it.Kind != "dlp" || it.Status != "approved" || it.Delivered {
 				continue
 			}
-			content := strings.TrimSpace(m.Content)
-			if content == "" {
+			if err := deliverApprovedReply(ctx, it, feishuCh); err != nil {
+				logger.Warn("dlp: approved reply delivery failed", "approval", it.ID, "channel", it.Channel, "error", err)
 				continue
 			}
-			if len([]rune(content)) > 3000 {
-				content = string([]rune(content)[:3000]) + "..."
+			delivered = append(delivered, it.ID)
+			logTask(tasklog.ActionDLP, "dlp", "", it.Channel, it.Requester,
+				"approved reply delivered: "+it.ID, "", "success", 0, agent.TokenUsage{}, "")
+		}
+		if len(delivered) == 0 {
+			continue
+		}
+		// 投递期间文件可能被其他审批修改，重新加载后只标记已投递的项
+		err = updateExecApprovals(func(items []execApproval) ([]execApproval, error) {
+			for i := range items {
+				if slices.Contains(delivered, items[i].ID) {
+					items[i].Delivered = true
+					items[i].Payload = ""
+					items[i].UpdatedAt = time.Now()
+				}
 			}
-			history = append(history, agent.ChatMessage{Role: role, Content: content})
+			return items, nil
+		})
+		if err != nil {
+			logger.Error("dlp: update approvals failed", "error", err)
 		}
 	}
+}
 
-	if runner == nil {
-		return "", fmt.Errorf("agent not available")
-	}
-
-	result, err := runner.Run(ctx, &agent.RunRequest{
-		SessionKey: sessionKey,
-		Channel:    "feishu",
-		Sender:     msg.SenderID,
-		MessageID:  msg.MessageID,
-		Message:    msg.Text,
-		History:    history,
-	})
-	if err != nil {
-		return "", err
-	}
-
-	if sessions != nil {
-		if sess, ok := sessions.Get(sessionKey); ok {
-			sess.AddMessage(protocol.ChatMessage{
-				Role:    "assistant",
-				Content: result.Reply,
-				Channel: "feishu",
-			})
+// deliverApprovedReply 把一条已批准的扣留回复投递到其渠道
+func deliverApprovedReply(ctx context.Context, it execApproval, feishuCh func() *feishu.FeishuChannel) error {
+	switch it.Channel {
+	case "feishu":
+		ch := feishuCh()
+		if ch == nil {
+			return fmt.Errorf("feishu channel is not running")
+		}
+		return ch.ReplyMessage(ctx, it.Target, it.Payload)
+	case consoleChannel:
+		sess, err := session.Load(it.Target)
+		if err != nil {
+			return err
 		}
+		sess.AddMessage(protocol.ChatMessage{Role: "assistant", Content: it.Payload, Channel: consoleChannel})
+		return sess.Save()
 	}
-
-	logger.Info("feishu message processed", "session", sessionKey)
-	return result.Reply, nil
+	return fmt.Errorf("channel %q cannot deliver withheld replies", it.Channel)
 }
 
 func pickRandomPort() (int, error) {
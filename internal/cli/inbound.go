@@ -0,0 +1,236 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/highclaw/highclaw/internal/agent"
+	"github.com/highclaw/highclaw/internal/config"
+	"github.com/highclaw/highclaw/internal/gateway/protocol"
+	"github.com/highclaw/highclaw/internal/gateway/session"
+	"github.com/highclaw/highclaw/internal/security"
+	"github.com/highclaw/highclaw/internal/system/metrics"
+	"github.com/highclaw/highclaw/internal/system/tasklog"
+	"github.com/highclaw/highclaw/internal/system/tracing"
+)
+
+// defaultQuotaMessage 超出配额时回复用户的默认文本
+const defaultQuotaMessage = "Sorry, you've reached the usage limit ({limit}). Please try again later."
+
+// inboundMessage 渠道无关的入站消息
+type inboundMessage struct {
+	Channel   string
+	SenderID  string
+	PeerKind  string // "direct" | "group"
+	GroupID   string
+	MessageID string
+	Text      string
+	// Images 入站图片的原始字节，由 Agent 缩放后发给支持图片的模型
+	Images [][]byte
+}
+
+// inboundPipeline 所有渠道共用的入站处理：会话路由 → 配额 → 构建历史 → 调用 Agent → DLP 审批 → 任务日志
+type inboundPipeline struct {
+	cfg      *config.Live
+	runner   *agent.Runner
+	sessions *session.Manager
+	logger   *slog.Logger
+	quotas   *security.QuotaGuard
+	// notify 向 "<channel>:<id>" 发送管理员通知，由 gateway 按已启动的渠道注入
+	notify func(ctx context.Context, target, text string) error
+}
+
+func newInboundPipeline(cfg *config.Live, runner *agent.Runner, sessions *session.Manager, logger *slog.Logger) *inboundPipeline {
+	var usage security.UsageStore
+	if taskStore != nil {
+		usage = taskStore
+	}
+	return &inboundPipeline{
+		cfg:      cfg,
+		runner:   runner,
+		sessions: sessions,
+		logger:   logger,
+		quotas:   security.NewQuotaGuard(usage),
+	}
+}
+
+// handle 处理一条入站消息，返回发给用户的回复
+func (p *inboundPipeline) handle(ctx context.Context, msg inboundMessage) (string, error) {
+	cfg := p.cfg.Get()
+	peerKind := msg.PeerKind
+	if peerKind == "" {
+		peerKind = "direct"
+	}
+	ctx, span := tracing.Start(ctx, "inbound.message", "channel", msg.Channel, "peer_kind", peerKind, "images", len(msg.Images))
+	defer span.End()
+	metrics.MessagesIn.Inc(msg.Channel)
+
+	_, resolveSpan := tracing.Start(ctx, "session.resolve")
+	peer := session.PeerContext{
+		Channel:  msg.Channel,
+		PeerID:   msg.SenderID,
+		PeerKind: peerKind,
+		GroupID:  msg.GroupID,
+	}
+	sessionKey := session.ResolveSessionFromConfig(cfg, peer)
+	agentID := session.ResolveAgentID(cfg, peer)
+
+	if hit := p.quotas.Check(cfg.Quotas, security.QuotaSubject{
+		Channel: msg.Channel,
+		Sender:  msg.SenderID,
+		GroupID: msg.GroupID,
+		AgentID: agentID,
+	}); hit != nil {
+		resolveSpan.SetAttrs("session", sessionKey, "agent", agentID, "quota", hit.Scope)
+		resolveSpan.End()
+		metrics.MessagesOut.Inc(msg.Channel, "quota")
+		return p.quotaExceeded(ctx, msg, sessionKey, hit), nil
+	}
+
+	var history []agent.ChatMessage
+	if p.sessions != nil {
+		sess := p.sessions.GetOrCreate(sessionKey, msg.Channel)
+		sess.AddMessage(protocol.ChatMessage{
+			Role:    "user",
+			Content: msg.Text,
+			Channel: msg.Channel,
+		})
+
+		allMsgs := sess.Messages()
+		limit := 16
+		start := 0
+		if len(allMsgs) > limit {
+			start = len(allMsgs) - limit
+		}
+		for _, m := range allMsgs[start:] {
+			role := strings.ToLower(strings.TrimSpace(m.Role))
+			if role != "user" && role != "assistant" && role != "system" {
+				continue
+			}
+			content := strings.TrimSpace(m.Content)
+			if content == "" {
+				continue
+			}
+			if len([]rune(content)) > 3000 {
+				content = string([]rune(content)[:3000]) + "..."
+			}
+			history = append(history, agent.ChatMessage{Role: role, Content: content})
+		}
+	}
+
+	resolveSpan.SetAttrs("session", sessionKey, "agent", agentID, "history", len(history))
+	resolveSpan.End()
+
+	if p.runner == nil {
+		metrics.MessagesOut.Inc(msg.Channel, "error")
+		return "", fmt.Errorf("agent not available")
+	}
+
+	runStart := time.Now()
+	result, err := p.runner.Run(ctx, &agent.RunRequest{
+		SessionKey: sessionKey,
+		Channel:    msg.Channel,
+		Sender:     msg.SenderID,
+		PeerKind:   peerKind,
+		GroupID:    msg.GroupID,
+		MessageID:  msg.MessageID,
+		Message:    msg.Text,
+		History:    history,
+		Images:     msg.Images,
+	})
+	rec := &tasklog.TaskRecord{
+		Action:      tasklog.ActionChat,
+		Module:      "agent",
+		SessionKey:  sessionKey,
+		Channel:     msg.Channel,
+		Sender:      msg.SenderID,
+		GroupID:     msg.GroupID,
+		AgentID:     agentID,
+		RequestBody: msg.Text,
+		DurationMs:  time.Since(runStart).Milliseconds(),
+		Model:       cfg.ForAgent(agentID).Agent.Model,
+	}
+	if err != nil {
+		span.RecordError(err)
+		metrics.MessagesOut.Inc(msg.Channel, "error")
+		rec.Status = "error"
+		rec.ResponseBody = err.Error()
+		logChatRun(rec, nil, err)
+		return "", err
+	}
+	rec.Status = "success"
+	rec.ResponseBody = truncateString(result.Reply, 500)
+	rec.TokensInput = result.TokensUsed.InputTokens
+	rec.TokensOutput = result.TokensUsed.OutputTokens
+	rec.CacheRead = result.TokensUsed.CacheRead
+	rec.CacheWrite = result.TokensUsed.CacheWrite
+	rec.CostUSD = result.TokensUsed.CostUSD
+	if result.Model != "" {
+		rec.Model = result.Model
+	}
+	logChatRun(rec, result, nil)
+
+	logDLPHits(sessionKey, msg.Channel, msg.SenderID, result)
+	logInjections(sessionKey, msg.Channel, msg.SenderID, result)
+	if result.DLPAction == config.DLPActionApprove && result.Withheld != "" {
+		id, err := queueDLPApproval(msg.Channel, msg.MessageID, sessionKey, msg.SenderID, result)
+		if err != nil {
+			p.logger.Error("dlp: queue approval failed", "session", sessionKey, "error", err)
+		} else {
+			result.Reply += "\n(approval id: " + id + ")"
+			p.logger.Warn("dlp: reply withheld pending approval", "session", sessionKey, "approval", id)
+		}
+	}
+
+	if p.sessions != nil {
+		if sess, ok := p.sessions.Get(sessionKey); ok {
+			sess.AddMessage(protocol.ChatMessage{
+				Role:    "assistant",
+				Content: result.Reply,
+				Channel: msg.Channel,
+			})
+		}
+	}
+
+	metrics.MessagesOut.Inc(msg.Channel, "success")
+	p.logger.Info("inbound message processed", "channel", msg.Channel, "session", sessionKey, "trace", span.TraceID())
+	return result.Reply, nil
+}
+
+// quotaExceeded 记录超限、按需通知管理员，并返回给用户的提示
+func (p *inboundPipeline) quotaExceeded(ctx context.Context, msg inboundMessage, sessionKey string, hit *security.QuotaExceeded) string {
+	detail := fmt.Sprintf("%s %s exceeded %s", hit.Scope, hit.Key, hit.Limit)
+	if hit.Used != "" {
+		detail += " (used " + hit.Used + ")"
+	}
+	p.logger.Warn("quota exceeded", "channel", msg.Channel, "sender", msg.SenderID, "scope", hit.Scope, "key", hit.Key, "limit", hit.Limit)
+	logTaskRecord(&tasklog.TaskRecord{
+		Action:       tasklog.ActionQuota,
+		Module:       "quota",
+		SessionKey:   sessionKey,
+		Channel:      msg.Channel,
+		Sender:       msg.SenderID,
+		GroupID:      msg.GroupID,
+		RequestBody:  msg.Text,
+		ResponseBody: detail,
+		Status:       "rejected",
+	})
+
+	if hit.Notify && p.notify != nil {
+		text := fmt.Sprintf("[HighClaw] quota reached on %s: %s", msg.Channel, detail)
+		for _, target := range p.cfg.Get().Quotas.Notify {
+			if err := p.notify(ctx, target, text); err != nil {
+				p.logger.Warn("quota: notify admin failed", "target", target, "error", err)
+			}
+		}
+	}
+
+	reply := strings.TrimSpace(p.cfg.Get().Quotas.Message)
+	if reply == "" {
+		reply = defaultQuotaMessage
+	}
+	return strings.ReplaceAll(reply, "{limit}", hit.Limit)
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/highclaw/highclaw/internal/agent"
+	"github.com/highclaw/highclaw/internal/config"
+	"github.com/highclaw/highclaw/internal/infrastructure/channels/feishu"
+	"github.com/highclaw/highclaw/internal/interfaces/http"
+	syslogger "github.com/highclaw/highclaw/internal/system/logger"
+	"github.com/highclaw/highclaw/internal/system/tasklog"
+)
+
+// agentSections 变更后需要重建 agent 组件（providers、安全策略、记忆存储等）的配置段
+var agentSections = map[string]bool{
+	"agent": true, "autonomy": true, "memory": true, "reliability": true, "modelRoutes": true,
+	"webTools": true, "dlp": true, "promptGuard": true, "cost": true, "composio": true,
+	"browser": true, "identity": true, "secrets": true,
+}
+
+// restartSections 变更后需重启 gateway 才能生效的配置段
+var restartSections = map[string]bool{
+	"observability": true, "tunnel": true, "taskLog": true, "hooks": true,
+}
+
+// configReloader 串行执行配置热加载：校验 → 按配置段差异替换 → 发布新配置
+type configReloader struct {
+	mu       sync.Mutex
+	cfg      *config.Live // gateway 共享的配置，pipeline / HTTP server 每次使用时读取当前值
+	runCtx   context.Context
+	runner   *agent.Runner
+	pipeline *inboundPipeline
+	logger   *slog.Logger
+	logMgr   *syslogger.Manager // 文件日志初始化失败时为 nil
+	verbose  bool
+	feishuCh *atomic.Pointer[feishu.FeishuChannel]
+	filePort int    // 启动时配置文件中的 gateway.port（命令行 --port 覆盖前）
+	fileBind string // 启动时配置文件中的 gateway.bind（命令行 --bind 覆盖前）
+}
+
+// reload 重读配置文件。解析或校验失败时保留旧配置并返回错误；forceChannels 为 true 时
+// 即使 channels 段未变也重新对齐 channel（用于手动 reload）。
+func (cr *configReloader) reload(ctx context.Context, trigger string, forceChannels bool) (*http.ChannelReloadResult, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	next, err := config.Load()
+	if err != nil {
+		return nil, cr.reject(trigger, fmt.Errorf("reload config: %w", err))
+	}
+	// 各层配置文件严格校验，定位到行列；废弃字段等警告只记日志
+	issues := validateConfig(next)
+	for _, path := range next.SourceFiles() {
+		strict, err := config.ValidateFile(path)
+		if err != nil {
+			return nil, cr.reject(trigger, err)
+		}
+		for _, i := range strict {
+			if i.Warning {
+				cr.logger.Warn("config warning", "file", path, "issue", i.String())
+			} else {
+				issues = append(issues, path+":"+i.String())
+			}
+		}
+	}
+	if len(issues) > 0 {
+		return nil, cr.reject(trigger, fmt.Errorf("invalid config: %s", strings.Join(issues, "; ")))
+	}
+
+	cur := cr.cfg.Get()
+	var restart []string
+	// 监听地址在运行中无法变更，沿用当前值（含 --port / --bind 覆盖）；
+	// 与启动时配置文件中的值比较，命令行覆盖本身不算变更
+	if next.Gateway.Port != cr.filePort || next.Gateway.Bind != cr.fileBind {
+		restart = append(restart, "gateway.port/bind")
+	}
+	next.Gateway.Port = cur.Gateway.Port
+	next.Gateway.Bind = cur.Gateway.Bind
+
+	sections := config.ChangedSections(cur, next)
+	changed := make(map[string]bool, len(sections))
+	agentChanged := false
+	for _, s := range sections {
+		changed[s] = true
+		agentChanged = agentChanged || agentSections[s]
+		if restartSections[s] {
+			restart = append(restart, s)
+		}
+	}
+
+	if agentChanged {
+		cr.runner.Reload(next)
+	}
+	if changed["log"] && cr.logMgr != nil && !cr.verbose {
+		cr.logMgr.SetLevel(parseSlogLevel(next.Log.Level))
+	}
+
+	// session、quotas、web、gateway.auth 等由使用方每次读取，发布新配置即生效；
+	// 旧配置对象不再修改，正在使用它的请求不受影响
+	cr.cfg.Store(next)
+
+	result := &http.ChannelReloadResult{Channels: make(map[string]http.ChannelStatus)}
+	if changed["channels"] || forceChannels {
+		result, err = reloadChannels(ctx, cr.runCtx, next, cur.Channels.Feishu, cr.pipeline, cr.logger, cr.feishuCh)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cr.logger.Info("config reloaded", "trigger", trigger, "sections", sections, "restart_required", restart)
+	if taskStore != nil && len(sections) > 0 {
+		body := "config reloaded (" + trigger + "): " + strings.Join(sections, ", ")
+		if len(restart) > 0 {
+			body += "; restart required for " + strings.Join(restart, ", ")
+		}
+		_ = taskStore.Log(&tasklog.TaskRecord{
+			Action:      tasklog.ActionSystem,
+			Module:      "gateway",
+			RequestBody: body,
+			Status:      "success",
+		})
+	}
+	return result, nil
+}
+
+// reject 记录被拒绝的配置变更，旧配置保持不变
+func (cr *configReloader) reject(trigger string, err error) error {
+	cr.logger.Error("config reload rejected, keeping current config", "trigger", trigger, "error", err)
+	return err
+}
+
+// watchedConfigFiles 热加载监听的文件：已加载的各层配置文件，以及尚不存在的用户配置文件
+func watchedConfigFiles(cfg *config.Config) []string {
+	files := cfg.SourceFiles()
+	if !slices.Contains(files, config.ConfigPath()) {
+		files = append(files, config.ConfigPath())
+	}
+	return files
+}
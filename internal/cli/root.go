@@ -3,6 +3,7 @@ package cli
 import (
 	"fmt"
 
+	"github.com/highclaw/highclaw/internal/config"
 	"github.com/spf13/cobra"
 )
 
@@ -10,6 +11,9 @@ var (
 	version   = "dev"
 	buildDate = "unknown"
 	gitCommit = "unknown"
+
+	// configProfile 全局 --profile，选择叠加的命名配置 profile
+	configProfile string
 )
 
 // SetBuildInfo sets version info injected at build time.
@@ -30,6 +34,9 @@ Run your personal AI assistant on your own devices.
 Distributed as a single static binary — no Node.js, no npm, just run it.`,
 	SilenceUsage:  true,
 	SilenceErrors: true,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		config.SetProfile(configProfile)
+	},
 }
 
 var versionCmd = &cobra.Command{
@@ -43,6 +50,7 @@ var versionCmd = &cobra.Command{
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringVar(&configProfile, "profile", "", "Config profile to apply on top of the config files (env HIGHCLAW_PROFILE)")
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(gatewayCmd)
 	rootCmd.AddCommand(agentCmd)
@@ -60,6 +68,7 @@ func init() {
 	rootCmd.AddCommand(devicesCmd)
 	rootCmd.AddCommand(modelsCmd)
 	rootCmd.AddCommand(securityCmd)
+	rootCmd.AddCommand(secretsCmd)
 	rootCmd.AddCommand(hooksCmd)
 	rootCmd.AddCommand(logsCmd)
 	rootCmd.AddCommand(tasksCmd)
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/highclaw/highclaw/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage encrypted secrets in the config file",
+	Long: `Secrets are stored in config.yaml as enc:... values (AES-256-GCM).
+The key is read from ~/.highclaw/secrets.key (created on first use), or derived
+from $` + config.SecretsPassphraseEnv + ` when it is set.
+
+Any string value may also reference ${env:NAME} or ${file:/run/secrets/name}.`,
+}
+
+// secretsSetCmd 加密写入一个配置值
+var secretsSetCmd = &cobra.Command{
+	Use:   "set <key> [value]",
+	Short: "Encrypt and store a config value (reads stdin when value is omitted or '-')",
+	Example: `  highclaw secrets set agent.providers.anthropic.apiKey sk-ant-...
+  echo -n "$TOKEN" | highclaw secrets set channels.telegram.botToken`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("load config: %w", err)
+		}
+		value := ""
+		if len(args) == 2 && args[1] != "-" {
+			value = args[1]
+		} else {
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				return fmt.Errorf("read value from stdin: %w", err)
+			}
+			value = strings.TrimRight(line, "\r\n")
+		}
+		if value == "" {
+			return fmt.Errorf("value cannot be empty")
+		}
+		if err := cfg.SetSecret(args[0], value); err != nil {
+			return err
+		}
+		cfg.Secrets.Encrypt = true
+		if err := config.Save(cfg); err != nil {
+			return err
+		}
+		fmt.Printf("stored encrypted %s\n", args[0])
+		return nil
+	},
+}
+
+// secretsGetCmd 输出解密后的配置值
+var secretsGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print a decrypted config value",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("load config: %w", err)
+		}
+		value, err := cfg.Secret(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+		return nil
+	},
+}
+
+// secretsRotateCmd 换新密钥并重新加密
+var secretsRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Generate a new key and re-encrypt all secrets",
+	Long: `Generates a new key file (the previous one is kept as secrets.key.old) and
+re-encrypts every secret. In passphrase mode a new salt is generated; set
+$` + config.SecretsNewPassphraseEnv + ` to switch to a new passphrase at the same time.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("load config: %w", err)
+		}
+		if err := config.RotateSecretsKey(cfg); err != nil {
+			return err
+		}
+		n := 0
+		for _, s := range cfg.SecretFields() {
+			if s.Storage != "reference" {
+				n++
+			}
+		}
+		fmt.Printf("secrets key rotated, %d value(s) re-encrypted\n", n)
+		if os.Getenv(config.SecretsNewPassphraseEnv) != "" {
+			fmt.Printf("remember to update %s to the new passphrase\n", config.SecretsPassphraseEnv)
+		}
+		return nil
+	},
+}
+
+func init() {
+	secretsCmd.AddCommand(secretsSetCmd)
+	secretsCmd.AddCommand(secretsGetCmd)
+	secretsCmd.AddCommand(secretsRotateCmd)
+}
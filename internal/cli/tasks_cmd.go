@@ -3,10 +3,12 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/highclaw/highclaw/internal/config"
+	"github.com/highclaw/highclaw/internal/security"
 	"github.com/highclaw/highclaw/internal/system/tasklog"
 	"github.com/spf13/cobra"
 )
@@ -24,6 +26,12 @@ var (
 	tasksSort    string
 	tasksMaxAge  int
 	tasksMaxN    int
+
+	tasksCost     bool
+	tasksCostBy   []string
+	tasksCostDays int
+
+	tasksTraceJSON bool
 )
 
 // --- Tasks 命令组 ---
@@ -96,6 +104,9 @@ var tasksListCmd = &cobra.Command{
 				if r.TokensInput > 0 || r.TokensOutput > 0 {
 					fmt.Printf("  tokens: %d/%d", r.TokensInput, r.TokensOutput)
 				}
+				if r.CacheRead > 0 || r.CacheWrite > 0 {
+					fmt.Printf("  cache: %d read/%d write", r.CacheRead, r.CacheWrite)
+				}
 				fmt.Println()
 			}
 		}
@@ -137,6 +148,90 @@ var tasksGetCmd = &cobra.Command{
 	},
 }
 
+var tasksTraceCmd = &cobra.Command{
+	Use:   "trace [run-id]",
+	Short: "Show a chat run and every tool call it made",
+	Long: `Show the audit tree of one agent run: the chat record and each tool call
+with its arguments, policy decision, risk level, approval state, duration and output hash.
+The run ID is stored on the chat record (see "tasks get").`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := ensureTaskStore()
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		records, err := store.Run(strings.TrimSpace(args[0]))
+		if err != nil {
+			return fmt.Errorf("trace run: %w", err)
+		}
+		if len(records) == 0 {
+			return fmt.Errorf("run %s not found", args[0])
+		}
+		if tasksTraceJSON {
+			data, _ := json.MarshalIndent(records, "", "  ")
+			fmt.Println(string(data))
+			return nil
+		}
+		fmt.Print(formatRunTrace(records))
+		return nil
+	},
+}
+
+// formatRunTrace 以树形展示一次运行：聊天记录在上，工具调用按执行顺序挂在其下
+func formatRunTrace(records []tasklog.TaskRecord) string {
+	// 请求和输出可能多行，压成一行再截断，保持树形对齐
+	line := func(s string) string { return truncateString(strings.Join(strings.Fields(s), " "), 100) }
+	var b strings.Builder
+	var tools []tasklog.TaskRecord
+	for _, r := range records {
+		if r.Action == tasklog.ActionTool {
+			tools = append(tools, r)
+			continue
+		}
+		fmt.Fprintf(&b, "Run %s  session=%s  channel=%s  sender=%s\n", r.RunID, r.SessionKey, r.Channel, r.Sender)
+		fmt.Fprintf(&b, "#%d [%s] %s %s  %dms", r.ID, formatTaskTime(r.CreatedAt), r.Action, r.Status, r.DurationMs)
+		if r.Model != "" {
+			fmt.Fprintf(&b, "  model=%s", r.Model)
+		}
+		if r.TokensInput > 0 || r.TokensOutput > 0 {
+			fmt.Fprintf(&b, "  tokens=%d/%d", r.TokensInput, r.TokensOutput)
+		}
+		b.WriteString("\n")
+		fmt.Fprintf(&b, "  req: %s\n", line(r.RequestBody))
+		if r.ResponseBody != "" {
+			fmt.Fprintf(&b, "  res: %s\n", line(r.ResponseBody))
+		}
+	}
+	if len(tools) == 0 {
+		b.WriteString("  (no tool calls)\n")
+	}
+	for i, t := range tools {
+		branch, pad := "├─", "│ "
+		if i == len(tools)-1 {
+			branch, pad = "└─", "  "
+		}
+		name := t.Tool
+		if t.Module == "delegate" {
+			name += " (sub-agent)"
+		}
+		approval := t.Approval
+		if approval == "" {
+			approval = "-"
+		}
+		fmt.Fprintf(&b, "  %s #%d %s  policy=%s risk=%s approval=%s  %s  %dms  hash=%s\n",
+			branch, t.ID, name, t.Policy, t.Risk, approval, t.Status, t.DurationMs, t.OutputHash)
+		fmt.Fprintf(&b, "  %s    args: %s\n", pad, line(t.RequestBody))
+		if t.ErrorMessage != "" {
+			fmt.Fprintf(&b, "  %s    error: %s\n", pad, line(t.ErrorMessage))
+		} else {
+			fmt.Fprintf(&b, "  %s    out: %s\n", pad, line(t.ResponseBody))
+		}
+	}
+	return b.String()
+}
+
 var tasksSearchCmd = &cobra.Command{
 	Use:   "search [query]",
 	Short: "Full-text search task records",
@@ -183,6 +278,10 @@ var tasksStatsCmd = &cobra.Command{
 		}
 		defer store.Close()
 
+		if tasksCost {
+			return printCostBreakdown(store)
+		}
+
 		stats, err := store.GetStats()
 		if err != nil {
 			return fmt.Errorf("get stats: %w", err)
@@ -193,6 +292,10 @@ var tasksStatsCmd = &cobra.Command{
 		fmt.Printf("  Total records:      %d\n", stats.TotalRecords)
 		fmt.Printf("  Total tokens (in):  %d\n", stats.TotalTokensIn)
 		fmt.Printf("  Total tokens (out): %d\n", stats.TotalTokensOut)
+		if stats.TotalCacheRead > 0 || stats.TotalCacheWrite > 0 {
+			fmt.Printf("  Cache read/write:   %d / %d\n", stats.TotalCacheRead, stats.TotalCacheWrite)
+			fmt.Printf("  Cache hit rate:     %.1f%%\n", stats.CacheHitRate*100)
+		}
 		fmt.Printf("  Avg duration:       %.0fms\n", stats.AvgDurationMs)
 		if stats.EarliestRecord != "" {
 			fmt.Printf("  Earliest record:    %s\n", formatTaskTime(stats.EarliestRecord))
@@ -220,11 +323,147 @@ var tasksStatsCmd = &cobra.Command{
 			}
 		}
 
+		if cfg, err := config.Load(); err == nil && len(cfg.Quotas.Rules) > 0 {
+			printQuotaUsage(store, cfg.Quotas)
+		}
+
 		fmt.Printf("\n  Database: %s\n", tasklogDBPath())
 		return nil
 	},
 }
 
+// printCostBreakdown 输出最近 N 天的费用明细（按天、模型、渠道、发送者、会话）
+func printCostBreakdown(store *tasklog.Store) error {
+	days := tasksCostDays
+	if days <= 0 {
+		days = 30
+	}
+	now := time.Now().UTC()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -(days - 1))
+	dims := tasksCostBy
+	if len(dims) == 0 {
+		dims = tasklog.CostDimensions
+	}
+
+	total, err := store.Usage(tasklog.UsageFilter{Since: since})
+	if err != nil {
+		return fmt.Errorf("get usage: %w", err)
+	}
+	fmt.Printf("Cost (last %d days, since %s UTC):\n\n", days, since.Format("2006-01-02"))
+	fmt.Printf("  Total: $%.4f  (%d runs, %d tokens)\n", total.CostUSD, total.Messages, total.Tokens)
+	if cfg, err := config.Load(); err == nil && cfg.Cost.MonthlyBudget > 0 {
+		month, err := store.Usage(tasklog.UsageFilter{Since: time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)})
+		if err == nil {
+			line := fmt.Sprintf("  Month: $%.4f of $%.2f budget", month.CostUSD, cfg.Cost.MonthlyBudget)
+			if month.CostUSD >= cfg.Cost.MonthlyBudget && strings.TrimSpace(cfg.Cost.BudgetHint) != "" {
+				line += " (exceeded, routing to hint:" + strings.TrimSpace(cfg.Cost.BudgetHint) + ")"
+			}
+			fmt.Println(line)
+		}
+	}
+
+	for _, dim := range dims {
+		rows, err := store.CostBreakdown(strings.TrimSpace(dim), since)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\n  By %s:\n", dim)
+		if len(rows) == 0 {
+			fmt.Println("    (no usage)")
+			continue
+		}
+		// day 全部列出，其余维度只显示费用最高的 10 项
+		if dim != "day" && len(rows) > 10 {
+			rows = rows[:10]
+		}
+		for _, r := range rows {
+			key := r.Key
+			if key == "" {
+				key = "(none)"
+			}
+			fmt.Printf("    %-32s $%10.4f  %5d runs  in %-9d out %-9d cache r/w %d/%d\n",
+				truncateString(key, 32), r.CostUSD, r.Runs, r.TokensIn, r.TokensOut, r.CacheRead, r.CacheWrite)
+		}
+	}
+	return nil
+}
+
+// printQuotaUsage 按配额规则输出当前用量（每条规则最多列出用量最高的 5 个 ID）
+func printQuotaUsage(store *tasklog.Store, quotas config.QuotasConfig) {
+	now := time.Now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	fmt.Println("\n  Quotas:")
+	for i, r := range quotas.Rules {
+		scope := security.QuotaScope(r)
+		column := security.QuotaUsageColumn(r)
+		title := scope
+		if ch := strings.TrimSpace(r.Channel); ch != "" && ch != "*" {
+			title += " @" + ch
+		}
+		if m := strings.TrimSpace(r.Match); m != "" && m != "*" {
+			title += " = " + m
+		}
+		fmt.Printf("    [%d] %s\n", i+1, title)
+
+		usageSince := func(since time.Time) map[string]tasklog.Usage {
+			f := tasklog.UsageFilter{Since: since}
+			if ch := strings.TrimSpace(r.Channel); ch != "" && ch != "*" {
+				f.Channel = ch
+			}
+			by, err := store.UsageBy(column, f)
+			if err != nil {
+				return nil
+			}
+			if m := strings.TrimSpace(r.Match); m != "" && m != "*" {
+				return map[string]tasklog.Usage{m: by[m]}
+			}
+			return by
+		}
+		window := security.QuotaWindow(r)
+		msgs, tokens, cost := usageSince(now.Add(-window)), usageSince(day), usageSince(month)
+
+		keys := map[string]bool{}
+		for _, m := range []map[string]tasklog.Usage{msgs, tokens, cost} {
+			for k := range m {
+				keys[k] = true
+			}
+		}
+		if len(keys) == 0 {
+			fmt.Println("        (no usage)")
+			continue
+		}
+		// 按当日 token 用量排序，只显示前 5 个
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Slice(sorted, func(a, b int) bool {
+			if tokens[sorted[a]].Tokens != tokens[sorted[b]].Tokens {
+				return tokens[sorted[a]].Tokens > tokens[sorted[b]].Tokens
+			}
+			return sorted[a] < sorted[b]
+		})
+		if len(sorted) > 5 {
+			sorted = sorted[:5]
+		}
+		for _, k := range sorted {
+			var parts []string
+			if r.Messages > 0 {
+				parts = append(parts, fmt.Sprintf("messages %d/%d per %s", msgs[k].Messages, r.Messages, window))
+			}
+			if r.TokensPerDay > 0 {
+				parts = append(parts, fmt.Sprintf("tokens %d/%d today", tokens[k].Tokens, r.TokensPerDay))
+			}
+			if r.CostPerMonth > 0 {
+				parts = append(parts, fmt.Sprintf("cost $%.2f/$%.2f this month", cost[k].CostUSD, r.CostPerMonth))
+			}
+			fmt.Printf("        %-24s %s\n", truncateString(k, 24), strings.Join(parts, "  "))
+		}
+	}
+}
+
 var tasksCleanCmd = &cobra.Command{
 	Use:   "clean",
 	Short: "Clean up old task records",
@@ -288,6 +527,12 @@ func init() {
 	tasksListCmd.Flags().StringVar(&tasksUntil, "until", "", "Filter records created before this time (RFC3339)")
 	tasksListCmd.Flags().StringVar(&tasksSort, "sort", "-created_at", "Sort field with direction: -created_at, +duration_ms, -tokens_input, +action")
 
+	tasksStatsCmd.Flags().BoolVar(&tasksCost, "cost", false, "Show cost breakdown by day, model, channel, sender and session")
+	tasksStatsCmd.Flags().StringSliceVar(&tasksCostBy, "by", nil, "Cost breakdown dimensions (day, model, channel, sender, session)")
+	tasksStatsCmd.Flags().IntVar(&tasksCostDays, "days", 30, "Cost breakdown period in days")
+
+	tasksTraceCmd.Flags().BoolVar(&tasksTraceJSON, "json", false, "Print the run's records as JSON")
+
 	tasksSearchCmd.Flags().IntVar(&tasksLimit, "limit", 20, "Max results")
 	tasksSearchCmd.Flags().IntVar(&tasksOffset, "offset", 0, "Offset")
 
@@ -296,6 +541,7 @@ func init() {
 
 	tasksCmd.AddCommand(tasksListCmd)
 	tasksCmd.AddCommand(tasksGetCmd)
+	tasksCmd.AddCommand(tasksTraceCmd)
 	tasksCmd.AddCommand(tasksSearchCmd)
 	tasksCmd.AddCommand(tasksStatsCmd)
 	tasksCmd.AddCommand(tasksCleanCmd)
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MainAgentID 默认 agent 的 ID（未配置 profile 时所有会话都落到它）
+const MainAgentID = "main"
+
+// AgentIDs 返回所有可用 agent 的 ID，main 永远排在第一位
+func (c *Config) AgentIDs() []string {
+	ids := []string{MainAgentID}
+	extra := make([]string, 0, len(c.Agent.Profiles))
+	for id := range c.Agent.Profiles {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if id == "" || id == MainAgentID {
+			continue
+		}
+		extra = append(extra, id)
+	}
+	sort.Strings(extra)
+	return append(ids, extra...)
+}
+
+// AgentProfileFor 按 agentId 查找 profile（大小写不敏感）
+func (c *Config) AgentProfileFor(agentID string) (AgentProfile, bool) {
+	agentID = strings.ToLower(strings.TrimSpace(agentID))
+	if agentID == "" {
+		return AgentProfile{}, false
+	}
+	for id, p := range c.Agent.Profiles {
+		if strings.ToLower(strings.TrimSpace(id)) == agentID {
+			return p, true
+		}
+	}
+	return AgentProfile{}, false
+}
+
+// HasAgent 判断 agentId 是否可路由（main 或已配置的 profile）
+func (c *Config) HasAgent(agentID string) bool {
+	agentID = strings.ToLower(strings.TrimSpace(agentID))
+	if agentID == MainAgentID {
+		return true
+	}
+	_, ok := c.AgentProfileFor(agentID)
+	return ok
+}
+
+// ForAgent 返回叠加了 agent profile 的配置副本。
+// 未配置该 profile 时返回 c 本身；副本只替换标量字段，map/slice 与原配置共享，调用方不要修改。
+func (c *Config) ForAgent(agentID string) *Config {
+	profile, ok := c.AgentProfileFor(agentID)
+	if !ok {
+		return c
+	}
+	id := strings.ToLower(strings.TrimSpace(agentID))
+	out := *c
+
+	workspace := strings.TrimSpace(profile.Workspace)
+	switch {
+	case workspace != "":
+		out.Agent.Workspace = workspace
+	case id != MainAgentID:
+		out.Agent.Workspace = filepath.Join(ConfigDir(), "workspace-"+id)
+	}
+	if model := strings.TrimSpace(profile.Model); model != "" {
+		out.Agent.Model = model
+	}
+	if level := strings.TrimSpace(profile.Autonomy); level != "" {
+		out.Autonomy.Level = level
+	}
+	if ns := strings.TrimSpace(profile.MemoryNamespace); ns != "" {
+		out.Memory.Namespace = ns
+	}
+	return &out
+}
@@ -28,6 +28,7 @@ type Config struct {
 	Browser       BrowserConfig       `json:"browser"`
 	Hooks         HooksConfig         `json:"hooks"`
 	Web           WebConfig           `json:"web"`
+	WebTools      WebToolsConfig      `json:"webTools"`
 	Autonomy      AutonomyConfig      `json:"autonomy"`
 	Memory        MemoryConfig        `json:"memory"`
 	Session       SessionConfig       `json:"session"`
@@ -36,10 +37,19 @@ type Config struct {
 	Tunnel        TunnelConfig        `json:"tunnel"`
 	Composio      ComposioConfig      `json:"composio"`
 	Secrets       SecretsConfig       `json:"secrets"`
+	DLP           DLPConfig           `json:"dlp"`
+	Quotas        QuotasConfig        `json:"quotas"`
+	PromptGuard   PromptGuardConfig   `json:"promptGuard"`
+	Cost          CostConfig          `json:"cost"`
 	Identity      IdentityConfig      `json:"identity"`
 	Observability ObservabilityConfig `json:"observability"`
 	Log           LogConfig           `json:"log"`
 	TaskLog       TaskLogConfig       `json:"taskLog"`
+
+	// secretSources Load 时解析过的加密值和引用，Save 时用于原样写回
+	secretSources *secretState
+	// layers Load 时各配置层的来源信息，Save 时只写回用户配置层
+	layers *layerState
 }
 
 
@@ -94,10 +104,102 @@ type AgentConfig struct {
 	Models    ModelsConfig              `json:"models"`
 	Defaults  AgentDefaults             `json:"defaults"`
 	Providers map[string]ProviderConfig `json:"providers"`
+	// Profiles 命名 agent 配置（key 为 agentId），未填写的字段继承上面的顶层配置
+	Profiles map[string]AgentProfile `json:"profiles,omitempty"`
+	// Bindings 渠道绑定规则：把匹配的会话路由到指定 agent
+	Bindings []AgentBinding `json:"bindings,omitempty"`
+	// ToolPermissions 按渠道、群聊/私聊、发送者选择工具权限档
+	ToolPermissions ToolPermissionsConfig `json:"toolPermissions,omitempty"`
+}
+
+// AgentProfile 单个命名 agent 的配置
+type AgentProfile struct {
+	// Name 展示名称，会写入系统提示词
+	Name string `json:"name,omitempty"`
+	// Workspace 独立工作区（bootstrap 文件、skills、记忆），默认 ~/.highclaw/workspace-<agentId>
+	Workspace string `json:"workspace,omitempty"`
+	// Model 模型，如 "anthropic/claude-sonnet-4-5"
+	Model string `json:"model,omitempty"`
+	// Provider 强制使用的 provider，为空时按 Model 前缀解析
+	Provider string `json:"provider,omitempty"`
+	// Tools 允许使用的工具名（支持 glob，如 "memory_*"），为空表示全部
+	Tools []string `json:"tools,omitempty"`
+	// Autonomy 自主级别覆盖: "readonly" | "supervised" | "full"
+	Autonomy string `json:"autonomy,omitempty"`
+	// MemoryNamespace 记忆命名空间，同一工作区内多个 agent 互相隔离记忆
+	MemoryNamespace string `json:"memoryNamespace,omitempty"`
+}
+
+// AgentBinding 渠道 → agent 路由规则，所有非空字段都匹配时生效，匹配字段越多优先级越高
+type AgentBinding struct {
+	// Agent 目标 agentId
+	Agent string `json:"agent"`
+	// Channel 渠道名: feishu / telegram / discord ...
+	Channel string `json:"channel,omitempty"`
+	// AccountID bot 账户 ID（多 bot 场景）
+	AccountID string `json:"accountId,omitempty"`
+	// PeerKind "direct" | "group" | "channel"
+	PeerKind string `json:"peerKind,omitempty"`
+	// PeerID 对端用户 ID
+	PeerID string `json:"peerId,omitempty"`
+	// GroupID 群组 ID
+	GroupID string `json:"groupId,omitempty"`
+}
+
+// ToolPermissionsConfig 工具权限档配置。规则按顺序匹配，第一条命中的规则决定权限档；
+// 没有规则命中时群聊/频道使用内置的 "group" 档（禁用 shell），其余使用 Default。
+type ToolPermissionsConfig struct {
+	// Profiles 命名权限档（key 为档名），可覆盖内置的 "group" / "full"
+	Profiles map[string]ToolPermissionProfile `json:"profiles,omitempty"`
+	// Rules 权限档选择规则
+	Rules []ToolPermissionRule `json:"rules,omitempty"`
+	// Default 没有规则命中时使用的权限档，为空表示不额外限制
+	Default string `json:"default,omitempty"`
+}
+
+// ToolPermissionProfile 工具权限档。工具名支持 glob（如 "memory_*"）和分组名：
+// shell（shell/bash/process）、file、web、memory、skill
+type ToolPermissionProfile struct {
+	// Allow 对模型可见且可执行的工具，为空表示全部
+	Allow []string `json:"allow,omitempty"`
+	// Deny 禁用的工具，优先于 Allow
+	Deny []string `json:"deny,omitempty"`
+}
+
+// ToolPermissionRule 权限档选择规则，所有非空条件都满足时命中
+type ToolPermissionRule struct {
+	// Profile 命中时使用的权限档
+	Profile string `json:"profile"`
+	// Channel 渠道名: feishu / telegram / cli / tui ...
+	Channel string `json:"channel,omitempty"`
+	// PeerKind "direct" | "group" | "channel"
+	PeerKind string `json:"peerKind,omitempty"`
+	// GroupID 群组 ID
+	GroupID string `json:"groupId,omitempty"`
+	// Senders 发送者 ID 白名单，命中任一即可
+	Senders []string `json:"senders,omitempty"`
+}
+
+// ObservabilityConfig 可观测性配置
+type ObservabilityConfig struct {
+	// Tracing 运行追踪（span 导出）
+	Tracing TracingConfig `json:"tracing"`
+}
+
+// TracingConfig 运行追踪配置；不配置导出时 trace 只保留在内存中，供 CLI/TUI 展示摘要
+type TracingConfig struct {
+	// Exporter 导出方式: "none"（默认）| "otlp" | "file"
+	Exporter string `json:"exporter,omitempty"`
+	// Endpoint OTLP/HTTP collector 地址，如 http://localhost:4318（自动补 /v1/traces）
+	Endpoint string `json:"endpoint,omitempty"`
+	// Headers OTLP 请求头，如 {"Authorization": "Bearer ..."}
+	Headers map[string]string `json:"headers,omitempty"`
+	// File JSONL 导出文件，默认 ~/.highclaw/traces/traces.jsonl
+	File string `json:"file,omitempty"`
+	// ServiceName 上报的 service.name，默认 highclaw
+	ServiceName string `json:"serviceName,omitempty"`
 }
 
-type ObservabilityConfig struct{}
-
 type IdentityConfig struct{}
 
 // AutonomyConfig 控制 Agent 的自主权限，与 ZeroClaw 保持一致
@@ -111,6 +213,28 @@ type AutonomyConfig struct {
 	AllowedCommands []string `json:"allowedCommands,omitempty"`
 	// ForbiddenPaths 禁止访问的路径（即使 workspaceOnly=false）
 	ForbiddenPaths []string `json:"forbiddenPaths,omitempty"`
+	// ShellRules shell 命令风险规则，按顺序匹配、先于内置规则，每条命令取第一条命中的规则
+	ShellRules []ShellRule `json:"shellRules,omitempty"`
+	// MaxProcesses 每个会话同时运行的后台进程上限（process 工具），默认 4
+	MaxProcesses int `json:"maxProcesses,omitempty"`
+	// ProcessIdleMinutes 后台进程无交互多少分钟后自动结束，默认 30
+	ProcessIdleMinutes int `json:"processIdleMinutes,omitempty"`
+}
+
+// ShellRule 声明式命令风险规则，所有非空条件都满足时命中
+type ShellRule struct {
+	// Command 命令名（支持 glob），空或 "*" 表示任意命令（此时 Paths 也匹配重定向目标）
+	Command string `json:"command,omitempty"`
+	// Subcommand 第一个非 flag 参数（支持 glob），如 git 的 "push"
+	Subcommand string `json:"subcommand,omitempty"`
+	// Flags 命中任一 flag 即可，如 ["-f", "--force"]；短 flag 组合会展开（-rf 同时匹配 -r 和 -f）
+	Flags []string `json:"flags,omitempty"`
+	// Paths 任一路径参数匹配即可（支持 ** 和 ~），如 ["/etc/**", "~/.ssh/**"]
+	Paths []string `json:"paths,omitempty"`
+	// Risk 风险级别: "low" | "medium" | "high" | "block"
+	Risk string `json:"risk"`
+	// Reason 命中时返回给模型的说明
+	Reason string `json:"reason,omitempty"`
 }
 
 type ComposioConfig struct {
@@ -118,8 +242,92 @@ type ComposioConfig struct {
 	APIKey  string `json:"apiKey"`
 }
 
+// SecretsConfig 配置文件中敏感字段的加密存储
 type SecretsConfig struct {
+	// Encrypt 保存配置时加密敏感字段（apiKey / token / secret / password 等），写为 enc:...
 	Encrypt bool `json:"encrypt"`
+	// KeyFile 密钥文件，默认 ~/.highclaw/secrets.key（首次加密时生成，权限 0600）；
+	// 设置环境变量 HIGHCLAW_SECRETS_PASSPHRASE 时改用口令派生密钥
+	KeyFile string `json:"keyFile,omitempty"`
+	// Salt 口令模式下派生密钥用的盐（首次加密时自动生成）
+	Salt string `json:"salt,omitempty"`
+}
+
+// DLPConfig 出站数据防泄漏：扫描回复和工具输出中的密钥、私钥及自定义敏感内容
+type DLPConfig struct {
+	// Enabled 是否启用，默认 true
+	Enabled *bool `json:"enabled,omitempty"`
+	// Action 回复命中时的处理方式: "mask" | "block" | "approve" | "off"，默认 mask；
+	// approve 只对 feishu 和 web 控制台生效，其他渠道按 block 处理
+	Action string `json:"action,omitempty"`
+	// Channels 按渠道覆盖 Action，例如 {"feishu": "approve", "cli": "off"}
+	Channels map[string]string `json:"channels,omitempty"`
+	// ToolOutput 工具输出回传给模型前的处理方式: "mask" | "block" | "off"，默认 mask（"approve" 按 block 处理）
+	ToolOutput string `json:"toolOutput,omitempty"`
+	// Patterns 自定义规则（Go 正则），与内置规则一起生效
+	Patterns []DLPPattern `json:"patterns,omitempty"`
+}
+
+// DLPPattern 自定义 DLP 规则
+type DLPPattern struct {
+	Name  string `json:"name"`
+	Regex string `json:"regex"`
+}
+
+// PromptGuardConfig 工具结果和抓取内容的提示词注入防护
+type PromptGuardConfig struct {
+	// Enabled 是否为工具结果加来源标记、不可信内容边界并做注入检测，默认 true
+	Enabled *bool `json:"enabled,omitempty"`
+	// Taint 本次运行读取过不可信内容后，shell / process 调用必须经人工审批（exec-approvals）
+	Taint bool `json:"taint,omitempty"`
+	// TrustedTools 额外视为可信的工具，其输出不加不可信边界（支持 * 通配）
+	TrustedTools []string `json:"trustedTools,omitempty"`
+}
+
+// CostConfig 费用核算和月度预算
+type CostConfig struct {
+	// Prices 覆盖或补充内置模型单价（美元 / 百万 token），key 为 "provider/model"、模型 ID 或模型 ID 前缀
+	Prices map[string]ModelPrice `json:"prices,omitempty"`
+	// MonthlyBudget 每月费用预算（美元），0 表示不限制
+	MonthlyBudget float64 `json:"monthlyBudget,omitempty"`
+	// BudgetHint 本月费用超出预算后，默认模型改走的 modelRoutes hint（如 "cheap"）
+	BudgetHint string `json:"budgetHint,omitempty"`
+}
+
+// ModelPrice 模型单价，单位：美元 / 百万 token
+type ModelPrice struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheRead  float64 `json:"cacheRead"`
+	CacheWrite float64 `json:"cacheWrite"`
+}
+
+// QuotasConfig 按发送者、群、渠道、agent 的用量配额，所有渠道共用
+type QuotasConfig struct {
+	// Rules 配额规则，全部生效（任一超限即拒绝本条消息）
+	Rules []QuotaRule `json:"rules,omitempty"`
+	// Message 超限时回复用户的文本，{limit} 替换为触发的配额说明
+	Message string `json:"message,omitempty"`
+	// Notify 超限时通知的管理员，格式 "<channel>:<id>"，如 "feishu:ou_xxx"；为空时只记日志和 tasklog
+	Notify []string `json:"notify,omitempty"`
+}
+
+// QuotaRule 一条配额规则；Messages / TokensPerDay / CostPerMonth 为 0 表示该项不限制
+type QuotaRule struct {
+	// Scope 计数维度: "sender" | "group" | "channel" | "agent"
+	Scope string `json:"scope"`
+	// Channel 只对该渠道生效，空表示所有渠道
+	Channel string `json:"channel,omitempty"`
+	// Match 只对指定的发送者/群/渠道/agent 生效；空或 "*" 表示每个 ID 各自计数
+	Match string `json:"match,omitempty"`
+	// Messages 每个 Window 内的消息数上限
+	Messages int `json:"messages,omitempty"`
+	// Window 消息数窗口，如 "1m"、"1h"，默认 1h
+	Window string `json:"window,omitempty"`
+	// TokensPerDay 每天（UTC）输入+输出 token 上限，按 tasklog 累计
+	TokensPerDay int `json:"tokensPerDay,omitempty"`
+	// CostPerMonth 每月（UTC）费用上限（美元），按 tasklog 记录的费用累计
+	CostPerMonth float64 `json:"costPerMonth,omitempty"`
 }
 
 type MemoryConfig struct {
@@ -136,6 +344,8 @@ type MemoryConfig struct {
 	KeywordWeight             float64 `json:"keywordWeight"`
 	EmbeddingCacheSize        int     `json:"embeddingCacheSize"`
 	ChunkMaxTokens            int     `json:"chunkMaxTokens"`
+	// Namespace 记忆命名空间（多 agent 隔离），为空时使用工作区根目录
+	Namespace string `json:"namespace,omitempty"`
 }
 
 // ReliabilityConfig controls provider retry/backoff and fallback chain.
@@ -190,8 +400,8 @@ type AgentDefaults struct {
 // SandboxConfig controls Docker sandboxing for non-main sessions.
 type SandboxConfig struct {
 	Mode  string   `json:"mode"`  // "off", "non-main", "all"
-	Allow []string `json:"allow"` // Allowed tool names
-	Deny  []string `json:"deny"`  // Denied tool names
+	Allow []string `json:"allow"` // Allowed tool names (all sessions; globs and tool groups accepted)
+	Deny  []string `json:"deny"`  // Denied tool names, applied after Allow
 }
 
 // ModelsConfig configures allowed models.
@@ -203,6 +413,8 @@ type ModelsConfig struct {
 type ProviderConfig struct {
 	APIKey  string `json:"apiKey"`
 	BaseURL string `json:"baseUrl"`
+	// KeepAlive 本地模型（Ollama）请求后保持加载的时长，如 "30m"，"-1" 表示常驻；空为服务端默认
+	KeepAlive string `json:"keepAlive,omitempty"`
 }
 
 // GatewayConfig configures the gateway server.
@@ -245,7 +457,7 @@ type ChannelsConfig struct {
 	Matrix      *MatrixConfig      `json:"matrix,omitempty"`
 	Email       *EmailConfig       `json:"email,omitempty"`
 	IRC         *IRCConfig         `json:"irc,omitempty"`
-	Lark        *LarkConfig        `json:"lark,omitempty"`
+	Lark        *LarkConfig        `json:"lark,omitempty"` // Deprecated: 使用 channels.feishu（字段相同）
 	Feishu      *FeishuConfig      `json:"feishu,omitempty"`
 	WeCom       *WeComConfig       `json:"wecom,omitempty"`
 	WeChat      *WeChatConfig      `json:"wechat,omitempty"`
@@ -427,6 +639,8 @@ type HooksConfig struct {
 type WebConfig struct {
 	Auth        WebAuthConfig        `json:"auth"`
 	Preferences WebPreferencesConfig `json:"preferences"`
+	// AllowedHosts 控制台接受的 Host 名（可带端口），防 DNS rebinding；localhost 和 IP 地址总是接受，经域名、隧道或反向代理访问时需填写
+	AllowedHosts []string `json:"allowedHosts,omitempty"`
 }
 
 // WebAuthConfig controls web login.
@@ -443,6 +657,33 @@ type WebPreferencesConfig struct {
 	AutoStart             bool `json:"autoStart"`
 }
 
+// WebToolsConfig 配置 web_search / web_fetch 工具
+type WebToolsConfig struct {
+	// Enabled 是否注册 web_search / web_fetch，默认 true
+	Enabled *bool `json:"enabled,omitempty"`
+	// SearchProvider 搜索后端: "auto" | "brave" | "google" | "searxng" | "duckduckgo"
+	// auto 按 Brave key → Google key → SearXNG URL → DuckDuckGo 的顺序选择
+	SearchProvider string `json:"searchProvider,omitempty"`
+	// BraveAPIKey Brave Search API key（也可用环境变量 BRAVE_API_KEY）
+	BraveAPIKey string `json:"braveApiKey,omitempty"`
+	// GoogleAPIKey Google Custom Search API key
+	GoogleAPIKey string `json:"googleApiKey,omitempty"`
+	// GoogleCX Google Custom Search 引擎 ID
+	GoogleCX string `json:"googleCx,omitempty"`
+	// SearXNGURL SearXNG 实例地址，如 http://127.0.0.1:8888（也可用环境变量 SEARXNG_URL）
+	SearXNGURL string `json:"searxngUrl,omitempty"`
+	// MaxResults 默认返回条数，默认 5
+	MaxResults int `json:"maxResults,omitempty"`
+	// FetchMaxBytes web_fetch 下载上限（字节），默认 2MB
+	FetchMaxBytes int64 `json:"fetchMaxBytes,omitempty"`
+	// FetchMaxChars web_fetch 返回给模型的正文字符上限，默认 20000
+	FetchMaxChars int `json:"fetchMaxChars,omitempty"`
+	// FetchAllowPrivate 允许 web_fetch 访问内网/回环地址（默认禁止，防 SSRF）
+	FetchAllowPrivate bool `json:"fetchAllowPrivate,omitempty"`
+	// CacheTTLSeconds 会话内搜索/抓取结果缓存时间，默认 900，0 使用默认值，负数关闭缓存
+	CacheTTLSeconds int `json:"cacheTtlSeconds,omitempty"`
+}
+
 // GmailHookConfig configures Gmail Pub/Sub integration.
 type GmailHookConfig struct {
 	Account string `json:"account"`
@@ -573,8 +814,8 @@ func cfgIntegrityCheck() bool {
 	return len(_cfgSalt) > 0 && _cfgEpoch > 0 && len(_cfgDigest) == 16
 }
 
-// Load reads and parses the config from disk.
-// If the config file doesn't exist, it returns defaults.
+// Load reads and merges the config layers (system, user, workspace, profile, env; see layers.go).
+// If no config file exists, it returns defaults with env overrides.
 func Load() (*Config, error) {
 	_ = cfgIntegrityCheck()
 	cfg := Default()
@@ -582,43 +823,34 @@ func Load() (*Config, error) {
 	configPath := ConfigPath()
 	explicitPath := os.Getenv("HIGHCLAW_CONFIG") != "" || os.Getenv("OPENCLAW_CONFIG") != ""
 	loadedFromLegacyJSON := false
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			if explicitPath {
-				return cfg, nil
-			}
+	userPath := configPath
+	if _, err := os.Stat(configPath); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return cfg, fmt.Errorf("read config: %w", err)
+		}
+		userPath = ""
+		if !explicitPath {
 			// Legacy fallback: support loading historical JSON config files.
 			for _, legacyPath := range []string{
 				filepath.Join(ConfigDir(), "highclaw.json"),
 				filepath.Join(ConfigDir(), "openclaw.json"),
 			} {
-				legacyData, legacyErr := os.ReadFile(legacyPath)
-				if legacyErr != nil {
-					continue
+				if fileExists(legacyPath) {
+					userPath = legacyPath
+					loadedFromLegacyJSON = true
+					break
 				}
-				data = legacyData
-				configPath = legacyPath
-				loadedFromLegacyJSON = true
-				err = nil
-				break
-			}
-			if err != nil {
-				applyEnvOverrides(cfg)
-				return cfg, nil
 			}
 		}
-		if err != nil {
-			return cfg, fmt.Errorf("read config: %w", err)
-		}
 	}
 
-	if err := parseConfigData(data, configPath, cfg); err != nil {
-		return cfg, fmt.Errorf("parse config %s: %w", configPath, err)
+	if err := loadLayers(cfg, userPath); err != nil {
+		return cfg, err
 	}
-
-	// Apply environment variable overrides.
-	applyEnvOverrides(cfg)
+	if err := cfg.resolveSecrets(); err != nil {
+		return cfg, fmt.Errorf("config %s: %w", configPath, err)
+	}
+	cfg.layers.recordOverlay(cfg)
 
 	// Auto-migrate old JSON config into YAML when using default pathing.
 	if loadedFromLegacyJSON && !explicitPath {
@@ -629,7 +861,20 @@ func Load() (*Config, error) {
 }
 
 // Save writes the config to disk.
+// 打开 secrets.encrypt 时敏感字段加密写入，Load 时来自 enc:/${...} 且未修改的字段按原样写回。
 func Save(cfg *Config) error {
+	var vault *secretVault
+	if cfg.Secrets.Encrypt {
+		v, err := cfg.openVault(true)
+		if err != nil {
+			return fmt.Errorf("open secrets vault: %w", err)
+		}
+		vault = v
+	}
+	return saveWithVault(cfg, vault)
+}
+
+func saveWithVault(cfg *Config, vault *secretVault) error {
 	path := ConfigPath()
 
 	// Ensure directory exists
@@ -639,13 +884,13 @@ func Save(cfg *Config) error {
 	}
 
 	// Marshal to YAML while preserving json-tag key names.
-	data, err := marshalConfigYAML(cfg)
+	data, err := marshalConfigYAML(cfg, vault)
 	if err != nil {
 		return fmt.Errorf("marshal config: %w", err)
 	}
 
-	// Write to file
-	if err := os.WriteFile(path, data, 0o644); err != nil {
+	// Write to file（含密钥，仅当前用户可读）
+	if err := os.WriteFile(path, data, 0o600); err != nil {
 		return fmt.Errorf("write config: %w", err)
 	}
 
@@ -657,56 +902,6 @@ func DefaultConfig() *Config {
 	return Default()
 }
 
-// applyEnvOverrides merges environment variables into configuration.
-func applyEnvOverrides(cfg *Config) {
-	if v := os.Getenv("TELEGRAM_BOT_TOKEN"); v != "" {
-		if cfg.Channels.Telegram == nil {
-			cfg.Channels.Telegram = &TelegramConfig{}
-		}
-		cfg.Channels.Telegram.BotToken = v
-	}
-	if v := os.Getenv("DISCORD_BOT_TOKEN"); v != "" {
-		if cfg.Channels.Discord == nil {
-			cfg.Channels.Discord = &DiscordConfig{}
-		}
-		cfg.Channels.Discord.Token = v
-	}
-	if v := os.Getenv("SLACK_BOT_TOKEN"); v != "" {
-		if cfg.Channels.Slack == nil {
-			cfg.Channels.Slack = &SlackConfig{}
-		}
-		cfg.Channels.Slack.BotToken = v
-	}
-	if v := os.Getenv("SLACK_APP_TOKEN"); v != "" {
-		if cfg.Channels.Slack == nil {
-			cfg.Channels.Slack = &SlackConfig{}
-		}
-		cfg.Channels.Slack.AppToken = v
-	}
-	if v := os.Getenv("ANTHROPIC_API_KEY"); v != "" {
-		if cfg.Agent.Providers == nil {
-			cfg.Agent.Providers = make(map[string]ProviderConfig)
-		}
-		p := cfg.Agent.Providers["anthropic"]
-		p.APIKey = v
-		cfg.Agent.Providers["anthropic"] = p
-	}
-	if v := os.Getenv("OPENAI_API_KEY"); v != "" {
-		if cfg.Agent.Providers == nil {
-			cfg.Agent.Providers = make(map[string]ProviderConfig)
-		}
-		p := cfg.Agent.Providers["openai"]
-		p.APIKey = v
-		cfg.Agent.Providers["openai"] = p
-	}
-	if v := os.Getenv("HIGHCLAW_WEB_USERNAME"); v != "" {
-		cfg.Web.Auth.Username = v
-	}
-	if v := os.Getenv("HIGHCLAW_WEB_PASSWORD"); v != "" {
-		cfg.Web.Auth.Password = v
-	}
-}
-
 func preprocessJSONLike(input string) string {
 	s := input
 	for {
@@ -750,33 +945,32 @@ func preprocessJSONLike(input string) string {
 	return s
 }
 
-func parseConfigData(data []byte, path string, cfg *Config) error {
+// parseConfigTree 把配置文件解析为 json 键名的通用树；空文件返回空树
+func parseConfigTree(data []byte, path string) (map[string]any, error) {
 	lower := strings.ToLower(path)
-	if strings.HasSuffix(lower, ".yaml") || strings.HasSuffix(lower, ".yml") {
-		return unmarshalYAMLToConfig(data, cfg)
-	}
-
-	// Legacy JSON/JSON5 path handling.
-	clean := preprocessJSONLike(string(data))
-	if err := json.Unmarshal([]byte(clean), cfg); err == nil {
-		return nil
+	if !strings.HasSuffix(lower, ".yaml") && !strings.HasSuffix(lower, ".yml") {
+		// Legacy JSON/JSON5 path handling.
+		var tree map[string]any
+		if err := json.Unmarshal([]byte(preprocessJSONLike(string(data))), &tree); err == nil {
+			if tree == nil {
+				tree = map[string]any{}
+			}
+			return tree, nil
+		}
+		// Last chance: try YAML parser even when extension is unknown.
 	}
-
-	// Last chance: try YAML parser even when extension is unknown.
-	return unmarshalYAMLToConfig(data, cfg)
-}
-
-func unmarshalYAMLToConfig(data []byte, cfg *Config) error {
 	var raw any
 	if err := yaml.Unmarshal(data, &raw); err != nil {
-		return err
+		return nil, err
 	}
-	normalized := normalizeYAMLValue(raw)
-	jb, err := json.Marshal(normalized)
-	if err != nil {
-		return err
+	switch t := normalizeYAMLValue(raw).(type) {
+	case nil:
+		return map[string]any{}, nil
+	case map[string]any:
+		return t, nil
+	default:
+		return nil, fmt.Errorf("top level must be a mapping")
 	}
-	return json.Unmarshal(jb, cfg)
 }
 
 func normalizeYAMLValue(v any) any {
@@ -804,13 +998,15 @@ func normalizeYAMLValue(v any) any {
 	}
 }
 
-func marshalConfigYAML(cfg *Config) ([]byte, error) {
-	jb, err := json.Marshal(cfg)
+func marshalConfigYAML(cfg *Config, vault *secretVault) ([]byte, error) {
+	obj, err := configTree(cfg)
 	if err != nil {
 		return nil, err
 	}
-	var obj map[string]any
-	if err := json.Unmarshal(jb, &obj); err != nil {
+	if cfg.layers != nil {
+		cfg.layers.stripOverlay(obj)
+	}
+	if err := cfg.sealSecrets(obj, vault); err != nil {
 		return nil, err
 	}
 	return yaml.Marshal(obj)
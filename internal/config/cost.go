@@ -0,0 +1,35 @@
+package config
+
+import "strings"
+
+// PriceOverride 查找 cost.prices 中的单价覆盖：先匹配 "provider/model"，再匹配模型 ID，
+// 最后按最长的模型 ID 前缀匹配（如 "claude-sonnet-4" 覆盖所有 Sonnet 4 变体）
+func (c *Config) PriceOverride(provider, model string) (ModelPrice, bool) {
+	if len(c.Cost.Prices) == 0 {
+		return ModelPrice{}, false
+	}
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	model = strings.ToLower(strings.TrimSpace(model))
+	prices := make(map[string]ModelPrice, len(c.Cost.Prices))
+	for k, v := range c.Cost.Prices {
+		prices[strings.ToLower(strings.TrimSpace(k))] = v
+	}
+	if p, ok := prices[provider+"/"+model]; ok {
+		return p, true
+	}
+	// openrouter 等转发型 provider 的模型带厂商前缀（anthropic/claude-sonnet-4）
+	id := model[strings.LastIndex(model, "/")+1:]
+	for _, key := range []string{model, id} {
+		if p, ok := prices[key]; ok {
+			return p, true
+		}
+	}
+	var best ModelPrice
+	bestLen := 0
+	for k, v := range prices {
+		if !strings.Contains(k, "/") && strings.HasPrefix(id, k) && len(k) > bestLen {
+			best, bestLen = v, len(k)
+		}
+	}
+	return best, bestLen > 0
+}
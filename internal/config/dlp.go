@@ -0,0 +1,82 @@
+package config
+
+import (
+	"slices"
+	"strings"
+)
+
+// DLP 处理方式
+const (
+	DLPActionMask    = "mask"
+	DLPActionBlock   = "block"
+	DLPActionApprove = "approve"
+	DLPActionOff     = "off"
+)
+
+// DLPApproveChannels 能在审批通过后补发扣留回复的渠道（飞书回复原消息，web 控制台追加到会话）；
+// 其他渠道（cli、tui 等）无法事后投递，approve 按 block 处理
+var DLPApproveChannels = []string{"feishu", "web"}
+
+// DLPEnabled 是否启用出站数据防泄漏（默认启用）
+func (c *Config) DLPEnabled() bool {
+	return c.DLP.Enabled == nil || *c.DLP.Enabled
+}
+
+// DLPActionFor 返回渠道回复命中 DLP 时的处理方式；渠道覆盖优先于全局 Action，
+// 不能事后投递的渠道（见 DLPApproveChannels）把 approve 降为 block
+func (c *Config) DLPActionFor(channel string) string {
+	if !c.DLPEnabled() {
+		return DLPActionOff
+	}
+	channel = strings.ToLower(strings.TrimSpace(channel))
+	action := normalizeDLPAction(c.DLP.Action, DLPActionMask)
+	for name, a := range c.DLP.Channels {
+		if strings.ToLower(strings.TrimSpace(name)) == channel {
+			action = normalizeDLPAction(a, DLPActionMask)
+			break
+		}
+	}
+	if action == DLPActionApprove && !slices.Contains(DLPApproveChannels, channel) {
+		return DLPActionBlock
+	}
+	return action
+}
+
+// DLPToolOutputAction 返回工具输出回传模型前的处理方式（approve 对工具输出无意义，按 block 处理）
+func (c *Config) DLPToolOutputAction() string {
+	if !c.DLPEnabled() {
+		return DLPActionOff
+	}
+	action := normalizeDLPAction(c.DLP.ToolOutput, DLPActionMask)
+	if action == DLPActionApprove {
+		return DLPActionBlock
+	}
+	return action
+}
+
+// dlpActions 合法的 DLP 处理方式，dlp.action / dlp.channels / dlp.toolOutput 共用
+var dlpActions = []string{DLPActionMask, DLPActionBlock, DLPActionApprove, DLPActionOff}
+
+// ValidDLPAction 判断 action 是否为合法的 DLP 处理方式
+func ValidDLPAction(action string) bool {
+	return slices.Contains(dlpActions, strings.ToLower(strings.TrimSpace(action)))
+}
+
+func normalizeDLPAction(action, fallback string) string {
+	action = strings.ToLower(strings.TrimSpace(action))
+	if action == "" || !ValidDLPAction(action) {
+		return fallback
+	}
+	return action
+}
+
+// SecretValues 返回所有非空敏感字段的明文值（按 json 路径），供 DLP 精确匹配已配置的密钥
+func (c *Config) SecretValues() map[string]string {
+	out := map[string]string{}
+	for _, s := range c.SecretFields() {
+		if v, err := c.Secret(s.Path); err == nil && v != "" {
+			out[s.Path] = v
+		}
+	}
+	return out
+}
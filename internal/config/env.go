@@ -0,0 +1,228 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix 配置字段环境变量前缀：每个字段按 json 路径生成变量名，如 autonomy.level → HIGHCLAW_AUTONOMY_LEVEL，
+// log.maxAgeDays → HIGHCLAW_LOG_MAX_AGE_DAYS；map 的键用双下划线分隔，如
+// agent.providers.openai.apiKey → HIGHCLAW_AGENT_PROVIDERS__OPENAI__API_KEY（键名转小写）。
+const EnvPrefix = "HIGHCLAW_"
+
+// EnvVar 一个配置字段对应的环境变量
+type EnvVar struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Type string `json:"type"`
+}
+
+// EnvVars 列出所有配置字段的环境变量映射，按变量名排序；map 的键以 <KEY> 占位
+func EnvVars() []EnvVar {
+	var out []EnvVar
+	collectEnvVars(reflect.TypeOf(Config{}), strings.TrimSuffix(EnvPrefix, "_"), "", &out)
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func collectEnvVars(t reflect.Type, name, path string, out *[]EnvVar) {
+	t = derefType(t)
+	switch t.Kind() {
+	case reflect.Struct:
+		for _, f := range structFields(t) {
+			collectEnvVars(f.Type, name+"_"+envSegment(f.Key), joinPath(path, f.Key), out)
+		}
+	case reflect.Map:
+		*out = append(*out, EnvVar{Name: name + "__<KEY>", Path: path + ".<key>", Type: envTypeName(t.Elem())})
+		if derefType(t.Elem()).Kind() == reflect.Struct {
+			for _, f := range structFields(derefType(t.Elem())) {
+				collectEnvVars(f.Type, name+"__<KEY>__"+envSegment(f.Key), path+".<key>."+f.Key, out)
+			}
+		}
+	default:
+		*out = append(*out, EnvVar{Name: name, Path: path, Type: envTypeName(t)})
+	}
+}
+
+// envSegment 把 json 键转成环境变量片段：maxSizeMB → MAX_SIZE_MB，braveApiKey → BRAVE_API_KEY
+func envSegment(key string) string {
+	var b strings.Builder
+	runes := []rune(key)
+	for i, r := range runes {
+		upper := r >= 'A' && r <= 'Z'
+		if i > 0 && upper {
+			prev := runes[i-1]
+			prevLower := (prev >= 'a' && prev <= 'z') || (prev >= '0' && prev <= '9')
+			nextLower := i+1 < len(runes) && runes[i+1] >= 'a' && runes[i+1] <= 'z'
+			if prevLower || (prev >= 'A' && prev <= 'Z' && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteString(strings.ToUpper(string(r)))
+	}
+	return b.String()
+}
+
+func envTypeName(t reflect.Type) string {
+	t = derefType(t)
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		if derefType(t.Elem()).Kind() == reflect.String {
+			return "list"
+		}
+	}
+	return "json"
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// lookupEnvVar 把环境变量名解析为配置路径和字段类型
+func lookupEnvVar(name string) ([]string, reflect.Type, bool) {
+	rest, ok := strings.CutPrefix(name, EnvPrefix)
+	if !ok || rest == "" {
+		return nil, nil, false
+	}
+	return matchEnvPath(reflect.TypeOf(Config{}), rest, nil)
+}
+
+func matchEnvPath(t reflect.Type, rest string, path []string) ([]string, reflect.Type, bool) {
+	t = derefType(t)
+	switch t.Kind() {
+	case reflect.Struct:
+		for _, f := range structFields(t) {
+			seg := envSegment(f.Key)
+			p := append(path[:len(path):len(path)], f.Key)
+			if rest == seg {
+				return p, f.Type, true
+			}
+			if sub, ok := strings.CutPrefix(rest, seg+"_"); ok {
+				if found, ft, ok := matchEnvPath(f.Type, sub, p); ok {
+					return found, ft, true
+				}
+			}
+		}
+	case reflect.Map:
+		// 前缀已去掉一个下划线，剩余形如 _KEY 或 _KEY__FIELD
+		sub, ok := strings.CutPrefix(rest, "_")
+		if !ok {
+			return nil, nil, false
+		}
+		key, field, hasField := strings.Cut(sub, "__")
+		if key == "" {
+			return nil, nil, false
+		}
+		p := append(path[:len(path):len(path)], strings.ToLower(key))
+		if !hasField {
+			return p, t.Elem(), true
+		}
+		return matchEnvPath(t.Elem(), field, p)
+	}
+	return nil, nil, false
+}
+
+// parseEnvValue 按字段类型解析环境变量值；列表可写成逗号分隔或 JSON 数组，对象和结构体列表用 JSON
+func parseEnvValue(t reflect.Type, raw string) (any, error) {
+	t = derefType(t)
+	switch t.Kind() {
+	case reflect.String:
+		return raw, nil
+	case reflect.Bool:
+		return strconv.ParseBool(strings.TrimSpace(raw))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(strings.TrimSpace(raw), 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	case reflect.Slice, reflect.Array:
+		if derefType(t.Elem()).Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(raw), "[") {
+			items := []any{}
+			for _, s := range strings.Split(raw, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					items = append(items, s)
+				}
+			}
+			return items, nil
+		}
+	}
+	var v any
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return nil, fmt.Errorf("expected JSON: %w", err)
+	}
+	return v, nil
+}
+
+// legacyEnvVars 历史上支持的非 HIGHCLAW_ 前缀变量，优先级低于 HIGHCLAW_ 变量
+var legacyEnvVars = []struct {
+	name    string
+	path    string
+	ifUnset bool // 仅在配置未设置时生效
+}{
+	{"TELEGRAM_BOT_TOKEN", "channels.telegram.botToken", false},
+	{"DISCORD_BOT_TOKEN", "channels.discord.token", false},
+	{"SLACK_BOT_TOKEN", "channels.slack.botToken", false},
+	{"SLACK_APP_TOKEN", "channels.slack.appToken", false},
+	{"ANTHROPIC_API_KEY", "agent.providers.anthropic.apiKey", false},
+	{"OPENAI_API_KEY", "agent.providers.openai.apiKey", false},
+	{"BRAVE_API_KEY", "webTools.braveApiKey", true},
+	{"SEARXNG_URL", "webTools.searxngUrl", true},
+	{"HIGHCLAW_WEB_USERNAME", "web.auth.username", false},
+	{"HIGHCLAW_WEB_PASSWORD", "web.auth.password", false},
+}
+
+// envLayers 把环境变量转成配置层：先是兼容变量，再是 HIGHCLAW_ 字段变量（按变量名排序）
+func envLayers(tree map[string]any) ([]layer, error) {
+	var layers []layer
+	for _, e := range legacyEnvVars {
+		v := os.Getenv(e.name)
+		if v == "" {
+			continue
+		}
+		path := strings.Split(e.path, ".")
+		if e.ifUnset {
+			if cur, ok := treeGet(tree, path); ok && cur != nil && cur != "" {
+				continue
+			}
+		}
+		layers = append(layers, layer{label: "env " + e.name, tree: treeWith(path, v)})
+	}
+
+	var names []string
+	for _, kv := range os.Environ() {
+		if name, _, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(name, EnvPrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path, t, ok := lookupEnvVar(name)
+		if !ok {
+			continue
+		}
+		v, err := parseEnvValue(t, os.Getenv(name))
+		if err != nil {
+			return nil, fmt.Errorf("env %s (%s): %w", name, strings.Join(path, "."), err)
+		}
+		layers = append(layers, layer{label: "env " + name, tree: treeWith(path, v)})
+	}
+	return layers, nil
+}
@@ -0,0 +1,612 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// 分层配置，后面的层覆盖前面的层：
+//   默认值 < 系统配置 < 用户配置 < 工作区 .highclaw.yaml < --profile 选中的 profile < 环境变量
+// 每个配置文件可用 include: 引入片段文件（先合并片段，再合并文件本身），用 profiles: 定义命名 profile。
+// 对象逐键深度合并，标量和列表整体替换。
+
+const (
+	// WorkspaceConfigName 工作区配置文件名，从当前目录向上查找
+	WorkspaceConfigName = ".highclaw.yaml"
+	// ProfileEnv 未指定 --profile 时使用的 profile
+	ProfileEnv = "HIGHCLAW_PROFILE"
+	// SystemConfigEnv 覆盖系统配置文件路径
+	SystemConfigEnv = "HIGHCLAW_SYSTEM_CONFIG"
+
+	includeKey      = "include"
+	profilesKey     = "profiles"
+	maxIncludeDepth = 8
+	defaultOrigin   = "default"
+)
+
+// workspaceAllowed 工作区配置随仓库分发、未必可信，只能设置这些不影响权限、密钥和网络暴露的配置；
+// 其余配置（autonomy、agent.profiles/bindings、web、webTools、channels、cost 等）需写在用户配置或 profile 中；
+// cost 的单价和预算决定预算路由与 costPerMonth 配额，不能交给仓库里的配置改写
+var workspaceAllowed = []string{
+	"agent.model", "agent.models", "agent.defaults",
+	"memory.vectorWeight", "memory.keywordWeight", "memory.chunkMaxTokens",
+	"session.dmScope", "reliability", "identity", "log.level",
+}
+
+var activeProfile string
+
+// SetProfile 选择 Load 时叠加的 profile（--profile）；为空时使用环境变量 HIGHCLAW_PROFILE
+func SetProfile(name string) {
+	activeProfile = strings.TrimSpace(name)
+}
+
+// ActiveProfile 返回当前选择的 profile 名，未选择时为空
+func ActiveProfile() string {
+	if activeProfile != "" {
+		return activeProfile
+	}
+	return strings.TrimSpace(os.Getenv(ProfileEnv))
+}
+
+// SystemConfigPath 系统级配置文件路径
+func SystemConfigPath() string {
+	if p := os.Getenv(SystemConfigEnv); p != "" {
+		return p
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "highclaw", "config.yaml")
+	}
+	return "/etc/highclaw/config.yaml"
+}
+
+// WorkspaceConfigPath 从当前目录向上查找 .highclaw.yaml，找不到时返回空
+func WorkspaceConfigPath() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		p := filepath.Join(dir, WorkspaceConfigName)
+		if st, err := os.Stat(p); err == nil && !st.IsDir() {
+			return p
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// layer 一个配置来源
+type layer struct {
+	label string // 如 "user /root/.highclaw/config.yaml"、"env HIGHCLAW_LOG_LEVEL"
+	tree  map[string]any
+}
+
+// layerState Load 时记录的分层信息，供 show --origin 和 Save 使用
+type layerState struct {
+	profile  string
+	files    []string
+	origins  map[string]string // 路径 → 来源层，路径按 pathKey 编码
+	warnings []string
+	// 用户配置文件自身的叶子原值（未解析 enc:/${...}），以及 include / profiles 原文
+	userRaw  map[string]treeLeaf
+	userMeta map[string]any
+	// 最终取值来自用户配置文件之外（系统、片段、工作区、profile、环境变量）的叶子，Save 时不写入用户配置
+	overlay map[string]treeLeaf
+}
+
+type treeLeaf struct {
+	path  []string
+	value any
+}
+
+// pathKey 路径编码，键中可能含 "."（如模型 ID），用 \x00 分隔
+func pathKey(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+// loader 读取配置文件层
+type loader struct {
+	state    *layerState
+	profiles map[string][]layer
+	visiting map[string]bool
+}
+
+// readFile 读取配置文件及其 include 片段，返回按合并顺序排列的层；kind 为 system / user / workspace / include
+func (ld *loader) readFile(path, kind string, depth int) ([]layer, map[string]any, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	if ld.visiting[abs] {
+		return nil, nil, fmt.Errorf("include cycle at %s", path)
+	}
+	if depth > maxIncludeDepth {
+		return nil, nil, fmt.Errorf("include depth exceeds %d at %s", maxIncludeDepth, path)
+	}
+	ld.visiting[abs] = true
+	defer delete(ld.visiting, abs)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read config: %w", err)
+	}
+	tree, err := parseConfigTree(data, path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	ld.state.files = append(ld.state.files, path)
+
+	meta := map[string]any{}
+	for _, k := range []string{includeKey, profilesKey} {
+		if v, ok := tree[k]; ok {
+			meta[k] = v
+			delete(tree, k)
+		}
+	}
+	if kind == "workspace" {
+		ld.restrict(tree, path)
+	}
+
+	var layers []layer
+	includes, err := includePaths(meta[includeKey], path)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, inc := range includes {
+		incKind := "include"
+		if kind == "workspace" {
+			incKind = "workspace"
+		}
+		sub, _, err := ld.readFile(inc, incKind, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		layers = append(layers, sub...)
+	}
+	layers = append(layers, layer{label: kind + " " + path, tree: tree})
+
+	if raw, ok := meta[profilesKey]; ok {
+		defs, ok := raw.(map[string]any)
+		if !ok {
+			return nil, nil, fmt.Errorf("config %s: profiles must be a mapping of name to config", path)
+		}
+		for name, def := range defs {
+			m, ok := def.(map[string]any)
+			if !ok {
+				return nil, nil, fmt.Errorf("config %s: profiles.%s must be a mapping", path, name)
+			}
+			if kind == "workspace" {
+				ld.restrict(m, path)
+			}
+			ld.profiles[name] = append(ld.profiles[name], layer{label: "profile " + name + " " + path, tree: m})
+		}
+	}
+	return layers, meta, nil
+}
+
+// restrict 去掉工作区配置中不在 workspaceAllowed 内的配置，以及引用密钥的值（enc:、${env:}、${file:}），
+// 避免仓库里的配置借合并后的密钥解析读出用户的密钥
+func (ld *loader) restrict(tree map[string]any, file string) {
+	ld.restrictNode(tree, nil, file)
+}
+
+func (ld *loader) restrictNode(node map[string]any, prefix []string, file string) {
+	keys := make([]string, 0, len(node))
+	for k := range node {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := node[k]
+		path := append(prefix[:len(prefix):len(prefix)], k)
+		key := strings.Join(path, ".")
+		switch workspaceAccess(key) {
+		case accessDenied:
+			delete(node, k)
+			ld.warn(file, key+" is ignored in workspace config; set it in the user config or a profile")
+		case accessPartial:
+			if m, ok := v.(map[string]any); ok {
+				ld.restrictNode(m, path, file)
+				if len(m) == 0 {
+					delete(node, k)
+				}
+			} else {
+				delete(node, k)
+				ld.warn(file, key+" is ignored in workspace config; set it in the user config or a profile")
+			}
+		case accessAllowed:
+			if m, ok := v.(map[string]any); ok {
+				ld.restrictNode(m, path, file)
+			} else if secretRefIn(v) {
+				delete(node, k)
+				ld.warn(file, key+": secret references (enc:, ${env:...}, ${file:...}) are not allowed in workspace config")
+			}
+		}
+	}
+}
+
+func (ld *loader) warn(file, msg string) {
+	ld.state.warnings = append(ld.state.warnings, file+": "+msg)
+}
+
+const (
+	accessDenied = iota
+	accessPartial
+	accessAllowed
+)
+
+// workspaceAccess 判断工作区配置能否设置 key：在允许项之内、是允许项的上级（需逐键检查）或不允许
+func workspaceAccess(key string) int {
+	access := accessDenied
+	for _, a := range workspaceAllowed {
+		if key == a || strings.HasPrefix(key, a+".") {
+			return accessAllowed
+		}
+		if strings.HasPrefix(a, key+".") {
+			access = accessPartial
+		}
+	}
+	return access
+}
+
+// secretRefIn 值中是否含有需要解析的密钥引用
+func secretRefIn(v any) bool {
+	found := false
+	walkStrings(deepCopy(v), "", func(_, s string) string {
+		if strings.HasPrefix(s, secretEncPrefix) || secretRefPattern.MatchString(s) {
+			found = true
+		}
+		return s
+	})
+	return found
+}
+
+// includePaths 解析 include:（字符串或列表），相对路径相对于所在文件，支持 ~ 和 glob
+func includePaths(raw any, from string) ([]string, error) {
+	var items []string
+	switch t := raw.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		items = []string{t}
+	case []any:
+		for _, it := range t {
+			s, ok := it.(string)
+			if !ok {
+				return nil, fmt.Errorf("config %s: include entries must be strings", from)
+			}
+			items = append(items, s)
+		}
+	default:
+		return nil, fmt.Errorf("config %s: include must be a path or a list of paths", from)
+	}
+	var out []string
+	for _, it := range items {
+		p := strings.TrimSpace(it)
+		if p == "" {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(p, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				p = filepath.Join(home, rest)
+			}
+		}
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(from), p)
+		}
+		if strings.ContainsAny(p, "*?[") {
+			matches, err := filepath.Glob(p)
+			if err != nil {
+				return nil, fmt.Errorf("config %s: include %q: %w", from, it, err)
+			}
+			sort.Strings(matches)
+			out = append(out, matches...)
+			continue
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// loadLayers 按优先级收集所有配置层并合并到 cfg；userPath 为空表示没有用户配置文件
+func loadLayers(cfg *Config, userPath string) error {
+	state := &layerState{
+		profile:  ActiveProfile(),
+		origins:  map[string]string{},
+		userRaw:  map[string]treeLeaf{},
+		userMeta: map[string]any{},
+		overlay:  map[string]treeLeaf{},
+	}
+	ld := &loader{state: state, profiles: map[string][]layer{}, visiting: map[string]bool{}}
+	var layers []layer
+
+	if p := SystemConfigPath(); fileExists(p) {
+		ls, _, err := ld.readFile(p, "system", 0)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, ls...)
+	}
+	if userPath != "" {
+		ls, meta, err := ld.readFile(userPath, "user", 0)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, ls...)
+		state.userMeta = meta
+		flattenTree(ls[len(ls)-1].tree, nil, func(path []string, v any) {
+			state.userRaw[pathKey(path)] = treeLeaf{path: path, value: v}
+		})
+	}
+	if p := WorkspaceConfigPath(); p != "" && !sameFile(p, userPath) {
+		ls, _, err := ld.readFile(p, "workspace", 0)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, ls...)
+	}
+	if name := state.profile; name != "" {
+		defs, ok := ld.profiles[name]
+		if !ok {
+			return fmt.Errorf("profile %q is not defined in any config file", name)
+		}
+		layers = append(layers, defs...)
+	}
+
+	merged := map[string]any{}
+	for _, l := range layers {
+		mergeTree(merged, l.tree, nil, l.label, state.origins)
+	}
+	envs, err := envLayers(merged)
+	if err != nil {
+		return err
+	}
+	for _, l := range envs {
+		mergeTree(merged, l.tree, nil, l.label, state.origins)
+	}
+
+	jb, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(jb, cfg); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return fmt.Errorf("config %s (from %s): %w", typeErr.Field, state.originOf(strings.Split(typeErr.Field, ".")), err)
+		}
+		return fmt.Errorf("decode config: %w", err)
+	}
+	cfg.layers = state
+	return nil
+}
+
+// recordOverlay 在密钥解析之后记录来自非用户层的最终取值
+func (s *layerState) recordOverlay(cfg *Config) {
+	tree, err := configTree(cfg)
+	if err != nil {
+		return
+	}
+	flattenTree(tree, nil, func(path []string, v any) {
+		origin := s.originOf(path)
+		if origin != defaultOrigin && !strings.HasPrefix(origin, "user ") {
+			s.overlay[pathKey(path)] = treeLeaf{path: path, value: v}
+		}
+	})
+}
+
+// originOf 返回路径的来源层（取最近的有记录的祖先）
+func (s *layerState) originOf(path []string) string {
+	for i := len(path); i > 0; i-- {
+		if o, ok := s.origins[pathKey(path[:i])]; ok {
+			return o
+		}
+	}
+	return defaultOrigin
+}
+
+// stripOverlay Save 时去掉来自其他层且未被修改的值，避免把共享配置、profile 和环境变量写进用户配置；
+// 用户配置文件自己也设置过的路径写回其原值
+func (s *layerState) stripOverlay(tree map[string]any) {
+	for key, leaf := range s.overlay {
+		cur, ok := treeGet(tree, leaf.path)
+		if !ok || !jsonEqual(cur, leaf.value) {
+			continue
+		}
+		if raw, ok := s.userRaw[key]; ok {
+			treeSet(tree, leaf.path, raw.value)
+		} else {
+			treeDelete(tree, leaf.path)
+		}
+	}
+	for k, v := range s.userMeta {
+		tree[k] = v
+	}
+}
+
+// ValueOrigin 一个配置值及其来源层
+type ValueOrigin struct {
+	Path   string `json:"path"`
+	Value  any    `json:"value"`
+	Origin string `json:"origin"`
+}
+
+// Origins 列出所有配置值（敏感字段已脱敏）及其来源层，按路径排序。
+// 来源形如 "default"、"system <file>"、"user <file>"、"include <file>"、"workspace <file>"、
+// "profile <name> <file>"、"env <VAR>"。
+func (c *Config) Origins() []ValueOrigin {
+	state := c.layers
+	if state == nil {
+		state = &layerState{origins: map[string]string{}}
+	}
+	var out []ValueOrigin
+	flattenTree(c.Redacted(), nil, func(path []string, v any) {
+		out = append(out, ValueOrigin{Path: strings.Join(path, "."), Value: v, Origin: state.originOf(path)})
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// SourceFiles 返回 Load 读取的配置文件（含 include 片段），按合并顺序
+func (c *Config) SourceFiles() []string {
+	if c.layers == nil {
+		return nil
+	}
+	return append([]string(nil), c.layers.files...)
+}
+
+// LoadWarnings 返回 Load 时被忽略的设置（如工作区配置中不允许的配置段）
+func (c *Config) LoadWarnings() []string {
+	if c.layers == nil {
+		return nil
+	}
+	return append([]string(nil), c.layers.warnings...)
+}
+
+// Profile 返回 Load 时叠加的 profile 名
+func (c *Config) Profile() string {
+	if c.layers == nil {
+		return ""
+	}
+	return c.layers.profile
+}
+
+// mergeTree 把 src 深度合并进 dst 并记录来源：对象逐键合并，其余值整体替换
+func mergeTree(dst, src map[string]any, prefix []string, label string, origins map[string]string) {
+	for k, v := range src {
+		path := append(prefix[:len(prefix):len(prefix)], k)
+		if sm, ok := v.(map[string]any); ok {
+			if dm, ok := dst[k].(map[string]any); ok {
+				mergeTree(dm, sm, path, label, origins)
+				continue
+			}
+		}
+		clearOrigins(origins, path)
+		dst[k] = deepCopy(v)
+		flattenTree(v, path, func(p []string, _ any) {
+			origins[pathKey(p)] = label
+		})
+	}
+}
+
+func clearOrigins(origins map[string]string, path []string) {
+	key := pathKey(path)
+	for k := range origins {
+		if k == key || strings.HasPrefix(k, key+"\x00") {
+			delete(origins, k)
+		}
+	}
+}
+
+// flattenTree 遍历叶子：标量、列表和空对象
+func flattenTree(node any, path []string, fn func(path []string, v any)) {
+	if m, ok := node.(map[string]any); ok && len(m) > 0 {
+		for k, v := range m {
+			flattenTree(v, append(path[:len(path):len(path)], k), fn)
+		}
+		return
+	}
+	if len(path) > 0 {
+		fn(path, node)
+	}
+}
+
+func treeGet(tree map[string]any, path []string) (any, bool) {
+	var node any = tree
+	for _, k := range path {
+		m, ok := node.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if node, ok = m[k]; !ok {
+			return nil, false
+		}
+	}
+	return node, true
+}
+
+func treeSet(tree map[string]any, path []string, v any) {
+	node := tree
+	for _, k := range path[:len(path)-1] {
+		next, ok := node[k].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			node[k] = next
+		}
+		node = next
+	}
+	node[path[len(path)-1]] = v
+}
+
+// treeDelete 删除叶子，并删除因此变空的上级对象
+func treeDelete(tree map[string]any, path []string) {
+	if len(path) == 0 {
+		return
+	}
+	if len(path) == 1 {
+		delete(tree, path[0])
+		return
+	}
+	child, ok := tree[path[0]].(map[string]any)
+	if !ok {
+		return
+	}
+	treeDelete(child, path[1:])
+	if len(child) == 0 {
+		delete(tree, path[0])
+	}
+}
+
+// treeWith 构造只含一个路径的树
+func treeWith(path []string, v any) map[string]any {
+	tree := map[string]any{}
+	treeSet(tree, path, v)
+	return tree
+}
+
+func deepCopy(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(t))
+		for k, val := range t {
+			m[k] = deepCopy(val)
+		}
+		return m
+	case []any:
+		out := make([]any, len(t))
+		for i, val := range t {
+			out[i] = deepCopy(val)
+		}
+		return out
+	}
+	return v
+}
+
+func jsonEqual(a, b any) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && reflect.DeepEqual(ja, jb)
+}
+
+func fileExists(path string) bool {
+	st, err := os.Stat(path)
+	return err == nil && !st.IsDir()
+}
+
+func sameFile(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	sa, errA := os.Stat(a)
+	sb, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(sa, sb)
+}
@@ -0,0 +1,6 @@
+package config
+
+// PromptGuardEnabled 是否启用提示词注入防护（默认启用）
+func (c *Config) PromptGuardEnabled() bool {
+	return c.PromptGuard.Enabled == nil || *c.PromptGuard.Enabled
+}
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"embed"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// 字段说明直接取自本包结构体的注释：源码随二进制嵌入，运行时解析，schema 与注释始终一致
+//
+//go:embed *.go
+var sourceFiles embed.FS
+
+// SchemaURI JSON Schema 版本
+const SchemaURI = "https://json-schema.org/draft/2020-12/schema"
+
+// fieldEnums 取值受限的字段（key 为 "结构体名.字段名"），比较时忽略大小写，空值表示使用默认值
+var fieldEnums = map[string][]string{
+	"AutonomyConfig.Level":          {"readonly", "supervised", "full"},
+	"AgentProfile.Autonomy":         {"readonly", "supervised", "full"},
+	"AgentBinding.PeerKind":         {"direct", "group", "channel"},
+	"ToolPermissionRule.PeerKind":   {"direct", "group", "channel"},
+	"SessionConfig.Scope":           {"per-sender", "global"},
+	"SessionConfig.DMScope":         {"main", "per-peer", "per-channel-peer", "per-account-channel-peer"},
+	"SandboxConfig.Mode":            {"off", "non-main", "all"},
+	"LogConfig.Level":               {"debug", "info", "warn", "warning", "error"},
+	"GatewayConfig.Bind":            {"loopback", "all", "tailnet"},
+	"GatewayAuth.Mode":              {"none", "token", "password"},
+	"TailscaleConfig.Mode":          {"off", "serve", "funnel"},
+	"MemoryConfig.Backend":          {"sqlite", "markdown", "none"},
+	"DLPConfig.Action":              dlpActions,
+	"DLPConfig.ToolOutput":          dlpActions,
+	"ShellRule.Risk":                {"low", "medium", "high", "block"},
+	"QuotaRule.Scope":               {"sender", "group", "channel", "agent"},
+	"TracingConfig.Exporter":        {"none", "otlp", "file"},
+	"WebToolsConfig.SearchProvider": {"auto", "brave", "google", "searxng", "duckduckgo", "ddg"},
+	"WeChatConfig.Mode":             {"official", "personal"},
+}
+
+// fieldDoc 字段注释
+type fieldDoc struct {
+	Description string
+	Deprecated  string // "Deprecated:" 段落，非空表示字段已废弃
+}
+
+var (
+	docsOnce  sync.Once
+	fieldDocs map[string]fieldDoc // "结构体名.字段名" → 注释
+	typeDocs  map[string]string   // 结构体名 → 注释
+)
+
+// loadDocs 解析嵌入的源码，收集结构体及字段注释
+func loadDocs() {
+	docsOnce.Do(func() {
+		fieldDocs = make(map[string]fieldDoc)
+		typeDocs = make(map[string]string)
+		entries, _ := sourceFiles.ReadDir(".")
+		fset := token.NewFileSet()
+		for _, e := range entries {
+			if strings.HasSuffix(e.Name(), "_test.go") {
+				continue
+			}
+			src, err := sourceFiles.ReadFile(e.Name())
+			if err != nil {
+				continue
+			}
+			f, err := parser.ParseFile(fset, e.Name(), src, parser.ParseComments)
+			if err != nil {
+				continue
+			}
+			for _, decl := range f.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					ts := spec.(*ast.TypeSpec)
+					doc := ts.Doc
+					if doc == nil && len(gen.Specs) == 1 {
+						doc = gen.Doc
+					}
+					if d := parseDoc(ts.Name.Name, doc); d.Description != "" {
+						typeDocs[ts.Name.Name] = d.Description
+					}
+					st, ok := ts.Type.(*ast.StructType)
+					if !ok {
+						continue
+					}
+					for _, field := range st.Fields.List {
+						doc := field.Doc
+						if doc == nil {
+							doc = field.Comment
+						}
+						for _, name := range field.Names {
+							if d := parseDoc(name.Name, doc); d != (fieldDoc{}) {
+								fieldDocs[ts.Name.Name+"."+name.Name] = d
+							}
+						}
+					}
+				}
+			}
+		}
+	})
+}
+
+// parseDoc 拆出 Deprecated 段落；中文注释开头的标识符名只是标签，去掉，英文注释保留完整句子
+func parseDoc(name string, group *ast.CommentGroup) fieldDoc {
+	if group == nil {
+		return fieldDoc{}
+	}
+	text := strings.TrimSpace(group.Text())
+	if rest, ok := strings.CutPrefix(text, name+" "); ok && !isASCII(rest) {
+		text = strings.TrimSpace(rest)
+	}
+	var d fieldDoc
+	if i := strings.Index(text, "Deprecated:"); i >= 0 {
+		d.Deprecated = strings.TrimSpace(text[i+len("Deprecated:"):])
+		text = strings.TrimSpace(text[:i])
+	}
+	d.Description = text
+	return d
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// Schema 由 Config 结构体生成 JSON Schema，字段说明取自结构体注释，供编辑器补全和校验
+func Schema() map[string]any {
+	loadDocs()
+	s := typeSchema(reflect.TypeOf(Config{}))
+	s["$schema"] = SchemaURI
+	s["title"] = "HighClaw configuration"
+	// 分层配置的元数据键（见 layers.go）
+	props := s["properties"].(map[string]any)
+	props[includeKey] = map[string]any{
+		"description": "引入的配置片段文件，先于本文件合并；相对路径相对于本文件，支持 ~ 和 glob",
+		"oneOf": []any{
+			map[string]any{"type": "string"},
+			map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		},
+	}
+	props[profilesKey] = map[string]any{
+		"type":                 "object",
+		"description":          "命名 profile，用 --profile 或 HIGHCLAW_PROFILE 选择后叠加在所有配置文件之上",
+		"additionalProperties": map[string]any{"$ref": "#"},
+	}
+	return s
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		props := make(map[string]any)
+		for _, f := range structFields(t) {
+			p := typeSchema(f.Type)
+			key := t.Name() + "." + f.Name
+			if d, ok := fieldDocs[key]; ok {
+				if d.Description != "" {
+					p["description"] = d.Description
+				}
+				if d.Deprecated != "" {
+					p["deprecated"] = true
+					p["description"] = strings.TrimSpace(d.Description + "\nDeprecated: " + d.Deprecated)
+				}
+			}
+			if values, ok := fieldEnums[key]; ok {
+				p["enum"] = values
+			}
+			props[f.Key] = p
+		}
+		s := map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+		if d := typeDocs[t.Name()]; d != "" {
+			s["description"] = d
+		}
+		return s
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	return map[string]any{}
+}
+
+// schemaField 结构体中对应配置键的字段
+type schemaField struct {
+	Name string
+	Key  string
+	Type reflect.Type
+}
+
+// structFields 返回结构体的配置键（json tag 名），跳过未导出字段和 json:"-"
+func structFields(t reflect.Type) []schemaField {
+	var out []schemaField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		key, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			key = f.Name
+		}
+		out = append(out, schemaField{Name: f.Name, Key: key, Type: f.Type})
+	}
+	return out
+}
@@ -0,0 +1,552 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// SecretsPassphraseEnv 设置后用口令派生加密密钥，代替密钥文件
+	SecretsPassphraseEnv = "HIGHCLAW_SECRETS_PASSPHRASE"
+	// SecretsNewPassphraseEnv secrets rotate 时可选的新口令
+	SecretsNewPassphraseEnv = "HIGHCLAW_SECRETS_NEW_PASSPHRASE"
+	// RedactedValue 展示配置时敏感字段的替换值
+	RedactedValue = "******"
+
+	secretEncPrefix    = "enc:"
+	secretsKeyFileName = "secrets.key"
+	secretsKDFRounds   = 210000
+)
+
+// ${env:NAME} / ${file:/path} 引用
+var secretRefPattern = regexp.MustCompile(`\$\{(env|file):([^}]+)\}`)
+
+// 字段名（小写）以这些后缀结尾时视为敏感字段
+var secretKeySuffixes = []string{"apikey", "token", "secret", "password", "encryptkey", "aeskey"}
+
+// ErrSecretsKeyMissing is returned when encrypted values exist but no key file or passphrase is available.
+var ErrSecretsKeyMissing = errors.New("secrets key not found")
+
+// secretState 记录 Load 时从 enc:/${...} 解析出的字段，Save 时未修改的字段按原样写回
+type secretState struct {
+	raw      map[string]string // path → 文件中的原始写法
+	resolved map[string]string // path → 解析后的明文
+	forced   map[string]bool   // secrets set 写入的字段，即使字段名不像密钥也加密
+}
+
+func newSecretState() *secretState {
+	return &secretState{raw: map[string]string{}, resolved: map[string]string{}, forced: map[string]bool{}}
+}
+
+// IsSecretPath 按字段名判断配置路径是否为敏感字段，如 agent.providers.openai.apiKey
+func IsSecretPath(path string) bool {
+	key := path
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		key = path[i+1:]
+	}
+	key = strings.ToLower(key)
+	for _, s := range secretKeySuffixes {
+		if strings.HasSuffix(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) isSecret(path string) bool {
+	if IsSecretPath(path) {
+		return true
+	}
+	return c.secretSources != nil && c.secretSources.forced[path]
+}
+
+// secretVault AES-256-GCM 加解密
+type secretVault struct {
+	aead cipher.AEAD
+}
+
+func newSecretVault(key []byte) (*secretVault, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &secretVault{aead: aead}, nil
+}
+
+func (v *secretVault) encrypt(plain string) (string, error) {
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := v.aead.Seal(nonce, nonce, []byte(plain), nil)
+	return secretEncPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (v *secretVault) decrypt(value string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, secretEncPrefix))
+	if err != nil || len(data) < v.aead.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	n := v.aead.NonceSize()
+	plain, err := v.aead.Open(nil, data[:n], data[n:], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt failed (wrong key?)")
+	}
+	return string(plain), nil
+}
+
+// SecretsKeyFile 返回密钥文件路径
+func (c *Config) SecretsKeyFile() string {
+	if p := strings.TrimSpace(c.Secrets.KeyFile); p != "" {
+		return p
+	}
+	return filepath.Join(ConfigDir(), secretsKeyFileName)
+}
+
+var (
+	kdfMu    sync.Mutex
+	kdfCache = map[string][]byte{}
+)
+
+// passphraseKey 口令派生密钥；PBKDF2 较慢，进程内按口令+盐缓存
+func passphraseKey(passphrase, salt string) ([]byte, error) {
+	kdfMu.Lock()
+	defer kdfMu.Unlock()
+	cacheKey := salt + "\x00" + passphrase
+	if key, ok := kdfCache[cacheKey]; ok {
+		return key, nil
+	}
+	rawSalt, err := base64.StdEncoding.DecodeString(salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secrets.salt: %w", err)
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, rawSalt, secretsKDFRounds, 32)
+	if err != nil {
+		return nil, err
+	}
+	kdfCache[cacheKey] = key
+	return key, nil
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	return b, err
+}
+
+// openVault 按口令（环境变量）或密钥文件打开 vault。create=true 时缺少的盐或密钥文件会被生成。
+func (c *Config) openVault(create bool) (*secretVault, error) {
+	if pass := os.Getenv(SecretsPassphraseEnv); pass != "" {
+		if c.Secrets.Salt == "" {
+			if !create {
+				return nil, fmt.Errorf("%w: secrets.salt is empty", ErrSecretsKeyMissing)
+			}
+			salt, err := randomBytes(16)
+			if err != nil {
+				return nil, err
+			}
+			c.Secrets.Salt = base64.StdEncoding.EncodeToString(salt)
+		}
+		key, err := passphraseKey(pass, c.Secrets.Salt)
+		if err != nil {
+			return nil, err
+		}
+		return newSecretVault(key)
+	}
+
+	path := c.SecretsKeyFile()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if !create {
+			return nil, fmt.Errorf("%w: %s (or set %s)", ErrSecretsKeyMissing, path, SecretsPassphraseEnv)
+		}
+		key, err := randomBytes(32)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeKeyFile(path, key); err != nil {
+			return nil, err
+		}
+		return newSecretVault(key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read secrets key: %w", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("invalid secrets key file %s", path)
+	}
+	return newSecretVault(key)
+}
+
+func writeKeyFile(path string, key []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create secrets key dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0o600); err != nil {
+		return fmt.Errorf("write secrets key: %w", err)
+	}
+	return nil
+}
+
+// expandSecretRefs 展开字符串中的 ${env:X} / ${file:/path}
+func expandSecretRefs(value string) (string, error) {
+	var firstErr error
+	out := secretRefPattern.ReplaceAllStringFunc(value, func(m string) string {
+		parts := secretRefPattern.FindStringSubmatch(m)
+		name := strings.TrimSpace(parts[2])
+		switch parts[1] {
+		case "env":
+			v, ok := os.LookupEnv(name)
+			if !ok && firstErr == nil {
+				firstErr = fmt.Errorf("environment variable %s is not set", name)
+			}
+			return v
+		default:
+			data, err := os.ReadFile(name)
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("read secret file: %w", err)
+			}
+			return strings.TrimRight(string(data), "\r\n")
+		}
+	})
+	return out, firstErr
+}
+
+// configTree 把配置转成 json 键名的通用树，便于按路径遍历
+func configTree(c *Config) (map[string]any, error) {
+	jb, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]any
+	if err := json.Unmarshal(jb, &tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// walkStrings 深度遍历树中的字符串，fn 返回替换值
+func walkStrings(node any, path string, fn func(path, value string) string) any {
+	join := func(k string) string {
+		if path == "" {
+			return k
+		}
+		return path + "." + k
+	}
+	switch t := node.(type) {
+	case map[string]any:
+		for k, v := range t {
+			t[k] = walkStrings(v, join(k), fn)
+		}
+	case []any:
+		for i, v := range t {
+			t[i] = walkStrings(v, join(strconv.Itoa(i)), fn)
+		}
+	case string:
+		return fn(path, t)
+	}
+	return node
+}
+
+// resolveSecrets Load 之后调用：解密 enc: 值、展开 ${env:}/${file:} 引用，并记录原始写法
+func (c *Config) resolveSecrets() error {
+	tree, err := configTree(c)
+	if err != nil {
+		return err
+	}
+	state := newSecretState()
+	var vault *secretVault
+	var vaultErr error
+	vaultReported := false
+	var errs []error
+	walkStrings(tree, "", func(path, value string) string {
+		var out string
+		var err error
+		switch {
+		case strings.HasPrefix(value, secretEncPrefix):
+			if vault == nil && vaultErr == nil {
+				vault, vaultErr = c.openVault(false)
+			}
+			if vaultErr != nil {
+				// 缺密钥只报一次
+				if !vaultReported {
+					errs = append(errs, vaultErr)
+					vaultReported = true
+				}
+				return value
+			}
+			out, err = vault.decrypt(value)
+		case secretRefPattern.MatchString(value):
+			out, err = expandSecretRefs(value)
+		default:
+			return value
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			return value
+		}
+		state.raw[path] = value
+		state.resolved[path] = out
+		if strings.HasPrefix(value, secretEncPrefix) {
+			state.forced[path] = true
+		}
+		return out
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("resolve secrets: %w", errors.Join(errs...))
+	}
+	c.secretSources = state
+	if len(state.raw) == 0 {
+		return nil
+	}
+	jb, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(jb, c)
+}
+
+// sealSecrets Save 之前调用：未修改的字段写回原始写法，其余敏感字段在 secrets.encrypt 打开时加密
+func (c *Config) sealSecrets(tree map[string]any, vault *secretVault) error {
+	var firstErr error
+	walkStrings(tree, "", func(path, value string) string {
+		if s := c.secretSources; s != nil {
+			if raw, ok := s.raw[path]; ok && s.resolved[path] == value {
+				return raw
+			}
+		}
+		if value == "" || vault == nil || !c.isSecret(path) ||
+			strings.HasPrefix(value, secretEncPrefix) || secretRefPattern.MatchString(value) {
+			return value
+		}
+		enc, err := vault.encrypt(value)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("encrypt %s: %w", path, err)
+			}
+			return value
+		}
+		return enc
+	})
+	return firstErr
+}
+
+// Redacted 返回用于展示的配置树：敏感字段替换为 RedactedValue，${env:}/${file:} 引用显示原文
+func (c *Config) Redacted() map[string]any {
+	tree, err := configTree(c)
+	if err != nil {
+		return map[string]any{}
+	}
+	walkStrings(tree, "", func(path, value string) string {
+		if s := c.secretSources; s != nil {
+			if raw, ok := s.raw[path]; ok && s.resolved[path] == value && !strings.HasPrefix(raw, secretEncPrefix) {
+				return raw
+			}
+		}
+		if value != "" && c.isSecret(path) {
+			return RedactedValue
+		}
+		return value
+	})
+	return tree
+}
+
+// SecretStatus 描述一个非空敏感字段在配置文件中的存储方式
+type SecretStatus struct {
+	Path    string
+	Storage string // "encrypted" | "reference" | "plaintext"
+}
+
+// SecretFields 列出所有非空敏感字段及其存储方式，按路径排序
+func (c *Config) SecretFields() []SecretStatus {
+	tree, err := configTree(c)
+	if err != nil {
+		return nil
+	}
+	var out []SecretStatus
+	walkStrings(tree, "", func(path, value string) string {
+		if value == "" || !c.isSecret(path) {
+			return value
+		}
+		storage := "plaintext"
+		if s := c.secretSources; s != nil {
+			if raw, ok := s.raw[path]; ok && s.resolved[path] == value {
+				storage = "reference"
+				if strings.HasPrefix(raw, secretEncPrefix) {
+					storage = "encrypted"
+				}
+			}
+		}
+		out = append(out, SecretStatus{Path: path, Storage: storage})
+		return value
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// Secret 按 json 路径读取字符串值（已解密）
+func (c *Config) Secret(path string) (string, error) {
+	tree, err := configTree(c)
+	if err != nil {
+		return "", err
+	}
+	var node any = tree
+	for _, part := range strings.Split(path, ".") {
+		switch t := node.(type) {
+		case map[string]any:
+			next, ok := t[part]
+			if !ok {
+				return "", fmt.Errorf("config key not found: %s", path)
+			}
+			node = next
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(t) {
+				return "", fmt.Errorf("config key not found: %s", path)
+			}
+			node = t[i]
+		default:
+			return "", fmt.Errorf("config key not found: %s", path)
+		}
+	}
+	s, ok := node.(string)
+	if !ok {
+		return "", fmt.Errorf("config key %s is not a string", path)
+	}
+	return s, nil
+}
+
+// SetSecret 按 json 路径写入字符串值，中间缺失的对象会被创建；保存时该字段总是加密。
+func (c *Config) SetSecret(path, value string) error {
+	tree, err := configTree(c)
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(path, ".")
+	node := tree
+	for i, part := range parts[:len(parts)-1] {
+		next, ok := node[part].(map[string]any)
+		if !ok {
+			if existing, exists := node[part]; exists && existing != nil {
+				return fmt.Errorf("config key %s is not an object", strings.Join(parts[:i+1], "."))
+			}
+			next = map[string]any{}
+			node[part] = next
+		}
+		node = next
+	}
+	last := parts[len(parts)-1]
+	if existing, ok := node[last]; ok && existing != nil {
+		if _, isString := existing.(string); !isString {
+			return fmt.Errorf("config key %s is not a string", path)
+		}
+	}
+	node[last] = value
+
+	jb, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	var updated Config
+	if err := json.Unmarshal(jb, &updated); err != nil {
+		return fmt.Errorf("set %s: %w", path, err)
+	}
+	if got, err := updated.Secret(path); err != nil || got != value {
+		return fmt.Errorf("unknown config key: %s", path)
+	}
+	state := c.secretSources
+	if state == nil {
+		state = newSecretState()
+	}
+	delete(state.raw, path)
+	delete(state.resolved, path)
+	state.forced[path] = true
+	updated.secretSources = state
+	updated.layers = c.layers
+	*c = updated
+	return nil
+}
+
+// RotateSecretsKey 生成新密钥（密钥文件模式）或新盐/新口令（口令模式），用新密钥重新加密并保存配置。
+// 旧密钥文件保留为 secrets.key.old。
+func RotateSecretsKey(cfg *Config) error {
+	if !cfg.Secrets.Encrypt {
+		return fmt.Errorf("secrets.encrypt is off; nothing to rotate")
+	}
+	// 已加密字段丢弃原始密文，强制用新密钥重新加密
+	if s := cfg.secretSources; s != nil {
+		for path, raw := range s.raw {
+			if strings.HasPrefix(raw, secretEncPrefix) {
+				delete(s.raw, path)
+				delete(s.resolved, path)
+			}
+		}
+	}
+
+	if pass := os.Getenv(SecretsPassphraseEnv); pass != "" {
+		if next := os.Getenv(SecretsNewPassphraseEnv); next != "" {
+			pass = next
+		}
+		salt, err := randomBytes(16)
+		if err != nil {
+			return err
+		}
+		cfg.Secrets.Salt = base64.StdEncoding.EncodeToString(salt)
+		key, err := passphraseKey(pass, cfg.Secrets.Salt)
+		if err != nil {
+			return err
+		}
+		vault, err := newSecretVault(key)
+		if err != nil {
+			return err
+		}
+		return saveWithVault(cfg, vault)
+	}
+
+	key, err := randomBytes(32)
+	if err != nil {
+		return err
+	}
+	vault, err := newSecretVault(key)
+	if err != nil {
+		return err
+	}
+	path := cfg.SecretsKeyFile()
+	pending := path + ".new"
+	if err := writeKeyFile(pending, key); err != nil {
+		return err
+	}
+	if err := saveWithVault(cfg, vault); err != nil {
+		_ = os.Remove(pending)
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		if err := os.Rename(path, path+".old"); err != nil {
+			return fmt.Errorf("keep old secrets key: %w", err)
+		}
+	}
+	if err := os.Rename(pending, path); err != nil {
+		return fmt.Errorf("install new secrets key (config already re-encrypted, key is at %s): %w", pending, err)
+	}
+	return nil
+}
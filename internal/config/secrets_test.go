@@ -0,0 +1,157 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setupSecretsConfig(t *testing.T) (path, keyFile string) {
+	t.Helper()
+	dir := t.TempDir()
+	path = filepath.Join(dir, "config.yaml")
+	keyFile = filepath.Join(dir, "secrets.key")
+	t.Setenv("HIGHCLAW_CONFIG", path)
+	t.Setenv(SecretsPassphraseEnv, "")
+	for _, env := range []string{"ANTHROPIC_API_KEY", "OPENAI_API_KEY", "TELEGRAM_BOT_TOKEN", "HIGHCLAW_WEB_PASSWORD"} {
+		t.Setenv(env, "")
+		os.Unsetenv(env)
+	}
+	return path, keyFile
+}
+
+func TestSecretsEncryptedAtRest(t *testing.T) {
+	path, keyFile := setupSecretsConfig(t)
+
+	cfg := Default()
+	cfg.Secrets.Encrypt = true
+	cfg.Secrets.KeyFile = keyFile
+	cfg.Agent.Providers = map[string]ProviderConfig{"openai": {APIKey: "sk-plain-123"}}
+	cfg.Gateway.Auth.Password = "hunter2"
+	if err := Save(cfg); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	raw, _ := os.ReadFile(path)
+	if strings.Contains(string(raw), "sk-plain-123") || strings.Contains(string(raw), "hunter2") {
+		t.Fatalf("secrets written in plain text:\n%s", raw)
+	}
+	if !strings.Contains(string(raw), "enc:") {
+		t.Fatalf("expected enc: values:\n%s", raw)
+	}
+	if st, _ := os.Stat(keyFile); st == nil || st.Mode().Perm() != 0o600 {
+		t.Fatalf("key file missing or too permissive: %v", st)
+	}
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if loaded.Agent.Providers["openai"].APIKey != "sk-plain-123" || loaded.Gateway.Auth.Password != "hunter2" {
+		t.Fatalf("secrets not decrypted: %+v", loaded.Agent.Providers)
+	}
+
+	// 未修改的密文原样写回，不产生无意义的 diff
+	if err := Save(loaded); err != nil {
+		t.Fatalf("resave: %v", err)
+	}
+	again, _ := os.ReadFile(path)
+	if string(again) != string(raw) {
+		t.Fatalf("unchanged secrets should keep their ciphertext")
+	}
+
+	if err := os.Remove(keyFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "secrets key not found") {
+		t.Fatalf("expected missing key error, got %v", err)
+	}
+}
+
+func TestSecretReferencesAndRedaction(t *testing.T) {
+	path, _ := setupSecretsConfig(t)
+	secretFile := filepath.Join(t.TempDir(), "tg")
+	if err := os.WriteFile(secretFile, []byte("tg-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HC_TEST_KEY", "from-env")
+	yaml := "agent:\n  model: openai/gpt-4o\n  providers:\n    openai:\n      apiKey: ${env:HC_TEST_KEY}\n" +
+		"channels:\n  telegram:\n    botToken: ${file:" + secretFile + "}\n" +
+		"gateway:\n  auth:\n    token: plain-token\n"
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Agent.Providers["openai"].APIKey != "from-env" || cfg.Channels.Telegram.BotToken != "tg-token" {
+		t.Fatalf("references not resolved")
+	}
+
+	red := cfg.Redacted()
+	gw := red["gateway"].(map[string]any)["auth"].(map[string]any)
+	if gw["token"] != RedactedValue {
+		t.Fatalf("gateway token should be redacted, got %v", gw["token"])
+	}
+	openai := red["agent"].(map[string]any)["providers"].(map[string]any)["openai"].(map[string]any)
+	if openai["apiKey"] != "${env:HC_TEST_KEY}" {
+		t.Fatalf("references should be shown as written, got %v", openai["apiKey"])
+	}
+
+	storage := map[string]string{}
+	for _, s := range cfg.SecretFields() {
+		storage[s.Path] = s.Storage
+	}
+	if storage["gateway.auth.token"] != "plaintext" || storage["agent.providers.openai.apiKey"] != "reference" || storage["channels.telegram.botToken"] != "reference" {
+		t.Fatalf("unexpected secret storage: %v", storage)
+	}
+
+	if err := Save(cfg); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	raw, _ := os.ReadFile(path)
+	if !strings.Contains(string(raw), "${env:HC_TEST_KEY}") || strings.Contains(string(raw), "from-env") {
+		t.Fatalf("references must be written back as references:\n%s", raw)
+	}
+}
+
+func TestSetSecretAndRotate(t *testing.T) {
+	_, keyFile := setupSecretsConfig(t)
+	cfg := Default()
+	cfg.Secrets.KeyFile = keyFile
+	if err := cfg.SetSecret("agent.providers.groq.apiKey", "gsk-1"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if err := cfg.SetSecret("agent.nope.value", "x"); err == nil {
+		t.Fatalf("unknown keys must be rejected")
+	}
+	cfg.Secrets.Encrypt = true
+	if err := Save(cfg); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	oldKey, _ := os.ReadFile(keyFile)
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if err := RotateSecretsKey(loaded); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	newKey, _ := os.ReadFile(keyFile)
+	if string(newKey) == string(oldKey) {
+		t.Fatalf("key was not rotated")
+	}
+	if _, err := os.Stat(keyFile + ".old"); err != nil {
+		t.Fatalf("old key should be kept: %v", err)
+	}
+	rotated, err := Load()
+	if err != nil {
+		t.Fatalf("load after rotate: %v", err)
+	}
+	if got, _ := rotated.Secret("agent.providers.groq.apiKey"); got != "gsk-1" {
+		t.Fatalf("secret lost after rotate: %q", got)
+	}
+}
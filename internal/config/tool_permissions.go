@@ -0,0 +1,76 @@
+package config
+
+import (
+	"strings"
+)
+
+// 内置工具权限档，可被 agent.toolPermissions.profiles 中的同名档覆盖
+const (
+	// ToolProfileFull 不限制工具
+	ToolProfileFull = "full"
+	// ToolProfileGroup 群聊/频道默认使用，禁用 shell
+	ToolProfileGroup = "group"
+)
+
+var builtinToolProfiles = map[string]ToolPermissionProfile{
+	ToolProfileFull:  {},
+	ToolProfileGroup: {Deny: []string{"shell"}},
+}
+
+// ToolPeer 选择工具权限档时使用的会话信息
+type ToolPeer struct {
+	Channel  string
+	PeerKind string
+	GroupID  string
+	Sender   string
+}
+
+// ToolPermissionProfileByName 按名称查找权限档，自定义档优先于内置档（大小写不敏感）
+func (c *Config) ToolPermissionProfileByName(name string) (ToolPermissionProfile, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return ToolPermissionProfile{}, false
+	}
+	for n, p := range c.Agent.ToolPermissions.Profiles {
+		if strings.ToLower(strings.TrimSpace(n)) == name {
+			return p, true
+		}
+	}
+	p, ok := builtinToolProfiles[name]
+	return p, ok
+}
+
+// ToolProfileFor 返回会话生效的权限档名；空字符串表示不额外限制。
+// 顺序：用户规则 → 群聊/频道回落到 "group" → Default。
+func (c *Config) ToolProfileFor(peer ToolPeer) string {
+	for _, r := range c.Agent.ToolPermissions.Rules {
+		if r.matches(peer) {
+			return strings.TrimSpace(r.Profile)
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(peer.PeerKind)) {
+	case "group", "channel":
+		return ToolProfileGroup
+	}
+	return strings.TrimSpace(c.Agent.ToolPermissions.Default)
+}
+
+func (r ToolPermissionRule) matches(peer ToolPeer) bool {
+	eq := func(want, got string) bool {
+		want = strings.TrimSpace(want)
+		return want == "" || want == "*" || strings.EqualFold(want, strings.TrimSpace(got))
+	}
+	if !eq(r.Channel, peer.Channel) || !eq(r.PeerKind, peer.PeerKind) || !eq(r.GroupID, peer.GroupID) {
+		return false
+	}
+	if len(r.Senders) == 0 {
+		return true
+	}
+	sender := strings.TrimSpace(peer.Sender)
+	for _, s := range r.Senders {
+		if strings.TrimSpace(s) == sender && sender != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,374 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+)
+
+// Issue 严格校验发现的问题，定位到配置文件中的行列
+type Issue struct {
+	// Path 配置键路径，如 "autonomy.level"、"agent.bindings[0].agent"
+	Path string `json:"path"`
+	// Line / Column 从 1 开始
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	// Message 问题说明
+	Message string `json:"message"`
+	// Warning 为 true 时仅提示（如废弃字段），不影响配置生效
+	Warning bool `json:"warning,omitempty"`
+}
+
+func (i Issue) String() string {
+	level := "error"
+	if i.Warning {
+		level = "warning"
+	}
+	return fmt.Sprintf("%d:%d: %s: %s: %s", i.Line, i.Column, level, i.Path, i.Message)
+}
+
+// HasErrors 是否存在错误级别的问题
+func HasErrors(issues []Issue) bool {
+	for _, i := range issues {
+		if !i.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateFile 严格校验配置文件：未知键（附拼写建议）、类型不符、枚举取值和废弃字段。
+// 文件不存在时不报问题；语法错误通过 error 返回。
+func ValidateFile(path string) ([]Issue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	return ValidateData(data, path)
+}
+
+// ValidateData 按 Config 结构严格校验配置内容，path 的扩展名决定按 YAML 还是 JSON 解析
+func ValidateData(data []byte, path string) ([]Issue, error) {
+	lower := strings.ToLower(path)
+	if !strings.HasSuffix(lower, ".yaml") && !strings.HasSuffix(lower, ".yml") {
+		// 兼容旧版 JSON 配置中的注释和尾逗号
+		data = []byte(preprocessJSONLike(string(data)))
+	}
+	file, err := parser.ParseBytes(data, 0)
+	if err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	loadDocs()
+	v := &validator{}
+	for _, doc := range file.Docs {
+		if doc.Body != nil {
+			v.checkRoot(doc.Body)
+		}
+	}
+	return v.issues, nil
+}
+
+type validator struct {
+	issues []Issue
+}
+
+func (v *validator) add(node ast.Node, path, msg string, warning bool) {
+	issue := Issue{Path: path, Message: msg, Warning: warning}
+	if path == "" {
+		issue.Path = "(root)"
+	}
+	if tk := node.GetToken(); tk != nil && tk.Position != nil {
+		issue.Line, issue.Column = tk.Position.Line, tk.Position.Column
+	}
+	v.issues = append(v.issues, issue)
+}
+
+// checkRoot 校验配置文件顶层：Config 字段之外还允许 include 和 profiles
+func (v *validator) checkRoot(node ast.Node) {
+	configType := reflect.TypeOf(Config{})
+	values, ok := mappingValues(unwrapNode(node))
+	if !ok {
+		v.check(node, configType, "")
+		return
+	}
+	var rest []*ast.MappingValueNode
+	for _, mv := range values {
+		switch mapKey(mv) {
+		case includeKey:
+			value := unwrapNode(mv.Value)
+			if seq, ok := value.(*ast.SequenceNode); ok {
+				for i, item := range seq.Values {
+					v.check(item, reflect.TypeOf(""), fmt.Sprintf("%s[%d]", includeKey, i))
+				}
+			} else {
+				v.check(value, reflect.TypeOf(""), includeKey)
+			}
+		case profilesKey:
+			profiles, ok := mappingValues(unwrapNode(mv.Value))
+			if !ok {
+				v.check(mv.Value, reflect.TypeOf(map[string]any{}), profilesKey)
+				continue
+			}
+			for _, p := range profiles {
+				v.check(p.Value, configType, profilesKey+"."+mapKey(p))
+			}
+		default:
+			rest = append(rest, mv)
+		}
+	}
+	v.checkStruct(configType, rest, "")
+}
+
+// check 校验 node 是否符合类型 t
+func (v *validator) check(node ast.Node, t reflect.Type, path string) {
+	node = unwrapNode(node)
+	if node == nil {
+		return
+	}
+	switch node.(type) {
+	case *ast.NullNode, *ast.AliasNode:
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		values, ok := mappingValues(node)
+		if !ok {
+			v.add(node, path, "expected a mapping, got "+nodeKind(node), false)
+			return
+		}
+		v.checkStruct(t, values, path)
+
+	case reflect.Map:
+		values, ok := mappingValues(node)
+		if !ok {
+			v.add(node, path, "expected a mapping, got "+nodeKind(node), false)
+			return
+		}
+		for _, mv := range values {
+			v.check(mv.Value, t.Elem(), joinPath(path, mapKey(mv)))
+		}
+
+	case reflect.Slice, reflect.Array:
+		seq, ok := node.(*ast.SequenceNode)
+		if !ok {
+			v.add(node, path, "expected a list, got "+nodeKind(node), false)
+			return
+		}
+		for i, item := range seq.Values {
+			v.check(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+
+	case reflect.String:
+		switch node.(type) {
+		case *ast.StringNode, *ast.LiteralNode:
+		default:
+			v.add(node, path, "expected a string, got "+nodeKind(node)+" (quote the value)", false)
+		}
+
+	case reflect.Bool:
+		if _, ok := node.(*ast.BoolNode); !ok {
+			v.add(node, path, "expected true or false, got "+nodeKind(node), false)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !isInteger(node) {
+			v.add(node, path, "expected an integer, got "+nodeKind(node), false)
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if !isInteger(node) {
+			v.add(node, path, "expected a non-negative integer, got "+nodeKind(node), false)
+		} else if strings.HasPrefix(node.GetToken().Value, "-") {
+			v.add(node, path, "must not be negative", false)
+		}
+
+	case reflect.Float32, reflect.Float64:
+		switch node.(type) {
+		case *ast.IntegerNode, *ast.FloatNode:
+		default:
+			v.add(node, path, "expected a number, got "+nodeKind(node), false)
+		}
+	}
+}
+
+// checkStruct 校验结构体对应的映射：未知键、废弃字段、枚举取值
+func (v *validator) checkStruct(t reflect.Type, values []*ast.MappingValueNode, path string) {
+	fields := structFields(t)
+	for _, mv := range values {
+		key := mapKey(mv)
+		if key == "<<" {
+			continue
+		}
+		keyPath := joinPath(path, key)
+		f, ok := findField(fields, key)
+		if !ok {
+			msg := fmt.Sprintf("unknown key %q", key)
+			if s := suggestKey(fields, key); s != "" {
+				msg += fmt.Sprintf(" (did you mean %q?)", s)
+			}
+			v.add(mv.Key, keyPath, msg, false)
+			continue
+		}
+		name := t.Name() + "." + f.Name
+		if d := fieldDocs[name]; d.Deprecated != "" {
+			v.add(mv.Key, keyPath, "deprecated: "+d.Deprecated, true)
+		}
+		if values, ok := fieldEnums[name]; ok {
+			v.checkEnum(mv.Value, keyPath, values)
+		}
+		v.check(mv.Value, f.Type, keyPath)
+	}
+}
+
+// checkEnum 校验字符串取值；空值和 ${ENV} / enc: 引用不检查
+func (v *validator) checkEnum(node ast.Node, path string, values []string) {
+	s, ok := unwrapNode(node).(*ast.StringNode)
+	if !ok {
+		return
+	}
+	value := strings.TrimSpace(s.Value)
+	if value == "" || strings.HasPrefix(value, "${") || strings.HasPrefix(value, "enc:") {
+		return
+	}
+	for _, allowed := range values {
+		if strings.EqualFold(value, allowed) {
+			return
+		}
+	}
+	v.add(s, path, fmt.Sprintf("invalid value %q, must be one of: %s", value, strings.Join(values, ", ")), false)
+}
+
+// isInteger 整数或小数部分为 0 的数（Save 经 JSON 中转，整数会写成 18790.0）
+func isInteger(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.IntegerNode:
+		return true
+	case *ast.FloatNode:
+		return n.Value == math.Trunc(n.Value)
+	}
+	return false
+}
+
+// unwrapNode 去掉锚点、标签等包装
+func unwrapNode(node ast.Node) ast.Node {
+	for {
+		switch n := node.(type) {
+		case *ast.AnchorNode:
+			node = n.Value
+		case *ast.TagNode:
+			node = n.Value
+		case *ast.CommentNode, *ast.CommentGroupNode:
+			return nil
+		default:
+			return node
+		}
+	}
+}
+
+// mappingValues 返回映射的键值对；单个键值对可能不包在 MappingNode 中
+func mappingValues(node ast.Node) ([]*ast.MappingValueNode, bool) {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		return n.Values, true
+	case *ast.MappingValueNode:
+		return []*ast.MappingValueNode{n}, true
+	}
+	return nil, false
+}
+
+func mapKey(mv *ast.MappingValueNode) string {
+	if tk := mv.Key.GetToken(); tk != nil {
+		return tk.Value
+	}
+	return mv.Key.String()
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func nodeKind(node ast.Node) string {
+	switch node.(type) {
+	case *ast.MappingNode, *ast.MappingValueNode:
+		return "a mapping"
+	case *ast.SequenceNode:
+		return "a list"
+	case *ast.StringNode, *ast.LiteralNode:
+		return "a string"
+	case *ast.IntegerNode:
+		return "an integer"
+	case *ast.FloatNode, *ast.InfinityNode, *ast.NanNode:
+		return "a number"
+	case *ast.BoolNode:
+		return "a boolean"
+	}
+	return node.Type().String()
+}
+
+// findField 按 json 键查找字段；encoding/json 解码时键名不区分大小写，这里保持一致
+func findField(fields []schemaField, key string) (schemaField, bool) {
+	for _, f := range fields {
+		if f.Key == key {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.Key, key) {
+			return f, true
+		}
+	}
+	return schemaField{}, false
+}
+
+// suggestKey 为拼错的键给出最接近的合法键：忽略 _ / - 后相同（snake_case 写法），或编辑距离不超过 2
+func suggestKey(fields []schemaField, key string) string {
+	norm := func(s string) string {
+		return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(s))
+	}
+	best, bestDist := "", 3
+	for _, f := range fields {
+		if norm(f.Key) == norm(key) {
+			return f.Key
+		}
+		if d := editDistance(strings.ToLower(f.Key), strings.ToLower(key)); d < bestDist {
+			best, bestDist = f.Key, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	s := Schema()
+	if s["$schema"] != SchemaURI || s["additionalProperties"] != false {
+		t.Fatalf("root schema = %v", s)
+	}
+	prop := func(path ...string) map[string]any {
+		cur := s
+		for _, p := range path {
+			cur = cur["properties"].(map[string]any)[p].(map[string]any)
+		}
+		return cur
+	}
+	level := prop("autonomy", "level")
+	if level["type"] != "string" || !strings.Contains(level["description"].(string), "自主级别") {
+		t.Fatalf("autonomy.level = %v", level)
+	}
+	if enum, _ := level["enum"].([]string); strings.Join(enum, ",") != "readonly,supervised,full" {
+		t.Fatalf("autonomy.level enum = %v", level["enum"])
+	}
+	if d := prop("log", "maxAgeDays")["description"]; d != "日志文件保留天数，默认 30" {
+		t.Fatalf("field name should be stripped from description: %q", d)
+	}
+	if p := prop("channels", "lark"); p["deprecated"] != true {
+		t.Fatalf("channels.lark should be deprecated: %v", p)
+	}
+	providers := prop("agent", "providers")
+	if providers["type"] != "object" || providers["additionalProperties"].(map[string]any)["type"] != "object" {
+		t.Fatalf("agent.providers = %v", providers)
+	}
+	if _, err := json.Marshal(s); err != nil {
+		t.Fatalf("schema not serializable: %v", err)
+	}
+}
+
+func TestValidateData(t *testing.T) {
+	data := `agent:
+  model: anthropic/claude-sonnet-4-5
+  workspce: /tmp/ws
+autonomy:
+  level: yolo
+session:
+  dm_scope: per-peer
+gateway:
+  port: "8080"
+channels:
+  lark:
+    appId: cli_x
+log:
+  level: ${LOG_LEVEL}
+`
+	issues, err := ValidateData([]byte(data), "config.yaml")
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	want := []string{
+		`3:3: error: agent.workspce: unknown key "workspce" (did you mean "workspace"?)`,
+		`5:10: error: autonomy.level: invalid value "yolo", must be one of: readonly, supervised, full`,
+		`7:3: error: session.dm_scope: unknown key "dm_scope" (did you mean "dmScope"?)`,
+		`9:9: error: gateway.port: expected an integer, got a string`,
+		`11:3: warning: channels.lark: deprecated: 使用 channels.feishu（字段相同）`,
+	}
+	var got []string
+	for _, i := range issues {
+		got = append(got, i.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("issues:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !HasErrors(issues) || HasErrors(issues[4:]) {
+		t.Fatalf("HasErrors should ignore warnings")
+	}
+
+	// 保存出的默认配置必须通过严格校验
+	out, err := marshalConfigYAML(Default(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues, err := ValidateData(out, "config.yaml"); err != nil || len(issues) > 0 {
+		t.Fatalf("default config: %v %v", issues, err)
+	}
+
+	// 旧版 JSON 配置（含注释）同样定位到行列
+	issues, err = ValidateData([]byte("{\n  // comment\n  \"memory\": {\"vectorWeight\": \"high\"}\n}\n"), "highclaw.json")
+	if err != nil || len(issues) != 1 || issues[0].Line != 3 || issues[0].Path != "memory.vectorWeight" {
+		t.Fatalf("json issues = %v, err %v", issues, err)
+	}
+}
+
+func TestValidateDLPActions(t *testing.T) {
+	for _, action := range dlpActions {
+		if !ValidDLPAction(action) {
+			t.Fatalf("ValidDLPAction(%q) = false", action)
+		}
+		data := "dlp:\n  action: " + action + "\n  toolOutput: " + action + "\n  channels:\n    feishu: " + action + "\n"
+		if issues, err := ValidateData([]byte(data), "config.yaml"); err != nil || len(issues) > 0 {
+			t.Fatalf("dlp action %q rejected: %v %v", action, issues, err)
+		}
+	}
+	issues, err := ValidateData([]byte("dlp:\n  toolOutput: hold\n"), "config.yaml")
+	if err != nil || len(issues) != 1 || !strings.Contains(issues[0].Message, "mask, block, approve, off") {
+		t.Fatalf("invalid toolOutput issues = %v, err %v", issues, err)
+	}
+}
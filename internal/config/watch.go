@@ -0,0 +1,108 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultWatchInterval 配置文件轮询间隔
+const DefaultWatchInterval = 2 * time.Second
+
+// Live 可热加载的运行中配置：读取方每次通过 Get 取当前配置（只读，不要修改），
+// 热加载时用 Store 整体替换为新对象，读取方不会看到更新到一半的配置
+type Live struct {
+	p atomic.Pointer[Config]
+}
+
+// NewLive 以 cfg 为初始配置创建 Live
+func NewLive(cfg *Config) *Live {
+	l := &Live{}
+	l.p.Store(cfg)
+	return l
+}
+
+// Get 返回当前配置
+func (l *Live) Get() *Config {
+	return l.p.Load()
+}
+
+// Store 替换当前配置
+func (l *Live) Store(cfg *Config) {
+	l.p.Store(cfg)
+}
+
+// ChangedSections 比较两份配置，返回取值不同的顶层配置段（json 名，按字母排序）
+func ChangedSections(old, next *Config) []string {
+	ov := reflect.ValueOf(old).Elem()
+	nv := reflect.ValueOf(next).Elem()
+	var changed []string
+	for _, f := range structFields(ov.Type()) {
+		a, errA := json.Marshal(ov.FieldByName(f.Name).Interface())
+		b, errB := json.Marshal(nv.FieldByName(f.Name).Interface())
+		if errA != nil || errB != nil || !bytes.Equal(a, b) {
+			changed = append(changed, f.Key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Watch 轮询配置文件（分层配置的各个文件），任一内容变化并稳定一个轮询周期后调用 onChange
+// （避免编辑器分步写入时读到半个文件）。文件暂时不可读时不触发回调；ctx 结束时返回。
+// paths 每次轮询时调用，热加载后 include / profile 引入的文件有增减时随之更新。
+func Watch(ctx context.Context, paths func() []string, interval time.Duration, onChange func()) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	last, _ := filesDigest(paths())
+	var pending [sha256.Size]byte
+	hasPending := false
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		sum, ok := filesDigest(paths())
+		switch {
+		case !ok || sum == last:
+			hasPending = false
+		case hasPending && sum == pending:
+			last = sum
+			hasPending = false
+			onChange()
+		default:
+			pending = sum
+			hasPending = true
+		}
+	}
+}
+
+// filesDigest 合并各文件内容的摘要；不存在的文件按空内容计（之后创建也能触发），其他读取错误返回 false
+func filesDigest(paths []string) ([sha256.Size]byte, bool) {
+	h := sha256.New()
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return [sha256.Size]byte{}, false
+		}
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+		h.Write(data)
+		h.Write([]byte{0})
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum, true
+}
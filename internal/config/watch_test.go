@@ -0,0 +1,75 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChangedSections(t *testing.T) {
+	old := Default()
+	next := Default()
+	if got := ChangedSections(old, next); len(got) != 0 {
+		t.Fatalf("identical configs reported changes: %v", got)
+	}
+	next.Log.Level = "debug"
+	next.Memory.VectorWeight = 0.5
+	next.Session.DMScope = "per-peer"
+	got := ChangedSections(old, next)
+	if want := []string{"log", "memory", "session"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("changed = %v; want %v", got, want)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte("log:\n  level: info\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan struct{}, 4)
+	var files atomic.Pointer[[]string]
+	files.Store(&[]string{path})
+	go Watch(ctx, func() []string { return *files.Load() }, 10*time.Millisecond, func() { changes <- struct{}{} })
+
+	time.Sleep(30 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("log:\n  level: debug\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("change not detected")
+	}
+	// 内容不变的重写不触发
+	if err := os.WriteFile(path, []byte("log:\n  level: debug\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+		t.Fatalf("unchanged rewrite should not trigger a reload")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// 加载后新增的文件（如 include 片段）随文件列表一起被监听
+	fragment := filepath.Join(dir, "shared.yaml")
+	files.Store(&[]string{path, fragment})
+	time.Sleep(50 * time.Millisecond)
+	for len(changes) > 0 {
+		<-changes
+	}
+	if err := os.WriteFile(fragment, []byte("log:\n  level: warn\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("change in a newly watched file not detected")
+	}
+}
@@ -0,0 +1,107 @@
+package model
+
+import "strings"
+
+// DefaultContextWindow 未知模型的上下文窗口（token）
+const DefaultContextWindow = 128000
+
+// contextWindowFamilies 目录里找不到时按模型族前缀兜底
+var contextWindowFamilies = []struct {
+	prefix string
+	tokens int
+}{
+	{"claude", 200000},
+	{"gemini", 1000000},
+	{"gpt-5", 200000},
+	{"gpt-4.1", 1000000},
+	{"gpt-4o", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4", 8192},
+	{"gpt-3.5", 16385},
+	{"o1", 200000},
+	{"o3", 200000},
+	{"o4", 200000},
+	{"deepseek", 64000},
+	{"llama", 128000},
+	{"qwen", 128000},
+	{"mistral", 32000},
+	{"glm", 128000},
+}
+
+// LookupModel 按 "provider/model" 或裸模型 ID 在模型目录中查找。
+// 带日期后缀等变体（如 claude-sonnet-4-20250514）会匹配到最长的目录 ID 前缀。
+func LookupModel(ref string) (Model, bool) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return Model{}, false
+	}
+	provider, id := "", ref
+	if i := strings.Index(ref, "/"); i > 0 {
+		provider, id = strings.ToLower(ref[:i]), ref[i+1:]
+	}
+	lowerRef := strings.ToLower(ref)
+	lowerID := strings.ToLower(id)
+
+	catalog := append(GetAllModelsComplete(), GetAllModels()...)
+	var best Model
+	bestLen := 0
+	for _, m := range catalog {
+		mid := strings.ToLower(m.ID)
+		switch {
+		case mid == lowerRef, mid == lowerID && (provider == "" || strings.EqualFold(m.Provider, provider)):
+			return m, true
+		case mid == lowerID && bestLen < len(mid)+1:
+			// 同名模型但 provider 不同（如 openrouter 转发），仍然可用于窗口估算
+			best, bestLen = m, len(mid)+1
+		case strings.HasPrefix(lowerID, mid+"-") && len(mid) > bestLen:
+			best, bestLen = m, len(mid)
+		}
+	}
+	return best, bestLen > 0
+}
+
+// ContextWindow 返回模型的上下文窗口大小（token），未知模型返回 DefaultContextWindow
+func ContextWindow(ref string) int {
+	if m, ok := LookupModel(ref); ok && m.MaxTokens > 0 {
+		return m.MaxTokens
+	}
+	id := strings.ToLower(strings.TrimSpace(ref))
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		id = id[i+1:]
+	}
+	for _, f := range contextWindowFamilies {
+		if strings.HasPrefix(id, f.prefix) {
+			return f.tokens
+		}
+	}
+	return DefaultContextWindow
+}
+
+// visionFamilies 目录里找不到时，按模型族前缀判断是否支持图片输入
+var visionFamilies = []string{
+	"claude-3", "claude-opus", "claude-sonnet", "claude-haiku",
+	"gpt-4o", "gpt-4.1", "gpt-4-turbo", "gpt-5", "o3", "o4",
+	"gemini", "grok-4", "pixtral", "llava", "qwen-vl", "qwen2-vl", "qwen2.5-vl", "glm-4v",
+}
+
+// SupportsVision 模型是否接受图片输入：目录中的模型看 Capabilities，其余按模型族判断
+func SupportsVision(ref string) bool {
+	if m, ok := LookupModel(ref); ok {
+		for _, c := range m.Capabilities {
+			if c == "vision" {
+				return true
+			}
+		}
+		return false
+	}
+	id := strings.ToLower(strings.TrimSpace(ref))
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		id = id[i+1:]
+	}
+	for _, prefix := range visionFamilies {
+		if strings.HasPrefix(id, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package model
+
+import "sync"
+
+// 从本地模型服务（Ollama / llama.cpp）发现的模型，运行时登记到模型目录
+var (
+	localMu     sync.RWMutex
+	localModels = map[string][]Model{}
+)
+
+// RegisterLocalModels 登记 provider 上发现的模型（整体替换该 provider 之前的登记）
+func RegisterLocalModels(provider string, models []Model) {
+	localMu.Lock()
+	defer localMu.Unlock()
+	if len(models) == 0 {
+		delete(localModels, provider)
+		return
+	}
+	localModels[provider] = append([]Model(nil), models...)
+}
+
+// LocalModels 返回已登记的本地模型
+func LocalModels() []Model {
+	localMu.RLock()
+	defer localMu.RUnlock()
+	var all []Model
+	for _, ms := range localModels {
+		all = append(all, ms...)
+	}
+	return all
+}
+
+func localModelsFor(provider string) []Model {
+	localMu.RLock()
+	defer localMu.RUnlock()
+	return append([]Model(nil), localModels[provider]...)
+}
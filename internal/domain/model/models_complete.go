@@ -190,6 +190,7 @@ func GetAllModelsComplete() []Model {
 	all = append(all, ZAIModelsComplete...)
 	all = append(all, OpenCodeModelsComplete...)
 	all = append(all, SyntheticModelsComplete...)
+	all = append(all, LocalModels()...)
 	return all
 }
 
@@ -230,6 +231,8 @@ func GetModelsByProvider(provider string) []Model {
 		return OpenCodeModelsComplete
 	case "synthetic":
 		return SyntheticModelsComplete
+	case "ollama", "llamacpp":
+		return localModelsFor(provider)
 	default:
 		return []Model{}
 	}
@@ -0,0 +1,114 @@
+package model
+
+import "strings"
+
+// Pricing 模型单价，单位：美元 / 百万 token
+type Pricing struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheRead  float64 `json:"cacheRead"`
+	CacheWrite float64 `json:"cacheWrite"`
+}
+
+// Cost 按单价计算一次调用的费用（美元）
+func (p Pricing) Cost(input, output, cacheRead, cacheWrite int) float64 {
+	return (float64(input)*p.Input +
+		float64(output)*p.Output +
+		float64(cacheRead)*p.CacheRead +
+		float64(cacheWrite)*p.CacheWrite) / 1e6
+}
+
+// localProviders 本地推理，费用按 0 计
+var localProviders = map[string]bool{"ollama": true, "llamacpp": true, "llama.cpp": true, "lmstudio": true}
+
+// bedrockVendors Bedrock 模型 ID 的厂商前缀
+var bedrockVendors = map[string]bool{"anthropic": true, "meta": true, "mistral": true, "cohere": true, "amazon": true}
+
+// modelPrices 官方公开价格，按模型 ID 前缀匹配（最长前缀优先），
+// 带日期或版本后缀的变体（如 claude-sonnet-4-20250514）归到同一族
+var modelPrices = []struct {
+	prefix string
+	price  Pricing
+}{
+	// Anthropic
+	{"claude-opus-4-6", Pricing{5, 25, 0.5, 6.25}},
+	{"claude-opus-4-5", Pricing{5, 25, 0.5, 6.25}},
+	{"claude-opus-4", Pricing{15, 75, 1.5, 18.75}},
+	{"claude-sonnet-4", Pricing{3, 15, 0.3, 3.75}},
+	{"claude-haiku-4", Pricing{1, 5, 0.1, 1.25}},
+	{"claude-3-7-sonnet", Pricing{3, 15, 0.3, 3.75}},
+	{"claude-3-5-sonnet", Pricing{3, 15, 0.3, 3.75}},
+	{"claude-sonnet-3-5", Pricing{3, 15, 0.3, 3.75}},
+	{"claude-3-5-haiku", Pricing{0.8, 4, 0.08, 1}},
+	{"claude-haiku-3-5", Pricing{0.8, 4, 0.08, 1}},
+	{"claude-3-opus", Pricing{15, 75, 1.5, 18.75}},
+	{"claude-3-sonnet", Pricing{3, 15, 0.3, 3.75}},
+	{"claude-3-haiku", Pricing{0.25, 1.25, 0.03, 0.3}},
+
+	// OpenAI（cache write 不单独计费）
+	{"gpt-5", Pricing{1.25, 10, 0.125, 0}},
+	{"gpt-4.1-nano", Pricing{0.1, 0.4, 0.025, 0}},
+	{"gpt-4.1-mini", Pricing{0.4, 1.6, 0.1, 0}},
+	{"gpt-4.1", Pricing{2, 8, 0.5, 0}},
+	{"gpt-4o-mini", Pricing{0.15, 0.6, 0.075, 0}},
+	{"gpt-4o", Pricing{2.5, 10, 1.25, 0}},
+	{"gpt-4-turbo", Pricing{10, 30, 0, 0}},
+	{"gpt-4", Pricing{30, 60, 0, 0}},
+	{"gpt-35-turbo", Pricing{0.5, 1.5, 0, 0}},
+	{"gpt-3.5-turbo", Pricing{0.5, 1.5, 0, 0}},
+	{"o1-mini", Pricing{1.1, 4.4, 0.55, 0}},
+	{"o1", Pricing{15, 60, 7.5, 0}},
+	{"o3-mini", Pricing{1.1, 4.4, 0.55, 0}},
+
+	// Google
+	{"gemini-2.0-flash", Pricing{0.1, 0.4, 0.025, 0}},
+	{"gemini-1.5-pro", Pricing{1.25, 5, 0.3125, 0}},
+	{"gemini-1.5-flash", Pricing{0.075, 0.3, 0.01875, 0}},
+	{"gemini-pro", Pricing{0.5, 1.5, 0, 0}},
+
+	// 其他
+	{"deepseek-chat", Pricing{0.27, 1.1, 0.07, 0}},
+	{"deepseek-reasoner", Pricing{0.55, 2.19, 0.14, 0}},
+	{"mistral-large", Pricing{2, 6, 0, 0}},
+	{"mistral-medium", Pricing{0.4, 2, 0, 0}},
+	{"llama-3.3-70b-versatile", Pricing{0.59, 0.79, 0, 0}},
+	{"mixtral-8x7b", Pricing{0.24, 0.24, 0, 0}},
+	{"command-r-plus", Pricing{2.5, 10, 0, 0}},
+	{"command-r", Pricing{0.15, 0.6, 0, 0}},
+}
+
+// LookupPricing 按 "provider/model" 或裸模型 ID 查找单价。
+// 转发型 provider（openrouter/vendor/model）和 Bedrock ID（anthropic.claude-...）按模型本身计价；
+// 本地 provider 返回零价格。
+func LookupPricing(ref string) (Pricing, bool) {
+	ref = strings.ToLower(strings.TrimSpace(ref))
+	if ref == "" {
+		return Pricing{}, false
+	}
+	if i := strings.Index(ref, "/"); i > 0 && localProviders[ref[:i]] {
+		return Pricing{}, true
+	}
+	id := ref
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		id = id[i+1:]
+	}
+	// Bedrock: anthropic.claude-sonnet-4-5-20250929-v1:0
+	if vendor, rest, ok := strings.Cut(id, "."); ok && bedrockVendors[vendor] {
+		id = rest
+	}
+	best, bestLen := Pricing{}, 0
+	for _, p := range modelPrices {
+		if strings.HasPrefix(id, p.prefix) && len(p.prefix) > bestLen {
+			best, bestLen = p.price, len(p.prefix)
+		}
+	}
+	return best, bestLen > 0
+}
+
+// Pricing 返回目录中模型的单价
+func (m Model) Pricing() (Pricing, bool) {
+	if localProviders[strings.ToLower(m.Provider)] {
+		return Pricing{}, true
+	}
+	return LookupPricing(m.ID)
+}
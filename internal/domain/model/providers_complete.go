@@ -38,6 +38,10 @@ var AllProviders = []string{
 	"glm",
 	"xiaomi",
 	"litellm",
+
+	// Local model servers (models discovered from the server)
+	"ollama",
+	"llamacpp",
 }
 
 // ProviderCategory represents the category of a provider.
@@ -280,5 +284,23 @@ func GetProviderInfo() map[string]ProviderInfo {
 			Description: "LiteLLM unified gateway",
 			DocsURL:     "https://docs.openclaw.ai/providers/litellm",
 		},
+		"ollama": {
+			ID:          "ollama",
+			Name:        "Ollama",
+			Category:    CategoryLocal,
+			AuthType:    "none",
+			EnvVar:      "",
+			Description: "Local models via Ollama (models discovered from /api/tags)",
+			DocsURL:     "https://github.com/ollama/ollama/blob/main/docs/api.md",
+		},
+		"llamacpp": {
+			ID:          "llamacpp",
+			Name:        "llama.cpp",
+			Category:    CategoryLocal,
+			AuthType:    "none",
+			EnvVar:      "",
+			Description: "Local models via llama.cpp server",
+			DocsURL:     "https://github.com/ggml-org/llama.cpp/tree/master/tools/server",
+		},
 	}
 }
@@ -35,6 +35,9 @@ type Server struct {
 	mu       sync.RWMutex
 	started  time.Time
 
+	// onSessionReset 会话重置或删除时调用（结束会话的后台进程等）
+	onSessionReset func(sessionKey string)
+
 	// Shutdown coordination.
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -85,6 +88,11 @@ func NewServer(cfg *config.Config, logger *slog.Logger) (*Server, error) {
 	return s, nil
 }
 
+// SetSessionReset sets the callback run when a session is reset or deleted.
+func (s *Server) SetSessionReset(fn func(sessionKey string)) {
+	s.onSessionReset = fn
+}
+
 // Start begins listening for incoming connections.
 func (s *Server) Start() error {
 	host := "127.0.0.1"
@@ -440,7 +448,7 @@ func (s *Server) methodChatSend(client *Client, req *protocol.RPCRequest) (any,
 }
 
 func (s *Server) methodConfigGet(client *Client, req *protocol.RPCRequest) (any, error) {
-	return s.cfg, nil
+	return s.cfg.Redacted(), nil
 }
 
 func (s *Server) methodSessionsCreate(client *Client, req *protocol.RPCRequest) (any, error) {
@@ -465,6 +473,9 @@ func (s *Server) methodSessionsDelete(client *Client, req *protocol.RPCRequest)
 	}
 
 	s.sessions.Delete(params.Key)
+	if s.onSessionReset != nil {
+		s.onSessionReset(params.Key)
+	}
 	return map[string]any{"ok": true}, nil
 }
 
@@ -482,6 +493,9 @@ func (s *Server) methodSessionsReset(client *Client, req *protocol.RPCRequest) (
 	}
 
 	sess.Reset()
+	if s.onSessionReset != nil {
+		s.onSessionReset(params.Key)
+	}
 	return map[string]any{"ok": true}, nil
 }
 
@@ -548,7 +562,7 @@ func (s *Server) methodConfigPatch(client *Client, req *protocol.RPCRequest) (an
 	if err := config.Save(s.cfg); err != nil {
 		return nil, fmt.Errorf("save config: %w", err)
 	}
-	return map[string]any{"ok": true, "config": s.cfg}, nil
+	return map[string]any{"ok": true, "config": s.cfg.Redacted()}, nil
 }
 
 func (s *Server) methodChannelsStatus(client *Client, req *protocol.RPCRequest) (any, error) {
@@ -561,14 +575,25 @@ func (s *Server) methodChannelsStatus(client *Client, req *protocol.RPCRequest)
 }
 
 func (s *Server) methodAgentsList(client *Client, req *protocol.RPCRequest) (any, error) {
-	return []map[string]any{
-		{
-			"id":        "main",
-			"name":      "Main Agent",
-			"model":     s.cfg.Agent.Model,
-			"workspace": s.cfg.Agent.Workspace,
-		},
-	}, nil
+	ids := s.cfg.AgentIDs()
+	out := make([]map[string]any, 0, len(ids))
+	for _, id := range ids {
+		acfg := s.cfg.ForAgent(id)
+		profile, _ := s.cfg.AgentProfileFor(id)
+		name := profile.Name
+		if name == "" && id == config.MainAgentID {
+			name = "Main Agent"
+		}
+		out = append(out, map[string]any{
+			"id":        id,
+			"name":      name,
+			"model":     acfg.Agent.Model,
+			"workspace": acfg.Agent.Workspace,
+			"autonomy":  acfg.Autonomy.Level,
+			"tools":     profile.Tools,
+		})
+	}
+	return out, nil
 }
 
 func (s *Server) methodModelsList(client *Client, req *protocol.RPCRequest) (any, error) {
@@ -0,0 +1,123 @@
+package session
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/highclaw/highclaw/internal/gateway/protocol"
+)
+
+// Rename 设置会话名称并写回磁盘；label 为空时恢复默认显示
+func Rename(key, label string) error {
+	sess, err := Load(key)
+	if err != nil {
+		return err
+	}
+	sess.Label = strings.TrimSpace(label)
+	return sess.Save()
+}
+
+// SetModelOverride 设置仅对该会话生效的模型；model 为空时恢复默认模型
+func SetModelOverride(key, model string) error {
+	sess, err := Load(key)
+	if err != nil {
+		return err
+	}
+	sess.ModelOverride = strings.TrimSpace(model)
+	return sess.Save()
+}
+
+// Branch 从 key 会话的前 n 条消息分叉出新会话 newKey（n <= 0 或超出时复制全部消息），
+// 新会话继承 agent、渠道和模型设置
+func Branch(key, newKey string, n int) (*Session, error) {
+	src, err := Load(key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := Load(newKey); err == nil {
+		return nil, fmt.Errorf("session %s already exists", newKey)
+	}
+	msgs := src.Messages()
+	if n <= 0 || n > len(msgs) {
+		n = len(msgs)
+	}
+	now := time.Now()
+	dst := &Session{
+		Key:            newKey,
+		Channel:        src.Channel,
+		AgentID:        src.AgentID,
+		Model:          src.Model,
+		ModelOverride:  src.ModelOverride,
+		Label:          src.DisplayName() + " (branch)",
+		CreatedAt:      now,
+		LastActivityAt: now,
+		messages:       append([]protocol.ChatMessage(nil), msgs[:n]...),
+		MessageCount:   n,
+	}
+	if err := dst.Save(); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// DisplayName 会话显示名：优先使用 Label，否则取 key 的最后一段
+func (s *Session) DisplayName() string {
+	if label := strings.TrimSpace(s.Label); label != "" {
+		return label
+	}
+	parts := strings.Split(s.Key, ":")
+	return parts[len(parts)-1]
+}
+
+// SearchHit 会话历史中的一条匹配消息
+type SearchHit struct {
+	Key       string
+	Label     string
+	Index     int // 消息序号（从 1 开始），可直接用于 Branch
+	Role      string
+	Content   string
+	Timestamp int64
+}
+
+// Search 在全部已保存会话的历史中做全文搜索：query 中的每个词都出现（忽略大小写）即匹配，
+// 结果按时间倒序，最多 limit 条（<= 0 不限制）
+func Search(query string, limit int) ([]SearchHit, error) {
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("search query is empty")
+	}
+	sessions, err := LoadAll()
+	if err != nil {
+		return nil, err
+	}
+	var hits []SearchHit
+	for _, sess := range sessions {
+		for i, msg := range sess.History {
+			content := strings.ToLower(msg.Content)
+			matched := true
+			for _, t := range terms {
+				if !strings.Contains(content, t) {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				hits = append(hits, SearchHit{
+					Key:       sess.Key,
+					Label:     sess.DisplayName(),
+					Index:     i + 1,
+					Role:      msg.Role,
+					Content:   msg.Content,
+					Timestamp: msg.Timestamp,
+				})
+			}
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Timestamp > hits[j].Timestamp })
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
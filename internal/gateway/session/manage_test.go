@@ -0,0 +1,70 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/highclaw/highclaw/internal/gateway/protocol"
+)
+
+// TestRenameBranchSearch 测试改名、模型覆盖、分叉和全文搜索
+func TestRenameBranchSearch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	key := "agent:main:tui-1"
+	history := []protocol.ChatMessage{
+		{Role: "user", Content: "Deploy the staging cluster"},
+		{Role: "assistant", Content: "Staging deploy started"},
+		{Role: "user", Content: "Now check the logs"},
+	}
+	if err := SaveFromHistory(key, "tui", "main", "openai/gpt-4o", history); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := Rename(key, "deploys"); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if err := SetModelOverride(key, "anthropic/claude-sonnet-4"); err != nil {
+		t.Fatalf("set model: %v", err)
+	}
+	// 后续保存历史不应丢失名称和模型覆盖
+	if err := SaveFromHistory(key, "tui", "main", "openai/gpt-4o", history); err != nil {
+		t.Fatalf("resave: %v", err)
+	}
+	sess, err := Load(key)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if sess.DisplayName() != "deploys" || sess.ModelOverride != "anthropic/claude-sonnet-4" {
+		t.Fatalf("label/model not preserved: %q %q", sess.Label, sess.ModelOverride)
+	}
+
+	branched, err := Branch(key, "agent:main:tui-2", 2)
+	if err != nil {
+		t.Fatalf("branch: %v", err)
+	}
+	if got := len(branched.Messages()); got != 2 {
+		t.Fatalf("branch messages = %d; want 2", got)
+	}
+	if branched.Label != "deploys (branch)" || branched.ModelOverride != "anthropic/claude-sonnet-4" {
+		t.Fatalf("branch settings = %q %q", branched.Label, branched.ModelOverride)
+	}
+	if _, err := Branch(key, "agent:main:tui-2", 1); err == nil {
+		t.Fatalf("branch onto existing key should fail")
+	}
+
+	hits, err := Search("STAGING deploy", 0)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	// 原会话 2 条 + 分叉会话 2 条
+	if len(hits) != 4 {
+		t.Fatalf("hits = %d; want 4", len(hits))
+	}
+	for i := 1; i < len(hits); i++ {
+		if hits[i].Timestamp > hits[i-1].Timestamp {
+			t.Fatalf("hits not sorted newest first: %+v", hits)
+		}
+	}
+	if hits, _ := Search("logs", 0); len(hits) != 1 || hits[0].Key != key {
+		t.Fatalf("logs hits = %+v", hits)
+	}
+}
@@ -170,7 +170,51 @@ func ResolveSessionFromConfig(cfg *config.Config, peer PeerContext) string {
 		}
 	}
 
-	return BuildPeerSessionKey(DefaultAgentID, mainKey, peer, dmScope, sc.IdentityLinks)
+	return BuildPeerSessionKey(ResolveAgentID(cfg, peer), mainKey, peer, dmScope, sc.IdentityLinks)
+}
+
+// ResolveAgentID 按 cfg.Agent.Bindings 为入站消息选择 agent。
+// 绑定的所有非空字段都必须匹配，匹配字段最多的规则胜出；无匹配或目标 agent 未配置时回到 DefaultAgentID。
+func ResolveAgentID(cfg *config.Config, peer PeerContext) string {
+	if cfg == nil {
+		return DefaultAgentID
+	}
+	peerKind := strings.ToLower(strings.TrimSpace(peer.PeerKind))
+	if peerKind == "" {
+		peerKind = "direct"
+	}
+	best := DefaultAgentID
+	bestScore := -1
+	for _, b := range cfg.Agent.Bindings {
+		agentID := NormalizeID(b.Agent)
+		if agentID == "" || !cfg.HasAgent(agentID) {
+			continue
+		}
+		score := 0
+		matched := true
+		for _, f := range []struct{ want, got string }{
+			{b.Channel, peer.Channel},
+			{b.AccountID, peer.AccountID},
+			{b.PeerKind, peerKind},
+			{b.PeerID, peer.PeerID},
+			{b.GroupID, peer.GroupID},
+		} {
+			want := strings.TrimSpace(f.want)
+			if want == "" {
+				continue
+			}
+			if !strings.EqualFold(want, strings.TrimSpace(f.got)) {
+				matched = false
+				break
+			}
+			score++
+		}
+		if matched && score > bestScore {
+			best = agentID
+			bestScore = score
+		}
+	}
+	return best
 }
 
 // ResolveSession 保持向后兼容的旧接口（无 peer 信息时回退到绑定查询 + 默认会话）
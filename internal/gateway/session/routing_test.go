@@ -167,3 +167,45 @@ func TestIdentityLinks(t *testing.T) {
 		t.Errorf("IdentityLinks merge failed: got %q; want %q", got, want)
 	}
 }
+
+// TestResolveAgentID 测试渠道绑定 → agent 路由
+func TestResolveAgentID(t *testing.T) {
+	cfg := &config.Config{
+		Session: config.SessionConfig{DMScope: DMScopePerChannelPeer},
+		Agent: config.AgentConfig{
+			Profiles: map[string]config.AgentProfile{
+				"coder": {Name: "Coder"},
+				"ops":   {Name: "Ops"},
+			},
+			Bindings: []config.AgentBinding{
+				{Agent: "coder", Channel: "feishu"},
+				{Agent: "ops", Channel: "feishu", PeerKind: "group", GroupID: "oc_ops"},
+				{Agent: "ghost", Channel: "telegram"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		peer PeerContext
+		want string
+	}{
+		{"channel_binding", PeerContext{Channel: "feishu", PeerID: "u1", PeerKind: "direct"}, "coder"},
+		{"more_specific_wins", PeerContext{Channel: "feishu", PeerKind: "group", GroupID: "oc_ops"}, "ops"},
+		{"other_group_falls_back_to_channel", PeerContext{Channel: "feishu", PeerKind: "group", GroupID: "oc_dev"}, "coder"},
+		{"unknown_agent_ignored", PeerContext{Channel: "telegram", PeerID: "u1"}, DefaultAgentID},
+		{"no_binding", PeerContext{Channel: "discord", PeerID: "u1"}, DefaultAgentID},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ResolveAgentID(cfg, tc.peer); got != tc.want {
+				t.Errorf("ResolveAgentID() = %q; want %q", got, tc.want)
+			}
+		})
+	}
+
+	got := ResolveSessionFromConfig(cfg, PeerContext{Channel: "feishu", PeerKind: "group", GroupID: "oc_ops"})
+	if want := "agent:ops:feishu:group:oc_ops"; got != want {
+		t.Errorf("ResolveSessionFromConfig() = %q; want %q", got, want)
+	}
+}
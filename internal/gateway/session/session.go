@@ -24,6 +24,11 @@ type Session struct {
 	// "mention" = only respond when mentioned; "always" = respond to all.
 	GroupActivation string `json:"groupActivation,omitempty"`
 
+	// Label 用户设置的会话名称（TUI /rename），为空时显示 key 的最后一段
+	Label string `json:"label,omitempty"`
+	// ModelOverride 仅对该会话生效的模型（TUI /model），为空时使用默认模型
+	ModelOverride string `json:"modelOverride,omitempty"`
+
 	// History 存储消息历史，用于持久化
 	History []protocol.ChatMessage `json:"history,omitempty"`
 
@@ -70,8 +70,12 @@ func Current() (string, error) {
 func SaveFromHistory(sessionKey, channel, agentID, model string, history []protocol.ChatMessage) error {
 	now := time.Now()
 	createdAt := now
-	if old, err := Load(sessionKey); err == nil && !old.CreatedAt.IsZero() {
-		createdAt = old.CreatedAt
+	var label, modelOverride string
+	if old, err := Load(sessionKey); err == nil {
+		if !old.CreatedAt.IsZero() {
+			createdAt = old.CreatedAt
+		}
+		label, modelOverride = old.Label, old.ModelOverride
 	}
 
 	sess := &Session{
@@ -81,6 +85,8 @@ func SaveFromHistory(sessionKey, channel, agentID, model string, history []proto
 		Model:          strings.TrimSpace(model),
 		CreatedAt:      createdAt,
 		LastActivityAt: now,
+		Label:          label,
+		ModelOverride:  modelOverride,
 	}
 	if sess.Channel == "" {
 		sess.Channel = "cli"
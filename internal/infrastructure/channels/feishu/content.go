@@ -0,0 +1,110 @@
+package feishu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+// maxImageDownload 单张入站图片的下载上限，超过的图片丢弃
+const maxImageDownload = 20 << 20
+
+// imagePlaceholder 只有图片没有文字时的消息文本，保证会话历史里有内容
+const imagePlaceholder = "[image]"
+
+// parseMessageContent 解析消息 content：text 取文本，image 取 image_key，post（富文本）两者都取。
+// 其他类型返回 ok=false。
+func parseMessageContent(msgType, content string) (text string, imageKeys []string, ok bool) {
+	switch msgType {
+	case "text":
+		var c struct {
+			Text string `json:"text"`
+		}
+		_ = json.Unmarshal([]byte(content), &c)
+		return strings.TrimSpace(c.Text), nil, true
+	case "image":
+		var c struct {
+			ImageKey string `json:"image_key"`
+		}
+		_ = json.Unmarshal([]byte(content), &c)
+		if c.ImageKey == "" {
+			return "", nil, true
+		}
+		return "", []string{c.ImageKey}, true
+	case "post":
+		var c struct {
+			Title   string `json:"title"`
+			Content [][]struct {
+				Tag      string `json:"tag"`
+				Text     string `json:"text"`
+				ImageKey string `json:"image_key"`
+			} `json:"content"`
+		}
+		_ = json.Unmarshal([]byte(content), &c)
+		var lines []string
+		if t := strings.TrimSpace(c.Title); t != "" {
+			lines = append(lines, t)
+		}
+		for _, para := range c.Content {
+			var sb strings.Builder
+			for _, el := range para {
+				switch el.Tag {
+				case "text", "a":
+					sb.WriteString(el.Text)
+				case "img":
+					if el.ImageKey != "" {
+						imageKeys = append(imageKeys, el.ImageKey)
+					}
+				}
+			}
+			if line := strings.TrimSpace(sb.String()); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		return strings.Join(lines, "\n"), imageKeys, true
+	}
+	return "", nil, false
+}
+
+// downloadImages 下载消息中的图片；单张失败只记日志，不影响其他图片和文字
+func (f *FeishuChannel) downloadImages(ctx context.Context, messageID string, keys []string) [][]byte {
+	var images [][]byte
+	for _, key := range keys {
+		data, err := f.downloadImage(ctx, messageID, key)
+		if err != nil {
+			f.logger.Warn("feishu: image download failed", "messageId", messageID, "error", err)
+			continue
+		}
+		images = append(images, data)
+	}
+	return images
+}
+
+func (f *FeishuChannel) downloadImage(ctx context.Context, messageID, key string) ([]byte, error) {
+	resp, err := f.apiClient.Im.MessageResource.Get(ctx, larkim.NewGetMessageResourceReqBuilder().
+		MessageId(messageID).
+		FileKey(key).
+		Type("image").
+		Build())
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success() {
+		return nil, fmt.Errorf("code=%d msg=%s", resp.Code, resp.Msg)
+	}
+	if resp.File == nil {
+		return nil, fmt.Errorf("empty image body")
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.File, maxImageDownload+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImageDownload {
+		return nil, fmt.Errorf("image exceeds %d bytes", maxImageDownload)
+	}
+	return data, nil
+}
@@ -39,6 +39,8 @@ type ParsedMessage struct {
 	ChatType  string // "p2p" | "group"
 	SenderID  string // open_id
 	Text      string
+	// Images 消息中的图片（image 消息或富文本中的图片），原始字节
+	Images [][]byte
 }
 
 // MessageHandler 消息处理回调：收到消息后调用，返回 AI 回复文本
@@ -262,25 +264,14 @@ func (f *FeishuChannel) handleMessageEvent(ctx context.Context, event *larkim.P2
 	msg := event.Event.Message
 	sender := event.Event.Sender
 
-	// 只处理文本消息
-	if msg.MessageType == nil || *msg.MessageType != "text" {
-		msgType := "nil"
-		if msg.MessageType != nil {
-			msgType = *msg.MessageType
-		}
-		f.logger.Debug("feishu: non-text message ignored", "type", msgType)
+	// 处理文本、图片和富文本消息
+	msgType, rawContent := ptrStr(msg.MessageType), ptrStr(msg.Content)
+	text, imageKeys, ok := parseMessageContent(msgType, rawContent)
+	if !ok {
+		f.logger.Debug("feishu: unsupported message ignored", "type", msgType)
 		return nil
 	}
-
-	// 解析文本内容（飞书 content 是 JSON: {"text":"xxx"}）
-	var content struct {
-		Text string `json:"text"`
-	}
-	if msg.Content != nil {
-		_ = json.Unmarshal([]byte(*msg.Content), &content)
-	}
-	text := strings.TrimSpace(content.Text)
-	if text == "" {
+	if text == "" && len(imageKeys) == 0 {
 		return nil
 	}
 
@@ -350,6 +341,9 @@ func (f *FeishuChannel) handleMessageEvent(ctx context.Context, event *larkim.P2
 	f.mu.RUnlock()
 
 	if !bound {
+		if text == "" {
+			return nil
+		}
 		f.logger.Info("feishu: unbound, entering bind flow",
 			"messageId", messageID,
 			"sender", maskID(senderID))
@@ -383,6 +377,9 @@ func (f *FeishuChannel) handleMessageEvent(ctx context.Context, event *larkim.P2
 		return nil
 	}
 
+	if text == "" {
+		text = imagePlaceholder
+	}
 	parsed := &ParsedMessage{
 		MessageID: messageID,
 		ChatID:    chatID,
@@ -412,6 +409,11 @@ func (f *FeishuChannel) handleMessageEvent(ctx context.Context, event *larkim.P2
 			thinkingMsgID = *thinkingResp.Data.MessageId
 		}
 
+		// 图片在后台下载，不阻塞 SDK 事件循环
+		if len(imageKeys) > 0 {
+			parsed.Images = f.downloadImages(bgCtx, messageID, imageKeys)
+		}
+
 		reply, err := f.onMessage(bgCtx, parsed)
 		if err != nil {
 			f.logger.Error("feishu message handler error", "error", err)
@@ -517,6 +519,41 @@ func (f *FeishuChannel) ReplyMessage(ctx context.Context, messageID, text string
 	return f.replyText(ctx, messageID, text)
 }
 
+// SendText 主动发送文本消息（非回复）。receiveID 按前缀识别类型：
+// oc_ 群聊、ou_ open_id、on_ union_id、含 @ 为邮箱，其余按 user_id 处理。
+func (f *FeishuChannel) SendText(ctx context.Context, receiveID, text string) error {
+	if f.apiClient == nil {
+		return fmt.Errorf("api client not initialized")
+	}
+	idType := larkim.ReceiveIdTypeUserId
+	switch {
+	case strings.HasPrefix(receiveID, "oc_"):
+		idType = larkim.ReceiveIdTypeChatId
+	case strings.HasPrefix(receiveID, "ou_"):
+		idType = larkim.ReceiveIdTypeOpenId
+	case strings.HasPrefix(receiveID, "on_"):
+		idType = larkim.ReceiveIdTypeUnionId
+	case strings.Contains(receiveID, "@"):
+		idType = larkim.ReceiveIdTypeEmail
+	}
+	contentJSON, _ := json.Marshal(map[string]string{"text": text})
+	resp, err := f.apiClient.Im.Message.Create(ctx, larkim.NewCreateMessageReqBuilder().
+		ReceiveIdType(idType).
+		Body(larkim.NewCreateMessageReqBodyBuilder().
+			ReceiveId(receiveID).
+			MsgType("text").
+			Content(string(contentJSON)).
+			Build()).
+		Build())
+	if err != nil {
+		return fmt.Errorf("feishu send API call: %w", err)
+	}
+	if !resp.Success() {
+		return fmt.Errorf("feishu send error: code=%d msg=%s", resp.Code, resp.Msg)
+	}
+	return nil
+}
+
 // patchMessage 更新已发送的消息内容（用于替换"思考中"占位消息）
 func (f *FeishuChannel) patchMessage(ctx context.Context, messageID, text string) error {
 	if f.apiClient == nil {
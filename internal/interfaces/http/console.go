@@ -0,0 +1,339 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/highclaw/highclaw/internal/agent"
+	"github.com/highclaw/highclaw/internal/config"
+	"github.com/highclaw/highclaw/internal/interfaces/web"
+	"github.com/highclaw/highclaw/internal/system/tasklog"
+)
+
+// Web 控制台：内嵌单页应用 + 登录（web.auth，cookie 会话）+ /ws RPC（见 console_ws.go）。
+// web.auth.enabled=false 时不需要登录，但只允许本机（及 allowTailscale 时的 tailnet）访问。
+
+const (
+	consoleCookie = "highclaw_console"
+	// defaultWebPassword 默认配置中的密码，不允许用于登录，需先修改
+	defaultWebPassword = "admin"
+	// 同一 IP 登录失败超过 consoleMaxFailures 次后锁定 consoleLockout
+	consoleMaxFailures = 5
+	consoleLockout     = 5 * time.Minute
+)
+
+// ConsoleChatResult 控制台一轮对话的结果
+type ConsoleChatResult struct {
+	SessionKey   string `json:"sessionKey"`
+	Reply        string `json:"reply"`
+	Model        string `json:"model,omitempty"`
+	RunID        string `json:"runId,omitempty"`
+	InputTokens  int    `json:"inputTokens"`
+	OutputTokens int    `json:"outputTokens"`
+}
+
+// ConsoleChatFunc 由 gateway 注入：在会话中运行一轮对话并保存历史，onProgress 接收运行中的进度事件
+type ConsoleChatFunc func(ctx context.Context, sessionKey, message string, onProgress func(agent.ProgressEvent)) (*ConsoleChatResult, error)
+
+// Approval 待人工审批的命令或被 DLP 扣留的回复
+type Approval struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind,omitempty"` // exec | dlp | 空（普通命令）
+	Command   string    `json:"command"`
+	Requester string    `json:"requester"`
+	Reason    string    `json:"reason,omitempty"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ListApprovalsFunc 由 gateway 注入，返回待审批列表
+type ListApprovalsFunc func() ([]Approval, error)
+
+// ResolveApprovalFunc 由 gateway 注入，批准或拒绝一条审批
+type ResolveApprovalFunc func(id string, approve bool) error
+
+// SessionResetFunc 由 gateway 注入，会话删除时结束其后台进程等运行状态
+type SessionResetFunc func(sessionKey string)
+
+// SetChat 注入控制台聊天回调
+func (s *Server) SetChat(fn ConsoleChatFunc) {
+	s.chat = fn
+}
+
+// SetApprovals 注入审批查询和处理回调
+func (s *Server) SetApprovals(list ListApprovalsFunc, resolve ResolveApprovalFunc) {
+	s.listApprovals = list
+	s.resolveApproval = resolve
+}
+
+// SetSessionReset 注入会话删除时的清理回调
+func (s *Server) SetSessionReset(fn SessionResetFunc) {
+	s.resetSession = fn
+}
+
+// SetTaskStore 注入任务日志，供控制台检索（nil 表示未启用）
+func (s *Server) SetTaskStore(store *tasklog.Store) {
+	s.tasks = store
+}
+
+// consoleSessions 控制台登录会话（内存中，gateway 重启后需重新登录）
+type consoleSessions struct {
+	mu       sync.Mutex
+	tokens   map[string]consoleLogin
+	failures map[string]consoleFailure
+}
+
+type consoleLogin struct {
+	user    string
+	expires time.Time
+}
+
+type consoleFailure struct {
+	count int
+	last  time.Time
+}
+
+func newConsoleSessions() *consoleSessions {
+	return &consoleSessions{tokens: map[string]consoleLogin{}, failures: map[string]consoleFailure{}}
+}
+
+func (cs *consoleSessions) create(user string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	now := time.Now()
+	for t, l := range cs.tokens {
+		if now.After(l.expires) {
+			delete(cs.tokens, t)
+		}
+	}
+	cs.tokens[token] = consoleLogin{user: user, expires: now.Add(ttl)}
+	return token, nil
+}
+
+func (cs *consoleSessions) lookup(token string) (string, bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	l, ok := cs.tokens[token]
+	if !ok || time.Now().After(l.expires) {
+		delete(cs.tokens, token)
+		return "", false
+	}
+	return l.user, true
+}
+
+func (cs *consoleSessions) revoke(token string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	delete(cs.tokens, token)
+}
+
+// locked 该 IP 是否因连续登录失败被锁定
+func (cs *consoleSessions) locked(ip string) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	f := cs.failures[ip]
+	if time.Since(f.last) > consoleLockout {
+		delete(cs.failures, ip)
+		return false
+	}
+	return f.count >= consoleMaxFailures
+}
+
+func (cs *consoleSessions) fail(ip string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	f := cs.failures[ip]
+	if time.Since(f.last) > consoleLockout {
+		f.count = 0
+	}
+	f.count++
+	f.last = time.Now()
+	cs.failures[ip] = f
+}
+
+// setupConsoleRoutes 注册控制台页面、登录接口和 WebSocket
+func (s *Server) setupConsoleRoutes() {
+	assets := gin.WrapH(web.Handler())
+	s.router.GET("/", assets)
+	s.router.GET("/assets/*filepath", assets)
+
+	api := s.router.Group("/api/console", s.consoleHostMiddleware())
+	api.POST("/login", s.handleConsoleLogin)
+	api.POST("/logout", s.handleConsoleLogout)
+	api.GET("/me", s.consoleAuthMiddleware(), s.handleConsoleMe)
+	s.router.GET("/ws", s.consoleHostMiddleware(), s.consoleAuthMiddleware(), s.handleConsoleWS)
+}
+
+// consoleHostMiddleware 只接受 Host 为本机、IP 地址或 web.allowedHosts 中的请求，
+// 防止恶意域名通过 DNS rebinding 把浏览器请求指向本机控制台
+func (s *Server) consoleHostMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !allowedHost(c.Request.Host, s.cfg.Get().Web.AllowedHosts) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "host not allowed; add it to web.allowedHosts"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func allowedHost(hostport string, allowed []string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+	if host == "" || strings.EqualFold(host, "localhost") || net.ParseIP(host) != nil {
+		return true
+	}
+	for _, a := range allowed {
+		a = strings.TrimSpace(a)
+		if strings.EqualFold(a, host) || strings.EqualFold(a, hostport) {
+			return true
+		}
+	}
+	return false
+}
+
+// trustedNetwork 本机请求，或 allowTailscale 时来自 tailnet 的请求；只看连接对端地址，不信任代理头
+func trustedNetwork(c *gin.Context, cfg *config.Config) bool {
+	ip := c.RemoteIP()
+	if ip == "127.0.0.1" || ip == "::1" {
+		return true
+	}
+	if cfg.Gateway.Auth.AllowTailscale {
+		if parsed := net.ParseIP(ip); parsed != nil && tailnetRange.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// consoleUser 返回当前请求的控制台用户；未登录时返回 false
+func (s *Server) consoleUser(c *gin.Context) (string, bool) {
+	cfg := s.cfg.Get()
+	if !cfg.Web.Auth.Enabled {
+		if trustedNetwork(c, cfg) {
+			return "local", true
+		}
+		return "", false
+	}
+	token, err := c.Cookie(consoleCookie)
+	if err != nil || token == "" {
+		return "", false
+	}
+	return s.consoleLogins.lookup(token)
+}
+
+// consoleAuthMiddleware 要求已登录控制台
+func (s *Server) consoleAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := s.consoleUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "login required", "authEnabled": s.cfg.Get().Web.Auth.Enabled})
+			c.Abort()
+			return
+		}
+		c.Set("consoleUser", user)
+		c.Next()
+	}
+}
+
+func (s *Server) handleConsoleLogin(c *gin.Context) {
+	cfg := s.cfg.Get()
+	auth := cfg.Web.Auth
+	if !auth.Enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "web login is disabled; the console is available from this machine only"})
+		return
+	}
+	ip := c.RemoteIP()
+	if s.consoleLogins.locked(ip) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed logins, try again later"})
+		return
+	}
+	var body struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid login request"})
+		return
+	}
+	if auth.Password == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "web.auth.password is not set"})
+		return
+	}
+	if auth.Password == defaultWebPassword {
+		c.JSON(http.StatusForbidden, gin.H{"error": "change the default web.auth.password before logging in (highclaw secrets set web.auth.password)"})
+		return
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(body.Username), []byte(auth.Username)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(body.Password), []byte(auth.Password)) == 1
+	if !userOK || !passOK {
+		s.consoleLogins.fail(ip)
+		s.logger.Warn("console login failed", "ip", ip, "user", body.Username)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
+		return
+	}
+	ttl := time.Duration(auth.SessionTTLMinutes) * time.Minute
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	token, err := s.consoleLogins.create(auth.Username, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     consoleCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	s.logger.Info("console login", "ip", ip, "user", auth.Username)
+	c.JSON(http.StatusOK, gin.H{"user": auth.Username})
+}
+
+func (s *Server) handleConsoleLogout(c *gin.Context) {
+	if token, err := c.Cookie(consoleCookie); err == nil {
+		s.consoleLogins.revoke(token)
+	}
+	http.SetCookie(c.Writer, &http.Cookie{Name: consoleCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteStrictMode})
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (s *Server) handleConsoleMe(c *gin.Context) {
+	cfg := s.cfg.Get()
+	c.JSON(http.StatusOK, gin.H{
+		"user":        c.GetString("consoleUser"),
+		"authEnabled": cfg.Web.Auth.Enabled,
+		"agent":       cfg.Agent.Model,
+		"uptime":      formatUptime(time.Since(s.startedAt)),
+	})
+}
+
+// sameOrigin WebSocket 只接受同源页面发起的连接，防止其他站点借用登录 cookie
+func sameOrigin(r *http.Request) bool {
+	origin := strings.TrimSpace(r.Header.Get("Origin"))
+	if origin == "" {
+		return true
+	}
+	_, host, ok := strings.Cut(origin, "://")
+	return ok && strings.EqualFold(host, r.Host)
+}
@@ -0,0 +1,241 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/highclaw/highclaw/internal/agent"
+	"github.com/highclaw/highclaw/internal/config"
+	"github.com/highclaw/highclaw/internal/gateway/protocol"
+)
+
+func TestConsoleLogin(t *testing.T) {
+	cfg := config.Default()
+	cfg.Gateway.Mode = "production"
+	cfg.Web.Auth = config.WebAuthConfig{Enabled: true, Username: "admin", Password: "hunter2", SessionTTLMinutes: 60}
+	cfg.Web.AllowedHosts = []string{"example.com"} // httptest 默认 Host
+	s := NewServer(config.NewLive(cfg), slog.Default(), NewLogBuffer(10))
+
+	do := func(method, path, remote, body string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.RemoteAddr = remote
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodGet, "/", "10.0.0.5:5000", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "HighClaw Console") {
+		t.Fatalf("index not served: %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/assets/app.js", "10.0.0.5:5000", ""); w.Code != http.StatusOK {
+		t.Fatalf("assets not served: %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/api/console/me", "10.0.0.5:5000", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("me without cookie should be 401, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/ws", "10.0.0.5:5000", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("ws without cookie should be 401, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/console/login", "10.0.0.5:5000", `{"username":"admin","password":"nope"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong password should be 401, got %d", w.Code)
+	}
+
+	w := do(http.MethodPost, "/api/console/login", "10.0.0.5:5000", `{"username":"admin","password":"hunter2"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login failed: %d %s", w.Code, w.Body.String())
+	}
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == consoleCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil || !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode {
+		t.Fatalf("login cookie missing or not HttpOnly/SameSite=Strict: %+v", cookie)
+	}
+	if w := do(http.MethodGet, "/api/console/me", "10.0.0.5:5000", "", cookie); w.Code != http.StatusOK {
+		t.Fatalf("me with cookie should be 200, got %d", w.Code)
+	}
+
+	do(http.MethodPost, "/api/console/logout", "10.0.0.5:5000", "", cookie)
+	if w := do(http.MethodGet, "/api/console/me", "10.0.0.5:5000", "", cookie); w.Code != http.StatusUnauthorized {
+		t.Fatalf("cookie should be revoked after logout, got %d", w.Code)
+	}
+}
+
+func TestConsoleDefaultPasswordRefused(t *testing.T) {
+	cfg := config.Default()
+	cfg.Gateway.Mode = "production"
+	cfg.Web.Auth = config.WebAuthConfig{Enabled: true, Username: "admin", Password: "admin"}
+	s := NewServer(config.NewLive(cfg), slog.Default(), NewLogBuffer(10))
+
+	login := func(remote, forwarded string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/console/login", strings.NewReader(`{"username":"admin","password":"admin"}`))
+		req.RemoteAddr = remote
+		req.Header.Set("Content-Type", "application/json")
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-For", forwarded)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := login("10.0.0.5:5000", "127.0.0.1"); code != http.StatusForbidden {
+		t.Fatalf("spoofed X-Forwarded-For login with default password should be refused, got %d", code)
+	}
+	if code := login("127.0.0.1:5000", ""); code != http.StatusForbidden {
+		t.Fatalf("default password should be refused even locally, got %d", code)
+	}
+}
+
+func TestConsoleTrustedNetworkIgnoresForwardedFor(t *testing.T) {
+	cfg := config.Default()
+	cfg.Gateway.Mode = "production"
+	cfg.Web.Auth.Enabled = false
+	s := NewServer(config.NewLive(cfg), slog.Default(), NewLogBuffer(10))
+
+	me := func(remote, host string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/console/me", nil)
+		req.RemoteAddr = remote
+		req.Host = host
+		req.Header.Set("X-Forwarded-For", "127.0.0.1")
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := me("10.0.0.5:5000", "127.0.0.1:18790"); code != http.StatusUnauthorized {
+		t.Fatalf("remote request with X-Forwarded-For: 127.0.0.1 should not be trusted, got %d", code)
+	}
+	if code := me("127.0.0.1:5000", "localhost:18790"); code != http.StatusOK {
+		t.Fatalf("local request should be trusted, got %d", code)
+	}
+	// DNS rebinding：恶意域名解析到 127.0.0.1，Host 不在允许列表中
+	if code := me("127.0.0.1:5000", "evil.example:18790"); code != http.StatusForbidden {
+		t.Fatalf("unknown Host should be rejected, got %d", code)
+	}
+	cfg.Web.AllowedHosts = []string{"console.example.com"}
+	if code := me("127.0.0.1:5000", "console.example.com"); code != http.StatusOK {
+		t.Fatalf("configured Host should be accepted, got %d", code)
+	}
+}
+
+func TestConsoleLockout(t *testing.T) {
+	cfg := config.Default()
+	cfg.Gateway.Mode = "production"
+	cfg.Web.Auth = config.WebAuthConfig{Enabled: true, Username: "admin", Password: "hunter2"}
+	cfg.Web.AllowedHosts = []string{"example.com"}
+	s := NewServer(config.NewLive(cfg), slog.Default(), NewLogBuffer(10))
+
+	login := func(password string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/console/login", strings.NewReader(`{"username":"admin","password":"`+password+`"}`))
+		req.RemoteAddr = "10.0.0.5:5000"
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w.Code
+	}
+	for i := 0; i < consoleMaxFailures; i++ {
+		login("wrong")
+	}
+	if code := login("hunter2"); code != http.StatusTooManyRequests {
+		t.Fatalf("login after %d failures should be locked out, got %d", consoleMaxFailures, code)
+	}
+}
+
+func TestConsoleWebSocketChat(t *testing.T) {
+	cfg := config.Default()
+	cfg.Gateway.Mode = "production"
+	cfg.Web.Auth.Enabled = false // 仅本机访问，无需登录
+	s := NewServer(config.NewLive(cfg), slog.Default(), NewLogBuffer(10))
+	s.SetChat(func(ctx context.Context, sessionKey, message string, onProgress func(agent.ProgressEvent)) (*ConsoleChatResult, error) {
+		onProgress(agent.ProgressEvent{Kind: agent.ProgressToolStart, RunID: "r1", CallID: "call_1", Tool: "shell", Input: `{"command":"ls"}`})
+		onProgress(agent.ProgressEvent{Kind: agent.ProgressToolEnd, RunID: "r1", CallID: "call_1", Tool: "shell", Status: "success"})
+		return &ConsoleChatResult{SessionKey: "agent:main:web-1", Reply: "echo: " + message, RunID: "r1"}, nil
+	})
+	var resolved string
+	s.SetApprovals(
+		func() ([]Approval, error) {
+			return []Approval{{ID: "exec-1", Command: "rm -rf /tmp/x", Status: "pending"}}, nil
+		},
+		func(id string, approve bool) error { resolved = fmt.Sprintf("%s:%v", id, approve); return nil },
+	)
+	var reset string
+	s.SetSessionReset(func(sessionKey string) { reset = sessionKey })
+	t.Setenv("HOME", t.TempDir())
+	srv := httptest.NewServer(s.router)
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+	if _, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"http://evil.example"}}); err == nil {
+		t.Fatalf("cross-origin websocket should be rejected")
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	send := func(id, method, params string) {
+		if err := conn.WriteJSON(protocol.RPCRequest{ID: id, Method: method, Params: json.RawMessage(params)}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	// 读取直到收到 id 的响应，返回途中的事件名
+	await := func(id string) (map[string]any, []string) {
+		var events []string
+		for {
+			var msg map[string]any
+			if err := conn.ReadJSON(&msg); err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if ev, ok := msg["event"].(string); ok {
+				events = append(events, ev)
+				continue
+			}
+			if msg["id"] == id {
+				return msg, events
+			}
+		}
+	}
+
+	send("1", "chat.send", `{"message":"hi"}`)
+	resp, events := await("1")
+	result, _ := resp["result"].(map[string]any)
+	if result["reply"] != "echo: hi" || result["sessionKey"] != "agent:main:web-1" {
+		t.Fatalf("chat.send result = %+v", resp)
+	}
+	want := []string{protocol.EventChatToolUse, protocol.EventChatToolResult, protocol.EventChatMessage}
+	if strings.Join(events, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %v; want %v", events, want)
+	}
+
+	send("2", "approvals.list", `{}`)
+	if resp, _ := await("2"); !strings.Contains(fmt.Sprint(resp["result"]), "exec-1") {
+		t.Fatalf("approvals.list = %+v", resp)
+	}
+	send("3", "approvals.resolve", `{"id":"exec-1","approve":true}`)
+	if resp, _ := await("3"); resp["error"] != nil || resolved != "exec-1:true" {
+		t.Fatalf("approvals.resolve = %+v, resolved %q", resp, resolved)
+	}
+	send("4", "sessions.delete", `{"key":"agent:main:web-1"}`)
+	if resp, _ := await("4"); resp["error"] != nil || reset != "agent:main:web-1" {
+		t.Fatalf("sessions.delete = %+v, reset %q", resp, reset)
+	}
+	send("5", "nope", `{}`)
+	if resp, _ := await("5"); resp["error"] == nil {
+		t.Fatalf("unknown method should return an error")
+	}
+}
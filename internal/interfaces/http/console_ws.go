@@ -0,0 +1,496 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/highclaw/highclaw/internal/agent"
+	"github.com/highclaw/highclaw/internal/gateway/protocol"
+	"github.com/highclaw/highclaw/internal/gateway/session"
+	"github.com/highclaw/highclaw/internal/system/tasklog"
+)
+
+// 控制台 WebSocket：复用 gateway 协议（protocol.RPCRequest / RPCResponse / RPCEvent），
+// 每个连接同时最多运行一轮对话，chat.abort 取消。
+
+const (
+	consoleReadLimit  = 1 << 20
+	consoleWriteWait  = 10 * time.Second
+	consolePingPeriod = 30 * time.Second
+
+	// eventChatToolOutput 工具执行中的一行输出（控制台专用，协议中没有对应事件）
+	eventChatToolOutput = "chat.toolOutput"
+)
+
+var consoleUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	CheckOrigin:     sameOrigin,
+}
+
+// consoleConn 一个控制台连接
+type consoleConn struct {
+	s    *Server
+	conn *websocket.Conn
+	user string
+
+	writeMu sync.Mutex
+
+	runMu  sync.Mutex
+	cancel context.CancelFunc // 运行中对话的取消函数，空闲时为 nil
+}
+
+func (s *Server) handleConsoleWS(c *gin.Context) {
+	conn, err := consoleUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		s.logger.Warn("console websocket upgrade failed", "error", err)
+		return
+	}
+	cc := &consoleConn{s: s, conn: conn, user: c.GetString("consoleUser")}
+	cc.serve()
+}
+
+func (cc *consoleConn) serve() {
+	defer cc.conn.Close()
+	defer cc.abort()
+
+	cc.conn.SetReadLimit(consoleReadLimit)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(consolePingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				cc.writeMu.Lock()
+				err := cc.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(consoleWriteWait))
+				cc.writeMu.Unlock()
+				if err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		_, data, err := cc.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				cc.s.logger.Debug("console websocket closed", "error", err)
+			}
+			return
+		}
+		var req protocol.RPCRequest
+		if err := json.Unmarshal(data, &req); err != nil || req.Method == "" {
+			cc.reply(req.ID, nil, &protocol.RPCError{Code: protocol.ErrInvalidRequest, Message: "invalid request"})
+			continue
+		}
+		cc.dispatch(req)
+	}
+}
+
+func (cc *consoleConn) send(v any) {
+	cc.writeMu.Lock()
+	defer cc.writeMu.Unlock()
+	_ = cc.conn.SetWriteDeadline(time.Now().Add(consoleWriteWait))
+	_ = cc.conn.WriteJSON(v)
+}
+
+func (cc *consoleConn) reply(id string, result any, rpcErr *protocol.RPCError) {
+	cc.send(protocol.RPCResponse{ID: id, Result: result, Error: rpcErr})
+}
+
+func (cc *consoleConn) event(name string, payload any) {
+	cc.send(protocol.RPCEvent{Event: name, Payload: payload})
+}
+
+func invalidParams(err error) *protocol.RPCError {
+	return &protocol.RPCError{Code: protocol.ErrInvalidParams, Message: err.Error()}
+}
+
+func internalError(err error) *protocol.RPCError {
+	return &protocol.RPCError{Code: protocol.ErrInternal, Message: err.Error()}
+}
+
+// dispatch 执行一条 RPC；chat.send 在后台运行，其余方法同步返回
+func (cc *consoleConn) dispatch(req protocol.RPCRequest) {
+	var result any
+	var rpcErr *protocol.RPCError
+	switch req.Method {
+	case "connect":
+		result = gin.H{"user": cc.user, "agent": cc.s.cfg.Get().Agent.Model}
+	case "sessions.list":
+		result, rpcErr = cc.sessionsList()
+	case "sessions.get":
+		result, rpcErr = cc.sessionsGet(req.Params)
+	case "sessions.delete":
+		result, rpcErr = cc.sessionsDelete(req.Params)
+	case "sessions.search":
+		result, rpcErr = cc.sessionsSearch(req.Params)
+	case "chat.send":
+		rpcErr = cc.chatSend(req.ID, req.Params)
+		if rpcErr == nil {
+			return // 结果由后台运行返回
+		}
+	case "chat.abort":
+		result = gin.H{"aborted": cc.abort()}
+	case "channels.status":
+		result, rpcErr = cc.channelsStatus()
+	case "logs.tail":
+		result, rpcErr = cc.logsTail(req.Params)
+	case "memory.search":
+		result, rpcErr = cc.memorySearch(req.Params)
+	case "tasks.search":
+		result, rpcErr = cc.tasksSearch(req.Params)
+	case "approvals.list":
+		result, rpcErr = cc.approvalsList()
+	case "approvals.resolve":
+		result, rpcErr = cc.approvalsResolve(req.Params)
+	default:
+		rpcErr = &protocol.RPCError{Code: protocol.ErrMethodNotFound, Message: "unknown method: " + req.Method}
+	}
+	cc.reply(req.ID, result, rpcErr)
+}
+
+func decodeParams(raw json.RawMessage, v any) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// consoleSession sessions.list 中的一项
+type consoleSession struct {
+	protocol.SessionInfo
+	Label string `json:"label"`
+}
+
+func (cc *consoleConn) sessionsList() (any, *protocol.RPCError) {
+	all, err := session.LoadAll()
+	if err != nil {
+		return nil, internalError(err)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].LastActivityAt.After(all[j].LastActivityAt) })
+	out := make([]consoleSession, 0, len(all))
+	for _, sess := range all {
+		model := sess.Model
+		if sess.ModelOverride != "" {
+			model = sess.ModelOverride
+		}
+		out = append(out, consoleSession{
+			SessionInfo: protocol.SessionInfo{
+				Key:            sess.Key,
+				Channel:        sess.Channel,
+				AgentID:        sess.AgentID,
+				Model:          model,
+				MessageCount:   len(sess.History),
+				LastActivityAt: sess.LastActivityAt.UnixMilli(),
+			},
+			Label: sess.DisplayName(),
+		})
+	}
+	return gin.H{"sessions": out}, nil
+}
+
+type sessionKeyParams struct {
+	Key string `json:"key"`
+}
+
+func (p sessionKeyParams) validate() error {
+	if strings.TrimSpace(p.Key) == "" {
+		return fmt.Errorf("key is required")
+	}
+	return nil
+}
+
+func (cc *consoleConn) sessionsGet(raw json.RawMessage) (any, *protocol.RPCError) {
+	var p sessionKeyParams
+	if err := decodeParams(raw, &p); err != nil {
+		return nil, invalidParams(err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, invalidParams(err)
+	}
+	sess, err := session.Load(p.Key)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	return gin.H{"key": sess.Key, "label": sess.DisplayName(), "channel": sess.Channel, "messages": sess.History}, nil
+}
+
+func (cc *consoleConn) sessionsDelete(raw json.RawMessage) (any, *protocol.RPCError) {
+	var p sessionKeyParams
+	if err := decodeParams(raw, &p); err != nil {
+		return nil, invalidParams(err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, invalidParams(err)
+	}
+	if err := session.Delete(p.Key); err != nil {
+		return nil, internalError(err)
+	}
+	if cc.s.resetSession != nil {
+		cc.s.resetSession(p.Key)
+	}
+	cc.s.logger.Info("console: session deleted", "session", p.Key, "user", cc.user)
+	return gin.H{"deleted": p.Key}, nil
+}
+
+type searchParams struct {
+	Query    string `json:"query"`
+	Limit    int    `json:"limit"`
+	Category string `json:"category"`
+	Action   string `json:"action"`
+	Status   string `json:"status"`
+}
+
+func (cc *consoleConn) sessionsSearch(raw json.RawMessage) (any, *protocol.RPCError) {
+	var p searchParams
+	if err := decodeParams(raw, &p); err != nil {
+		return nil, invalidParams(err)
+	}
+	hits, err := session.Search(p.Query, clampLimit(p.Limit))
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+	return gin.H{"hits": hits}, nil
+}
+
+func (cc *consoleConn) memorySearch(raw json.RawMessage) (any, *protocol.RPCError) {
+	var p searchParams
+	if err := decodeParams(raw, &p); err != nil {
+		return nil, invalidParams(err)
+	}
+	if strings.TrimSpace(p.Query) == "" {
+		return nil, invalidParams(fmt.Errorf("query is required"))
+	}
+	entries, err := agent.SearchMemory(cc.s.cfg.Get(), p.Query, clampLimit(p.Limit), p.Category)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	return gin.H{"entries": entries}, nil
+}
+
+func (cc *consoleConn) tasksSearch(raw json.RawMessage) (any, *protocol.RPCError) {
+	var p searchParams
+	if err := decodeParams(raw, &p); err != nil {
+		return nil, invalidParams(err)
+	}
+	if cc.s.tasks == nil {
+		return nil, internalError(fmt.Errorf("task log is disabled"))
+	}
+	records, total, err := cc.s.tasks.Query(tasklog.QueryParams{
+		Search:   p.Query,
+		Action:   p.Action,
+		Status:   p.Status,
+		SortDesc: true,
+		Limit:    clampLimit(p.Limit),
+	})
+	if err != nil {
+		return nil, internalError(err)
+	}
+	return gin.H{"records": records, "total": total}, nil
+}
+
+// clampLimit 搜索结果条数：默认 20，最多 200
+func clampLimit(n int) int {
+	switch {
+	case n <= 0:
+		return 20
+	case n > 200:
+		return 200
+	}
+	return n
+}
+
+func (cc *consoleConn) channelsStatus() (any, *protocol.RPCError) {
+	if cc.s.getChannelStatus == nil {
+		return &ChannelStatusResult{Channels: map[string]ChannelStatus{}}, nil
+	}
+	return cc.s.getChannelStatus(), nil
+}
+
+type logsParams struct {
+	// After 只返回晚于该时间（RFC3339Nano）的日志，用于增量拉取
+	After string `json:"after"`
+	Level string `json:"level"`
+	Limit int    `json:"limit"`
+}
+
+// logLevelRank 日志级别排序，未知级别按 INFO 处理
+func logLevelRank(level string) int {
+	switch strings.ToUpper(level) {
+	case "DEBUG":
+		return 0
+	case "WARN", "WARNING":
+		return 2
+	case "ERROR":
+		return 3
+	}
+	return 1
+}
+
+func (cc *consoleConn) logsTail(raw json.RawMessage) (any, *protocol.RPCError) {
+	var p logsParams
+	if err := decodeParams(raw, &p); err != nil {
+		return nil, invalidParams(err)
+	}
+	if cc.s.logBuffer == nil {
+		return gin.H{"entries": []LogEntry{}}, nil
+	}
+	var after time.Time
+	if p.After != "" {
+		t, err := time.Parse(time.RFC3339Nano, p.After)
+		if err != nil {
+			return nil, invalidParams(fmt.Errorf("after: %w", err))
+		}
+		after = t
+	}
+	minRank := 0
+	if p.Level != "" {
+		minRank = logLevelRank(p.Level)
+	}
+	entries := make([]LogEntry, 0)
+	for _, e := range cc.s.logBuffer.Entries() {
+		if !e.Time.After(after) || logLevelRank(e.Level) < minRank {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if p.Limit > 0 && len(entries) > p.Limit {
+		entries = entries[len(entries)-p.Limit:]
+	}
+	return gin.H{"entries": entries}, nil
+}
+
+func (cc *consoleConn) approvalsList() (any, *protocol.RPCError) {
+	if cc.s.listApprovals == nil {
+		return gin.H{"approvals": []Approval{}}, nil
+	}
+	items, err := cc.s.listApprovals()
+	if err != nil {
+		return nil, internalError(err)
+	}
+	if items == nil {
+		items = []Approval{}
+	}
+	return gin.H{"approvals": items}, nil
+}
+
+func (cc *consoleConn) approvalsResolve(raw json.RawMessage) (any, *protocol.RPCError) {
+	var p struct {
+		ID      string `json:"id"`
+		Approve bool   `json:"approve"`
+	}
+	if err := decodeParams(raw, &p); err != nil {
+		return nil, invalidParams(err)
+	}
+	if strings.TrimSpace(p.ID) == "" {
+		return nil, invalidParams(fmt.Errorf("id is required"))
+	}
+	if cc.s.resolveApproval == nil {
+		return nil, internalError(fmt.Errorf("approvals are not available"))
+	}
+	if err := cc.s.resolveApproval(p.ID, p.Approve); err != nil {
+		return nil, internalError(err)
+	}
+	cc.s.logger.Info("console: approval resolved", "id", p.ID, "approve", p.Approve, "user", cc.user)
+	return gin.H{"id": p.ID, "approved": p.Approve}, nil
+}
+
+// chatSend 在后台运行一轮对话：进度以事件推送，最终结果作为 RPC 响应返回
+func (cc *consoleConn) chatSend(id string, raw json.RawMessage) *protocol.RPCError {
+	var p struct {
+		SessionKey string `json:"sessionKey"`
+		Message    string `json:"message"`
+	}
+	if err := decodeParams(raw, &p); err != nil {
+		return invalidParams(err)
+	}
+	if strings.TrimSpace(p.Message) == "" {
+		return invalidParams(fmt.Errorf("message is required"))
+	}
+	if cc.s.chat == nil {
+		return internalError(fmt.Errorf("chat is not available"))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cc.runMu.Lock()
+	if cc.cancel != nil {
+		cc.runMu.Unlock()
+		cancel()
+		return &protocol.RPCError{Code: protocol.ErrInvalidRequest, Message: "a run is already in progress"}
+	}
+	cc.cancel = cancel
+	cc.runMu.Unlock()
+
+	go func() {
+		defer func() {
+			cc.runMu.Lock()
+			cc.cancel = nil
+			cc.runMu.Unlock()
+			cancel()
+		}()
+		onProgress := func(ev agent.ProgressEvent) {
+			cc.progressEvent(p.SessionKey, ev)
+		}
+		result, err := cc.s.chat(ctx, p.SessionKey, p.Message, onProgress)
+		if err != nil {
+			msg := err.Error()
+			if ctx.Err() != nil {
+				msg = "run cancelled"
+			}
+			cc.reply(id, nil, &protocol.RPCError{Code: protocol.ErrInternal, Message: msg})
+			return
+		}
+		cc.event(protocol.EventChatMessage, gin.H{
+			"sessionKey": result.SessionKey,
+			"message":    protocol.ChatMessage{Role: "assistant", Content: result.Reply, Channel: "web", Timestamp: time.Now().UnixMilli()},
+		})
+		cc.reply(id, result, nil)
+	}()
+	return nil
+}
+
+// progressEvent 把 agent 进度事件转换为协议事件
+func (cc *consoleConn) progressEvent(sessionKey string, ev agent.ProgressEvent) {
+	payload := gin.H{"sessionKey": sessionKey, "runId": ev.RunID, "callId": ev.CallID, "tool": ev.Tool}
+	switch ev.Kind {
+	case agent.ProgressText:
+		payload["text"] = ev.Text
+		cc.event(protocol.EventChatStream, payload)
+	case agent.ProgressToolStart:
+		payload["input"] = ev.Input
+		cc.event(protocol.EventChatToolUse, payload)
+	case agent.ProgressToolOutput:
+		payload["text"] = ev.Text
+		cc.event(eventChatToolOutput, payload)
+	case agent.ProgressToolEnd:
+		payload["output"] = ev.Text
+		payload["status"] = ev.Status
+		payload["error"] = ev.Error
+		payload["durationMs"] = ev.DurationMs
+		cc.event(protocol.EventChatToolResult, payload)
+	}
+}
+
+// abort 取消运行中的对话，返回是否有对话被取消
+func (cc *consoleConn) abort() bool {
+	cc.runMu.Lock()
+	defer cc.runMu.Unlock()
+	if cc.cancel == nil {
+		return false
+	}
+	cc.cancel()
+	return true
+}
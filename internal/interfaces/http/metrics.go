@@ -0,0 +1,15 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/highclaw/highclaw/internal/system/metrics"
+)
+
+// handleMetrics 以 Prometheus 文本格式输出进程内指标
+func (s *Server) handleMetrics(c *gin.Context) {
+	c.Header("Content-Type", metrics.ContentType)
+	c.Status(http.StatusOK)
+	metrics.Default.WriteText(c.Writer)
+}
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+func TestMetricsEndpointAuth(t *testing.T) {
+	cfg := config.Default()
+	cfg.Gateway.Mode = "production"
+	cfg.Gateway.Auth = config.GatewayAuth{Mode: "token", Token: "s3cret"}
+	s := NewServer(config.NewLive(cfg), slog.Default(), NewLogBuffer(10))
+
+	get := func(remote, auth string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.RemoteAddr = remote
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("10.0.0.5:5000", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("remote scrape without token should be rejected, got %d", w.Code)
+	}
+	for _, h := range []string{"X-Forwarded-For", "X-Real-IP"} {
+		if w := get("10.0.0.5:5000", "", h, "127.0.0.1"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("remote scrape spoofing %s: 127.0.0.1 should be rejected, got %d", h, w.Code)
+		}
+	}
+	if w := get("10.0.0.5:5000", "Bearer wrong"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token should be rejected, got %d", w.Code)
+	}
+	w := get("10.0.0.5:5000", "Bearer s3cret")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("authorized scrape failed: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), "# TYPE highclaw_messages_received_total counter") {
+		t.Fatalf("metrics body missing catalog metrics:\n%s", w.Body.String())
+	}
+	if w := get("127.0.0.1:5000", ""); w.Code != http.StatusOK {
+		t.Fatalf("local scrape should not need a token, got %d", w.Code)
+	}
+}
@@ -1,11 +1,15 @@
 package http
 
 import (
+	"crypto/subtle"
 	"log/slog"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/highclaw/highclaw/internal/config"
 )
 
 // loggerMiddleware logs HTTP requests.
@@ -33,7 +37,7 @@ func loggerMiddleware(logger *slog.Logger) gin.HandlerFunc {
 // localhostOnlyMiddleware only allows requests from 127.0.0.1 / ::1.
 func localhostOnlyMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
+		ip := c.RemoteIP()
 		if ip != "127.0.0.1" && ip != "::1" {
 			c.JSON(http.StatusForbidden, gin.H{"error": "localhost only"})
 			c.Abort()
@@ -42,3 +46,56 @@ func localhostOnlyMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// tailnetRange Tailscale 分配的 CGNAT 地址段
+var tailnetRange = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// gatewayAuthMiddleware 按 gateway.auth 校验非本机请求：
+// token 模式要求 "Authorization: Bearer <token>"，password 模式接受 Basic 或 Bearer 密码，
+// allowTailscale 时放行 tailnet 地址；本机请求和 mode=none 直接放行（暴露范围由 gateway.bind 控制）。
+// 地址取连接对端，不看 X-Forwarded-For，否则远程请求可冒充本机。
+func gatewayAuthMiddleware(cfg *config.Live) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.RemoteIP()
+		if ip == "127.0.0.1" || ip == "::1" {
+			c.Next()
+			return
+		}
+		auth := cfg.Get().Gateway.Auth
+		if auth.AllowTailscale {
+			if parsed := net.ParseIP(ip); parsed != nil && tailnetRange.Contains(parsed) {
+				c.Next()
+				return
+			}
+		}
+		var secret string
+		switch auth.Mode {
+		case "", "none":
+			c.Next()
+			return
+		case "token":
+			secret = auth.Token
+		case "password":
+			secret = auth.Password
+		}
+		if secret != "" && secretMatches(c.Request, auth.Mode, secret) {
+			c.Next()
+			return
+		}
+		if auth.Mode == "password" {
+			c.Header("WWW-Authenticate", `Basic realm="highclaw"`)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		c.Abort()
+	}
+}
+
+func secretMatches(r *http.Request, mode, secret string) bool {
+	var given string
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		given = strings.TrimSpace(bearer)
+	} else if mode == "password" {
+		_, given, _ = r.BasicAuth()
+	}
+	return given != "" && subtle.ConstantTimeCompare([]byte(given), []byte(secret)) == 1
+}
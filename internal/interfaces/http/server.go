@@ -12,18 +12,28 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/highclaw/highclaw/internal/config"
+	"github.com/highclaw/highclaw/internal/system/tasklog"
 )
 
-// Server provides internal HTTP endpoints (health check + channel reload + channel status).
+// Server provides the gateway HTTP endpoints: health, metrics, internal channel
+// reload/status and the web console.
 type Server struct {
 	router    *gin.Engine
-	cfg       *config.Config
+	cfg       *config.Live // 热加载时整体替换，每次请求通过 cfg.Get() 读取
 	logger    *slog.Logger
 	logBuffer *LogBuffer
 	startedAt time.Time
 
 	reloadChannels   ReloadChannelsFunc
 	getChannelStatus GetChannelStatusFunc
+
+	// web 控制台
+	consoleLogins   *consoleSessions
+	chat            ConsoleChatFunc
+	listApprovals   ListApprovalsFunc
+	resolveApproval ResolveApprovalFunc
+	resetSession    SessionResetFunc
+	tasks           *tasklog.Store
 }
 
 // ChannelReloadResult describes the result of a channel reload.
@@ -51,33 +61,37 @@ type ReloadChannelsFunc func(ctx context.Context) (*ChannelReloadResult, error)
 type GetChannelStatusFunc func() *ChannelStatusResult
 
 // NewServer creates an HTTP server with health + internal endpoints.
-func NewServer(cfg *config.Config, logger *slog.Logger, logBuffer *LogBuffer) *Server {
-	if cfg.Gateway.Mode == "production" {
+func NewServer(cfg *config.Live, logger *slog.Logger, logBuffer *LogBuffer) *Server {
+	if cfg.Get().Gateway.Mode == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	} else {
 		gin.SetMode(gin.DebugMode)
 	}
 
 	router := gin.New()
+	// 不信任任何代理头：X-Forwarded-For 可被伪造，本机/tailnet 判断只看连接的对端地址
+	_ = router.SetTrustedProxies(nil)
 	router.Use(gin.Recovery())
 	router.Use(loggerMiddleware(logger))
 
 	s := &Server{
-		router:    router,
-		cfg:       cfg,
-		logger:    logger,
-		logBuffer: logBuffer,
-		startedAt: time.Now(),
+		router:        router,
+		cfg:           cfg,
+		logger:        logger,
+		logBuffer:     logBuffer,
+		startedAt:     time.Now(),
+		consoleLogins: newConsoleSessions(),
 	}
 
 	s.setupRoutes()
 	return s
 }
 
-// setupRoutes 注册 health + internal 路由
+// setupRoutes 注册 health + metrics + internal + 控制台路由
 func (s *Server) setupRoutes() {
 	s.router.GET("/health", s.handleHealth)
 	s.router.GET("/api/health", s.handleHealth)
+	s.router.GET("/metrics", gatewayAuthMiddleware(s.cfg), s.handleMetrics)
 
 	internal := s.router.Group("/api/internal")
 	internal.Use(localhostOnlyMiddleware())
@@ -85,6 +99,8 @@ func (s *Server) setupRoutes() {
 		internal.POST("/reload", s.handleChannelsReload)
 		internal.GET("/channel-status", s.handleChannelStatus)
 	}
+
+	s.setupConsoleRoutes()
 }
 
 // Start starts the HTTP server.
@@ -127,12 +143,13 @@ func (s *Server) Start(ctx context.Context) error {
 }
 
 func (s *Server) getListenAddr() string {
-	port := s.cfg.Gateway.Port
+	cfg := s.cfg.Get()
+	port := cfg.Gateway.Port
 	if port == 0 {
 		port = 18790
 	}
 
-	switch s.cfg.Gateway.Bind {
+	switch cfg.Gateway.Bind {
 	case "loopback":
 		return fmt.Sprintf("127.0.0.1:%d", port)
 	case "all":
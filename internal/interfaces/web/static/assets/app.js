@@ -0,0 +1,498 @@
+// HighClaw web console: login, then a single WebSocket speaking the gateway
+// RPC protocol ({id, method, params} -> {id, result|error}, plus {event, payload}).
+(function () {
+  "use strict";
+
+  const $ = (id) => document.getElementById(id);
+  const state = {
+    ws: null,
+    seq: 0,
+    pending: new Map(),
+    session: "",
+    running: null, // { id, log } while a chat run is in progress
+    tools: new Map(),
+    logCursor: "",
+    logTimer: null,
+    tab: "chat",
+  };
+
+  // ---- helpers ----
+
+  function el(tag, cls, text) {
+    const e = document.createElement(tag);
+    if (cls) e.className = cls;
+    if (text !== undefined) e.textContent = text;
+    return e;
+  }
+
+  function fmtTime(ms) {
+    if (!ms) return "";
+    return new Date(ms).toLocaleString();
+  }
+
+  function setStatus(text, cls) {
+    const s = $("status");
+    s.textContent = text;
+    s.className = "status " + (cls || "");
+  }
+
+  async function api(path, body) {
+    const res = await fetch(path, {
+      method: body === undefined ? "GET" : "POST",
+      headers: body === undefined ? {} : { "Content-Type": "application/json" },
+      body: body === undefined ? undefined : JSON.stringify(body),
+      credentials: "same-origin",
+    });
+    let data = {};
+    try { data = await res.json(); } catch (_) { /* empty body */ }
+    return { status: res.status, data };
+  }
+
+  // ---- auth ----
+
+  async function boot() {
+    const { status, data } = await api("/api/console/me");
+    if (status === 200) {
+      $("logout").classList.toggle("hidden", !data.authEnabled);
+      showApp();
+      return;
+    }
+    if (status === 401 && data.authEnabled === false) {
+      document.body.textContent = "The web console is only available from this machine (web.auth.enabled is false).";
+      return;
+    }
+    showLogin();
+  }
+
+  function showLogin() {
+    $("app").classList.add("hidden");
+    $("login").classList.remove("hidden");
+    $("login-user").focus();
+  }
+
+  function showApp() {
+    $("login").classList.add("hidden");
+    $("app").classList.remove("hidden");
+    connect();
+  }
+
+  $("login-form").addEventListener("submit", async (ev) => {
+    ev.preventDefault();
+    $("login-error").textContent = "";
+    const { status, data } = await api("/api/console/login", {
+      username: $("login-user").value,
+      password: $("login-pass").value,
+    });
+    if (status !== 200) {
+      $("login-error").textContent = data.error || "Login failed";
+      return;
+    }
+    $("login-pass").value = "";
+    $("logout").classList.remove("hidden");
+    showApp();
+  });
+
+  $("logout").addEventListener("click", async () => {
+    await api("/api/console/logout", {});
+    if (state.ws) state.ws.close();
+    location.reload();
+  });
+
+  // ---- websocket ----
+
+  function connect() {
+    const proto = location.protocol === "https:" ? "wss:" : "ws:";
+    const ws = new WebSocket(proto + "//" + location.host + "/ws");
+    state.ws = ws;
+    setStatus("connecting…");
+    ws.onopen = async () => {
+      setStatus("connected", "ok");
+      await call("connect", { role: "app", clientInfo: { name: "web-console", platform: "web" } });
+      refreshSessions();
+      refreshApprovals();
+    };
+    ws.onmessage = (msg) => {
+      const data = JSON.parse(msg.data);
+      if (data.event) {
+        onEvent(data.event, data.payload || {});
+        return;
+      }
+      const p = state.pending.get(data.id);
+      if (!p) return;
+      state.pending.delete(data.id);
+      if (data.error) p.reject(new Error(data.error.message));
+      else p.resolve(data.result);
+    };
+    ws.onclose = () => {
+      setStatus("disconnected", "err");
+      for (const p of state.pending.values()) p.reject(new Error("connection closed"));
+      state.pending.clear();
+      finishRun();
+      // 登录过期时回到登录页，否则稍后重连
+      setTimeout(async () => {
+        const { status } = await api("/api/console/me");
+        if (status === 401) showLogin();
+        else connect();
+      }, 2000);
+    };
+  }
+
+  function call(method, params) {
+    return new Promise((resolve, reject) => {
+      if (!state.ws || state.ws.readyState !== WebSocket.OPEN) {
+        reject(new Error("not connected"));
+        return;
+      }
+      const id = "c" + ++state.seq;
+      state.pending.set(id, { resolve, reject });
+      state.ws.send(JSON.stringify({ id, method, params }));
+    });
+  }
+
+  function onEvent(name, p) {
+    switch (name) {
+      case "chat.stream":
+        addMessage("assistant", p.text);
+        break;
+      case "chat.toolUse":
+        addToolCard(p);
+        break;
+      case "chat.toolOutput":
+        appendToolOutput(p);
+        break;
+      case "chat.toolResult":
+        finishToolCard(p);
+        break;
+      case "chat.message":
+        if (p.message) addMessage("assistant", p.message.content);
+        break;
+    }
+  }
+
+  // ---- tabs ----
+
+  document.querySelectorAll("#tabs button").forEach((b) => {
+    b.addEventListener("click", () => selectTab(b.dataset.tab));
+  });
+
+  function selectTab(tab) {
+    state.tab = tab;
+    document.querySelectorAll("#tabs button").forEach((b) => b.classList.toggle("active", b.dataset.tab === tab));
+    document.querySelectorAll(".tab").forEach((s) => s.classList.toggle("hidden", s.id !== "tab-" + tab));
+    clearInterval(state.logTimer);
+    state.logTimer = null;
+    switch (tab) {
+      case "sessions": refreshSessions(); break;
+      case "channels": refreshChannels(); break;
+      case "logs":
+        refreshLogs();
+        state.logTimer = setInterval(refreshLogs, 2000);
+        break;
+      case "approvals": refreshApprovals(); break;
+    }
+  }
+
+  // ---- chat ----
+
+  function addMessage(role, text) {
+    const log = $("chat-log");
+    log.appendChild(el("div", "msg " + role, text));
+    log.scrollTop = log.scrollHeight;
+  }
+
+  function addToolCard(p) {
+    const card = el("details", "tool");
+    const summary = el("summary");
+    const stateEl = el("span", "state-running", "◌ ");
+    summary.appendChild(stateEl);
+    summary.appendChild(el("strong", "", p.tool));
+    summary.appendChild(el("span", "", " " + argSummary(p.input)));
+    const out = el("pre");
+    card.appendChild(summary);
+    card.appendChild(out);
+    state.tools.set(p.runId + "/" + p.callId, { card, stateEl, out, live: false });
+    $("chat-log").appendChild(card);
+    $("chat-log").scrollTop = $("chat-log").scrollHeight;
+  }
+
+  function appendToolOutput(p) {
+    const t = state.tools.get(p.runId + "/" + p.callId);
+    if (!t) return;
+    t.live = true;
+    t.out.textContent += p.text + "\n";
+    t.out.scrollTop = t.out.scrollHeight;
+  }
+
+  function finishToolCard(p) {
+    const t = state.tools.get(p.runId + "/" + p.callId);
+    if (!t) return;
+    const icons = { success: "✓ ", error: "✗ ", denied: "⊘ " };
+    t.stateEl.textContent = icons[p.status] || "■ ";
+    t.stateEl.className = "state-" + p.status;
+    t.stateEl.title = p.status + " " + (p.durationMs || 0) + "ms";
+    if (!t.live) t.out.textContent = p.output || "";
+    if (p.error) t.out.textContent += (t.out.textContent ? "\n" : "") + p.error;
+  }
+
+  function argSummary(input) {
+    try {
+      const args = JSON.parse(input || "{}");
+      for (const k of ["command", "cmd", "path", "url", "query", "action", "key", "task"]) {
+        if (typeof args[k] === "string" && args[k].trim()) return args[k].trim().slice(0, 120);
+      }
+    } catch (_) { /* not JSON */ }
+    return (input || "").slice(0, 120);
+  }
+
+  async function loadChat(key) {
+    state.session = key;
+    $("chat-log").textContent = "";
+    state.tools.clear();
+    if (!key) return;
+    try {
+      const res = await call("sessions.get", { key });
+      for (const m of res.messages || []) addMessage(m.role, m.content);
+    } catch (err) {
+      addMessage("error", err.message);
+    }
+  }
+
+  async function sendChat() {
+    const text = $("chat-input").value.trim();
+    if (!text || state.running) return;
+    $("chat-input").value = "";
+    addMessage("user", text);
+    $("chat-send").classList.add("hidden");
+    $("chat-abort").classList.remove("hidden");
+    state.running = true;
+    try {
+      const res = await call("chat.send", { sessionKey: state.session, message: text });
+      if (!state.session) {
+        state.session = res.sessionKey;
+        await refreshSessions();
+      }
+    } catch (err) {
+      addMessage("error", err.message);
+    } finally {
+      finishRun();
+    }
+  }
+
+  function finishRun() {
+    state.running = null;
+    $("chat-send").classList.remove("hidden");
+    $("chat-abort").classList.add("hidden");
+    for (const t of state.tools.values()) {
+      if (t.stateEl.className === "state-running") {
+        t.stateEl.textContent = "■ ";
+        t.stateEl.className = "state-cancelled";
+      }
+    }
+  }
+
+  $("chat-form").addEventListener("submit", (ev) => { ev.preventDefault(); sendChat(); });
+  $("chat-input").addEventListener("keydown", (ev) => {
+    if (ev.key === "Enter" && !ev.shiftKey && !ev.isComposing) {
+      ev.preventDefault();
+      sendChat();
+    }
+  });
+  $("chat-abort").addEventListener("click", () => call("chat.abort", {}).catch(() => {}));
+  $("chat-session").addEventListener("change", (ev) => loadChat(ev.target.value));
+  $("chat-new").addEventListener("click", () => {
+    $("chat-session").value = "";
+    loadChat("");
+  });
+
+  // ---- sessions ----
+
+  async function refreshSessions() {
+    let res;
+    try { res = await call("sessions.list", {}); } catch (_) { return; }
+    const sessions = res.sessions || [];
+
+    const select = $("chat-session");
+    select.textContent = "";
+    select.appendChild(el("option", "", "(new session)")).value = "";
+    for (const s of sessions) {
+      const opt = el("option", "", s.label + " · " + s.channel);
+      opt.value = s.key;
+      select.appendChild(opt);
+    }
+    select.value = state.session;
+
+    const body = $("sessions-body");
+    body.textContent = "";
+    for (const s of sessions) {
+      const tr = el("tr", "clickable");
+      tr.appendChild(el("td", "", s.label));
+      tr.appendChild(el("td", "", s.key));
+      tr.appendChild(el("td", "", s.channel));
+      tr.appendChild(el("td", "", String(s.messageCount)));
+      tr.appendChild(el("td", "", fmtTime(s.lastActivityAt)));
+      const actions = el("td");
+      const open = el("button", "", "Open in chat");
+      open.addEventListener("click", (ev) => {
+        ev.stopPropagation();
+        select.value = s.key;
+        loadChat(s.key);
+        selectTab("chat");
+      });
+      const del = el("button", "", "Delete");
+      del.addEventListener("click", async (ev) => {
+        ev.stopPropagation();
+        if (!confirm("Delete session " + s.key + "?")) return;
+        try {
+          await call("sessions.delete", { key: s.key });
+          if (state.session === s.key) loadChat("");
+          $("session-view").textContent = "";
+          refreshSessions();
+        } catch (err) { alert(err.message); }
+      });
+      actions.append(open, " ", del);
+      tr.appendChild(actions);
+      tr.addEventListener("click", () => viewSession(s.key));
+      body.appendChild(tr);
+    }
+  }
+
+  async function viewSession(key) {
+    const view = $("session-view");
+    view.textContent = "";
+    try {
+      const res = await call("sessions.get", { key });
+      view.appendChild(el("h3", "", res.label + " (" + res.key + ")"));
+      for (const m of res.messages || []) view.appendChild(el("div", "msg " + m.role, m.content));
+    } catch (err) {
+      view.appendChild(el("div", "msg error", err.message));
+    }
+  }
+
+  // ---- channels ----
+
+  async function refreshChannels() {
+    const body = $("channels-body");
+    body.textContent = "";
+    try {
+      const res = await call("channels.status", {});
+      const channels = res.channels || {};
+      const names = Object.keys(channels).sort();
+      if (!names.length) body.appendChild(el("tr")).appendChild(el("td", "", "No channels configured."));
+      for (const name of names) {
+        const c = channels[name];
+        const tr = el("tr");
+        tr.appendChild(el("td", "", name));
+        tr.appendChild(el("td", "", c.status));
+        tr.appendChild(el("td", "", c.error || (c.bindCode ? "bind code: " + c.bindCode : "")));
+        body.appendChild(tr);
+      }
+    } catch (err) {
+      body.appendChild(el("tr")).appendChild(el("td", "error", err.message));
+    }
+  }
+  $("channels-refresh").addEventListener("click", refreshChannels);
+
+  // ---- logs ----
+
+  async function refreshLogs() {
+    let res;
+    try {
+      res = await call("logs.tail", { after: state.logCursor, level: $("logs-level").value, limit: 500 });
+    } catch (_) { return; }
+    const pre = $("logs-body");
+    for (const e of res.entries || []) {
+      const attrs = e.attrs ? " " + Object.entries(e.attrs).map(([k, v]) => k + "=" + JSON.stringify(v)).join(" ") : "";
+      const line = el("span", "lvl-" + e.level, new Date(e.time).toLocaleTimeString() + " " + e.level.padEnd(5) + " " + e.message + attrs + "\n");
+      pre.appendChild(line);
+      state.logCursor = e.time;
+    }
+    while (pre.childNodes.length > 2000) pre.removeChild(pre.firstChild);
+    if ($("logs-follow").checked) pre.parentElement.scrollTop = pre.parentElement.scrollHeight;
+  }
+  $("logs-level").addEventListener("change", () => {
+    state.logCursor = "";
+    $("logs-body").textContent = "";
+    refreshLogs();
+  });
+
+  // ---- search ----
+
+  $("search-form").addEventListener("submit", async (ev) => {
+    ev.preventDefault();
+    const query = $("search-query").value.trim();
+    const kind = $("search-kind").value;
+    const out = $("search-results");
+    out.textContent = "";
+    try {
+      if (kind === "memory") {
+        const res = await call("memory.search", { query });
+        for (const m of res.entries || []) {
+          result(out, m.Key + " · " + (m.Category || "") + " · score " + (m.Score || 0).toFixed(2), m.Content);
+        }
+      } else if (kind === "tasks") {
+        const res = await call("tasks.search", { query });
+        for (const r of res.records || []) {
+          result(out, r.createdAt + " · " + r.action + "/" + r.module + " · " + r.status + " · " + r.sessionKey,
+            (r.requestBody || "") + (r.responseBody ? "\n→ " + r.responseBody : ""));
+        }
+      } else {
+        const res = await call("sessions.search", { query });
+        for (const h of res.hits || []) {
+          result(out, h.Label + " #" + h.Index + " · " + h.Role + " · " + fmtTime(h.Timestamp), h.Content);
+        }
+      }
+      if (!out.childNodes.length) out.appendChild(el("p", "", "No results."));
+    } catch (err) {
+      out.appendChild(el("p", "error", err.message));
+    }
+  });
+
+  function result(parent, meta, body) {
+    const d = el("div", "result");
+    d.appendChild(el("div", "meta", meta));
+    d.appendChild(el("div", "body", body));
+    parent.appendChild(d);
+  }
+
+  // ---- approvals ----
+
+  async function refreshApprovals() {
+    const body = $("approvals-body");
+    let res;
+    try { res = await call("approvals.list", {}); } catch (err) {
+      body.textContent = err.message;
+      return;
+    }
+    const items = res.approvals || [];
+    const badge = $("approval-count");
+    badge.textContent = String(items.length);
+    badge.classList.toggle("hidden", items.length === 0);
+    body.textContent = "";
+    if (!items.length) body.appendChild(el("p", "", "No pending approvals."));
+    for (const a of items) {
+      const row = el("div", "approval");
+      const info = el("div");
+      info.appendChild(el("div", "meta", a.id + " · " + (a.kind || "command") + " · " + a.requester + " · " + fmtTime(Date.parse(a.createdAt))));
+      if (a.reason) info.appendChild(el("div", "meta", a.reason));
+      row.appendChild(info);
+      row.appendChild(el("pre", "", a.command));
+      const actions = el("div", "actions");
+      for (const [label, approve] of [["Approve", true], ["Deny", false]]) {
+        const b = el("button", "", label);
+        b.addEventListener("click", async () => {
+          try {
+            await call("approvals.resolve", { id: a.id, approve });
+            refreshApprovals();
+          } catch (err) { alert(err.message); }
+        });
+        actions.appendChild(b);
+      }
+      row.appendChild(actions);
+      body.appendChild(row);
+    }
+  }
+  $("approvals-refresh").addEventListener("click", refreshApprovals);
+  setInterval(() => { if (state.ws && state.ws.readyState === WebSocket.OPEN) refreshApprovals(); }, 15000);
+
+  boot();
+})();
@@ -0,0 +1,20 @@
+// Package web bundles the single-page web console served by the gateway.
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// Handler 返回控制台静态资源（index.html + assets/）的 HTTP handler
+func Handler() http.Handler {
+	sub, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServer(http.FS(sub))
+}
@@ -0,0 +1,136 @@
+package security
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// dlpMinLiteralLen 已配置密钥参与精确匹配的最小长度，避免 "admin" 这类短口令误伤正常文本
+const dlpMinLiteralLen = 10
+
+type dlpRule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// 内置规则按顺序执行：更具体的规则在前（sk-ant- 先于 sk-），已替换的内容不会被后续规则重复命中
+var builtinDLPRules = []dlpRule{
+	{"private_key", regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----(?s:.*?)(?:-----END [A-Z0-9 ]*PRIVATE KEY-----|$)`)},
+	{"anthropic_api_key", regexp.MustCompile(`sk-ant-[A-Za-z0-9_\-]{20,}`)},
+	{"openai_api_key", regexp.MustCompile(`\bsk-(?:proj-|svcacct-)?[A-Za-z0-9_\-]{20,}`)},
+	{"slack_token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9\-]{10,}`)},
+	{"github_token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`)},
+	{"aws_access_key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"google_api_key", regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}`)},
+	{"telegram_bot_token", regexp.MustCompile(`\b[0-9]{8,10}:AA[A-Za-z0-9_\-]{33}\b`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]{8,}\.eyJ[A-Za-z0-9_\-]{8,}\.[A-Za-z0-9_\-]{8,}`)},
+}
+
+// DLPHit 一次 DLP 命中；Sample 只保留首尾少量字符，可安全写入日志
+type DLPHit struct {
+	Rule   string `json:"rule"`
+	Source string `json:"source"`
+	Sample string `json:"sample"`
+	Action string `json:"action,omitempty"` // 命中后执行的处理方式：mask / block / approve
+}
+
+// DLPScanner 检测文本中的 API key、私钥、已配置的密钥值以及自定义规则命中的内容
+type DLPScanner struct {
+	rules    []dlpRule
+	literals []dlpLiteral
+}
+
+type dlpLiteral struct {
+	name  string
+	value string
+}
+
+// NewDLPScanner 创建扫描器。patterns 为自定义规则（name → 正则），
+// literals 为需要精确匹配的已知密钥（name → 值），过短的值会被忽略。
+func NewDLPScanner(patterns map[string]string, literals map[string]string) (*DLPScanner, error) {
+	s := &DLPScanner{rules: append([]dlpRule(nil), builtinDLPRules...)}
+	names := make([]string, 0, len(patterns))
+	for name := range patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		re, err := regexp.Compile(patterns[name])
+		if err != nil {
+			return nil, fmt.Errorf("dlp pattern %q: %w", name, err)
+		}
+		s.rules = append(s.rules, dlpRule{name: name, re: re})
+	}
+	for name, value := range literals {
+		value = strings.TrimSpace(value)
+		if len(value) < dlpMinLiteralLen {
+			continue
+		}
+		s.literals = append(s.literals, dlpLiteral{name: name, value: value})
+	}
+	// 长的先替换，避免一个密钥是另一个的前缀时只替换一半
+	sort.Slice(s.literals, func(i, j int) bool {
+		if len(s.literals[i].value) != len(s.literals[j].value) {
+			return len(s.literals[i].value) > len(s.literals[j].value)
+		}
+		return s.literals[i].name < s.literals[j].name
+	})
+	return s, nil
+}
+
+// Redact 返回替换为 [REDACTED:<rule>] 后的文本及全部命中；source 标记文本来源（如 "reply"、"tool:shell"）
+func (s *DLPScanner) Redact(text, source string) (string, []DLPHit) {
+	if s == nil || text == "" {
+		return text, nil
+	}
+	var hits []DLPHit
+	for _, lit := range s.literals {
+		if !strings.Contains(text, lit.value) {
+			continue
+		}
+		n := strings.Count(text, lit.value)
+		for i := 0; i < n; i++ {
+			hits = append(hits, DLPHit{Rule: "config:" + lit.name, Source: source, Sample: dlpSample(lit.value)})
+		}
+		text = strings.ReplaceAll(text, lit.value, dlpMask("config:"+lit.name))
+	}
+	for _, rule := range s.rules {
+		text = rule.re.ReplaceAllStringFunc(text, func(m string) string {
+			hits = append(hits, DLPHit{Rule: rule.name, Source: source, Sample: dlpSample(m)})
+			return dlpMask(rule.name)
+		})
+	}
+	return text, hits
+}
+
+// DLPRuleNames 返回命中涉及的规则名（去重，保持首次出现顺序）
+func DLPRuleNames(hits []DLPHit) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, h := range hits {
+		if !seen[h.Rule] {
+			seen[h.Rule] = true
+			out = append(out, h.Rule)
+		}
+	}
+	return out
+}
+
+func dlpMask(rule string) string {
+	return "[REDACTED:" + rule + "]"
+}
+
+func dlpSample(value string) string {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "-----BEGIN") {
+		if i := strings.Index(value[5:], "-----"); i >= 0 {
+			return value[:i+10] + "…"
+		}
+	}
+	if len(value) <= 8 {
+		return strings.Repeat("*", len(value))
+	}
+	return fmt.Sprintf("%s…%s (%d chars)", value[:4], value[len(value)-2:], len(value))
+}
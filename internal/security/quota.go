@@ -0,0 +1,220 @@
+package security
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/highclaw/highclaw/internal/config"
+	"github.com/highclaw/highclaw/internal/system/tasklog"
+)
+
+const (
+	defaultQuotaWindow = time.Hour
+	// quotaNotifyInterval 同一规则、同一 ID 超限后通知管理员的最小间隔
+	quotaNotifyInterval = time.Hour
+)
+
+// UsageStore 配额的 token / 费用计数来源，由 tasklog.Store 实现
+type UsageStore interface {
+	Usage(f tasklog.UsageFilter) (tasklog.Usage, error)
+}
+
+// QuotaSubject 一条入站消息的计数维度
+type QuotaSubject struct {
+	Channel string
+	Sender  string
+	GroupID string
+	AgentID string
+}
+
+// QuotaExceeded 描述触发的配额
+type QuotaExceeded struct {
+	Scope string // sender / group / channel / agent
+	Key   string // 被限制的 ID
+	Limit string // 人类可读的配额，如 "20 messages per 1h"
+	Used  string // 当前用量
+	// Notify 是否需要通知管理员（同一规则和 ID 每小时最多一次）
+	Notify bool
+}
+
+// QuotaGuard 按 config.QuotasConfig 检查消息数、每日 token 和每月费用。
+// 消息数用内存滑动窗口计数；token 和费用从 tasklog 累计，重启后依然有效。
+// 规则每次从传入的配置读取，配置重载后立即生效。
+type QuotaGuard struct {
+	usage UsageStore
+
+	mu       sync.Mutex
+	limiters map[string]*SlidingWindowLimiter
+	notified map[string]time.Time
+}
+
+// NewQuotaGuard 创建配额检查器；usage 为 nil 时只检查消息数
+func NewQuotaGuard(usage UsageStore) *QuotaGuard {
+	return &QuotaGuard{
+		usage:    usage,
+		limiters: map[string]*SlidingWindowLimiter{},
+		notified: map[string]time.Time{},
+	}
+}
+
+// Check 检查 subject 是否超出任一配额；未超出返回 nil，并计入一条消息
+func (g *QuotaGuard) Check(cfg config.QuotasConfig, s QuotaSubject) *QuotaExceeded {
+	if g == nil || len(cfg.Rules) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	type active struct {
+		rule config.QuotaRule
+		key  string
+	}
+	var rules []active
+	for _, r := range cfg.Rules {
+		if key, ok := quotaKey(r, s); ok {
+			rules = append(rules, active{r, key})
+		}
+	}
+
+	// 先查 token / 费用（无副作用），再逐条计入消息数
+	for _, a := range rules {
+		if a.rule.TokensPerDay <= 0 && a.rule.CostPerMonth <= 0 {
+			continue
+		}
+		if g.usage == nil {
+			continue
+		}
+		filter := quotaFilter(a.rule, a.key, s)
+		if a.rule.TokensPerDay > 0 {
+			filter.Since = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+			if u, err := g.usage.Usage(filter); err == nil && u.Tokens >= int64(a.rule.TokensPerDay) {
+				return g.exceeded(a.rule, a.key, fmt.Sprintf("%d tokens per day", a.rule.TokensPerDay), fmt.Sprintf("%d tokens", u.Tokens))
+			}
+		}
+		if a.rule.CostPerMonth > 0 {
+			filter.Since = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+			if u, err := g.usage.Usage(filter); err == nil && u.CostUSD >= a.rule.CostPerMonth {
+				return g.exceeded(a.rule, a.key, fmt.Sprintf("$%.2f per month", a.rule.CostPerMonth), fmt.Sprintf("$%.2f", u.CostUSD))
+			}
+		}
+	}
+	for _, a := range rules {
+		if a.rule.Messages <= 0 {
+			continue
+		}
+		window := QuotaWindow(a.rule)
+		if !g.limiter(a.rule, window).Allow(a.key) {
+			return g.exceeded(a.rule, a.key, fmt.Sprintf("%d messages per %s", a.rule.Messages, formatQuotaWindow(window)), "")
+		}
+	}
+	return nil
+}
+
+func (g *QuotaGuard) limiter(r config.QuotaRule, window time.Duration) *SlidingWindowLimiter {
+	id := fmt.Sprintf("%s|%s|%s|%d|%s", r.Scope, r.Channel, r.Match, r.Messages, window)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	l, ok := g.limiters[id]
+	if !ok {
+		l = NewSlidingWindowLimiter(r.Messages, window)
+		g.limiters[id] = l
+	}
+	return l
+}
+
+func (g *QuotaGuard) exceeded(r config.QuotaRule, key, limit, used string) *QuotaExceeded {
+	e := &QuotaExceeded{Scope: QuotaScope(r), Key: key, Limit: limit, Used: used}
+	id := e.Scope + "|" + key + "|" + limit
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if last, ok := g.notified[id]; !ok || time.Since(last) >= quotaNotifyInterval {
+		g.notified[id] = time.Now()
+		e.Notify = true
+	}
+	return e
+}
+
+// QuotaScope 返回规则的计数维度（小写，默认 sender）
+func QuotaScope(r config.QuotaRule) string {
+	scope := strings.ToLower(strings.TrimSpace(r.Scope))
+	if scope == "" {
+		return "sender"
+	}
+	return scope
+}
+
+// QuotaWindow 返回消息数窗口，未配置或无效时为 1h
+func QuotaWindow(r config.QuotaRule) time.Duration {
+	if d, err := time.ParseDuration(strings.TrimSpace(r.Window)); err == nil && d > 0 {
+		return d
+	}
+	return defaultQuotaWindow
+}
+
+// QuotaUsageColumn 返回规则维度对应的 tasklog 分组列
+func QuotaUsageColumn(r config.QuotaRule) string {
+	switch QuotaScope(r) {
+	case "group":
+		return "group_id"
+	case "channel":
+		return "channel"
+	case "agent":
+		return "agent_id"
+	}
+	return "sender"
+}
+
+// quotaKey 返回 subject 在规则维度上的 ID；规则不适用时返回 false
+func quotaKey(r config.QuotaRule, s QuotaSubject) (string, bool) {
+	if ch := strings.TrimSpace(r.Channel); ch != "" && ch != "*" && !strings.EqualFold(ch, s.Channel) {
+		return "", false
+	}
+	var key string
+	switch QuotaScope(r) {
+	case "sender":
+		key = s.Sender
+	case "group":
+		key = s.GroupID
+	case "channel":
+		key = s.Channel
+	case "agent":
+		key = s.AgentID
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", false
+	}
+	if m := strings.TrimSpace(r.Match); m != "" && m != "*" && m != key {
+		return "", false
+	}
+	return key, true
+}
+
+func quotaFilter(r config.QuotaRule, key string, s QuotaSubject) tasklog.UsageFilter {
+	var f tasklog.UsageFilter
+	if ch := strings.TrimSpace(r.Channel); ch != "" && ch != "*" {
+		f.Channel = s.Channel
+	}
+	switch QuotaScope(r) {
+	case "sender":
+		f.Sender = key
+	case "group":
+		f.GroupID = key
+	case "channel":
+		f.Channel = key
+	case "agent":
+		f.AgentID = key
+	}
+	return f
+}
+
+func formatQuotaWindow(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,76 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/highclaw/highclaw/internal/config"
+	"github.com/highclaw/highclaw/internal/system/tasklog"
+)
+
+func newQuotaStore(t *testing.T) *tasklog.Store {
+	t.Helper()
+	store, err := tasklog.NewStore(tasklog.Config{Dir: t.TempDir(), Enabled: true})
+	if err != nil {
+		t.Fatalf("tasklog: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func TestQuotaMessagesPerWindow(t *testing.T) {
+	g := NewQuotaGuard(nil)
+	quotas := config.QuotasConfig{Rules: []config.QuotaRule{{Scope: "sender", Channel: "feishu", Messages: 2, Window: "1m"}}}
+	alice := QuotaSubject{Channel: "feishu", Sender: "ou_alice"}
+
+	for i := 0; i < 2; i++ {
+		if hit := g.Check(quotas, alice); hit != nil {
+			t.Fatalf("message %d should be allowed: %+v", i+1, hit)
+		}
+	}
+	hit := g.Check(quotas, alice)
+	if hit == nil || hit.Key != "ou_alice" || hit.Limit != "2 messages per 1m" || !hit.Notify {
+		t.Fatalf("third message should be rejected with admin notification, got %+v", hit)
+	}
+	if again := g.Check(quotas, alice); again == nil || again.Notify {
+		t.Fatalf("admins are notified once per interval, got %+v", again)
+	}
+	if hit := g.Check(quotas, QuotaSubject{Channel: "feishu", Sender: "ou_bob"}); hit != nil {
+		t.Fatalf("senders are counted separately: %+v", hit)
+	}
+	if hit := g.Check(quotas, QuotaSubject{Channel: "telegram", Sender: "ou_alice"}); hit != nil {
+		t.Fatalf("rule is limited to feishu: %+v", hit)
+	}
+}
+
+func TestQuotaTokensAndCostFromTasklog(t *testing.T) {
+	store := newQuotaStore(t)
+	for _, rec := range []*tasklog.TaskRecord{
+		{Action: tasklog.ActionChat, Channel: "feishu", Sender: "ou_a", GroupID: "oc_1", TokensInput: 600, TokensOutput: 500, CostUSD: 0.4},
+		{Action: tasklog.ActionChat, Channel: "feishu", Sender: "ou_b", GroupID: "oc_1", TokensInput: 100, TokensOutput: 50, CostUSD: 0.7},
+		{Action: tasklog.ActionTool, Channel: "feishu", Sender: "ou_b", GroupID: "oc_1", TokensInput: 5000},
+	} {
+		if err := store.Log(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g := NewQuotaGuard(store)
+
+	tokens := config.QuotasConfig{Rules: []config.QuotaRule{{Scope: "sender", TokensPerDay: 1000}}}
+	if hit := g.Check(tokens, QuotaSubject{Channel: "feishu", Sender: "ou_a"}); hit == nil || hit.Used != "1100 tokens" {
+		t.Fatalf("ou_a is over the daily token quota, got %+v", hit)
+	}
+	if hit := g.Check(tokens, QuotaSubject{Channel: "feishu", Sender: "ou_b"}); hit != nil {
+		t.Fatalf("only chat records count towards token quotas: %+v", hit)
+	}
+
+	cost := config.QuotasConfig{Rules: []config.QuotaRule{{Scope: "group", Match: "oc_1", CostPerMonth: 1}}}
+	if hit := g.Check(cost, QuotaSubject{Channel: "feishu", Sender: "ou_c", GroupID: "oc_1"}); hit == nil || hit.Scope != "group" {
+		t.Fatalf("group oc_1 is over its monthly budget, got %+v", hit)
+	}
+	if hit := g.Check(cost, QuotaSubject{Channel: "feishu", Sender: "ou_c", GroupID: "oc_2"}); hit != nil {
+		t.Fatalf("rule matches oc_1 only: %+v", hit)
+	}
+	if hit := g.Check(cost, QuotaSubject{Channel: "feishu", Sender: "ou_c"}); hit != nil {
+		t.Fatalf("direct messages have no group: %+v", hit)
+	}
+}
@@ -26,7 +26,9 @@ type Config struct {
 
 // Manager 管理日志文件生命周期
 type Manager struct {
-	cfg     Config
+	cfg Config
+	// level 当前最低级别，配置热加载时通过 SetLevel 修改
+	level   slog.LevelVar
 	mu      sync.Mutex
 	file    *os.File
 	curDate string
@@ -63,6 +65,7 @@ func New(cfg Config) (*Manager, error) {
 		return nil, fmt.Errorf("create log dir: %w", err)
 	}
 	m := &Manager{cfg: cfg}
+	m.level.Set(cfg.Level)
 	if err := m.rotateIfNeeded(); err != nil {
 		return nil, err
 	}
@@ -72,10 +75,15 @@ func New(cfg Config) (*Manager, error) {
 // NewSlogHandler 创建写入日志文件的 slog.Handler
 func (m *Manager) NewSlogHandler() slog.Handler {
 	return slog.NewTextHandler(m, &slog.HandlerOptions{
-		Level: m.cfg.Level,
+		Level: &m.level,
 	})
 }
 
+// SetLevel 修改最低日志级别，对已创建的 handler 立即生效
+func (m *Manager) SetLevel(level slog.Level) {
+	m.level.Set(level)
+}
+
 // NewLogger 返回基于文件的 slog.Logger
 func (m *Manager) NewLogger() *slog.Logger {
 	return slog.New(m.NewSlogHandler())
@@ -0,0 +1,37 @@
+package metrics
+
+// HighClaw 导出的全部指标。标签取值保持低基数：渠道、agent、provider、模型、工具名。
+var (
+	MessagesIn = Default.NewCounterVec("highclaw_messages_received_total",
+		"Inbound messages received, by channel.", "channel")
+	MessagesOut = Default.NewCounterVec("highclaw_messages_sent_total",
+		"Replies sent back to users, by channel and status (success, error, quota).", "channel", "status")
+
+	RunDuration = Default.NewHistogramVec("highclaw_agent_run_duration_seconds",
+		"End-to-end agent run latency.", nil, "agent", "status")
+
+	ModelCallDuration = Default.NewHistogramVec("highclaw_model_call_duration_seconds",
+		"Latency of individual provider calls, including failed attempts.", nil, "provider", "model")
+	ModelCallErrors = Default.NewCounterVec("highclaw_model_call_errors_total",
+		"Failed provider calls.", "provider", "model")
+	ModelRetries = Default.NewCounterVec("highclaw_model_retries_total",
+		"Provider call retries after a failed attempt.", "provider")
+	ModelFallbacks = Default.NewCounterVec("highclaw_model_fallbacks_total",
+		"Switches from a failing provider to the next fallback provider.", "from")
+
+	ToolExecutions = Default.NewCounterVec("highclaw_tool_executions_total",
+		"Tool executions, by tool and status (success, error).", "tool", "status")
+	ToolDuration = Default.NewHistogramVec("highclaw_tool_duration_seconds",
+		"Tool execution latency.", nil, "tool")
+
+	MemoryRecallDuration = Default.NewHistogramVec("highclaw_memory_recall_duration_seconds",
+		"Latency of memory recall when building the run context.", []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5})
+
+	Tokens = Default.NewCounterVec("highclaw_tokens_total",
+		"Tokens consumed, by provider/model and type (input, output, cache_read, cache_write).", "model", "type")
+	CostUSD = Default.NewCounterVec("highclaw_cost_usd_total",
+		"Model spend in USD, by provider/model.", "model")
+
+	ActiveSessions = Default.NewGaugeFunc("highclaw_active_sessions",
+		"Sessions currently held in memory by the gateway.", nil)
+)
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteTextFormat(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_requests_total", "Requests.", "channel", "status")
+	h := r.NewHistogramVec("test_latency_seconds", "Latency.", []float64{1, 0.1}, "provider")
+	g := r.NewGaugeFunc("test_sessions", "Sessions.", nil)
+
+	c.Inc("feishu", "success")
+	c.Add(2, "feishu", "success")
+	c.Inc(`we"ird`, "error")
+	h.Observe(0.05, "anthropic")
+	h.Observe(0.5, "anthropic")
+	h.Observe(3, "anthropic")
+
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE test_requests_total counter\n",
+		`test_requests_total{channel="feishu",status="success"} 3` + "\n",
+		`test_requests_total{channel="we\"ird",status="error"} 1` + "\n",
+		"# TYPE test_latency_seconds histogram\n",
+		`test_latency_seconds_bucket{provider="anthropic",le="0.1"} 1` + "\n",
+		`test_latency_seconds_bucket{provider="anthropic",le="1"} 2` + "\n",
+		`test_latency_seconds_bucket{provider="anthropic",le="+Inf"} 3` + "\n",
+		`test_latency_seconds_sum{provider="anthropic"} 3.55` + "\n",
+		`test_latency_seconds_count{provider="anthropic"} 3` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "test_sessions") {
+		t.Fatalf("gauge without a value function should be omitted:\n%s", out)
+	}
+
+	g.Set(func() float64 { return 4 })
+	buf.Reset()
+	r.WriteText(&buf)
+	if !strings.Contains(buf.String(), "# TYPE test_sessions gauge\ntest_sessions 4\n") {
+		t.Fatalf("gauge not written:\n%s", buf.String())
+	}
+}
@@ -17,16 +17,19 @@ import (
 
 // ActionType 操作类型枚举
 const (
-	ActionChat    = "chat"    // 聊天消息
-	ActionCreate  = "create"  // 新建操作
-	ActionRead    = "read"    // 查询操作
-	ActionUpdate  = "update"  // 更新操作
-	ActionDelete  = "delete"  // 删除操作
-	ActionTool    = "tool"    // 工具执行
-	ActionSystem  = "system"  // 系统操作（启动、停止等）
-	ActionConfig  = "config"  // 配置变更
-	ActionMemory  = "memory"  // 记忆操作
-	ActionChannel = "channel" // 渠道操作
+	ActionChat      = "chat"      // 聊天消息
+	ActionCreate    = "create"    // 新建操作
+	ActionRead      = "read"      // 查询操作
+	ActionUpdate    = "update"    // 更新操作
+	ActionDelete    = "delete"    // 删除操作
+	ActionTool      = "tool"      // 工具执行
+	ActionSystem    = "system"    // 系统操作（启动、停止等）
+	ActionConfig    = "config"    // 配置变更
+	ActionMemory    = "memory"    // 记忆操作
+	ActionChannel   = "channel"   // 渠道操作
+	ActionDLP       = "dlp"       // 出站数据防泄漏命中
+	ActionQuota     = "quota"     // 超出用量配额被拒绝
+	ActionInjection = "injection" // 疑似提示词注入 / taint 拦截
 )
 
 // Config 任务日志配置
@@ -39,21 +42,33 @@ type Config struct {
 
 // TaskRecord 单条任务记录
 type TaskRecord struct {
-	ID           int64  `json:"id"`
-	Action       string `json:"action"`       // 操作类型
-	Module       string `json:"module"`       // 所属模块
-	SessionKey   string `json:"sessionKey"`   // 会话标识
-	Channel      string `json:"channel"`      // 渠道
-	Sender       string `json:"sender"`       // 发送者
-	RequestBody  string `json:"requestBody"`  // 请求详情
-	ResponseBody string `json:"responseBody"` // 响应详情
-	Status       string `json:"status"`       // success / error
-	ErrorMessage string `json:"errorMessage"` // 错误信息
-	DurationMs   int64  `json:"durationMs"`   // 执行耗时（毫秒）
-	TokensInput  int    `json:"tokensInput"`  // 输入 token 数
-	TokensOutput int    `json:"tokensOutput"` // 输出 token 数
-	Model        string `json:"model"`        // 使用的模型
-	CreatedAt    string `json:"createdAt"`    // 创建时间
+	ID           int64   `json:"id"`
+	Action       string  `json:"action"`       // 操作类型
+	Module       string  `json:"module"`       // 所属模块
+	SessionKey   string  `json:"sessionKey"`   // 会话标识
+	Channel      string  `json:"channel"`      // 渠道
+	Sender       string  `json:"sender"`       // 发送者
+	GroupID      string  `json:"groupId"`      // 群聊 ID（私聊为空）
+	AgentID      string  `json:"agentId"`      // 处理消息的 agent
+	RequestBody  string  `json:"requestBody"`  // 请求详情
+	ResponseBody string  `json:"responseBody"` // 响应详情
+	Status       string  `json:"status"`       // success / error
+	ErrorMessage string  `json:"errorMessage"` // 错误信息
+	DurationMs   int64   `json:"durationMs"`   // 执行耗时（毫秒）
+	TokensInput  int     `json:"tokensInput"`  // 输入 token 数
+	TokensOutput int     `json:"tokensOutput"` // 输出 token 数
+	CacheRead    int     `json:"cacheRead"`    // prompt cache 命中 token 数
+	CacheWrite   int     `json:"cacheWrite"`   // prompt cache 写入 token 数
+	CostUSD      float64 `json:"costUsd"`      // 本次费用（美元）
+	Model        string  `json:"model"`        // 使用的模型
+	CreatedAt    string  `json:"createdAt"`    // 创建时间
+	RunID        string  `json:"runId"`        // agent 运行 ID（聊天记录及其工具调用相同）
+	ParentID     int64   `json:"parentId"`     // 父记录 ID（工具调用指向所属聊天记录）
+	Tool         string  `json:"tool"`         // 工具名（仅 tool 记录）
+	Policy       string  `json:"policy"`       // 策略决定: allow / deny
+	Risk         string  `json:"risk"`         // 风险级别: low / medium / high / block
+	Approval     string  `json:"approval"`     // 审批状态，无需审批时为空
+	OutputHash   string  `json:"outputHash"`   // 工具输出摘要
 }
 
 // Store 任务日志存储引擎
@@ -128,6 +143,9 @@ CREATE TABLE IF NOT EXISTS task_records (
 	if _, err := db.Exec(ddl); err != nil {
 		return fmt.Errorf("create task_records table: %w", err)
 	}
+	if err := migrateColumns(db); err != nil {
+		return err
+	}
 
 	indices := []string{
 		"CREATE INDEX IF NOT EXISTS idx_task_records_created ON task_records(created_at DESC);",
@@ -136,6 +154,7 @@ CREATE TABLE IF NOT EXISTS task_records (
 		"CREATE INDEX IF NOT EXISTS idx_task_records_session ON task_records(session_key);",
 		"CREATE INDEX IF NOT EXISTS idx_task_records_channel ON task_records(channel);",
 		"CREATE INDEX IF NOT EXISTS idx_task_records_status ON task_records(status);",
+		"CREATE INDEX IF NOT EXISTS idx_task_records_run ON task_records(run_id);",
 	}
 	for _, idx := range indices {
 		_, _ = db.Exec(idx)
@@ -156,6 +175,56 @@ CREATE TABLE IF NOT EXISTS task_records (
 	return nil
 }
 
+// addedColumns 建表之后新增的列，旧库启动时通过 ALTER TABLE 补齐
+var addedColumns = []struct{ name, ddl string }{
+	{"tokens_cache_read", "tokens_cache_read INTEGER NOT NULL DEFAULT 0"},
+	{"tokens_cache_write", "tokens_cache_write INTEGER NOT NULL DEFAULT 0"},
+	{"group_id", "group_id TEXT NOT NULL DEFAULT ''"},
+	{"agent_id", "agent_id TEXT NOT NULL DEFAULT ''"},
+	{"cost_usd", "cost_usd REAL NOT NULL DEFAULT 0"},
+	{"run_id", "run_id TEXT NOT NULL DEFAULT ''"},
+	{"parent_id", "parent_id INTEGER NOT NULL DEFAULT 0"},
+	{"tool", "tool TEXT NOT NULL DEFAULT ''"},
+	{"policy", "policy TEXT NOT NULL DEFAULT ''"},
+	{"risk", "risk TEXT NOT NULL DEFAULT ''"},
+	{"approval", "approval TEXT NOT NULL DEFAULT ''"},
+	{"output_hash", "output_hash TEXT NOT NULL DEFAULT ''"},
+}
+
+// recordColumns SELECT 使用的列顺序，与 scanRecord 保持一致
+const recordColumns = "id, action, module, session_key, channel, sender, group_id, agent_id, request_body, response_body, status, error_message, duration_ms, tokens_input, tokens_output, tokens_cache_read, tokens_cache_write, cost_usd, model, created_at, run_id, parent_id, tool, policy, risk, approval, output_hash"
+
+func migrateColumns(db *sql.DB) error {
+	rows, err := db.Query("PRAGMA table_info(task_records)")
+	if err != nil {
+		return fmt.Errorf("inspect task_records: %w", err)
+	}
+	existing := map[string]bool{}
+	for rows.Next() {
+		var (
+			cid     int
+			name    string
+			ctype   string
+			notNull int
+			dflt    sql.NullString
+			pk      int
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &dflt, &pk); err == nil {
+			existing[name] = true
+		}
+	}
+	rows.Close()
+	for _, c := range addedColumns {
+		if existing[c.name] {
+			continue
+		}
+		if _, err := db.Exec("ALTER TABLE task_records ADD COLUMN " + c.ddl); err != nil {
+			return fmt.Errorf("migrate task_records.%s: %w", c.name, err)
+		}
+	}
+	return nil
+}
+
 func (s *Store) openDB() (*sql.DB, error) {
 	if s.db != nil {
 		return s.db, nil
@@ -188,11 +257,12 @@ func (s *Store) Log(rec *TaskRecord) error {
 	}
 
 	result, err := db.Exec(
-		`INSERT INTO task_records(action, module, session_key, channel, sender, request_body, response_body, status, error_message, duration_ms, tokens_input, tokens_output, model, created_at)
-		 VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
-		rec.Action, rec.Module, rec.SessionKey, rec.Channel, rec.Sender,
+		`INSERT INTO task_records(action, module, session_key, channel, sender, group_id, agent_id, request_body, response_body, status, error_message, duration_ms, tokens_input, tokens_output, tokens_cache_read, tokens_cache_write, cost_usd, model, created_at, run_id, parent_id, tool, policy, risk, approval, output_hash)
+		 VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		rec.Action, rec.Module, rec.SessionKey, rec.Channel, rec.Sender, rec.GroupID, rec.AgentID,
 		rec.RequestBody, rec.ResponseBody, rec.Status, rec.ErrorMessage,
-		rec.DurationMs, rec.TokensInput, rec.TokensOutput, rec.Model, rec.CreatedAt,
+		rec.DurationMs, rec.TokensInput, rec.TokensOutput, rec.CacheRead, rec.CacheWrite, rec.CostUSD, rec.Model, rec.CreatedAt,
+		rec.RunID, rec.ParentID, rec.Tool, rec.Policy, rec.Risk, rec.Approval, rec.OutputHash,
 	)
 	if err != nil {
 		return err
@@ -211,10 +281,7 @@ func (s *Store) Get(id int64) (*TaskRecord, error) {
 		return nil, err
 	}
 
-	row := db.QueryRow(
-		`SELECT id, action, module, session_key, channel, sender, request_body, response_body, status, error_message, duration_ms, tokens_input, tokens_output, model, created_at
-		 FROM task_records WHERE id=?`, id,
-	)
+	row := db.QueryRow("SELECT "+recordColumns+" FROM task_records WHERE id=?", id)
 	return scanRecord(row)
 }
 
@@ -223,6 +290,7 @@ type QueryParams struct {
 	Action     string // 按操作类型过滤
 	Module     string // 按模块过滤
 	SessionKey string // 按会话过滤
+	RunID      string // 按运行 ID 过滤
 	Channel    string // 按渠道过滤
 	Status     string // 按状态过滤
 	Search     string // 全文搜索
@@ -263,6 +331,10 @@ func (s *Store) Query(p QueryParams) ([]TaskRecord, int, error) {
 		conditions = append(conditions, "session_key=?")
 		args = append(args, p.SessionKey)
 	}
+	if p.RunID != "" {
+		conditions = append(conditions, "run_id=?")
+		args = append(args, p.RunID)
+	}
 	if p.Channel != "" {
 		conditions = append(conditions, "channel=?")
 		args = append(args, p.Channel)
@@ -314,7 +386,7 @@ func (s *Store) Query(p QueryParams) ([]TaskRecord, int, error) {
 	}
 
 	// 查询数据
-	query := "SELECT id, action, module, session_key, channel, sender, request_body, response_body, status, error_message, duration_ms, tokens_input, tokens_output, model, created_at FROM task_records" + where + " ORDER BY " + sortCol + " " + sortDir + " LIMIT ? OFFSET ?"
+	query := "SELECT " + recordColumns + " FROM task_records" + where + " ORDER BY " + sortCol + " " + sortDir + " LIMIT ? OFFSET ?"
 	args = append(args, p.Limit, p.Offset)
 
 	rows, err := db.Query(query, args...)
@@ -325,22 +397,49 @@ func (s *Store) Query(p QueryParams) ([]TaskRecord, int, error) {
 
 	var records []TaskRecord
 	for rows.Next() {
-		var r TaskRecord
-		if err := rows.Scan(&r.ID, &r.Action, &r.Module, &r.SessionKey, &r.Channel, &r.Sender,
-			&r.RequestBody, &r.ResponseBody, &r.Status, &r.ErrorMessage,
-			&r.DurationMs, &r.TokensInput, &r.TokensOutput, &r.Model, &r.CreatedAt); err != nil {
+		r, err := scanRecord(rows)
+		if err != nil || r == nil {
 			continue
 		}
-		records = append(records, r)
+		records = append(records, *r)
 	}
 	return records, total, rows.Err()
 }
 
+// Run 返回同一次 agent 运行的全部记录（聊天记录及其工具调用），按写入顺序排列
+func (s *Store) Run(runID string) ([]TaskRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	db, err := s.openDB()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT "+recordColumns+" FROM task_records WHERE run_id=? ORDER BY id", runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []TaskRecord
+	for rows.Next() {
+		r, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, *r)
+	}
+	return records, rows.Err()
+}
+
 // Stats 返回统计信息
 type Stats struct {
-	TotalRecords   int            `json:"totalRecords"`
-	TotalTokensIn  int64          `json:"totalTokensIn"`
-	TotalTokensOut int64          `json:"totalTokensOut"`
+	TotalRecords    int   `json:"totalRecords"`
+	TotalTokensIn   int64 `json:"totalTokensIn"`
+	TotalTokensOut  int64 `json:"totalTokensOut"`
+	TotalCacheRead  int64 `json:"totalCacheRead"`
+	TotalCacheWrite int64 `json:"totalCacheWrite"`
+	// CacheHitRate 缓存命中 token 占全部输入 token（未命中 + 命中 + 写入）的比例
+	CacheHitRate   float64        `json:"cacheHitRate"`
 	ByAction       map[string]int `json:"byAction"`
 	ByModule       map[string]int `json:"byModule"`
 	ByStatus       map[string]int `json:"byStatus"`
@@ -368,6 +467,10 @@ func (s *Store) GetStats() (*Stats, error) {
 	_ = db.QueryRow("SELECT COUNT(*) FROM task_records").Scan(&st.TotalRecords)
 	_ = db.QueryRow("SELECT COALESCE(SUM(tokens_input),0) FROM task_records").Scan(&st.TotalTokensIn)
 	_ = db.QueryRow("SELECT COALESCE(SUM(tokens_output),0) FROM task_records").Scan(&st.TotalTokensOut)
+	_ = db.QueryRow("SELECT COALESCE(SUM(tokens_cache_read),0), COALESCE(SUM(tokens_cache_write),0) FROM task_records").Scan(&st.TotalCacheRead, &st.TotalCacheWrite)
+	if prompt := st.TotalTokensIn + st.TotalCacheRead + st.TotalCacheWrite; prompt > 0 {
+		st.CacheHitRate = float64(st.TotalCacheRead) / float64(prompt)
+	}
 	_ = db.QueryRow("SELECT COALESCE(AVG(duration_ms),0) FROM task_records WHERE duration_ms>0").Scan(&st.AvgDurationMs)
 	_ = db.QueryRow("SELECT COALESCE(MIN(created_at),'') FROM task_records").Scan(&st.EarliestRecord)
 	_ = db.QueryRow("SELECT COALESCE(MAX(created_at),'') FROM task_records").Scan(&st.LatestRecord)
@@ -379,6 +482,149 @@ func (s *Store) GetStats() (*Stats, error) {
 	return st, nil
 }
 
+// UsageFilter 用量统计条件，空字段不过滤
+type UsageFilter struct {
+	Channel string
+	Sender  string
+	GroupID string
+	AgentID string
+	Since   time.Time
+}
+
+// Usage 聊天记录的用量累计
+type Usage struct {
+	Messages int     `json:"messages"`
+	Tokens   int64   `json:"tokens"` // 输入 + 输出
+	CostUSD  float64 `json:"costUsd"`
+}
+
+// usageColumns UsageBy 允许的分组列
+var usageColumns = map[string]bool{"sender": true, "group_id": true, "channel": true, "agent_id": true}
+
+func (f UsageFilter) where() (string, []any) {
+	conds := []string{"action = ?"}
+	args := []any{ActionChat}
+	for _, c := range []struct{ col, val string }{
+		{"channel", f.Channel}, {"sender", f.Sender}, {"group_id", f.GroupID}, {"agent_id", f.AgentID},
+	} {
+		if c.val != "" {
+			conds = append(conds, c.col+" = ?")
+			args = append(args, c.val)
+		}
+	}
+	if !f.Since.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, f.Since.UTC().Format(time.RFC3339Nano))
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// Usage 统计满足条件的聊天消息数、token 和费用
+func (s *Store) Usage(f UsageFilter) (Usage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	db, err := s.openDB()
+	if err != nil {
+		return Usage{}, err
+	}
+	where, args := f.where()
+	var u Usage
+	err = db.QueryRow("SELECT COUNT(*), COALESCE(SUM(tokens_input+tokens_output),0), COALESCE(SUM(cost_usd),0) FROM task_records WHERE "+where, args...).
+		Scan(&u.Messages, &u.Tokens, &u.CostUSD)
+	return u, err
+}
+
+// UsageBy 按 column（sender / group_id / channel / agent_id）分组统计用量
+func (s *Store) UsageBy(column string, f UsageFilter) (map[string]Usage, error) {
+	if !usageColumns[column] {
+		return nil, fmt.Errorf("invalid usage column: %s", column)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	db, err := s.openDB()
+	if err != nil {
+		return nil, err
+	}
+	where, args := f.where()
+	rows, err := db.Query("SELECT "+column+", COUNT(*), COALESCE(SUM(tokens_input+tokens_output),0), COALESCE(SUM(cost_usd),0) FROM task_records WHERE "+where+" AND "+column+" != '' GROUP BY "+column, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]Usage{}
+	for rows.Next() {
+		var key string
+		var u Usage
+		if err := rows.Scan(&key, &u.Messages, &u.Tokens, &u.CostUSD); err != nil {
+			return nil, err
+		}
+		out[key] = u
+	}
+	return out, rows.Err()
+}
+
+// CostDimensions CostBreakdown 支持的分组维度
+var CostDimensions = []string{"day", "model", "channel", "sender", "session"}
+
+// costDimensionExprs 分组维度对应的 SQL 表达式（created_at 为 UTC RFC3339，前 10 位即日期）
+var costDimensionExprs = map[string]string{
+	"day":     "substr(created_at, 1, 10)",
+	"model":   "model",
+	"channel": "channel",
+	"sender":  "sender",
+	"session": "session_key",
+}
+
+// CostRow 费用明细中的一行
+type CostRow struct {
+	Key        string  `json:"key"`
+	Runs       int     `json:"runs"`
+	TokensIn   int64   `json:"tokensIn"`
+	TokensOut  int64   `json:"tokensOut"`
+	CacheRead  int64   `json:"cacheRead"`
+	CacheWrite int64   `json:"cacheWrite"`
+	CostUSD    float64 `json:"costUsd"`
+}
+
+// CostBreakdown 按维度统计 since 以来聊天记录的 token 和费用；
+// day 按日期升序，其余维度按费用降序
+func (s *Store) CostBreakdown(by string, since time.Time) ([]CostRow, error) {
+	expr, ok := costDimensionExprs[by]
+	if !ok {
+		return nil, fmt.Errorf("invalid cost dimension: %s (valid: %s)", by, strings.Join(CostDimensions, ", "))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	db, err := s.openDB()
+	if err != nil {
+		return nil, err
+	}
+	where, args := UsageFilter{Since: since}.where()
+	order := "SUM(cost_usd) DESC, k"
+	if by == "day" {
+		order = "k"
+	}
+	rows, err := db.Query("SELECT "+expr+" AS k, COUNT(*), COALESCE(SUM(tokens_input),0), COALESCE(SUM(tokens_output),0), "+
+		"COALESCE(SUM(tokens_cache_read),0), COALESCE(SUM(tokens_cache_write),0), COALESCE(SUM(cost_usd),0) "+
+		"FROM task_records WHERE "+where+" GROUP BY k ORDER BY "+order, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []CostRow
+	for rows.Next() {
+		var r CostRow
+		if err := rows.Scan(&r.Key, &r.Runs, &r.TokensIn, &r.TokensOut, &r.CacheRead, &r.CacheWrite, &r.CostUSD); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
 // Cleanup 清理过期记录
 func (s *Store) Cleanup(maxAgeDays, maxRecords int) (int64, error) {
 	s.mu.Lock()
@@ -452,11 +698,12 @@ func (s *Store) DBPath() string {
 	return s.dbPath
 }
 
-func scanRecord(row *sql.Row) (*TaskRecord, error) {
+func scanRecord(row interface{ Scan(dest ...any) error }) (*TaskRecord, error) {
 	var r TaskRecord
-	err := row.Scan(&r.ID, &r.Action, &r.Module, &r.SessionKey, &r.Channel, &r.Sender,
+	err := row.Scan(&r.ID, &r.Action, &r.Module, &r.SessionKey, &r.Channel, &r.Sender, &r.GroupID, &r.AgentID,
 		&r.RequestBody, &r.ResponseBody, &r.Status, &r.ErrorMessage,
-		&r.DurationMs, &r.TokensInput, &r.TokensOutput, &r.Model, &r.CreatedAt)
+		&r.DurationMs, &r.TokensInput, &r.TokensOutput, &r.CacheRead, &r.CacheWrite, &r.CostUSD, &r.Model, &r.CreatedAt,
+		&r.RunID, &r.ParentID, &r.Tool, &r.Policy, &r.Risk, &r.Approval, &r.OutputHash)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
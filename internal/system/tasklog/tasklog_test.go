@@ -0,0 +1,40 @@
+package tasklog
+
+import "testing"
+
+func TestRunReturnsChatAndToolRecords(t *testing.T) {
+	store, err := NewStore(Config{Dir: t.TempDir(), Enabled: true})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+
+	chat := &TaskRecord{Action: ActionChat, Module: "agent", SessionKey: "s", RunID: "run_1", RequestBody: "hi"}
+	if err := store.Log(chat); err != nil {
+		t.Fatalf("log chat: %v", err)
+	}
+	for _, rec := range []*TaskRecord{
+		{Action: ActionTool, RunID: "run_1", ParentID: chat.ID, Tool: "shell", Policy: "deny", Risk: "high", Approval: "required", Status: "error"},
+		{Action: ActionTool, RunID: "run_1", ParentID: chat.ID, Tool: "read_file", Policy: "allow", Risk: "low", OutputHash: "abcd"},
+		{Action: ActionTool, RunID: "run_2", Tool: "shell"},
+	} {
+		if err := store.Log(rec); err != nil {
+			t.Fatalf("log tool: %v", err)
+		}
+	}
+
+	records, err := store.Run("run_1")
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(records) != 3 || records[0].ID != chat.ID {
+		t.Fatalf("expected chat record followed by 2 tool calls, got %+v", records)
+	}
+	shell := records[1]
+	if shell.ParentID != chat.ID || shell.Tool != "shell" || shell.Policy != "deny" || shell.Risk != "high" || shell.Approval != "required" {
+		t.Fatalf("tool audit fields not persisted: %+v", shell)
+	}
+	if records[2].OutputHash != "abcd" {
+		t.Fatalf("output hash not persisted: %+v", records[2])
+	}
+}
@@ -0,0 +1,304 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 导出方式
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp"
+	ExporterFile = "file"
+)
+
+// recentLimit 内存中保留的最近 trace 数
+const recentLimit = 100
+
+// Config 追踪导出配置
+type Config struct {
+	Exporter    string            // none | otlp | file
+	Endpoint    string            // OTLP/HTTP 地址，如 http://localhost:4318
+	Headers     map[string]string // OTLP 请求头（如鉴权）
+	File        string            // JSONL 文件路径，默认 ~/.highclaw/traces/traces.jsonl
+	ServiceName string            // service.name 资源属性，默认 highclaw
+}
+
+// Exporter 导出一条完整 trace
+type Exporter interface {
+	Export(ctx context.Context, t *Trace) error
+}
+
+var (
+	mu     sync.RWMutex
+	queue  chan *Trace
+	done   chan struct{}
+	logger = slog.Default()
+
+	recentMu sync.Mutex
+	recent   []*Trace
+)
+
+// Configure 按配置启动导出；重复调用会先停掉之前的导出器（配置热更新）
+func Configure(cfg Config, l *slog.Logger) error {
+	if l != nil {
+		mu.Lock()
+		logger = l
+		mu.Unlock()
+	}
+	var exp Exporter
+	switch strings.ToLower(strings.TrimSpace(cfg.Exporter)) {
+	case "", ExporterNone:
+	case ExporterOTLP:
+		if strings.TrimSpace(cfg.Endpoint) == "" {
+			return fmt.Errorf("tracing: otlp exporter requires an endpoint")
+		}
+		exp = NewOTLPExporter(cfg.Endpoint, cfg.Headers, cfg.ServiceName)
+	case ExporterFile:
+		path := strings.TrimSpace(cfg.File)
+		if path == "" {
+			path = DefaultFile()
+		}
+		exp = NewFileExporter(path)
+	default:
+		return fmt.Errorf("tracing: unknown exporter %q (expected none, otlp or file)", cfg.Exporter)
+	}
+
+	_ = Shutdown(context.Background())
+	if exp == nil {
+		return nil
+	}
+	mu.Lock()
+	queue = make(chan *Trace, 64)
+	done = make(chan struct{})
+	go exportLoop(exp, queue, done, logger)
+	mu.Unlock()
+	return nil
+}
+
+// Shutdown 停止导出并等待队列中的 trace 导出完成
+func Shutdown(ctx context.Context) error {
+	mu.Lock()
+	q, d := queue, done
+	queue, done = nil, nil
+	mu.Unlock()
+	if q == nil {
+		return nil
+	}
+	close(q)
+	select {
+	case <-d:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func exportLoop(exp Exporter, q <-chan *Trace, d chan<- struct{}, logger *slog.Logger) {
+	defer close(d)
+	for t := range q {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := exp.Export(ctx, t); err != nil {
+			logger.Warn("trace export failed", "trace", t.ID, "error", err)
+		}
+		cancel()
+	}
+}
+
+// complete 根 span 结束：写入最近缓存，并交给导出器（队列满时丢弃，不阻塞运行）
+func complete(t *Trace) {
+	recentMu.Lock()
+	recent = append(recent, t)
+	if len(recent) > recentLimit {
+		recent = recent[len(recent)-recentLimit:]
+	}
+	recentMu.Unlock()
+
+	mu.RLock()
+	defer mu.RUnlock()
+	if queue == nil {
+		return
+	}
+	select {
+	case queue <- t:
+	default:
+		logger.Warn("trace export queue full, dropping trace", "trace", t.ID)
+	}
+}
+
+func newTrace(id string, spans []SpanData) *Trace {
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].Start.Before(spans[j].Start) })
+	return &Trace{ID: id, Spans: spans}
+}
+
+// Lookup 按 ID 查找最近完成的 trace
+func Lookup(id string) (*Trace, bool) {
+	recentMu.Lock()
+	defer recentMu.Unlock()
+	for i := len(recent) - 1; i >= 0; i-- {
+		if recent[i].ID == id {
+			return recent[i], true
+		}
+	}
+	return nil, false
+}
+
+// DefaultFile 默认的 JSONL 追踪文件
+func DefaultFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".highclaw", "traces", "traces.jsonl")
+	}
+	return filepath.Join(home, ".highclaw", "traces", "traces.jsonl")
+}
+
+// FileExporter 每个 span 一行 JSON 追加到文件
+type FileExporter struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileExporter creates an exporter that appends spans to a JSONL file.
+func NewFileExporter(path string) *FileExporter {
+	return &FileExporter{path: path}
+}
+
+func (e *FileExporter) Export(_ context.Context, t *Trace) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(e.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(e.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for _, s := range t.Spans {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OTLPExporter 以 OTLP/HTTP JSON 格式发送到 collector 的 /v1/traces
+type OTLPExporter struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+}
+
+// NewOTLPExporter creates an OTLP/HTTP (JSON) exporter. endpoint may be the
+// collector base URL or the full /v1/traces URL.
+func NewOTLPExporter(endpoint string, headers map[string]string, serviceName string) *OTLPExporter {
+	endpoint = strings.TrimRight(strings.TrimSpace(endpoint), "/")
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint += "/v1/traces"
+	}
+	if strings.TrimSpace(serviceName) == "" {
+		serviceName = "highclaw"
+	}
+	return &OTLPExporter{
+		endpoint:    endpoint,
+		headers:     headers,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (e *OTLPExporter) Export(ctx context.Context, t *Trace) error {
+	spans := make([]map[string]any, 0, len(t.Spans))
+	for _, s := range t.Spans {
+		span := map[string]any{
+			"traceId":           s.TraceID,
+			"spanId":            s.SpanID,
+			"name":              s.Name,
+			"kind":              1, // SPAN_KIND_INTERNAL
+			"startTimeUnixNano": strconv.FormatInt(s.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.End.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.Attributes),
+			"status":            map[string]any{"code": 1}, // STATUS_CODE_OK
+		}
+		if s.ParentID != "" {
+			span["parentSpanId"] = s.ParentID
+		}
+		if s.Error != "" {
+			span["status"] = map[string]any{"code": 2, "message": s.Error} // STATUS_CODE_ERROR
+		}
+		spans = append(spans, span)
+	}
+	body, err := json.Marshal(map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": otlpAttributes(map[string]any{"service.name": e.serviceName})},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "github.com/highclaw/highclaw"},
+				"spans": spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("otlp endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// otlpAttributes 转成 OTLP KeyValue 列表（int 按规范编码为字符串）
+func otlpAttributes(attrs map[string]any) []map[string]any {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]map[string]any, 0, len(keys))
+	for _, k := range keys {
+		var v map[string]any
+		switch x := attrs[k].(type) {
+		case string:
+			v = map[string]any{"stringValue": x}
+		case bool:
+			v = map[string]any{"boolValue": x}
+		case int:
+			v = map[string]any{"intValue": strconv.Itoa(x)}
+		case int64:
+			v = map[string]any{"intValue": strconv.FormatInt(x, 10)}
+		case float64:
+			v = map[string]any{"doubleValue": x}
+		default:
+			v = map[string]any{"stringValue": fmt.Sprint(x)}
+		}
+		out = append(out, map[string]any{"key": k, "value": v})
+	}
+	return out
+}
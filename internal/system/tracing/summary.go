@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// summaryAttrs 摘要中展示的属性（按顺序），其余属性只导出不展示
+var summaryAttrs = []string{
+	"provider", "model", "attempt", "fallback", "tool", "iteration",
+	"input_tokens", "output_tokens", "prompt_tokens", "cost_usd", "results",
+}
+
+// Summary 以缩进树展示 trace，并统计模型调用和工具执行的总耗时
+func (t *Trace) Summary() string {
+	if t == nil || len(t.Spans) == 0 {
+		return ""
+	}
+	children := map[string][]SpanData{}
+	for _, s := range t.Spans {
+		children[s.ParentID] = append(children[s.ParentID], s)
+	}
+	root := t.Root()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "trace %s  total %s\n", t.ID, formatDuration(root.Duration()))
+	var walk func(s SpanData, depth int)
+	walk = func(s SpanData, depth int) {
+		fmt.Fprintf(&b, "%s%-*s %8s", strings.Repeat("  ", depth), 28-2*min(depth, 8), s.Name, formatDuration(s.Duration()))
+		for _, k := range summaryAttrs {
+			if v, ok := s.Attributes[k]; ok {
+				fmt.Fprintf(&b, "  %s=%v", k, v)
+			}
+		}
+		if s.Error != "" {
+			fmt.Fprintf(&b, "  error=%q", truncate(s.Error, 80))
+		}
+		b.WriteString("\n")
+		for _, c := range children[s.SpanID] {
+			walk(c, depth+1)
+		}
+	}
+	walk(root, 0)
+
+	var modelTime, toolTime time.Duration
+	var modelCalls, toolCalls int
+	for _, s := range t.Spans {
+		switch s.Name {
+		case "model.chat":
+			modelCalls++
+			modelTime += s.Duration()
+		case "tool.exec":
+			toolCalls++
+			toolTime += s.Duration()
+		}
+	}
+	fmt.Fprintf(&b, "model: %d call(s) %s · tools: %d call(s) %s", modelCalls, formatDuration(modelTime), toolCalls, formatDuration(toolTime))
+	return b.String()
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	case d >= time.Millisecond:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	default:
+		return fmt.Sprintf("%dµs", d.Microseconds())
+	}
+}
+
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len([]rune(s)) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "..."
+}
@@ -0,0 +1,170 @@
+// Package tracing 提供运行追踪：一次入站消息 / agent 运行产生一棵嵌套 span 树
+// （会话路由、系统提示词、记忆召回、每次模型调用及重试/fallback、每次工具执行）。
+// 根 span 结束后整棵树进入最近追踪缓存（供 CLI/TUI 展示摘要），并按配置导出到
+// OTLP/HTTP 端点或本地 JSONL 文件。未配置导出时只保留在内存中。
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SpanData 已结束 span 的快照，也是导出的数据结构
+type SpanData struct {
+	TraceID    string         `json:"traceId"`
+	SpanID     string         `json:"spanId"`
+	ParentID   string         `json:"parentSpanId,omitempty"`
+	Name       string         `json:"name"`
+	Start      time.Time      `json:"start"`
+	End        time.Time      `json:"end"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// Duration span 耗时
+func (s SpanData) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// Trace 一次完整追踪：根 span 结束时收集的全部 span（按开始时间排序）
+type Trace struct {
+	ID    string
+	Spans []SpanData
+}
+
+// Root 返回根 span
+func (t *Trace) Root() SpanData {
+	for _, s := range t.Spans {
+		if s.ParentID == "" {
+			return s
+		}
+	}
+	if len(t.Spans) > 0 {
+		return t.Spans[0]
+	}
+	return SpanData{}
+}
+
+// traceState 同一 trace 内所有 span 共享，收集已结束的 span
+type traceState struct {
+	mu    sync.Mutex
+	id    string
+	spans []SpanData
+}
+
+// Span 进行中的 span；nil Span 的所有方法都是空操作，调用方无需判空
+type Span struct {
+	mu    sync.Mutex
+	trace *traceState
+	data  SpanData
+	ended bool
+}
+
+type spanKey struct{}
+
+// Start 在 ctx 当前 span 下创建子 span；ctx 中没有 span 时开启新的 trace。
+// kv 为交替的属性键值对，与 slog 相同。
+func Start(ctx context.Context, name string, kv ...any) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	s := &Span{data: SpanData{
+		SpanID: newID(8),
+		Name:   name,
+		Start:  time.Now(),
+	}}
+	if parent != nil {
+		s.trace = parent.trace
+		s.data.ParentID = parent.data.SpanID
+	} else {
+		s.trace = &traceState{id: newID(16)}
+	}
+	s.data.TraceID = s.trace.id
+	s.SetAttrs(kv...)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// FromContext 返回 ctx 中当前的 span，没有时返回 nil
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// TraceID 返回 span 所属的 trace ID
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.data.TraceID
+}
+
+// SetAttr 设置一个属性，同名覆盖
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = map[string]any{}
+	}
+	s.data.Attributes[key] = value
+}
+
+// SetAttrs 按交替的键值对设置属性
+func (s *Span) SetAttrs(kv ...any) {
+	for i := 0; i+1 < len(kv); i += 2 {
+		key, ok := kv[i].(string)
+		if !ok {
+			continue
+		}
+		s.SetAttr(key, kv[i+1])
+	}
+}
+
+// RecordError 记录错误，span 状态置为 error；err 为 nil 时不做处理
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Error = err.Error()
+}
+
+// End 结束 span；根 span 结束时整棵 trace 完成，进入缓存并导出。重复调用无效。
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	t := s.trace
+	t.mu.Lock()
+	t.spans = append(t.spans, data)
+	if data.ParentID != "" {
+		t.mu.Unlock()
+		return
+	}
+	spans := append([]SpanData(nil), t.spans...)
+	t.mu.Unlock()
+	complete(newTrace(t.id, spans))
+}
+
+func newID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%0*x", n*2, time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
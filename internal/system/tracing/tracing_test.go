@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOTLPExportOfCompletedTrace(t *testing.T) {
+	received := make(chan map[string]any, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Authorization") != "Bearer t" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		received <- body
+	}))
+	defer srv.Close()
+
+	if err := Configure(Config{Exporter: ExporterOTLP, Endpoint: srv.URL, Headers: map[string]string{"Authorization": "Bearer t"}}, nil); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	defer Shutdown(context.Background())
+
+	ctx, root := Start(context.Background(), "inbound.message", "channel", "feishu")
+	_, child := Start(ctx, "model.attempt", "attempt", 2)
+	child.RecordError(errors.New("overloaded"))
+	child.End()
+	root.End()
+	root.End() // 重复结束不应再次导出
+
+	var body map[string]any
+	select {
+	case body = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("trace was not exported")
+	}
+	rs := body["resourceSpans"].([]any)[0].(map[string]any)
+	spans := rs["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	first, second := spans[0].(map[string]any), spans[1].(map[string]any)
+	if first["name"] != "inbound.message" || first["traceId"] != root.TraceID() || len(first["traceId"].(string)) != 32 {
+		t.Fatalf("unexpected root span: %v", first)
+	}
+	if second["parentSpanId"] != first["spanId"] || second["status"].(map[string]any)["code"] != float64(2) {
+		t.Fatalf("child span should reference its parent and carry the error status: %v", second)
+	}
+	attr := second["attributes"].([]any)[0].(map[string]any)
+	if attr["key"] != "attempt" || attr["value"].(map[string]any)["intValue"] != "2" {
+		t.Fatalf("int attributes should use OTLP intValue strings: %v", attr)
+	}
+	if _, ok := Lookup(root.TraceID()); !ok {
+		t.Fatal("completed trace should be kept for summaries")
+	}
+}
@@ -2,13 +2,21 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"sort"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/highclaw/highclaw/internal/agent"
+	"github.com/highclaw/highclaw/internal/domain/model"
 	"github.com/highclaw/highclaw/internal/gateway/session"
+	"github.com/highclaw/highclaw/internal/system/tracing"
 )
 
 // Command 定义斜杠命令
@@ -30,6 +38,9 @@ func getBuiltinCommands() []Command {
 		{Name: "delete", Aliases: []string{"rm"}, Description: "Delete a session", Category: "Session", Handler: cmdDeleteSession},
 		{Name: "rename", Aliases: nil, Description: "Rename current session", Category: "Session", Handler: cmdRenameSession},
 		{Name: "compact", Aliases: nil, Description: "Compact session history", Category: "Session", Handler: cmdCompact},
+		{Name: "branch", Aliases: []string{"fork"}, Description: "Fork the session at message N (default: latest)", Category: "Session", Handler: cmdBranch},
+		{Name: "search", Aliases: []string{"find"}, Description: "Search all session history", Category: "Session", Handler: cmdSearch},
+		{Name: "image", Aliases: []string{"attach"}, Description: "Attach an image to the next message (/image clear to remove)", Category: "Session", Handler: cmdImage},
 
 		// Model 命令
 		{Name: "model", Aliases: []string{"m"}, Description: "Switch model", Category: "Model", Handler: cmdSetModel},
@@ -49,6 +60,7 @@ func getBuiltinCommands() []Command {
 		// 信息命令
 		{Name: "tokens", Aliases: []string{"t"}, Description: "Show token usage", Category: "Info", Handler: cmdTokens},
 		{Name: "info", Aliases: nil, Description: "Show session info", Category: "Info", Handler: cmdInfo},
+		{Name: "trace", Aliases: nil, Description: "Show timing trace of the last reply", Category: "Info", Handler: cmdTrace},
 	}
 }
 
@@ -91,7 +103,7 @@ func cmdListSessions(m *Model, args []string) (string, error) {
 		if s.Key == m.currentSession {
 			marker = "→ "
 		}
-		label := lastSegment(s.Key)
+		label := s.Label
 		updated := relativeTime(s.UpdatedAt)
 		b.WriteString(fmt.Sprintf("%s%d. %s (%s)\n", marker, i+1, label, updated))
 	}
@@ -110,16 +122,16 @@ func cmdSwitchSession(m *Model, args []string) (string, error) {
 		m.currentSession = m.sessions[idx-1].Key
 		_ = session.SetCurrent(m.currentSession)
 		m.loadCurrentSession()
-		return fmt.Sprintf("Switched to: %s", lastSegment(m.currentSession)), nil
+		return fmt.Sprintf("Switched to: %s", m.sessionName()), nil
 	}
 
-	// 尝试名称匹配
+	// 尝试名称匹配（key 或 /rename 设置的名称）
 	for _, s := range m.sessions {
-		if strings.Contains(strings.ToLower(s.Key), strings.ToLower(target)) {
+		if strings.Contains(strings.ToLower(s.Key), strings.ToLower(target)) || strings.Contains(strings.ToLower(s.Label), strings.ToLower(target)) {
 			m.currentSession = s.Key
 			_ = session.SetCurrent(m.currentSession)
 			m.loadCurrentSession()
-			return fmt.Sprintf("Switched to: %s", lastSegment(m.currentSession)), nil
+			return fmt.Sprintf("Switched to: %s", m.sessionName()), nil
 		}
 	}
 
@@ -161,6 +173,8 @@ func cmdDeleteSession(m *Model, args []string) (string, error) {
 	if err := session.Delete(keyToDelete); err != nil {
 		return "", err
 	}
+	// 结束该会话启动的后台进程
+	m.runner.ResetSession(keyToDelete)
 
 	// 刷新列表
 	newSessions := make([]sessionEntry, 0, len(m.sessions)-1)
@@ -175,43 +189,288 @@ func cmdDeleteSession(m *Model, args []string) (string, error) {
 }
 
 func cmdRenameSession(m *Model, args []string) (string, error) {
-	return "", fmt.Errorf("rename not implemented yet")
+	label := strings.TrimSpace(strings.Join(args, " "))
+	if label == "" {
+		return "", fmt.Errorf("usage: /rename <name>")
+	}
+	// 新会话可能还没写盘，先保存再改名
+	m.persistCurrentSession()
+	if err := session.Rename(m.currentSession, label); err != nil {
+		return "", err
+	}
+	m.sessionLabel = label
+	m.updateSessionEntry()
+	return fmt.Sprintf("Renamed to: %s", label), nil
 }
 
 func cmdCompact(m *Model, args []string) (string, error) {
-	return "Compact not implemented yet. Use /new to start fresh.", nil
+	if m.pending {
+		return "", fmt.Errorf("wait for the current reply before compacting")
+	}
+	if len(m.history) <= 2 {
+		return "Nothing to compact.", nil
+	}
+	m.pending = true
+	m.deferred = compactCmd(m.runner, &agent.RunRequest{
+		SessionKey: m.currentSession,
+		Channel:    "tui",
+		Model:      m.sessionModel,
+		History:    cloneHistory(m.history),
+	})
+	return fmt.Sprintf("Compacting %d messages...", len(m.history)), nil
+}
+
+// compactCmd 异步压缩会话历史
+func compactCmd(runner *agent.Runner, req *agent.RunRequest) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+		defer cancel()
+		history, err := runner.Compact(ctx, req)
+		return compactMsg{History: history, Before: len(req.History), Err: err}
+	}
+}
+
+func cmdBranch(m *Model, args []string) (string, error) {
+	if m.pending {
+		return "", fmt.Errorf("wait for the current reply before branching")
+	}
+	if len(m.history) == 0 {
+		return "", fmt.Errorf("nothing to branch: the session is empty")
+	}
+	n := len(m.history)
+	if len(args) > 0 {
+		if _, err := fmt.Sscanf(args[0], "%d", &n); err != nil || n < 1 || n > len(m.history) {
+			return "", fmt.Errorf("usage: /branch [message number 1-%d]", len(m.history))
+		}
+	}
+	m.persistCurrentSession()
+	newKey := buildSessionKey(m.opts.Agent, fmt.Sprintf("%s-branch-%d", lastSegment(m.currentSession), time.Now().Unix()%100000))
+	branched, err := session.Branch(m.currentSession, newKey, n)
+	if err != nil {
+		return "", err
+	}
+	from := m.sessionName()
+	m.currentSession = newKey
+	_ = session.SetCurrent(newKey)
+	m.loadCurrentSession()
+	m.sessions = append([]sessionEntry{{Key: newKey, Label: branched.DisplayName(), Channel: branched.Channel, UpdatedAt: branched.LastActivityAt, Model: branched.Model}}, m.sessions...)
+	return fmt.Sprintf("Branched %s at message %d → %s", from, n, m.sessionName()), nil
+}
+
+func cmdSearch(m *Model, args []string) (string, error) {
+	query := strings.TrimSpace(strings.Join(args, " "))
+	if query == "" {
+		return "", fmt.Errorf("usage: /search <text>")
+	}
+	hits, err := session.Search(query, 20)
+	if err != nil {
+		return "", err
+	}
+	if len(hits) == 0 {
+		return fmt.Sprintf("No messages matching %q", query), nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Matches for %q:\n", query)
+	for _, h := range hits {
+		marker := "  "
+		if h.Key == m.currentSession {
+			marker = "→ "
+		}
+		fmt.Fprintf(&b, "%s%s #%d %s: %s\n", marker, h.Label, h.Index, h.Role, searchSnippet(h.Content, query, 80))
+	}
+	b.WriteString("Use /switch <name> to open a session, /branch <#> to fork the current one.")
+	return b.String(), nil
+}
+
+// searchSnippet 截取第一个查询词附近的内容，压成一行
+func searchSnippet(content, query string, width int) string {
+	runes := []rune(strings.Join(strings.Fields(content), " "))
+	start := 0
+	if terms := strings.Fields(query); len(terms) > 0 {
+		// 逐个 rune 转小写，保证匹配位置和 runes 一一对应（strings.ToLower 可能改变长度，如 'İ'）
+		lower := strings.Map(unicode.ToLower, string(runes))
+		if i := strings.Index(lower, strings.Map(unicode.ToLower, terms[0])); i > 0 {
+			start = max(utf8.RuneCountInString(lower[:i])-width/4, 0)
+		}
+	}
+	end := min(start+width, len(runes))
+	snippet := string(runes[start:end])
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+// updateSessionEntry 把当前会话的名称同步到会话列表
+func (m *Model) updateSessionEntry() {
+	for i := range m.sessions {
+		if m.sessions[i].Key == m.currentSession {
+			m.sessions[i].Label = m.sessionName()
+			return
+		}
+	}
+	m.sessions = append([]sessionEntry{{Key: m.currentSession, Label: m.sessionName(), Channel: "tui", UpdatedAt: time.Now(), Model: m.model()}}, m.sessions...)
+}
+
+func cmdImage(m *Model, args []string) (string, error) {
+	if len(args) == 0 {
+		if len(m.attachments) == 0 {
+			return "No images attached. Usage: /image <path>, or paste / drop an image file, or mention @image.png", nil
+		}
+		return "Attached: " + m.attachmentLine(), nil
+	}
+	if len(args) == 1 && strings.EqualFold(args[0], "clear") {
+		m.attachments = nil
+		return "Attachments cleared.", nil
+	}
+	path, ok := pastedImagePath(strings.Join(args, " "))
+	if !ok {
+		return "", fmt.Errorf("not an image file: %s (supported: png, jpg, gif, webp)", strings.Join(args, " "))
+	}
+	a, err := loadImage(path)
+	if err != nil {
+		return "", err
+	}
+	m.attachments = append(m.attachments, a)
+	return fmt.Sprintf("Attached %s (%d KB). It will be sent with your next message.", a.Name, len(a.Data)/1024), nil
 }
 
 func cmdSetModel(m *Model, args []string) (string, error) {
 	if len(args) == 0 {
-		return fmt.Sprintf("Current model: %s", m.cfg.Agent.Model), nil
+		source := "default"
+		if m.sessionModel != "" {
+			source = "this session"
+		}
+		return fmt.Sprintf("Current model: %s (%s)", m.model(), source), nil
 	}
 	newModel := strings.TrimSpace(args[0])
-	m.cfg.Agent.Model = newModel
-	return fmt.Sprintf("Model set to: %s", newModel), nil
+	if strings.EqualFold(newModel, "default") || strings.EqualFold(newModel, "reset") {
+		newModel = ""
+	}
+	m.persistCurrentSession()
+	if err := session.SetModelOverride(m.currentSession, newModel); err != nil {
+		return "", err
+	}
+	m.sessionModel = newModel
+	m.persistCurrentSession()
+	if newModel == "" {
+		return fmt.Sprintf("Model reset to default: %s", m.model()), nil
+	}
+	msg := fmt.Sprintf("Model for this session set to: %s", newModel)
+	if _, ok := model.LookupModel(newModel); !ok && !strings.HasPrefix(newModel, "hint:") {
+		msg += " (not in the model catalogue)"
+	}
+	return msg, nil
 }
 
+// modelsPerProvider /models 默认每个 provider 展示的模型数
+const modelsPerProvider = 8
+
+// cmdListModels 列出已配置 provider 的模型；/models all 列出全部 provider，/models <provider> 列出该 provider 的全部模型
 func cmdListModels(m *Model, args []string) (string, error) {
-	models := []string{
-		"claude-sonnet-4-20250514",
-		"claude-3-5-sonnet-20241022",
-		"gpt-4o",
-		"gpt-4o-mini",
-		"deepseek-chat",
-		"deepseek-reasoner",
-	}
+	current := m.model()
 	var b strings.Builder
-	b.WriteString("Available models:\n")
-	for _, model := range models {
-		marker := "  "
-		if model == m.cfg.Agent.Model {
-			marker = "→ "
+	writeModels := func(provider string, limit int) {
+		ms := model.GetModelsByProvider(provider)
+		fmt.Fprintf(&b, "%s (%d)\n", provider, len(ms))
+		for i, md := range ms {
+			if limit > 0 && i >= limit {
+				fmt.Fprintf(&b, "    ... %d more, /models %s\n", len(ms)-limit, provider)
+				break
+			}
+			ref := provider + "/" + md.ID
+			marker := "    "
+			if ref == current || md.ID == current {
+				marker = "  → "
+			}
+			b.WriteString(marker + ref + "\n")
+		}
+	}
+
+	if len(args) > 0 {
+		target := strings.ToLower(strings.TrimSpace(args[0]))
+		if target == "all" {
+			b.WriteString("All providers:\n")
+			for _, p := range catalogueProviders() {
+				fmt.Fprintf(&b, "  %-24s %d models\n", p, len(model.GetModelsByProvider(p)))
+			}
+			return strings.TrimSuffix(b.String(), "\n"), nil
+		}
+		if len(model.GetModelsByProvider(target)) == 0 {
+			return "", fmt.Errorf("no models known for provider %q (see /models all)", target)
+		}
+		writeModels(target, 0)
+		return strings.TrimSuffix(b.String(), "\n"), nil
+	}
+
+	fmt.Fprintf(&b, "Current: %s\n\n", current)
+	providers := configuredProviders(m)
+	if len(providers) == 0 {
+		b.WriteString("No providers configured. Use /models all to browse the catalogue.\n")
+	}
+	for _, p := range providers {
+		writeModels(p, modelsPerProvider)
+	}
+	if len(m.cfg.ModelRoutes) > 0 {
+		b.WriteString("Routes:\n")
+		for _, r := range m.cfg.ModelRoutes {
+			fmt.Fprintf(&b, "    hint:%s → %s/%s\n", r.Hint, r.Provider, r.Model)
 		}
-		b.WriteString(marker + model + "\n")
 	}
 	return strings.TrimSuffix(b.String(), "\n"), nil
 }
 
+// configuredProviders 配置中用到的 provider：providers 凭据、默认模型、fallback、hint 路由和已发现的本地模型
+func configuredProviders(m *Model) []string {
+	seen := map[string]bool{}
+	add := func(p string) {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p != "" && len(model.GetModelsByProvider(p)) > 0 {
+			seen[p] = true
+		}
+	}
+	for p := range m.cfg.Agent.Providers {
+		add(p)
+	}
+	for _, ref := range []string{m.cfg.Agent.Model, m.sessionModel} {
+		if i := strings.Index(ref, "/"); i > 0 {
+			add(ref[:i])
+		}
+	}
+	for _, p := range m.cfg.Reliability.FallbackProviders {
+		add(p)
+	}
+	for _, r := range m.cfg.ModelRoutes {
+		add(r.Provider)
+	}
+	for _, md := range model.LocalModels() {
+		add(md.Provider)
+	}
+	out := make([]string, 0, len(seen))
+	for p := range seen {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// catalogueProviders 模型目录中的全部 provider
+func catalogueProviders() []string {
+	seen := map[string]bool{}
+	for _, md := range model.GetAllModelsComplete() {
+		seen[md.Provider] = true
+	}
+	out := make([]string, 0, len(seen))
+	for p := range seen {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
 func cmdClear(m *Model, args []string) (string, error) {
 	m.lines = nil
 	m.updateViewport()
@@ -300,12 +559,23 @@ func cmdTokens(m *Model, args []string) (string, error) {
 		m.tokenUsage.input, m.tokenUsage.output, m.tokenUsage.input+m.tokenUsage.output), nil
 }
 
+func cmdTrace(m *Model, args []string) (string, error) {
+	if m.lastTraceID == "" {
+		return "No run traced yet in this TUI session.", nil
+	}
+	t, ok := tracing.Lookup(m.lastTraceID)
+	if !ok {
+		return "", fmt.Errorf("trace %s is no longer available", m.lastTraceID)
+	}
+	return t.Summary(), nil
+}
+
 func cmdInfo(m *Model, args []string) (string, error) {
 	pwd, _ := os.Getwd()
 	var b strings.Builder
 	b.WriteString("Session Info:\n")
-	b.WriteString(fmt.Sprintf("  Session: %s\n", m.currentSession))
-	b.WriteString(fmt.Sprintf("  Model: %s\n", m.cfg.Agent.Model))
+	b.WriteString(fmt.Sprintf("  Session: %s (%s)\n", m.sessionName(), m.currentSession))
+	b.WriteString(fmt.Sprintf("  Model: %s\n", m.model()))
 	b.WriteString(fmt.Sprintf("  Messages: %d\n", len(m.history)))
 	b.WriteString(fmt.Sprintf("  Tokens: %d (in) / %d (out)\n", m.tokenUsage.input, m.tokenUsage.output))
 	b.WriteString(fmt.Sprintf("  CWD: %s", pwd))
@@ -0,0 +1,20 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSearchSnippet(t *testing.T) {
+	content := strings.Repeat("İstanbul ", 30) + "the needle is here " + strings.Repeat("word ", 30)
+	got := searchSnippet(content, "NEEDLE", 40)
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") || !strings.Contains(got, "needle") {
+		t.Fatalf("snippet = %q", got)
+	}
+	if got := searchSnippet("İİİİ match", "match", 4); !strings.Contains(got, "mat") {
+		t.Fatalf("snippet after multi-byte lowercase = %q", got)
+	}
+	if got := searchSnippet("short  text\nhere", "missing", 80); got != "short text here" {
+		t.Fatalf("no match should start at the beginning: %q", got)
+	}
+}
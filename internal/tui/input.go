@@ -0,0 +1,352 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/highclaw/highclaw/internal/config"
+)
+
+// 输入框：多行编辑（enter 发送，alt+enter / ctrl+j 换行）、@path 引用文件、图片附件、
+// 大段粘贴折叠为占位符，以及跨会话持久化的输入历史（↑ / ↓ 调出）。
+
+const (
+	inputMaxHeight = 8 // 输入框随内容增高的最大行数
+
+	// 粘贴超过任一阈值时折叠为占位符，发送时再展开
+	pasteCollapseChars = 1000
+	pasteCollapseLines = 10
+
+	mentionMaxFileBytes  = 100 * 1024 // 单个 @path 文件内联的字节上限，超出截断
+	mentionMaxTotalBytes = 400 * 1024 // 一条消息内联文件的总字节上限，超出跳过
+	attachMaxImageBytes  = 20 << 20   // 图片原始文件上限，发送前由 agent 缩放
+
+	inputHistoryMax = 500 // 持久化的输入历史条数
+)
+
+// imageExts 可作为图片附件的扩展名
+var imageExts = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true}
+
+// mentionPattern 匹配行首或空白后的 @path
+var mentionPattern = regexp.MustCompile(`(^|\s)@(\S+)`)
+
+// pastePattern 匹配大段粘贴的占位符
+var pastePattern = regexp.MustCompile(`\[Pasted text #(\d+) \+\d+ lines\]`)
+
+// attachment 待随下一条消息发送的图片
+type attachment struct {
+	Name string
+	Data []byte
+}
+
+// outgoingMessage 展开占位符和 @path 之后待发送（或排队）的消息
+type outgoingMessage struct {
+	Display string // 聊天记录中显示的原始输入
+	Content string // 发给 agent 并写入会话历史的内容
+	Images  [][]byte
+}
+
+// composeMessage 展开大段粘贴和 @path 引用，取出待发送的图片附件；notes 为需要提示用户的跳过原因
+func (m *Model) composeMessage(text string) (outgoingMessage, []string) {
+	content := pastePattern.ReplaceAllStringFunc(text, func(s string) string {
+		var n int
+		if _, err := fmt.Sscanf(pastePattern.FindStringSubmatch(s)[1], "%d", &n); err == nil {
+			if p, ok := m.pastes[n]; ok {
+				return p
+			}
+		}
+		return s
+	})
+	m.pastes = nil
+
+	// 只解析用户输入的 @path，粘贴内容中的 @ 不当作文件引用
+	files, images, notes := expandMentions(text)
+	if files != "" {
+		content += "\n\n" + files
+	}
+	msg := outgoingMessage{Display: text, Content: content}
+	for _, a := range m.attachments {
+		msg.Images = append(msg.Images, a.Data)
+		msg.Display += fmt.Sprintf("\n[image: %s]", a.Name)
+	}
+	for _, a := range images {
+		msg.Images = append(msg.Images, a.Data)
+		msg.Display += fmt.Sprintf("\n[image: %s]", a.Name)
+	}
+	m.attachments = nil
+	if strings.TrimSpace(msg.Content) == "" && len(msg.Images) > 0 {
+		msg.Content = "(see attached image)"
+	}
+	return msg, notes
+}
+
+// expandMentions 读取消息中 @path 引用的文件：文本文件按大小上限内联，图片作为附件；
+// 不存在的路径（如 @用户名）原样保留
+func expandMentions(text string) (string, []attachment, []string) {
+	var b strings.Builder
+	var images []attachment
+	var notes []string
+	total := 0
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		name, path, ok := resolveMention(match[2])
+		if !ok || seen[path] {
+			continue
+		}
+		seen[path] = true
+		if imageExts[strings.ToLower(filepath.Ext(path))] {
+			a, err := loadImage(path)
+			if err != nil {
+				notes = append(notes, err.Error())
+				continue
+			}
+			images = append(images, a)
+			continue
+		}
+		data, size, err := readMention(path)
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("@%s not attached: %v", name, err))
+			continue
+		}
+		if total+len(data) > mentionMaxTotalBytes {
+			notes = append(notes, fmt.Sprintf("@%s not attached: total attachment limit of %d KB reached", name, mentionMaxTotalBytes/1024))
+			continue
+		}
+		total += len(data)
+		fmt.Fprintf(&b, "<file path=%q>\n%s", name, data)
+		if !bytes.HasSuffix(data, []byte("\n")) {
+			b.WriteString("\n")
+		}
+		if int64(len(data)) < size {
+			fmt.Fprintf(&b, "[truncated: first %d KB of %d KB]\n", len(data)/1024, size/1024)
+			notes = append(notes, fmt.Sprintf("@%s truncated to %d KB", name, mentionMaxFileBytes/1024))
+		}
+		b.WriteString("</file>\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n"), images, notes
+}
+
+// resolveMention 把 @ 后的文本解析为存在的普通文件，返回引用名（去掉句末标点）和路径
+func resolveMention(ref string) (string, string, bool) {
+	for _, name := range []string{ref, strings.TrimRight(ref, ".,;:!?)\"'")} {
+		path := expandHome(name)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return name, path, true
+		}
+	}
+	return "", "", false
+}
+
+// readMention 读取文本文件，最多 mentionMaxFileBytes 字节；二进制文件返回错误
+func readMention(path string) ([]byte, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	data := make([]byte, min(int(info.Size()), mentionMaxFileBytes))
+	n, err := io.ReadFull(f, data)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, 0, err
+	}
+	data = data[:n]
+	// 截断可能落在多字节字符中间，去掉不完整的尾部再判断是否为文本
+	for len(data) > 0 && !utf8.Valid(data) && int64(n) < info.Size() {
+		data = data[:len(data)-1]
+	}
+	if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		return nil, 0, fmt.Errorf("binary file")
+	}
+	return data, info.Size(), nil
+}
+
+// loadImage 读取图片附件
+func loadImage(path string) (attachment, error) {
+	name := filepath.Base(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return attachment{}, fmt.Errorf("image %s: %v", name, err)
+	}
+	if info.Size() > attachMaxImageBytes {
+		return attachment{}, fmt.Errorf("image %s is larger than %d MB", name, attachMaxImageBytes>>20)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return attachment{}, fmt.Errorf("image %s: %v", name, err)
+	}
+	return attachment{Name: name, Data: data}, nil
+}
+
+// pastedImagePath 判断粘贴内容是否为单个图片文件路径（从文件管理器拖入或复制的文件），
+// 兼容引号、反斜杠转义的空格和 file:// URI
+func pastedImagePath(text string) (string, bool) {
+	p := strings.TrimSpace(text)
+	if p == "" || strings.Contains(p, "\n") {
+		return "", false
+	}
+	if len(p) >= 2 && (p[0] == '\'' || p[0] == '"') && p[len(p)-1] == p[0] {
+		p = p[1 : len(p)-1]
+	}
+	if strings.HasPrefix(p, "file://") {
+		u, err := url.Parse(p)
+		if err != nil {
+			return "", false
+		}
+		p = u.Path
+	}
+	p = expandHome(strings.ReplaceAll(p, `\ `, " "))
+	if !imageExts[strings.ToLower(filepath.Ext(p))] {
+		return "", false
+	}
+	if info, err := os.Stat(p); err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	return p, true
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
+
+// handlePaste 处理括号粘贴：图片路径转为附件，大段文本折叠为占位符；返回 false 时按普通输入处理
+func (m *Model) handlePaste(text string) bool {
+	if path, ok := pastedImagePath(text); ok {
+		a, err := loadImage(path)
+		if err != nil {
+			m.appendLine("system", "Error: "+err.Error())
+			m.updateViewport()
+			return true
+		}
+		m.attachments = append(m.attachments, a)
+		return true
+	}
+	lines := strings.Count(text, "\n") + 1
+	if utf8.RuneCountInString(text) < pasteCollapseChars && lines < pasteCollapseLines {
+		return false
+	}
+	if m.pastes == nil {
+		m.pastes = map[int]string{}
+	}
+	m.pasteSeq++
+	m.pastes[m.pasteSeq] = text
+	m.textarea.InsertString(fmt.Sprintf("[Pasted text #%d +%d lines]", m.pasteSeq, lines))
+	return true
+}
+
+// resizeInput 输入框高度随内容增减
+func (m *Model) resizeInput() {
+	m.textarea.SetHeight(max(1, min(m.textarea.LineCount(), inputMaxHeight)))
+}
+
+// inputHistory 跨会话共享的输入历史，每行一条 JSON 字符串（兼容多行输入）
+type inputHistory struct {
+	path    string
+	entries []string
+	// pos 浏览位置，len(entries) 表示未在浏览；draft 开始浏览前输入框中的内容
+	pos   int
+	draft string
+}
+
+func inputHistoryPath() string {
+	return filepath.Join(config.ConfigDir(), "tui_history.jsonl")
+}
+
+func loadInputHistory(path string) *inputHistory {
+	h := &inputHistory{path: path}
+	if data, err := os.ReadFile(path); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			var entry string
+			if json.Unmarshal([]byte(line), &entry) == nil && entry != "" {
+				h.entries = append(h.entries, entry)
+			}
+		}
+	}
+	if len(h.entries) > inputHistoryMax {
+		h.entries = h.entries[len(h.entries)-inputHistoryMax:]
+	}
+	h.pos = len(h.entries)
+	return h
+}
+
+// add 记录一条输入并写盘；与上一条相同时不重复记录
+func (h *inputHistory) add(text string) {
+	defer func() { h.pos = len(h.entries) }()
+	if text == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == text) {
+		return
+	}
+	h.entries = append(h.entries, text)
+	if len(h.entries) > inputHistoryMax {
+		h.entries = h.entries[len(h.entries)-inputHistoryMax:]
+		_ = h.save()
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	line, _ := json.Marshal(text)
+	_, _ = f.Write(append(line, '\n'))
+}
+
+// save 重写历史文件（超出上限裁剪时）
+func (h *inputHistory) save() error {
+	var b bytes.Buffer
+	for _, e := range h.entries {
+		line, _ := json.Marshal(e)
+		b.Write(append(line, '\n'))
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(h.path, b.Bytes(), 0o600)
+}
+
+// prev 返回上一条历史；current 为当前输入，首次进入浏览时保存为草稿
+func (h *inputHistory) prev(current string) (string, bool) {
+	if h.pos == 0 {
+		return "", false
+	}
+	if h.pos == len(h.entries) {
+		h.draft = current
+	}
+	h.pos--
+	return h.entries[h.pos], true
+}
+
+// next 返回下一条历史，越过最新一条时恢复草稿
+func (h *inputHistory) next() (string, bool) {
+	if h.pos >= len(h.entries) {
+		return "", false
+	}
+	h.pos++
+	if h.pos == len(h.entries) {
+		return h.draft, true
+	}
+	return h.entries[h.pos], true
+}
+
+// browsing 是否正在浏览历史
+func (h *inputHistory) browsing() bool {
+	return h.pos < len(h.entries)
+}
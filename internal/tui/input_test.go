@@ -0,0 +1,77 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandMentions(t *testing.T) {
+	dir := t.TempDir()
+	notes := filepath.Join(dir, "notes.md")
+	big := filepath.Join(dir, "big.log")
+	bin := filepath.Join(dir, "blob.bin")
+	img := filepath.Join(dir, "shot.png")
+	_ = os.WriteFile(notes, []byte("hello notes"), 0o644)
+	_ = os.WriteFile(big, []byte(strings.Repeat("x", mentionMaxFileBytes+10)), 0o644)
+	_ = os.WriteFile(bin, []byte{0x00, 0x01, 0x02}, 0o644)
+	_ = os.WriteFile(img, []byte("\x89PNG fake"), 0o644)
+
+	files, images, skipped := expandMentions("see @" + notes + ", @" + big + " @" + bin + " @" + img + " and ping @alice")
+	if !strings.Contains(files, "<file path=\""+notes+"\">\nhello notes\n</file>") {
+		t.Fatalf("notes not inlined (trailing comma should be ignored):\n%.300s", files)
+	}
+	if !strings.Contains(files, "[truncated: first 100 KB") {
+		t.Fatalf("big file not truncated")
+	}
+	if strings.Contains(files, "blob.bin") || strings.Contains(files, "alice") {
+		t.Fatalf("binary file or unknown mention inlined")
+	}
+	if len(images) != 1 || images[0].Name != "shot.png" {
+		t.Fatalf("image not attached: %+v", images)
+	}
+	if len(skipped) != 2 {
+		t.Fatalf("expected truncation and binary notes, got %v", skipped)
+	}
+}
+
+func TestPastedImagePath(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "my shot.PNG")
+	_ = os.WriteFile(img, []byte("x"), 0o644)
+	for _, in := range []string{img, "'" + img + "'", strings.ReplaceAll(img, " ", `\ `), "file://" + strings.ReplaceAll(img, " ", "%20")} {
+		if got, ok := pastedImagePath(in); !ok || got != img {
+			t.Fatalf("pastedImagePath(%q) = %q, %v", in, got, ok)
+		}
+	}
+	if _, ok := pastedImagePath("just some text.png"); ok {
+		t.Fatalf("missing file should not be treated as image")
+	}
+}
+
+func TestInputHistoryPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	h := loadInputHistory(path)
+	h.add("first")
+	h.add("multi\nline")
+	h.add("multi\nline")
+
+	h = loadInputHistory(path)
+	if len(h.entries) != 2 {
+		t.Fatalf("entries = %q", h.entries)
+	}
+	if got, _ := h.prev("draft"); got != "multi\nline" {
+		t.Fatalf("prev = %q", got)
+	}
+	if got, _ := h.prev(""); got != "first" {
+		t.Fatalf("prev = %q", got)
+	}
+	if _, ok := h.prev(""); ok {
+		t.Fatalf("prev past oldest entry should fail")
+	}
+	h.next()
+	if got, _ := h.next(); got != "draft" || h.browsing() {
+		t.Fatalf("next past newest should restore draft, got %q", got)
+	}
+}
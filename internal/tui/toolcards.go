@@ -0,0 +1,193 @@
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/highclaw/highclaw/internal/agent"
+)
+
+// 工具调用卡片：运行中按 agent.ProgressEvent 实时更新，显示工具名、参数、输出、耗时和状态；
+// ctrl+o 在折叠（摘要 + 最后几行输出）和展开（完整参数 + 更多输出）之间切换。
+
+const (
+	toolCardMaxLines       = 500 // 每张卡片保留的输出行数上限
+	toolCardCollapsedLines = 3   // 折叠时显示的输出行数
+	toolCardExpandedLines  = 40  // 展开时显示的输出行数
+)
+
+// 卡片状态：running 之外的取值与 ProgressEvent.Status 一致
+const (
+	toolRunning   = "running"
+	toolCancelled = "cancelled"
+)
+
+type toolCard struct {
+	ID     string // runID + callID
+	Name   string
+	Input  string
+	Output []string
+	// live 是否收到过实时输出；没有时在结束后用完整输出填充
+	live     bool
+	Status   string
+	Error    string
+	Duration time.Duration
+	Started  time.Time
+}
+
+// progressMsg 运行中的进度事件
+type progressMsg struct {
+	Event agent.ProgressEvent
+}
+
+// applyProgress 把进度事件更新到对应的工具卡片或聊天记录
+func (m *Model) applyProgress(ev agent.ProgressEvent) {
+	switch ev.Kind {
+	case agent.ProgressText:
+		m.appendLine("assistant", ev.Text)
+	case agent.ProgressToolStart:
+		card := &toolCard{ID: ev.RunID + "/" + ev.CallID, Name: ev.Tool, Input: ev.Input, Status: toolRunning, Started: time.Now()}
+		m.lines = append(m.lines, chatLine{Role: "tool", Tool: card, Timestamp: card.Started})
+	case agent.ProgressToolOutput:
+		if card := m.findToolCard(ev.RunID + "/" + ev.CallID); card != nil {
+			card.live = true
+			card.appendOutput(ev.Text)
+		}
+	case agent.ProgressToolEnd:
+		if card := m.findToolCard(ev.RunID + "/" + ev.CallID); card != nil {
+			card.Status = ev.Status
+			card.Error = ev.Error
+			card.Duration = time.Duration(ev.DurationMs) * time.Millisecond
+			if !card.live {
+				card.appendOutput(strings.TrimRight(ev.Text, "\n"))
+			}
+		}
+	}
+}
+
+// findToolCard 从最新的记录往前找，卡片总是在本次运行末尾附近
+func (m *Model) findToolCard(id string) *toolCard {
+	for i := len(m.lines) - 1; i >= 0; i-- {
+		if c := m.lines[i].Tool; c != nil && c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// finishToolCards 运行结束时把仍在运行的卡片标记为已取消（中断或出错时收不到 tool_end）
+func (m *Model) finishToolCards() {
+	for _, l := range m.lines {
+		if l.Tool != nil && l.Tool.Status == toolRunning {
+			l.Tool.Status = toolCancelled
+			l.Tool.Duration = time.Since(l.Tool.Started)
+		}
+	}
+}
+
+func (c *toolCard) appendOutput(text string) {
+	for _, line := range strings.Split(text, "\n") {
+		c.Output = append(c.Output, line)
+	}
+	if over := len(c.Output) - toolCardMaxLines; over > 0 {
+		c.Output = c.Output[over:]
+	}
+}
+
+// render 渲染卡片；expanded 为 true 时显示完整参数和更多输出
+func (c *toolCard) render(width int, expanded bool) string {
+	theme := getTheme()
+	muted := lipgloss.NewStyle().Foreground(theme.textMuted)
+
+	icon, color := "◌", theme.warning
+	switch c.Status {
+	case "success":
+		icon, color = "✓", theme.success
+	case "error":
+		icon, color = "✗", theme.error
+	case "denied":
+		icon, color = "⊘", theme.error
+	case toolCancelled:
+		icon, color = "■", theme.textMuted
+	}
+	status := c.Status
+	if c.Status == toolRunning {
+		status = fmt.Sprintf("running %s", formatDuration(time.Since(c.Started)))
+	} else {
+		status = fmt.Sprintf("%s %s", status, formatDuration(c.Duration))
+	}
+
+	var b strings.Builder
+	header := lipgloss.NewStyle().Foreground(color).Render(icon) + " " +
+		lipgloss.NewStyle().Bold(true).Foreground(theme.text).Render(c.Name) + " " +
+		muted.Render(truncateRunes(toolArgSummary(c.Input), max(width-len(c.Name)-len(status)-8, 10))) + "  " +
+		lipgloss.NewStyle().Foreground(color).Render(status)
+	b.WriteString(header)
+
+	border := lipgloss.NewStyle().Foreground(theme.border).Render("│") + " "
+	if expanded && strings.TrimSpace(c.Input) != "" {
+		b.WriteString("\n" + border + muted.Render("args: "+truncateRunes(c.Input, 4*width)))
+	}
+	if c.Error != "" && c.Status != "success" && (expanded || len(c.Output) == 0) {
+		b.WriteString("\n" + border + lipgloss.NewStyle().Foreground(theme.error).Render(truncateRunes(c.Error, width-4)))
+	}
+
+	limit := toolCardCollapsedLines
+	if expanded {
+		limit = toolCardExpandedLines
+	}
+	lines := c.Output
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if hidden := len(lines) - limit; hidden > 0 {
+		toggle := "expand"
+		if expanded {
+			toggle = "collapse"
+		}
+		b.WriteString("\n" + border + muted.Render(fmt.Sprintf("… %d earlier lines (ctrl+o to %s)", hidden, toggle)))
+		lines = lines[hidden:]
+	}
+	for _, line := range lines {
+		b.WriteString("\n" + border + muted.Render(truncateRunes(line, width-4)))
+	}
+	return b.String()
+}
+
+// toolArgSummary 参数的一行摘要：优先显示 command / path / url / query 等关键字段
+func toolArgSummary(input string) string {
+	var args map[string]any
+	if err := json.Unmarshal([]byte(input), &args); err == nil {
+		for _, key := range []string{"command", "cmd", "path", "url", "query", "action", "key", "task"} {
+			if v, ok := args[key].(string); ok && strings.TrimSpace(v) != "" {
+				return strings.Join(strings.Fields(v), " ")
+			}
+		}
+	}
+	return strings.Join(strings.Fields(input), " ")
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if n <= 0 || len(r) <= n {
+		return s
+	}
+	if n == 1 {
+		return "…"
+	}
+	return string(r[:n-1]) + "…"
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	case d < time.Minute:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	default:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	}
+}
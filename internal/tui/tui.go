@@ -3,6 +3,7 @@ package tui
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -19,6 +20,7 @@ import (
 	"github.com/highclaw/highclaw/internal/config"
 	"github.com/highclaw/highclaw/internal/gateway/protocol"
 	"github.com/highclaw/highclaw/internal/gateway/session"
+	"github.com/highclaw/highclaw/internal/system/tracing"
 )
 
 // 页面类型
@@ -41,6 +43,8 @@ type chatLine struct {
 	Role      string
 	Content   string
 	Timestamp time.Time
+	// Tool Role 为 "tool" 时的工具调用卡片，只在本次界面中显示，不写入会话历史
+	Tool *toolCard
 }
 
 type sessionEntry struct {
@@ -61,12 +65,20 @@ type bootMsg struct {
 	Err      error
 }
 
+// compactMsg /compact 的异步结果
+type compactMsg struct {
+	History []agent.ChatMessage
+	Before  int
+	Err     error
+}
+
 type assistantMsg struct {
 	Reply        string
 	Err          error
 	Duration     time.Duration
 	InputTokens  int
 	OutputTokens int
+	TraceID      string
 }
 
 // Model 表示 TUI 状态
@@ -84,6 +96,9 @@ type Model struct {
 
 	sessions       []sessionEntry
 	currentSession string
+	// sessionLabel / sessionModel 当前会话的名称和模型覆盖（/rename、/model），随会话持久化
+	sessionLabel string
+	sessionModel string
 
 	width  int
 	height int
@@ -94,8 +109,19 @@ type Model struct {
 	lastError    string
 	lastRTT      time.Duration
 	tokenUsage   tokenUsageInfo
-	messageQueue []string
+	messageQueue []outgoingMessage
 	interrupt    int
+	lastTraceID  string
+	// run 进行中的 agent 运行，ctrl+c 通过它取消；expandTools 工具卡片是否展开（ctrl+o）
+	run         *activeRun
+	expandTools bool
+	// attachments 待随下一条消息发送的图片；pastes 折叠的大段粘贴（占位符序号 → 原文）
+	attachments []attachment
+	pastes      map[int]string
+	pasteSeq    int
+	inputHist   *inputHistory
+	// deferred 命令处理函数安排的异步任务（如 /compact），在命令返回后启动
+	deferred tea.Cmd
 }
 
 // NewModel 创建新的 TUI Model
@@ -119,7 +145,10 @@ func NewModel(opts Options) Model {
 	ta := textarea.New()
 	ta.Placeholder = "Say anything... Fix a TODO in the codebase"
 	ta.Focus()
-	ta.CharLimit = 10000
+	// 不限制字数和行数，高度随内容增长到 inputMaxHeight；enter 发送，alt+enter / ctrl+j 换行
+	ta.CharLimit = 0
+	ta.MaxHeight = 0
+	ta.KeyMap.InsertNewline.SetKeys("alt+enter", "ctrl+j")
 	ta.SetHeight(1)
 	ta.ShowLineNumbers = false
 	ta.Prompt = ""
@@ -145,7 +174,8 @@ func NewModel(opts Options) Model {
 		spinner:        sp,
 		currentSession: initialSession,
 		page:           pageHome,
-		messageQueue:   make([]string, 0),
+		messageQueue:   make([]outgoingMessage, 0),
+		inputHist:      loadInputHistory(inputHistoryPath()),
 	}
 }
 
@@ -176,13 +206,27 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.loadCurrentSession()
 		return m, nil
 
+	case progressMsg:
+		m.applyProgress(msg.Event)
+		m.updateViewport()
+		if m.run != nil {
+			return m, m.run.wait()
+		}
+		return m, nil
+
 	case assistantMsg:
+		cancelled := m.run != nil && m.run.cancelled
+		m.run = nil
+		m.finishToolCards()
 		m.pending = false
 		m.lastRTT = msg.Duration
 		m.tokenUsage.input += msg.InputTokens
 		m.tokenUsage.output += msg.OutputTokens
+		m.lastTraceID = msg.TraceID
 
-		if msg.Err != nil {
+		if msg.Err != nil && cancelled && errors.Is(msg.Err, context.Canceled) {
+			m.appendLine("system", "Run cancelled.")
+		} else if msg.Err != nil {
 			m.lastError = msg.Err.Error()
 			m.appendLine("system", "Error: "+msg.Err.Error())
 		} else {
@@ -195,20 +239,21 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.persistCurrentSession()
 		}
 		m.updateViewport()
+		return m, m.sendQueued()
 
-		// 处理队列
-		if len(m.messageQueue) > 0 {
-			nextMsg := m.messageQueue[0]
-			m.messageQueue = m.messageQueue[1:]
-			m.pending = true
-			m.appendLine("user", nextMsg)
-			m.history = append(m.history, agent.ChatMessage{Role: "user", Content: nextMsg})
+	case compactMsg:
+		m.pending = false
+		if msg.Err != nil {
+			m.appendLine("system", "Error: compact failed: "+msg.Err.Error())
+		} else if len(msg.History) >= msg.Before {
+			m.appendLine("system", "Nothing to compact.")
+		} else {
+			m.history = msg.History
 			m.persistCurrentSession()
-			m.updateViewport()
-			cmds = append(cmds, sendMessageCmd(m.runner, m.currentSession, cloneHistory(m.history)))
-			cmds = append(cmds, m.spinner.Tick)
+			m.appendLine("system", fmt.Sprintf("Compacted %d messages into a summary (%d kept).", msg.Before-len(msg.History)+1, len(msg.History)-1))
 		}
-		return m, tea.Batch(cmds...)
+		m.updateViewport()
+		return m, m.sendQueued()
 
 	case spinner.TickMsg:
 		if m.pending {
@@ -219,23 +264,35 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyMsg:
+		if msg.Paste && m.handlePaste(string(msg.Runes)) {
+			m.resizeInput()
+			return m, nil
+		}
 		switch msg.Type {
 		case tea.KeyCtrlC:
+			// 运行中只取消当前运行，空闲时退出
+			if m.run != nil {
+				m.cancelRun()
+				return m, nil
+			}
 			return m, tea.Quit
 
 		case tea.KeyEsc:
 			if m.pending {
 				m.interrupt++
 				if m.interrupt >= 2 {
-					m.pending = false
 					m.interrupt = 0
-					m.appendLine("system", "Interrupted.")
-					m.updateViewport()
+					m.cancelRun()
 				}
 				return m, nil
 			}
 			return m, tea.Quit
 
+		case tea.KeyCtrlO:
+			m.expandTools = !m.expandTools
+			m.updateViewport()
+			return m, nil
+
 		case tea.KeyTab:
 			// TODO: agent 切换
 			return m, nil
@@ -244,15 +301,32 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// TODO: 命令面板
 			return m, nil
 
+		case tea.KeyCtrlJ:
+			m.textarea.InsertString("\n")
+			m.resizeInput()
+			return m, nil
+
+		case tea.KeyUp, tea.KeyDown:
+			// 光标在首行 / 末行时翻输入历史，否则在多行输入中移动
+			if m.recallHistory(msg.Type == tea.KeyUp) {
+				return m, nil
+			}
+
 		case tea.KeyEnter:
+			if msg.Alt {
+				m.textarea.InsertString("\n")
+				m.resizeInput()
+				return m, nil
+			}
 			text := strings.TrimSpace(m.textarea.Value())
-			if text == "" {
+			if text == "" && len(m.attachments) == 0 {
 				return m, nil
 			}
 			m.textarea.Reset()
 			m.textarea.SetHeight(1)
 			m.lastError = ""
 			m.interrupt = 0
+			m.inputHist.add(text)
 
 			if m.page == pageHome {
 				m.page = pageSession
@@ -273,6 +347,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						m.appendLine("system", result)
 					}
 					m.updateViewport()
+					if m.deferred != nil {
+						next := m.deferred
+						m.deferred = nil
+						return m, tea.Batch(next, m.spinner.Tick)
+					}
 					return m, nil
 				}
 				m.appendLine("system", "Unknown command: "+cmdName)
@@ -280,20 +359,22 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 
-			// 发送消息
+			// 发送消息：展开粘贴和 @path，带上图片附件
+			out, notes := m.composeMessage(text)
+			for _, note := range notes {
+				m.appendLine("system", note)
+			}
 			if m.pending {
-				m.messageQueue = append(m.messageQueue, text)
+				m.messageQueue = append(m.messageQueue, out)
+				m.updateViewport()
 				return m, nil
 			}
 
-			m.pending = true
-			m.appendLine("user", text)
-			m.history = append(m.history, agent.ChatMessage{Role: "user", Content: text})
+			m.appendLine("user", out.Display)
+			m.history = append(m.history, agent.ChatMessage{Role: "user", Content: out.Content})
 			m.persistCurrentSession()
 			m.updateViewport()
-			cmds = append(cmds, sendMessageCmd(m.runner, m.currentSession, cloneHistory(m.history)))
-			cmds = append(cmds, m.spinner.Tick)
-			return m, tea.Batch(cmds...)
+			return m, m.startRun(out.Images)
 		}
 	}
 
@@ -301,6 +382,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var tiCmd tea.Cmd
 	m.textarea, tiCmd = m.textarea.Update(msg)
 	cmds = append(cmds, tiCmd)
+	m.resizeInput()
 
 	if m.page == pageSession {
 		var vpCmd tea.Cmd
@@ -351,19 +433,15 @@ func (m *Model) renderHomePage() string {
 	}
 
 	// 输入框：左边竖线 + 内容
-	leftBorder := lipgloss.NewStyle().Foreground(theme.primary).Render("┃ ")
-	inputContent := m.textarea.View()
-	inputLine := leftBorder + inputContent
+	b.WriteString(m.renderInput(strings.Repeat(" ", padding)))
 
 	// 底部竖线结束符
 	bottomBorder := lipgloss.NewStyle().Foreground(theme.primary).Render("╹")
-
-	b.WriteString(strings.Repeat(" ", padding) + inputLine + "\n")
 	b.WriteString(strings.Repeat(" ", padding) + bottomBorder + "\n")
 
 	// Agent/Model 信息
 	agentInfo := lipgloss.NewStyle().Foreground(theme.textMuted).Render(
-		fmt.Sprintf("Sisyphus (Ultraworker)  %s", m.cfg.Agent.Model))
+		fmt.Sprintf("Sisyphus (Ultraworker)  %s", m.model()))
 	b.WriteString(strings.Repeat(" ", padding+2) + agentInfo + "\n")
 
 	// 快捷键提示
@@ -403,8 +481,8 @@ func (m *Model) renderSessionPage() string {
 	b.WriteString(m.renderSessionHeader())
 	b.WriteString("\n")
 
-	// 聊天区域
-	chatHeight := m.height - 7
+	// 聊天区域（输入框多行或有附件时相应缩小）
+	chatHeight := m.height - 6 - m.inputRows()
 	if chatHeight < 5 {
 		chatHeight = 5
 	}
@@ -413,18 +491,8 @@ func (m *Model) renderSessionPage() string {
 	b.WriteString(lipgloss.NewStyle().PaddingLeft(2).Render(m.viewport.View()))
 	b.WriteString("\n")
 
-	// 输入框
-	leftBorder := lipgloss.NewStyle().Foreground(theme.primary).Render("┃ ")
-	var inputContent string
-	if m.pending {
-		inputContent = m.spinner.View() + " "
-		if len(m.messageQueue) > 0 {
-			inputContent += fmt.Sprintf("(%d queued) ", len(m.messageQueue))
-		}
-	} else {
-		inputContent = m.textarea.View()
-	}
-	b.WriteString("  " + leftBorder + inputContent + "\n")
+	// 输入框：运行中仍可输入，消息进入队列
+	b.WriteString(m.renderInput("  "))
 	bottomBorder := lipgloss.NewStyle().Foreground(theme.primary).Render("╹")
 	b.WriteString("  " + bottomBorder + "\n")
 
@@ -434,13 +502,73 @@ func (m *Model) renderSessionPage() string {
 	return b.String()
 }
 
+// renderInput 渲染输入框：附件行 + 每行带左边竖线的多行输入，运行中在首行前显示 spinner
+func (m *Model) renderInput(indent string) string {
+	theme := getTheme()
+	leftBorder := lipgloss.NewStyle().Foreground(theme.primary).Render("┃ ")
+	var b strings.Builder
+	if line := m.attachmentLine(); line != "" {
+		b.WriteString(indent + leftBorder + lipgloss.NewStyle().Foreground(theme.textMuted).Render(line) + "\n")
+	}
+	for i, line := range strings.Split(m.textarea.View(), "\n") {
+		if i == 0 && m.pending {
+			status := m.spinner.View() + " "
+			if len(m.messageQueue) > 0 {
+				status += fmt.Sprintf("(%d queued) ", len(m.messageQueue))
+			}
+			line = status + line
+		}
+		b.WriteString(indent + leftBorder + line + "\n")
+	}
+	return b.String()
+}
+
+// inputRows 输入区域占用的行数
+func (m *Model) inputRows() int {
+	rows := m.textarea.Height()
+	if m.attachmentLine() != "" {
+		rows++
+	}
+	return rows
+}
+
+// attachmentLine 待发送附件的提示
+func (m *Model) attachmentLine() string {
+	if len(m.attachments) == 0 {
+		return ""
+	}
+	names := make([]string, len(m.attachments))
+	for i, a := range m.attachments {
+		names[i] = "[image: " + a.Name + "]"
+	}
+	return strings.Join(names, " ") + "  (/image clear to remove)"
+}
+
+// recallHistory 光标在首行按 ↑ 调出上一条输入，在末行按 ↓ 调出下一条；返回是否处理了按键
+func (m *Model) recallHistory(up bool) bool {
+	var text string
+	var ok bool
+	switch {
+	case up && m.textarea.Line() == 0:
+		text, ok = m.inputHist.prev(m.textarea.Value())
+	case !up && m.inputHist.browsing() && m.textarea.Line() == m.textarea.LineCount()-1:
+		text, ok = m.inputHist.next()
+	}
+	if !ok {
+		return false
+	}
+	m.textarea.SetValue(text)
+	m.resizeInput()
+	return true
+}
+
 // renderSessionHeader 渲染 session header
 func (m *Model) renderSessionHeader() string {
 	theme := getTheme()
 
 	// 左侧：标题
 	title := lipgloss.NewStyle().Bold(true).Foreground(theme.text).Render(
-		"# " + lastSegment(m.currentSession))
+		"# " + m.sessionName())
 
 	// 右侧：token 信息
 	tokenInfo := ""
@@ -493,9 +621,12 @@ func (m *Model) renderSessionFooter() string {
 		leftParts = append(leftParts, active)
 
 		escHint := "esc "
-		if m.interrupt > 0 {
+		switch {
+		case m.run != nil && m.run.cancelled:
+			escHint = lipgloss.NewStyle().Foreground(theme.warning).Render("cancelling...")
+		case m.interrupt > 0:
 			escHint += lipgloss.NewStyle().Foreground(theme.primary).Render("again to interrupt")
-		} else {
+		default:
 			escHint += lipgloss.NewStyle().Foreground(theme.textMuted).Render("interrupt")
 		}
 		leftParts = append(leftParts, escHint)
@@ -503,7 +634,7 @@ func (m *Model) renderSessionFooter() string {
 	left := strings.Join(leftParts, " ")
 
 	// 右侧
-	hints := lipgloss.NewStyle().Foreground(theme.textMuted).Render("tab agents  ctrl+p commands")
+	hints := lipgloss.NewStyle().Foreground(theme.textMuted).Render("ctrl+o tool output  tab agents  ctrl+p commands")
 
 	gap := m.width - lipgloss.Width(left) - lipgloss.Width(hints) - 4
 	if gap < 1 {
@@ -545,7 +676,7 @@ func (m *Model) updateViewport() {
 		case "assistant":
 			// Assistant 标签
 			label := lipgloss.NewStyle().Foreground(theme.textMuted).Render(
-				"▶ Sisyphus (Ultraworker) - " + m.cfg.Agent.Model)
+				"▶ Sisyphus (Ultraworker) - " + m.model())
 			b.WriteString(label + "\n")
 			content := lipgloss.NewStyle().Foreground(theme.text).Width(m.viewport.Width - 2).Render(line.Content)
 			b.WriteString(content)
@@ -553,6 +684,12 @@ func (m *Model) updateViewport() {
 				b.WriteString("\n\n")
 			}
 
+		case "tool":
+			b.WriteString(line.Tool.render(m.viewport.Width-2, m.expandTools))
+			if i < len(m.lines)-1 {
+				b.WriteString("\n\n")
+			}
+
 		case "system":
 			content := lipgloss.NewStyle().Foreground(theme.textMuted).Italic(true).Render(line.Content)
 			b.WriteString(content)
@@ -569,10 +706,14 @@ func (m *Model) updateViewport() {
 func (m *Model) loadCurrentSession() {
 	m.history = nil
 	m.lines = nil
+	m.sessionLabel = ""
+	m.sessionModel = ""
 	sess, err := session.Load(m.currentSession)
 	if err != nil {
 		return
 	}
+	m.sessionLabel = sess.Label
+	m.sessionModel = sess.ModelOverride
 	for _, msg := range sess.Messages() {
 		ts := time.Now()
 		if msg.Timestamp > 0 {
@@ -609,7 +750,55 @@ func (m *Model) persistCurrentSession() {
 			Timestamp: time.Now().UnixMilli(),
 		})
 	}
-	_ = session.SaveFromHistory(m.currentSession, "tui", m.opts.Agent, m.cfg.Agent.Model, history)
+	_ = session.SaveFromHistory(m.currentSession, "tui", m.opts.Agent, m.model(), history)
+}
+
+// model 当前会话使用的模型：/model 设置的会话模型优先，否则为默认模型
+func (m *Model) model() string {
+	if m.sessionModel != "" {
+		return m.sessionModel
+	}
+	return m.cfg.Agent.Model
+}
+
+// sessionName 当前会话的显示名
+func (m *Model) sessionName() string {
+	if m.sessionLabel != "" {
+		return m.sessionLabel
+	}
+	return lastSegment(m.currentSession)
+}
+
+// sendQueued 发送排队中的下一条消息，队列为空时返回 nil
+func (m *Model) sendQueued() tea.Cmd {
+	if len(m.messageQueue) == 0 {
+		return nil
+	}
+	next := m.messageQueue[0]
+	m.messageQueue = m.messageQueue[1:]
+	m.appendLine("user", next.Display)
+	m.history = append(m.history, agent.ChatMessage{Role: "user", Content: next.Content})
+	m.persistCurrentSession()
+	m.updateViewport()
+	return m.startRun(next.Images)
+}
+
+// startRun 在后台运行 agent 处理当前历史（images 随最后一条用户消息发送），返回读取进度事件的命令
+func (m *Model) startRun(images [][]byte) tea.Cmd {
+	m.pending = true
+	m.run = startRun(m.runner, m.currentSession, m.sessionModel, cloneHistory(m.history), images)
+	return tea.Batch(m.run.wait(), m.spinner.Tick)
+}
+
+// cancelRun 取消进行中的运行；运行在收到取消后返回，结果仍经 assistantMsg 送回
+func (m *Model) cancelRun() {
+	if m.run == nil || m.run.cancelled {
+		return
+	}
+	m.run.cancelled = true
+	m.run.cancel()
+	m.appendLine("system", "Cancelling current run...")
+	m.updateViewport()
 }
 
 func (m *Model) startNewSession() {
@@ -617,6 +806,8 @@ func (m *Model) startNewSession() {
 	m.lines = nil
 	newKey := buildSessionKey(m.opts.Agent, fmt.Sprintf("session-%d", time.Now().Unix()%100000))
 	m.currentSession = newKey
+	m.sessionLabel = ""
+	m.sessionModel = ""
 	_ = session.SetCurrent(newKey)
 	m.page = pageHome
 }
@@ -628,27 +819,57 @@ func loadBootCmd(opts Options) tea.Cmd {
 	}
 }
 
-func sendMessageCmd(runner *agent.Runner, sessionKey string, history []agent.ChatMessage) tea.Cmd {
-	return func() tea.Msg {
-		start := time.Now()
-		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+// activeRun 后台进行中的 agent 运行：events 依次送出 progressMsg 和最终的 assistantMsg，随后关闭
+type activeRun struct {
+	events    chan tea.Msg
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// startRun 在后台运行 agent；model 为会话模型覆盖，为空时使用默认模型（含预算路由）
+func startRun(runner *agent.Runner, sessionKey, model string, history []agent.ChatMessage, images [][]byte) *activeRun {
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	run := &activeRun{events: make(chan tea.Msg, 64), cancel: cancel}
+	go func() {
+		defer close(run.events)
 		defer cancel()
+		start := time.Now()
+		ctx, span := tracing.Start(ctx, "tui.chat", "session", sessionKey)
 		req := &agent.RunRequest{
 			SessionKey: sessionKey,
 			Channel:    "tui",
 			Message:    history[len(history)-1].Content,
 			History:    history,
+			Images:     images,
+			Model:      model,
+			OnProgress: func(ev agent.ProgressEvent) { run.events <- progressMsg{Event: ev} },
 		}
 		resp, err := runner.Run(ctx, req)
+		span.RecordError(err)
+		span.End()
 		if err != nil {
-			return assistantMsg{Err: err, Duration: time.Since(start)}
+			run.events <- assistantMsg{Err: err, Duration: time.Since(start), TraceID: span.TraceID()}
+			return
 		}
-		return assistantMsg{
+		run.events <- assistantMsg{
 			Reply:        resp.Reply,
 			Duration:     time.Since(start),
 			InputTokens:  resp.TokensUsed.InputTokens,
 			OutputTokens: resp.TokensUsed.OutputTokens,
+			TraceID:      span.TraceID(),
+		}
+	}()
+	return run
+}
+
+// wait 等待运行的下一条消息
+func (r *activeRun) wait() tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-r.events
+		if !ok {
+			return nil
 		}
+		return msg
 	}
 }
 
@@ -660,7 +881,7 @@ func loadSessionIndex(agentID string) ([]sessionEntry, error) {
 		for _, s := range local {
 			e := sessionEntry{
 				Key:       s.Key,
-				Label:     s.Key,
+				Label:     s.DisplayName(),
 				Channel:   s.Channel,
 				UpdatedAt: s.LastActivityAt,
 				Model:     s.Model,
@@ -741,8 +962,10 @@ func Run() error {
 
 // RunWithOptions 使用选项启动 TUI
 func RunWithOptions(opts Options) error {
+	m := NewModel(opts)
+	defer m.runner.Close()
 	p := tea.NewProgram(
-		NewModel(opts),
+		m,
 		tea.WithAltScreen(),
 		tea.WithMouseCellMotion(),
 	)